@@ -0,0 +1,47 @@
+package muz
+
+import (
+	"os"
+	"os/user"
+	"runtime/debug"
+)
+
+// Audit identifies who and what applied the migrations, recorded with every applied file
+// by drivers implementing AuditStore, like PostgresDriver and PgxDriver.
+type Audit struct {
+	// AppliedBy is the identity of the deployer, like the OS user or a CI job.
+	//  - Default: "", the database user is recorded.
+	AppliedBy string `cfg:"applied_by" json:"applied_by,omitempty"`
+	// Hostname of the machine running the migrations.
+	Hostname string `cfg:"hostname" json:"hostname,omitempty"`
+	// AppVersion is the version of the application shipping the migrations.
+	AppVersion string `cfg:"app_version" json:"app_version,omitempty"`
+	// GitSHA is the commit the migrations were built from.
+	GitSHA string `cfg:"git_sha" json:"git_sha,omitempty"`
+}
+
+// DefaultAudit returns the OS user, the hostname, and the version and VCS revision of the main
+// module from debug.ReadBuildInfo. Values that cannot be read are left empty.
+func DefaultAudit() Audit {
+	var a Audit
+
+	if u, err := user.Current(); err == nil {
+		a.AppliedBy = u.Username
+	}
+
+	a.Hostname, _ = os.Hostname()
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v := info.Main.Version; v != "(devel)" {
+			a.AppVersion = v
+		}
+
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				a.GitSHA = s.Value
+			}
+		}
+	}
+
+	return a
+}
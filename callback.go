@@ -0,0 +1,109 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Callback files in the migration root, run at the lifecycle points of Migrate.
+const (
+	// CallbackBeforeMigrate runs after Start, before the first migration.
+	CallbackBeforeMigrate = "before_migrate"
+	// CallbackAfterMigrate runs after the last migration, before End.
+	CallbackAfterMigrate = "after_migrate"
+	// CallbackAfterError runs after End of a failed run, outside of its transaction.
+	CallbackAfterError = "after_error"
+)
+
+var callbackNames = []string{CallbackBeforeMigrate, CallbackAfterMigrate, CallbackAfterError}
+
+// isCallback reports whether a file name like "before_migrate.sql" is a callback file.
+func isCallback(name string) bool {
+	name = trimEncoding(name)
+	name = strings.TrimSuffix(name, path.Ext(name))
+
+	for _, c := range callbackNames {
+		if strings.EqualFold(name, c) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// callbacks reads the callback files of the migration root by callback name.
+// Custom sources have no callbacks.
+func (m Migrate) callbacks() (map[string][]byte, error) {
+	if m.Source != nil {
+		return nil, nil
+	}
+
+	fileSystem, closeFS, err := m.openFS(m.rootPath())
+	if err != nil {
+		return nil, err
+	}
+	defer closeFS()
+
+	entries, err := fs.ReadDir(fileSystem, ".")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	root := NewMuzo(".", nil, fileSystem)
+	if m.substitutes() {
+		root.vars = m.placeholders()
+	}
+
+	var found map[string][]byte
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !isCallback(name) || m.shouldSkip(name) {
+			continue
+		}
+
+		if !m.matchesExtension(".", name) {
+			continue
+		}
+
+		content, err := root.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if found == nil {
+			found = make(map[string][]byte)
+		}
+
+		base := trimEncoding(name)
+		found[strings.ToLower(strings.TrimSuffix(base, path.Ext(base)))] = content
+	}
+
+	return found, nil
+}
+
+// runCallback executes a callback file when it exists.
+func runCallback(ctx context.Context, driver Driver, callbacks map[string][]byte, name string) error {
+	content, ok := callbacks[name]
+	if !ok {
+		return nil
+	}
+
+	exec, ok := driver.(Executor)
+	if !ok {
+		return fmt.Errorf("callback %s: %w", name, ErrNotSupported)
+	}
+
+	if err := exec.Exec(ctx, content); err != nil {
+		return fmt.Errorf("callback %s: %w", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// execDriver records the callback scripts and processed files in order.
+type execDriver struct {
+	recordDriver
+
+	calls   []string
+	failDir string
+}
+
+func (d *execDriver) Exec(_ context.Context, content []byte) error {
+	d.calls = append(d.calls, string(content))
+	return nil
+}
+
+func (d *execDriver) Process(ctx context.Context, data *Muzo) error {
+	if data.Dir == d.failDir {
+		return errors.New("boom")
+	}
+
+	for _, file := range data.Files {
+		d.calls = append(d.calls, data.Dir+"/"+file.Path)
+	}
+
+	return d.recordDriver.Process(ctx, data)
+}
+
+func TestMigrateCallbacks(t *testing.T) {
+	files := map[string]string{
+		"migrations/before_migrate.sql": "before",
+		"migrations/after_migrate.sql":  "after",
+		"migrations/after_error.sql":    "error",
+		"migrations/app/001_users.sql":  "CREATE TABLE users();",
+	}
+
+	tests := []struct {
+		name    string
+		failDir string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "success",
+			want: []string{"before", "app/001_users.sql", "after"},
+		},
+		{
+			name:    "failure",
+			failDir: "app",
+			want:    []string{"before", "error"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Migrate{FS: MapFS(files)}
+
+			driver := &execDriver{failDir: tt.failDir}
+			_, err := m.Migrate(context.Background(), driver)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Migrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !slices.Equal(driver.calls, tt.want) {
+				t.Errorf("calls = %v, want %v", driver.calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrateCallbacksNotSupported(t *testing.T) {
+	m := Migrate{FS: MapFS(map[string]string{
+		"migrations/before_migrate.sql": "before",
+		"migrations/001_users.sql":      "CREATE TABLE users();",
+	})}
+
+	if _, err := m.Migrate(context.Background(), &recordDriver{}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Migrate() error = %v, want ErrNotSupported", err)
+	}
+}
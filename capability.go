@@ -0,0 +1,83 @@
+package muz
+
+import "context"
+
+// Optional driver capabilities, detected by type assertion. Beside these,
+// StatusReporter lists applied migrations and Recorder edits the tracking table.
+
+// Locker is implemented by drivers that serialize concurrent runs, like several
+// replicas starting at the same time. Lock is called before Start and Unlock after End.
+type Locker interface {
+	Lock(ctx context.Context) error
+	Unlock(ctx context.Context) error
+}
+
+// ForceUnlocker is implemented by Lockers whose lock outlives a crashed process, like TableLock.
+// ForceUnlock removes the lock of any owner.
+type ForceUnlocker interface {
+	ForceUnlock(ctx context.Context) error
+}
+
+// Rollbacker is implemented by drivers that can roll back migrations.
+// Rollback executes the rollback file of every entry of data.Files, in the given order,
+// and removes the tracking record of its version. It is called between Start and End.
+type Rollbacker interface {
+	Rollback(ctx context.Context, data *Muzo) error
+}
+
+// ChecksumStore is implemented by drivers that keep the checksum of applied files.
+// StoreChecksum is called after a file was applied, within the same session.
+type ChecksumStore interface {
+	StoreChecksum(ctx context.Context, dir string, version int64, checksum string) error
+}
+
+// AuditStore is implemented by drivers that keep the Audit of applied files.
+// StoreAudit is called with Migrate.Audit after a file was applied, within the same session.
+type AuditStore interface {
+	StoreAudit(ctx context.Context, dir string, version int64, audit Audit) error
+}
+
+// Cloner is implemented by drivers that can run several sessions at the same time, like a driver
+// on a connection pool. Clone returns a driver with the settings of the receiver and a session of its own,
+// used by Migrate.Parallel. Clones are not locked, the receiver holds the lock for all of them.
+type Cloner interface {
+	Clone() (Driver, error)
+}
+
+// Executor is implemented by drivers that can run a script outside of the migration files,
+// used for the callback files. Between Start and End it runs in the session's transaction.
+type Executor interface {
+	Exec(ctx context.Context, content []byte) error
+}
+
+// RowQuerier is implemented by drivers that can read any table of their database, used by ImportHistory
+// to read the state tables of other migration tools. row is called for every row with its Scan function.
+// Between Start and End it reads in the session's transaction.
+type RowQuerier interface {
+	QueryRows(ctx context.Context, query string, row func(scan func(dest ...any) error) error) error
+}
+
+// storeChecksum saves the checksum of an applied file when the driver is a ChecksumStore.
+func storeChecksum(ctx context.Context, driver Driver, info *Muzo, file FileInfo) error {
+	store, ok := driver.(ChecksumStore)
+	if !ok {
+		return nil
+	}
+
+	sum, err := info.checksum(file.Path)
+	if err != nil {
+		return err
+	}
+
+	return store.StoreChecksum(ctx, info.Dir, file.Version, sum)
+}
+
+// storeAudit saves the audit of an applied file when it is set and the driver is an AuditStore.
+func storeAudit(ctx context.Context, driver Driver, dir string, file FileInfo, audit Audit) error {
+	store, ok := driver.(AuditStore)
+	if !ok || audit == (Audit{}) {
+		return nil
+	}
+
+	return store.StoreAudit(ctx, dir, file.Version, audit)
+}
@@ -0,0 +1,171 @@
+package muz
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrSyntax is returned by SyntaxChecker for content the database rejects when parsing it.
+var ErrSyntax = errors.New("syntax error")
+
+// SyntaxChecker is implemented by drivers that can have the database parse a migration without running it,
+// like PostgresDriver preparing its statements.
+// CheckSyntax returns ErrSyntax for content the database rejects, other errors are failures of the check.
+type SyntaxChecker interface {
+	CheckSyntax(ctx context.Context, content []byte) error
+}
+
+// SyntaxError is a pending file rejected by the database.
+type SyntaxError struct {
+	Dir     string `json:"dir"`
+	File    string `json:"file"`
+	Version int64  `json:"version"`
+	Error   string `json:"error"`
+}
+
+// CheckReport is the result of Check.
+type CheckReport struct {
+	Validation *ValidationReport `json:"validation"`
+	// Drift compares the tracking table with the files, nil for drivers without StatusReporter.
+	Drift *DriftReport `json:"drift,omitempty"`
+	// Dirty are the failed migrations of drivers implementing DirtyStore.
+	Dirty []DirtyRecord `json:"dirty,omitempty"`
+	// Syntax are the pending files rejected by drivers implementing SyntaxChecker.
+	Syntax []SyntaxError `json:"syntax,omitempty"`
+}
+
+// OK reports whether the next run can apply the pending files: the tree has no validation error,
+// no applied file was edited or deleted, no directory is dirty and every pending file parses.
+func (r *CheckReport) OK() bool {
+	if r.Validation.HasErrors() || len(r.Dirty) > 0 || len(r.Syntax) > 0 {
+		return false
+	}
+
+	return r.Drift == nil || r.Drift.Count(DriftModified)+r.Drift.Count(DriftMissing) == 0
+}
+
+// Check is a read-only verification for CI: it validates the tree, compares the applied history with the files,
+// reads the dirty state and has the database parse the pending files where the driver supports it.
+// Neither Start nor Lock are called and no migration runs.
+func (m Migrate) Check(ctx context.Context, driver Driver) (*CheckReport, error) {
+	validation, err := m.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CheckReport{Validation: validation}
+
+	type key struct {
+		dir     string
+		version int64
+	}
+
+	// pending files by directory and version, nil checks every file
+	var pending map[key]bool
+
+	if _, ok := driver.(StatusReporter); ok {
+		report.Drift, err = m.Verify(ctx, driver)
+		if err != nil {
+			return nil, err
+		}
+
+		pending = make(map[key]bool)
+		for _, d := range report.Drift.Drifts {
+			if d.Kind == DriftPending {
+				pending[key{d.Dir, d.Version}] = true
+			}
+		}
+	}
+
+	if store, ok := driver.(DirtyStore); ok {
+		report.Dirty, err = store.Dirty(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	checker, ok := driver.(SyntaxChecker)
+	if !ok {
+		return report, nil
+	}
+
+	for info, err := range m.Iter() {
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range info.Files {
+			if (pending != nil && !pending[key{info.Dir, file.Version}]) || info.GoMigration(file.Path) != nil {
+				continue
+			}
+
+			content, err := info.ReadFile(file.Path)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := checker.CheckSyntax(ctx, content); err != nil {
+				if !errors.Is(err, ErrSyntax) {
+					return nil, err
+				}
+
+				report.Syntax = append(report.Syntax, SyntaxError{Dir: info.Dir, File: file.Path, Version: file.Version, Error: err.Error()})
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// //////////////////////////////
+
+// parseStatement has the database parse statement without running it. Queries are prepared
+// as the body of a PREPARE statement, which is parsed but not analyzed.
+func parseStatement(ctx context.Context, tx *sql.Tx, statement string) error {
+	if preparable(statement) {
+		statement = "PREPARE muz_check AS " + statement
+	}
+
+	stmt, err := tx.PrepareContext(ctx, statement)
+	if err != nil {
+		return syntaxError(err)
+	}
+
+	return stmt.Close()
+}
+
+// preparable reports whether statement is a query PREPARE takes, like SELECT or INSERT.
+func preparable(statement string) bool {
+	words := strings.Fields(strings.ToUpper(strings.TrimLeft(stripComments(statement), " \t\r\n(")))
+	if len(words) == 0 {
+		return false
+	}
+
+	switch words[0] {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "MERGE", "VALUES", "WITH", "TABLE":
+		return true
+	}
+
+	return false
+}
+
+// syntaxError returns err as an ErrSyntax when the database rejected the statement: syntax errors,
+// and for the statements it analyzes when parsing, like CREATE TABLE ... AS, unknown objects and invalid values.
+// Other errors, like a lost connection, are failures of the check.
+func syntaxError(err error) error {
+	var state interface{ SQLState() string }
+	if !errors.As(err, &state) {
+		return err
+	}
+
+	// Syntax errors and access rule violations, data exceptions and unsupported features.
+	code := state.SQLState()
+	if strings.HasPrefix(code, "42") || strings.HasPrefix(code, "22") || strings.HasPrefix(code, "0A") {
+		return fmt.Errorf("%w: %w", ErrSyntax, err)
+	}
+
+	return err
+}
@@ -0,0 +1,118 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// syntaxDriver rejects the contents in invalid like the database would.
+type syntaxDriver struct {
+	recordDriver
+
+	invalid map[string]bool
+	checked []string
+}
+
+func (d *syntaxDriver) CheckSyntax(_ context.Context, content []byte) error {
+	d.checked = append(d.checked, string(content))
+	if d.invalid[string(content)] {
+		return fmt.Errorf("%w: at or near %q", ErrSyntax, content)
+	}
+
+	return nil
+}
+
+func TestMigrateCheck(t *testing.T) {
+	m := Migrate{FS: MapFS(map[string]string{
+		"migrations/app/001_users.sql":  "CREATE TABLE users();",
+		"migrations/app/002_orders.sql": "CREATE TABLE orders();",
+		"migrations/app/003_items.sql":  "CREATE TABLE items(;",
+	})}
+
+	driver := &syntaxDriver{
+		recordDriver: recordDriver{records: []Record{{Version: 1, Directory: "app", FileName: "001_users.sql"}}},
+		invalid:      map[string]bool{"CREATE TABLE items(;": true},
+	}
+
+	report, err := m.Check(context.Background(), driver)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	// Only the pending files are parsed, nothing is recorded.
+	if len(driver.checked) != 2 || len(driver.records) != 1 || driver.endErr != nil {
+		t.Errorf("checked %q with records %+v, want the two pending files and no change", driver.checked, driver.records)
+	}
+
+	if len(report.Syntax) != 1 || report.Syntax[0].File != "003_items.sql" || report.OK() {
+		t.Errorf("Check() syntax = %+v, OK = %v, want 003_items.sql rejected", report.Syntax, report.OK())
+	}
+
+	delete(driver.invalid, "CREATE TABLE items(;")
+	driver.checked = nil
+
+	report, err = m.Check(context.Background(), driver)
+	if err != nil || !report.OK() {
+		t.Errorf("Check() = %+v, %v, want OK", report, err)
+	}
+}
+
+func TestMigrateCheckHistory(t *testing.T) {
+	m := Migrate{FS: MapFS(map[string]string{"migrations/app/001_users.sql": "CREATE TABLE users();"})}
+
+	// A recorded file deleted since.
+	driver := &recordDriver{records: []Record{{Version: 2, Directory: "app", FileName: "002_orders.sql"}}}
+
+	report, err := m.Check(context.Background(), driver)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	if report.OK() || report.Drift.Count(DriftMissing) != 1 {
+		t.Errorf("Check() drift = %+v, want the missing file to fail the check", report.Drift)
+	}
+}
+
+func TestPreparable(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      bool
+	}{
+		{statement: "SELECT 1", want: true},
+		{statement: "-- seed\ninsert into users values (1)", want: true},
+		{statement: "(SELECT 1) UNION (SELECT 2)", want: true},
+		{statement: "WITH a AS (SELECT 1) DELETE FROM users", want: true},
+		{statement: "CREATE TABLE users (id int)"},
+		{statement: "COMMIT"},
+	}
+
+	for _, tt := range tests {
+		if got := preparable(tt.statement); got != tt.want {
+			t.Errorf("preparable(%q) = %v, want %v", tt.statement, got, tt.want)
+		}
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	refused := errors.New("connection refused")
+
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{err: stateError("42601"), want: ErrSyntax},
+		{err: stateError("42P01"), want: ErrSyntax},
+		{err: stateError("25006"), want: stateError("25006")},
+		{err: refused, want: refused},
+		{err: nil, want: nil},
+	}
+
+	for _, tt := range tests {
+		got := syntaxError(tt.err)
+		if (tt.want == nil) != (got == nil) || (tt.want != nil && !errors.Is(got, tt.want)) {
+			t.Errorf("syntaxError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package muz
+
+import (
+	"errors"
+	"fmt"
+	"path"
+)
+
+// ErrChecksumMismatch is returned by ChecksumError for an applied file edited after it ran.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ChecksumPolicy is the policy for applied files whose checksum differs from the one
+// stored when they were applied, the driver must implement StatusReporter.
+// Records without a checksum, like the ones of drivers not implementing ChecksumStore, are not verified.
+type ChecksumPolicy string
+
+const (
+	// ChecksumIgnore does not verify checksums.
+	ChecksumIgnore ChecksumPolicy = ""
+	// ChecksumError fails the run with ErrChecksumMismatch before pending files of the directory run.
+	ChecksumError ChecksumPolicy = "error"
+	// ChecksumWarn reports edited files as OutcomeModified.
+	ChecksumWarn ChecksumPolicy = "warn"
+)
+
+func (c ChecksumPolicy) valid() bool {
+	switch c {
+	case ChecksumIgnore, ChecksumError, ChecksumWarn:
+		return true
+	}
+
+	return false
+}
+
+// verifyChecksum compares the checksum of an applied file with the recorded one,
+// returning ErrChecksumMismatch or setting OutcomeModified depending on the policy.
+func (m Migrate) verifyChecksum(info *Muzo, file FileInfo, recorded string, fr *FileResult) error {
+	if m.Checksum == ChecksumIgnore || recorded == "" {
+		return nil
+	}
+
+	sum, err := info.checksum(file.Path)
+	if err != nil || sum == recorded {
+		return err
+	}
+
+	if m.Checksum == ChecksumError {
+		return fmt.Errorf("%w: %s was edited after it was applied", ErrChecksumMismatch, path.Join(info.Dir, file.Path))
+	}
+
+	fr.Outcome = OutcomeModified
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMigrateChecksum(t *testing.T) {
+	files := MapFS(map[string]string{
+		"migrations/app/001_users.sql": "CREATE TABLE users (id int, name text);",
+		"migrations/app/002_posts.sql": "CREATE TABLE posts();",
+	})
+
+	tests := []struct {
+		name     string
+		policy   ChecksumPolicy
+		recorded string
+		want     []Outcome
+		wantErr  error
+	}{
+		{
+			name:     "ignore",
+			recorded: checksum([]byte("CREATE TABLE users (id int);")),
+			want:     []Outcome{OutcomeSkipped, OutcomeApplied},
+		},
+		{
+			name:     "unchanged",
+			policy:   ChecksumError,
+			recorded: checksum([]byte("CREATE TABLE users (id int, name text);")),
+			want:     []Outcome{OutcomeSkipped, OutcomeApplied},
+		},
+		{
+			name:     "error",
+			policy:   ChecksumError,
+			recorded: checksum([]byte("CREATE TABLE users (id int);")),
+			want:     []Outcome{},
+			wantErr:  ErrChecksumMismatch,
+		},
+		{
+			name:     "warn",
+			policy:   ChecksumWarn,
+			recorded: checksum([]byte("CREATE TABLE users (id int);")),
+			want:     []Outcome{OutcomeModified, OutcomeApplied},
+		},
+		{
+			name:   "record without checksum",
+			policy: ChecksumError,
+			want:   []Outcome{OutcomeSkipped, OutcomeApplied},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver := &recordDriver{records: []Record{{Version: 1, Directory: "app", FileName: "001_users.sql", Checksum: tt.recorded}}}
+
+			result, err := Migrate{FS: files, Checksum: tt.policy}.Migrate(context.Background(), driver)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Migrate() error = %v, want %v", err, tt.wantErr)
+			}
+
+			got := []Outcome{}
+			for _, f := range result.Files {
+				got = append(got, f.Outcome)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("outcomes = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("outcomes = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	if _, err := (Migrate{FS: files, Checksum: ChecksumWarn}).Migrate(context.Background(), nopDriver{}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Migrate() without StatusReporter error = %v, want ErrNotSupported", err)
+	}
+}
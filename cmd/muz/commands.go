@@ -0,0 +1,680 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/rakunlabs/muz"
+)
+
+func runUp(ctx context.Context, args []string, stdout io.Writer) error {
+	fs, o := newFlagSet("up")
+	dir := fs.String("dir", "", "only migrate this directory, used with -to")
+	to := fs.Int64("to", 0, "apply the migrations of -dir up to this version")
+	steps := fs.Int("steps", 0, "apply at most this many pending migrations")
+	output := fs.String("output", "text", "output format of the summary: text, markdown or json")
+	dryRun := fs.Bool("dry-run", false, "print the SQL script instead of executing it, postgres only")
+	if err := o.parse(fs, args); err != nil {
+		return err
+	}
+
+	if (*dir == "") != (*to == 0) {
+		return errors.New("-dir and -to must be given together")
+	}
+
+	if *steps < 0 || (*steps > 0 && *dir != "") {
+		return errors.New("-steps must be positive and cannot be combined with -dir")
+	}
+
+	// -to rolls back the migrations of -dir above the version.
+	if *dir != "" && !*dryRun {
+		if err := o.confirm(fmt.Sprintf("migrate %s to version %d", *dir, *to)); err != nil {
+			return err
+		}
+	}
+
+	driver, err := o.driver(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDriver(driver)
+
+	if *dryRun {
+		pg, ok := driver.(*muz.PostgresDriver)
+		if !ok {
+			return errors.New("-dry-run needs a postgres dsn")
+		}
+
+		pg.DryRun = stdout
+	}
+
+	var result *muz.Result
+	switch {
+	case *dir != "":
+		result, err = o.migrate().MigrateTo(ctx, driver, *dir, *to)
+	case *steps > 0:
+		result, err = o.migrate().Up(ctx, driver, *steps)
+	default:
+		result, err = o.migrate().Migrate(ctx, driver)
+	}
+
+	if *dryRun {
+		return err
+	}
+
+	return errors.Join(err, renderResult(stdout, *output, result))
+}
+
+// renderResult writes the files touched by a run and its duration.
+func renderResult(stdout io.Writer, output string, result *muz.Result) error {
+	if result == nil {
+		return nil
+	}
+
+	return renderTable(stdout, output, result)
+}
+
+func runDown(ctx context.Context, args []string, stdout io.Writer) error {
+	fs, o := newFlagSet("down")
+	steps := fs.Int("steps", 1, "number of migrations to roll back")
+	output := fs.String("output", "text", "output format of the summary: text, markdown or json")
+	if err := o.parse(fs, args); err != nil {
+		return err
+	}
+
+	if *steps < 1 {
+		return errors.New("-steps must be at least 1")
+	}
+
+	if err := o.confirm(fmt.Sprintf("roll back %d migrations", *steps)); err != nil {
+		return err
+	}
+
+	driver, err := o.driver(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDriver(driver)
+
+	result, err := o.migrate().Down(ctx, driver, *steps)
+
+	return errors.Join(err, renderResult(stdout, *output, result))
+}
+
+func runStatus(ctx context.Context, args []string, stdout io.Writer) error {
+	fs, o := newFlagSet("status")
+	output := fs.String("output", "text", "output format: text, markdown or json")
+	if err := o.parse(fs, args); err != nil {
+		return err
+	}
+
+	driver, err := o.driver(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDriver(driver)
+
+	status, err := o.migrate().Status(ctx, driver)
+	if err != nil {
+		return err
+	}
+
+	return renderTable(stdout, *output, status)
+}
+
+func runVerify(ctx context.Context, args []string, stdout io.Writer) error {
+	fs, o := newFlagSet("verify")
+	output := fs.String("output", "text", "output format: text or json")
+	allowPending := fs.Bool("allow-pending", false, "do not fail on pending files")
+	if err := o.parse(fs, args); err != nil {
+		return err
+	}
+
+	driver, err := o.driver(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDriver(driver)
+
+	report, err := o.migrate().Verify(ctx, driver)
+	if err != nil {
+		return err
+	}
+
+	err = render(stdout, *output, report, func(w io.Writer) {
+		fmt.Fprintln(w, "KIND\tDIRECTORY\tVERSION\tFILE")
+		for _, d := range report.Drifts {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", d.Kind, d.Dir, d.Version, d.File)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	drifts := len(report.Drifts)
+	if *allowPending {
+		drifts -= report.Count(muz.DriftPending)
+	}
+
+	if drifts > 0 {
+		return fmt.Errorf("verify: %d drifts found", drifts)
+	}
+
+	return nil
+}
+
+func runCheck(ctx context.Context, args []string, stdout io.Writer) error {
+	fs, o := newFlagSet("check")
+	output := fs.String("output", "text", "output format: text or json")
+	if err := o.parse(fs, args); err != nil {
+		return err
+	}
+
+	driver, err := o.driver(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDriver(driver)
+
+	report, err := o.migrate().Check(ctx, driver)
+	if err != nil {
+		return err
+	}
+
+	err = render(stdout, *output, report, func(w io.Writer) {
+		fmt.Fprintln(w, "CHECK\tDIRECTORY\tFILE\tMESSAGE")
+		for _, i := range report.Validation.Issues {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", i.Severity, i.Dir, i.File, i.Message)
+		}
+		if report.Drift != nil {
+			for _, d := range report.Drift.Drifts {
+				if d.Kind == muz.DriftModified || d.Kind == muz.DriftMissing {
+					fmt.Fprintf(w, "%s\t%s\t%s\tversion %d\n", d.Kind, d.Dir, d.File, d.Version)
+				}
+			}
+		}
+		for _, r := range report.Dirty {
+			fmt.Fprintf(w, "dirty\t%s\t%s\t%s\n", r.Directory, r.FileName, r.Error)
+		}
+		for _, e := range report.Syntax {
+			fmt.Fprintf(w, "syntax\t%s\t%s\t%s\n", e.Dir, e.File, e.Error)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if !report.OK() {
+		return errors.New("check: the next run would fail")
+	}
+
+	return nil
+}
+
+func runFailures(ctx context.Context, args []string, stdout io.Writer) error {
+	fs, o := newFlagSet("failures")
+	output := fs.String("output", "text", "output format: text or json")
+	if err := o.parse(fs, args); err != nil {
+		return err
+	}
+
+	driver, err := o.driver(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDriver(driver)
+
+	store, ok := driver.(muz.FailureStore)
+	if !ok {
+		return fmt.Errorf("failures: %w", muz.ErrNotSupported)
+	}
+
+	failures, err := store.Failures(ctx)
+	if err != nil {
+		return err
+	}
+
+	if failures == nil {
+		failures = []muz.Failure{}
+	}
+
+	return render(stdout, *output, failures, func(w io.Writer) {
+		fmt.Fprintln(w, "FAILED AT\tDIRECTORY\tVERSION\tFILE\tDURATION\tERROR")
+		for _, f := range failures {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n", f.FailedAt.Local().Format("2006-01-02 15:04:05"), f.Directory, f.Version, f.FileName, f.Duration, f.Error)
+		}
+	})
+}
+
+func runHistory(ctx context.Context, args []string, stdout io.Writer) error {
+	fs, o := newFlagSet("history")
+	format := fs.String("format", "json", "export format: json or csv")
+	if err := o.parse(fs, args); err != nil {
+		return err
+	}
+
+	driver, err := o.driver(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDriver(driver)
+
+	return o.migrate().ExportHistory(ctx, driver, stdout, muz.ExportFormat(*format))
+}
+
+func runPlan(ctx context.Context, args []string, stdout io.Writer) error {
+	fs, o := newFlagSet("plan")
+	output := fs.String("output", "text", "output format: text, markdown or json")
+	withSQL := fs.Bool("sql", false, "print the SQL of the pending migrations instead of a table")
+	if err := o.parse(fs, args); err != nil {
+		return err
+	}
+
+	driver, err := o.driver(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDriver(driver)
+
+	if *withSQL {
+		return o.migrate().WritePlan(ctx, driver, stdout)
+	}
+
+	plan, err := o.migrate().Plan(ctx, driver)
+	if err != nil {
+		return err
+	}
+
+	return renderTable(stdout, *output, plan)
+}
+
+func runForce(ctx context.Context, args []string, stdout io.Writer) error {
+	fs, o := newFlagSet("force")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: muz force [flags] <dir> <version>")
+		fs.PrintDefaults()
+	}
+
+	if err := o.parse(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("force: expected <dir> and <version>")
+	}
+
+	version, err := strconv.ParseInt(fs.Arg(1), 10, 64)
+	if err != nil || version < 0 {
+		return fmt.Errorf("force: invalid version %q", fs.Arg(1))
+	}
+
+	if err := o.confirm(fmt.Sprintf("force %s to version %d", fs.Arg(0), version)); err != nil {
+		return err
+	}
+
+	driver, err := o.driver(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDriver(driver)
+
+	if err := o.migrate().Force(ctx, driver, fs.Arg(0), version); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "%s forced to version %d\n", fs.Arg(0), version)
+
+	return nil
+}
+
+func runBaseline(ctx context.Context, args []string, stdout io.Writer) error {
+	fs, o := newFlagSet("baseline")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: muz baseline [flags] <version>")
+		fs.PrintDefaults()
+	}
+
+	if err := o.parse(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("baseline: expected <version>")
+	}
+
+	version, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || version <= 0 {
+		return fmt.Errorf("baseline: invalid version %q", fs.Arg(0))
+	}
+
+	if err := o.confirm(fmt.Sprintf("baseline to version %d", version)); err != nil {
+		return err
+	}
+
+	driver, err := o.driver(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDriver(driver)
+
+	if err := o.migrate().Baseline(ctx, driver, version); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "migrations up to version %d marked as applied\n", version)
+
+	return nil
+}
+
+func runMark(ctx context.Context, args []string, stdout io.Writer) error {
+	fs, o := newFlagSet("mark")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: muz mark [flags] <dir> <file>...")
+		fs.PrintDefaults()
+	}
+
+	if err := o.parse(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return errors.New("mark: expected <dir> and at least one <file>")
+	}
+
+	if err := o.confirm(fmt.Sprintf("mark %d files of %s as applied", fs.NArg()-1, fs.Arg(0))); err != nil {
+		return err
+	}
+
+	driver, err := o.driver(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDriver(driver)
+
+	if err := o.migrate().MarkApplied(ctx, driver, fs.Arg(0), fs.Args()[1:]...); err != nil {
+		return err
+	}
+
+	for _, f := range fs.Args()[1:] {
+		fmt.Fprintln(stdout, "marked", fs.Arg(0), f)
+	}
+
+	return nil
+}
+
+func runSquash(_ context.Context, args []string, stdout io.Writer) error {
+	fs, o := newFlagSet("squash")
+	name := fs.String("name", "squashed", "name of the squashed file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: muz squash [flags] <dir> <version>")
+		fs.PrintDefaults()
+	}
+
+	if err := o.parse(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("squash: expected <dir> and <version>")
+	}
+
+	version, err := strconv.ParseInt(fs.Arg(1), 10, 64)
+	if err != nil || version <= 0 {
+		return fmt.Errorf("squash: invalid version %q", fs.Arg(1))
+	}
+
+	result, err := muz.Squash(fs.Arg(0), version, muz.SquashConfig{
+		Path:      o.path,
+		Name:      *name,
+		Extension: o.extension,
+		Skip:      o.skip,
+		Table:     o.table,
+		Schema:    o.schema,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, f := range result.Removed {
+		fmt.Fprintln(stdout, "removed", f)
+	}
+	fmt.Fprintln(stdout, "created", result.File)
+	fmt.Fprintf(stdout, "\n-- run on databases already at version %d:\n%s", version, result.SQL)
+
+	return nil
+}
+
+func runUnlock(ctx context.Context, args []string, stdout io.Writer) error {
+	fs, o := newFlagSet("unlock")
+	if err := o.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := o.confirm("remove the migration lock"); err != nil {
+		return err
+	}
+
+	driver, err := o.driver(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDriver(driver)
+
+	unlocker, ok := driver.(muz.ForceUnlocker)
+	if !ok {
+		return fmt.Errorf("unlock: %w", muz.ErrNotSupported)
+	}
+
+	if err := unlocker.ForceUnlock(ctx); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, "lock removed")
+
+	return nil
+}
+
+func runRepair(ctx context.Context, args []string, stdout io.Writer) error {
+	fs, o := newFlagSet("repair")
+	if err := o.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := o.confirm("repair"); err != nil {
+		return err
+	}
+
+	driver, err := o.driver(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDriver(driver)
+
+	removed, err := o.migrate().Repair(ctx, driver)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range removed {
+		fmt.Fprintf(stdout, "removed record %s %d %s\n", f.Dir, f.Version, f.File)
+	}
+
+	if len(removed) == 0 {
+		fmt.Fprintln(stdout, "nothing to repair")
+	}
+
+	return nil
+}
+
+// render writes v as JSON or as a text table produced by text.
+func render(stdout io.Writer, output string, v any, text func(w io.Writer)) error {
+	switch output {
+	case "json":
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(v)
+	case "text":
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		text(w)
+
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q", output)
+	}
+}
+
+// renderTable writes a status, plan or result as JSON or as a table of muz.FormatStatus.
+func renderTable(stdout io.Writer, output string, v any) error {
+	if output == "json" {
+		return render(stdout, output, v, nil)
+	}
+
+	return muz.FormatStatus(v, stdout, muz.TableFormat(output))
+}
+
+func runCreate(_ context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	path := fs.String("path", "migrations", "migration root directory")
+	ext := fs.String("ext", ".sql", "extension of the created files")
+	digits := fs.Int("digits", 0, "zero padded width of the version, defaults to the latest file")
+	upTemplate := fs.String("up-template", "", "template `file` for the migration")
+	downTemplate := fs.String("down-template", "", "template `file` for the rollback stub")
+	noDown := fs.Bool("no-down", false, "do not create a rollback stub")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: muz create [flags] <dir> <name>")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("create: expected <dir> and <name>")
+	}
+
+	cfg := muz.GenerateConfig{
+		Path:      *path,
+		Extension: *ext,
+		Digits:    *digits,
+		NoDown:    *noDown,
+	}
+
+	var err error
+	if cfg.UpTemplate, err = readTemplate(*upTemplate); err != nil {
+		return err
+	}
+	if cfg.DownTemplate, err = readTemplate(*downTemplate); err != nil {
+		return err
+	}
+
+	files, err := muz.Generate(fs.Arg(0), fs.Arg(1), cfg)
+	for _, f := range files {
+		fmt.Fprintln(stdout, "created", f)
+	}
+
+	return err
+}
+
+func readTemplate(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(path)
+
+	return string(data), err
+}
+
+func runValidate(_ context.Context, args []string, stdout io.Writer) error {
+	fs, o := newFlagSet("validate")
+	output := fs.String("output", "text", "output format: text or json")
+	if err := o.parse(fs, args); err != nil {
+		return err
+	}
+
+	report, err := o.migrate().Validate()
+	if err != nil {
+		return err
+	}
+
+	err = render(stdout, *output, report, func(w io.Writer) {
+		fmt.Fprintln(w, "SEVERITY\tKIND\tDIRECTORY\tFILE\tMESSAGE")
+		for _, issue := range report.Issues {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", issue.Severity, issue.Kind, issue.Dir, issue.File, issue.Message)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// -strict also fails on the warnings left, like empty files.
+	if report.HasErrors() || (o.strict && len(report.Issues) > 0) {
+		return fmt.Errorf("validate: %d issues found", len(report.Issues))
+	}
+
+	return nil
+}
+
+func runManifest(_ context.Context, args []string, stdout io.Writer) error {
+	fs, o := newFlagSet("manifest")
+	if err := o.parse(fs, args); err != nil {
+		return err
+	}
+
+	// The manifest of a signed tree is printed without verifying the old signature.
+	m := o.migrate()
+	m.Signature = nil
+
+	content, err := m.Manifest()
+	if err != nil {
+		return err
+	}
+
+	_, err = stdout.Write(content)
+
+	return err
+}
+
+func runLock(_ context.Context, args []string, stdout io.Writer) error {
+	fs, o := newFlagSet("lock")
+	if err := o.parse(fs, args); err != nil {
+		return err
+	}
+
+	m := o.migrate()
+
+	root := withDefault(o.path, "migrations")
+	if o.path == "" && m.Compat == muz.CompatDbmate {
+		root = "db/migrations"
+	}
+
+	var lock bytes.Buffer
+	if err := m.WriteLock(&lock); err != nil {
+		return err
+	}
+
+	name := filepath.Join(root, "muz.lock")
+	if err := os.WriteFile(name, lock.Bytes(), 0o644); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, "wrote", name)
+
+	return nil
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// configFiles are looked up in the working directory when -config is not given.
+var configFiles = []string{"muz.yaml", "muz.yml", "muz.toml"}
+
+// settings are the values a config file can set, at top level or per environment.
+type settings struct {
+	DSN       string   `yaml:"dsn"       toml:"dsn"`
+	Path      string   `yaml:"path"      toml:"path"`
+	Table     string   `yaml:"table"     toml:"table"`
+	Schema    string   `yaml:"schema"    toml:"schema"`
+	Extension string   `yaml:"extension" toml:"extension"`
+	Order     []string `yaml:"order"     toml:"order"`
+	Skip      []string `yaml:"skip"      toml:"skip"`
+	// OutOfOrder is the muz.OutOfOrder policy.
+	OutOfOrder string `yaml:"out_of_order" toml:"out_of_order"`
+	// Checksum is the muz.ChecksumPolicy.
+	Checksum string `yaml:"checksum" toml:"checksum"`
+	// Match is the muz.Match of applied files.
+	Match string `yaml:"match" toml:"match"`
+	// Linear versions are one sequence across all directories.
+	Linear bool `yaml:"linear" toml:"linear"`
+	// IncludeUnnumbered applies files without a leading number after the numbered ones.
+	IncludeUnnumbered bool `yaml:"include_unnumbered" toml:"include_unnumbered"`
+	// Compat is the muz.Compat file naming.
+	Compat string `yaml:"compat" toml:"compat"`
+	// PublicKey is the PEM public key file verifying the signature of the migration tree.
+	PublicKey string `yaml:"public_key" toml:"public_key"`
+	// Lockfile refuses files missing from muz.lock or edited since it was written.
+	Lockfile bool `yaml:"lockfile" toml:"lockfile"`
+	// DecryptCommand decrypts .age and .enc files from stdin to stdout, see muz.CommandDecryptor.
+	DecryptCommand string `yaml:"decrypt_command" toml:"decrypt_command"`
+	// AutoNoTransaction runs files with statements like CREATE INDEX CONCURRENTLY outside of the transaction.
+	AutoNoTransaction bool `yaml:"auto_no_transaction" toml:"auto_no_transaction"`
+	// Strict turns on the safest policies, see muz.Migrate.Strict.
+	Strict bool `yaml:"strict" toml:"strict"`
+	// Vars are the values of ${NAME} placeholders in the migration files.
+	Vars map[string]string `yaml:"vars" toml:"vars"`
+	// DirConfig overrides the extension, skip patterns and transaction mode of single directories.
+	DirConfig map[string]dirSettings `yaml:"dir_config" toml:"dir_config"`
+	// SecretsDir is the directory of the files read by ${file:name} placeholders.
+	SecretsDir string `yaml:"secrets_dir" toml:"secrets_dir"`
+	// LockTTL serializes runs of database/sql backends like MySQL with a lock table.
+	LockTTL time.Duration `yaml:"lock_ttl" toml:"lock_ttl"`
+	// LockTimeout and StatementTimeout apply to the Postgres migration transactions.
+	LockTimeout      time.Duration `yaml:"lock_timeout"      toml:"lock_timeout"`
+	StatementTimeout time.Duration `yaml:"statement_timeout" toml:"statement_timeout"`
+	// Role is the Postgres role the migrations run as.
+	Role string `yaml:"role" toml:"role"`
+	// CheckPrivileges verifies the privileges of the Postgres role before starting.
+	CheckPrivileges bool `yaml:"check_privileges" toml:"check_privileges"`
+	// Retry is the number of retries of a run failing with a transient error.
+	Retry int `yaml:"retry" toml:"retry"`
+	// Wait is how long to wait for the database to be reachable.
+	Wait time.Duration `yaml:"wait" toml:"wait"`
+	// FileTimeout cancels a migration file running longer.
+	FileTimeout time.Duration `yaml:"file_timeout" toml:"file_timeout"`
+	// Parallel is the number of directories applied at the same time.
+	Parallel int `yaml:"parallel" toml:"parallel"`
+	// Webhook is notified when a run finishes, WebhookFormat is json, slack or teams.
+	Webhook       string `yaml:"webhook"        toml:"webhook"`
+	WebhookFormat string `yaml:"webhook_format" toml:"webhook_format"`
+	// AppliedBy, AppVersion and GitSHA are recorded with the applied files, see muz.Audit.
+	AppliedBy  string `yaml:"applied_by"  toml:"applied_by"`
+	AppVersion string `yaml:"app_version" toml:"app_version"`
+	GitSHA     string `yaml:"git_sha"     toml:"git_sha"`
+	// Protected environments refuse destructive commands without confirmation.
+	Protected bool `yaml:"protected" toml:"protected"`
+}
+
+// dirSettings are the muz.DirOptions of a directory.
+//
+//	dir_config:
+//	  seed:
+//	    extension: .csv
+//	    tx_mode: none
+//	    seed: true
+type dirSettings struct {
+	Extension string   `yaml:"extension" toml:"extension"`
+	Skip      []string `yaml:"skip"      toml:"skip"`
+	TxMode    string   `yaml:"tx_mode"   toml:"tx_mode"`
+	Seed      bool     `yaml:"seed"      toml:"seed"`
+}
+
+// config is the content of a muz.yaml or muz.toml file.
+//
+//	path: migrations
+//	dsn: ${DATABASE_URL}
+//	environments:
+//	  prod:
+//	    dsn: postgres://${PROD_USER}:${PROD_PASS}@db/app
+//	    protected: true
+type config struct {
+	settings `yaml:",inline"`
+
+	Environments map[string]settings `yaml:"environments" toml:"environments"`
+}
+
+// loadConfig reads the config file at path, or the first default file found
+// when path is empty. A missing default file is not an error.
+func loadConfig(path string) (*config, error) {
+	explicit := path != ""
+
+	if !explicit {
+		for _, name := range configFiles {
+			if _, err := os.Stat(name); err == nil {
+				path = name
+				break
+			}
+		}
+
+		if path == "" {
+			return nil, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	cfg := &config{}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	default:
+		return nil, fmt.Errorf("config %s: unknown format, use .yaml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// resolve returns the settings of the named environment on top of the top level ones.
+// Environment variables like ${DATABASE_URL} are expanded.
+func (c *config) resolve(env string) (settings, error) {
+	s := c.settings
+
+	if env != "" {
+		e, ok := c.Environments[env]
+		if !ok {
+			return settings{}, fmt.Errorf("config: unknown environment %q", env)
+		}
+
+		s = merge(e, s)
+	}
+
+	s.DSN = os.ExpandEnv(s.DSN)
+	s.Path = os.ExpandEnv(s.Path)
+	s.Table = os.ExpandEnv(s.Table)
+	s.Webhook = os.ExpandEnv(s.Webhook)
+	s.AppliedBy = os.ExpandEnv(s.AppliedBy)
+	s.AppVersion = os.ExpandEnv(s.AppVersion)
+	s.GitSHA = os.ExpandEnv(s.GitSHA)
+
+	return s, nil
+}
+
+// merge returns s with its empty values taken from base.
+func merge(s, base settings) settings {
+	s.DSN = withDefault(s.DSN, base.DSN)
+	s.Path = withDefault(s.Path, base.Path)
+	s.Table = withDefault(s.Table, base.Table)
+	s.Schema = withDefault(s.Schema, base.Schema)
+	s.Role = withDefault(s.Role, base.Role)
+	s.Extension = withDefault(s.Extension, base.Extension)
+	s.SecretsDir = withDefault(s.SecretsDir, base.SecretsDir)
+	s.OutOfOrder = withDefault(s.OutOfOrder, base.OutOfOrder)
+	s.Checksum = withDefault(s.Checksum, base.Checksum)
+	s.Match = withDefault(s.Match, base.Match)
+	s.Compat = withDefault(s.Compat, base.Compat)
+	s.PublicKey = withDefault(s.PublicKey, base.PublicKey)
+	s.DecryptCommand = withDefault(s.DecryptCommand, base.DecryptCommand)
+	s.Webhook = withDefault(s.Webhook, base.Webhook)
+	s.AppliedBy = withDefault(s.AppliedBy, base.AppliedBy)
+	s.AppVersion = withDefault(s.AppVersion, base.AppVersion)
+	s.GitSHA = withDefault(s.GitSHA, base.GitSHA)
+	s.WebhookFormat = withDefault(s.WebhookFormat, base.WebhookFormat)
+	s.Linear = s.Linear || base.Linear
+	s.IncludeUnnumbered = s.IncludeUnnumbered || base.IncludeUnnumbered
+	s.AutoNoTransaction = s.AutoNoTransaction || base.AutoNoTransaction
+	s.Strict = s.Strict || base.Strict
+	s.Lockfile = s.Lockfile || base.Lockfile
+	s.CheckPrivileges = s.CheckPrivileges || base.CheckPrivileges
+	s.Protected = s.Protected || base.Protected
+	if s.LockTTL == 0 {
+		s.LockTTL = base.LockTTL
+	}
+	if s.LockTimeout == 0 {
+		s.LockTimeout = base.LockTimeout
+	}
+	if s.StatementTimeout == 0 {
+		s.StatementTimeout = base.StatementTimeout
+	}
+	if s.Retry == 0 {
+		s.Retry = base.Retry
+	}
+	if s.Wait == 0 {
+		s.Wait = base.Wait
+	}
+	if s.FileTimeout == 0 {
+		s.FileTimeout = base.FileTimeout
+	}
+	if s.Parallel == 0 {
+		s.Parallel = base.Parallel
+	}
+
+	if len(s.Order) == 0 {
+		s.Order = slices.Clone(base.Order)
+	}
+	if len(s.Skip) == 0 {
+		s.Skip = slices.Clone(base.Skip)
+	}
+	for name, value := range base.Vars {
+		if _, ok := s.Vars[name]; !ok {
+			if s.Vars == nil {
+				s.Vars = make(map[string]string)
+			}
+
+			s.Vars[name] = value
+		}
+	}
+	for dir, d := range base.DirConfig {
+		if _, ok := s.DirConfig[dir]; !ok {
+			if s.DirConfig == nil {
+				s.DirConfig = make(map[string]dirSettings)
+			}
+
+			s.DirConfig[dir] = d
+		}
+	}
+
+	return s
+}
+
+func withDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+
+	return v
+}
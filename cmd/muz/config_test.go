@@ -0,0 +1,157 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/rakunlabs/muz"
+)
+
+func TestOptionsConfig(t *testing.T) {
+	t.Setenv("MUZ_DSN", "")
+	t.Setenv("MUZ_CONFIG", "")
+	t.Setenv("MUZ_ENV", "")
+	t.Setenv("PROD_PASS", "secret")
+
+	yamlConfig := `
+path: migrations
+order: [schema, data]
+dsn: postgres://localhost/dev
+environments:
+  prod:
+    dsn: postgres://app:${PROD_PASS}@db/app
+    table: schema_migrations
+`
+
+	tomlConfig := `
+path = "migrations"
+order = ["schema", "data"]
+dsn = "postgres://localhost/dev"
+
+[environments.prod]
+dsn = "postgres://app:${PROD_PASS}@db/app"
+table = "schema_migrations"
+`
+
+	tests := []struct {
+		name      string
+		file      string
+		content   string
+		args      []string
+		want      options
+		wantError bool
+	}{
+		{
+			name:    "yaml top level",
+			file:    "muz.yaml",
+			content: yamlConfig,
+			want:    options{dsn: "postgres://localhost/dev", path: "migrations", order: stringList{"schema", "data"}},
+		},
+		{
+			name:    "yaml environment",
+			file:    "muz.yaml",
+			content: yamlConfig,
+			args:    []string{"-env", "prod"},
+			want:    options{env: "prod", dsn: "postgres://app:secret@db/app", path: "migrations", table: "schema_migrations", order: stringList{"schema", "data"}},
+		},
+		{
+			name:    "toml environment",
+			file:    "muz.toml",
+			content: tomlConfig,
+			args:    []string{"-env", "prod"},
+			want:    options{env: "prod", dsn: "postgres://app:secret@db/app", path: "migrations", table: "schema_migrations", order: stringList{"schema", "data"}},
+		},
+		{
+			name:    "flags win",
+			file:    "muz.yaml",
+			content: yamlConfig,
+			args:    []string{"-path", "other", "-order", "data"},
+			want:    options{dsn: "postgres://localhost/dev", path: "other", order: stringList{"data"}},
+		},
+		{
+			name:      "unknown environment",
+			file:      "muz.yaml",
+			content:   yamlConfig,
+			args:      []string{"-env", "staging"},
+			wantError: true,
+		},
+		{
+			name:      "environment without config",
+			args:      []string{"-env", "prod"},
+			wantError: true,
+		},
+		{
+			name: "no config",
+			want: options{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Chdir(dir)
+
+			if tt.file != "" {
+				if err := os.WriteFile(filepath.Join(dir, tt.file), []byte(tt.content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			fs, o := newFlagSet("test")
+			err := o.parse(fs, tt.args)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("parse() error = %v, wantError %v", err, tt.wantError)
+			}
+
+			if tt.wantError {
+				return
+			}
+
+			if o.env != tt.want.env || o.dsn != tt.want.dsn || o.path != tt.want.path || o.table != tt.want.table ||
+				!slices.Equal(o.order, tt.want.order) || !slices.Equal(o.skip, tt.want.skip) {
+				t.Errorf("parse() = %+v, want %+v", *o, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptionsDirConfig(t *testing.T) {
+	t.Setenv("MUZ_CONFIG", "")
+	t.Setenv("MUZ_ENV", "")
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	content := `
+dir_config:
+  seed:
+    extension: .csv
+    skip: ["*_draft.csv"]
+    seed: true
+environments:
+  prod:
+    dir_config:
+      indexes:
+        tx_mode: none
+`
+	if err := os.WriteFile(filepath.Join(dir, "muz.yaml"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, o := newFlagSet("test")
+	if err := o.parse(fs, []string{"-env", "prod"}); err != nil {
+		t.Fatalf("parse() error = %v", err)
+	}
+
+	m := o.migrate()
+
+	if got := m.DirConfig["seed"]; got.Extension != ".csv" || !slices.Equal(got.Skip, []string{"*_draft.csv"}) || !got.Seed {
+		t.Errorf("seed options = %+v, want a seed directory with the .csv extension and the draft skip pattern", got)
+	}
+
+	if got := m.DirConfig["indexes"]; got.TxMode != muz.TxModeNone {
+		t.Errorf("indexes options = %+v, want TxModeNone", got)
+	}
+}
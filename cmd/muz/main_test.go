@@ -0,0 +1,123 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestStringList(t *testing.T) {
+	var l stringList
+	l.Set("schema, data")
+	l.Set("seed")
+
+	want := []string{"schema", "data", "seed"}
+	if !slices.Equal(l, want) {
+		t.Errorf("got %v, want %v", l, want)
+	}
+}
+
+func TestVarMap(t *testing.T) {
+	var v varMap
+	if err := v.Set("OWNER=app"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Set("DSN=postgres://a?b=c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Set("missing"); err == nil {
+		t.Error("expected an error without =")
+	}
+
+	if got, want := v.String(), "DSN=postgres://a?b=c,OWNER=app"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "no command", args: nil},
+		{name: "unknown command", args: []string{"sideways"}},
+		{name: "missing dsn", args: []string{"up", "-dsn", ""}},
+		{name: "to without dir", args: []string{"up", "-to", "3"}},
+		{name: "negative steps", args: []string{"up", "-steps", "-1"}},
+		{name: "invalid steps", args: []string{"down", "-steps", "0"}},
+		{name: "force without version", args: []string{"force", "schema"}},
+		{name: "force invalid version", args: []string{"force", "schema", "x"}},
+		{name: "baseline without version", args: []string{"baseline"}},
+		{name: "mark without file", args: []string{"mark", "schema"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := run(t.Context(), tt.args, io.Discard); err == nil {
+				t.Errorf("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        options
+		interactive bool
+		input       string
+		wantError   bool
+	}{
+		{name: "yes flag", opts: options{yes: true, protected: true}},
+		{name: "not interactive", opts: options{}},
+		{name: "not interactive protected", opts: options{env: "prod", protected: true}, wantError: true},
+		{name: "answer y", opts: options{}, interactive: true, input: "y\n"},
+		{name: "answer no", opts: options{}, interactive: true, input: "n\n", wantError: true},
+		{name: "protected environment name", opts: options{env: "prod", protected: true}, interactive: true, input: "prod\n"},
+		{name: "protected y", opts: options{env: "prod", protected: true}, interactive: true, input: "y\n", wantError: true},
+	}
+
+	defer func(r io.Reader, f func() bool) { stdin, interactive = r, f }(stdin, interactive)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdin = strings.NewReader(tt.input)
+			interactive = func() bool { return tt.interactive }
+
+			if err := tt.opts.confirm("force"); (err != nil) != tt.wantError {
+				t.Errorf("confirm() error = %v, wantError %v", err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestRunUpToProtected(t *testing.T) {
+	t.Setenv("MUZ_CONFIG", "")
+	t.Setenv("MUZ_ENV", "")
+	t.Setenv("MUZ_DSN", "")
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	content := `
+environments:
+  prod:
+    dsn: postgres://localhost/app
+    protected: true
+`
+	if err := os.WriteFile(filepath.Join(dir, "muz.yaml"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(f func() bool) { interactive = f }(interactive)
+	interactive = func() bool { return false }
+
+	// -to may roll back migrations, it is refused before connecting like down.
+	err := run(t.Context(), []string{"up", "-env", "prod", "-dir", "schema", "-to", "1"}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "protected") {
+		t.Errorf("run() error = %v, want the protected refusal", err)
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rakunlabs/muz"
+)
+
+// options are the flags shared by all commands.
+type options struct {
+	config     string
+	env        string
+	dsn        string
+	path       string
+	table      string
+	schema     string
+	extension  string
+	order      stringList
+	skip       stringList
+	tags       stringList
+	exclude    stringList
+	outOfOrder string
+	checksum   string
+	match      string
+	linear     bool
+	unnumbered bool
+	compat     string
+	publicKey  string
+	lockfile   bool
+	decryptCmd string
+	checkPriv  bool
+	autoNoTx   bool
+	strict     bool
+	maxDepth   int
+	vars       varMap
+	dirConfig  map[string]muz.DirOptions
+	reseed     bool
+	secretsDir string
+	lockTTL    time.Duration
+	lockTO     time.Duration
+	stmtTO     time.Duration
+	role       string
+	retry      int
+	wait       time.Duration
+	fileTO     time.Duration
+	parallel   int
+	webhook    string
+	appliedBy  string
+	appVersion string
+	gitSHA     string
+	webhookFmt string
+	minVersion int64
+	maxVersion int64
+	yes        bool
+	protected  bool
+}
+
+func newFlagSet(name string) (*flag.FlagSet, *options) {
+	o := &options{}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&o.config, "config", os.Getenv("MUZ_CONFIG"), "config `file`, defaults to $MUZ_CONFIG or muz.yaml, muz.yml, muz.toml if present")
+	fs.StringVar(&o.env, "env", os.Getenv("MUZ_ENV"), "environment of the config file, defaults to $MUZ_ENV")
+	fs.StringVar(&o.dsn, "dsn", os.Getenv("MUZ_DSN"), "database `url`, defaults to $MUZ_DSN")
+	fs.StringVar(&o.path, "path", "", "migration directory or .zip file (default \"migrations\")")
+	fs.StringVar(&o.table, "table", "", "migration tracking table")
+	fs.StringVar(&o.schema, "schema", "", "schema of the tracking table, postgres only")
+	fs.StringVar(&o.extension, "ext", "", "only consider files with this extension, like .sql")
+	fs.Var(&o.order, "order", "directories applied first, comma separated or repeated")
+	fs.Var(&o.skip, "skip", "skip patterns, comma separated or repeated")
+	fs.Var(&o.tags, "tags", "also run files with these tags, comma separated or repeated")
+	fs.Var(&o.exclude, "exclude-tags", "never run files with these tags, comma separated or repeated")
+	fs.StringVar(&o.outOfOrder, "out-of-order", "", "policy for files older than the applied version: error, warn or apply (default skip)")
+	fs.StringVar(&o.checksum, "checksum", "", "policy for applied files edited since they ran: error or warn (default not verified)")
+	fs.StringVar(&o.match, "match", "", "applied files are matched by file_name or checksum (default version)")
+	fs.BoolVar(&o.linear, "linear", false, "versions are one sequence across all directories")
+	fs.BoolVar(&o.unnumbered, "include-unnumbered", false, "apply files without a leading number after the numbered ones")
+	fs.StringVar(&o.compat, "compat", "", "file naming of another tool: golang-migrate or dbmate (default muz naming)")
+	fs.StringVar(&o.publicKey, "public-key", "", "PEM public key `file` verifying the muz.sig signature of the migration tree, like cosign.pub")
+	fs.BoolVar(&o.lockfile, "lockfile", false, "refuse files missing from muz.lock or edited since it was written by \"muz lock\"")
+	fs.StringVar(&o.decryptCmd, "decrypt-command", "", "`command` decrypting .age and .enc files from stdin to stdout, like \"age --decrypt -i key.txt\"")
+	fs.BoolVar(&o.strict, "strict", false, "safest policies: checksum and out of order errors, validation warnings fail the run")
+	fs.BoolVar(&o.autoNoTx, "auto-no-transaction", false, "run files with statements like CREATE INDEX CONCURRENTLY outside of the transaction")
+	fs.BoolVar(&o.reseed, "seed", false, "run every file of the seed directories, not only the ones changed since they last ran")
+	fs.IntVar(&o.maxDepth, "max-depth", 0, "directory levels below -path to walk (default no limit)")
+	fs.Int64Var(&o.minVersion, "min-version", 0, "ignore files below this version")
+	fs.Int64Var(&o.maxVersion, "max-version", 0, "ignore files above this version")
+	fs.Var(&o.vars, "var", "value of a ${NAME} placeholder as NAME=value, repeated")
+	fs.StringVar(&o.secretsDir, "secrets-dir", "", "directory of the files read by ${file:name} placeholders, like /run/secrets")
+	fs.DurationVar(&o.lockTTL, "lock-ttl", 0, "serialize runs of mysql and sqlite with a lock table, taken over when stale for this long")
+	fs.DurationVar(&o.lockTO, "lock-timeout", 0, "lock_timeout of the migration transactions, postgres only")
+	fs.DurationVar(&o.stmtTO, "statement-timeout", 0, "statement_timeout of the migration transactions, postgres only")
+	fs.BoolVar(&o.checkPriv, "check-privileges", false, "verify the role can create objects and owns the tracking table before starting, postgres only")
+	fs.StringVar(&o.role, "role", "", "role the migrations run as with SET ROLE, postgres only")
+	fs.DurationVar(&o.wait, "wait", 0, "wait up to this long for the database to be reachable, like in an init container")
+	fs.DurationVar(&o.fileTO, "file-timeout", 0, "cancel a migration file running longer than this")
+	fs.IntVar(&o.parallel, "parallel", 0, "directories applied at the same time, the ones of -order first (default one after another)")
+	fs.StringVar(&o.webhook, "webhook", "", "`url` notified when a run finishes, like a Slack incoming webhook")
+	fs.StringVar(&o.webhookFmt, "webhook-format", "", "payload of -webhook: json, slack or teams (default json)")
+	fs.IntVar(&o.retry, "retry", 0, "retries of a run failing with a transient error, like a deadlock or a dropped connection")
+	fs.StringVar(&o.appliedBy, "applied-by", "", "identity recorded with the applied files (default the OS user)")
+	fs.BoolVar(&o.yes, "yes", false, "do not ask for confirmation of destructive commands")
+
+	return fs, o
+}
+
+// parse parses the flags and fills the options not given on the command line from the config file.
+func (o *options) parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(o.config)
+	if err != nil {
+		return err
+	}
+
+	if cfg == nil {
+		if o.env != "" {
+			return fmt.Errorf("environment %q given without a config file", o.env)
+		}
+
+		return nil
+	}
+
+	s, err := cfg.resolve(o.env)
+	if err != nil {
+		return err
+	}
+
+	o.dsn = withDefault(o.dsn, s.DSN)
+	o.path = withDefault(o.path, s.Path)
+	o.table = withDefault(o.table, s.Table)
+	o.schema = withDefault(o.schema, s.Schema)
+	o.role = withDefault(o.role, s.Role)
+	o.extension = withDefault(o.extension, s.Extension)
+	o.secretsDir = withDefault(o.secretsDir, s.SecretsDir)
+	o.outOfOrder = withDefault(o.outOfOrder, s.OutOfOrder)
+	o.checksum = withDefault(o.checksum, s.Checksum)
+	o.match = withDefault(o.match, s.Match)
+	o.compat = withDefault(o.compat, s.Compat)
+	o.publicKey = withDefault(o.publicKey, s.PublicKey)
+	o.decryptCmd = withDefault(o.decryptCmd, s.DecryptCommand)
+	o.webhook = withDefault(o.webhook, s.Webhook)
+	o.appliedBy = withDefault(o.appliedBy, s.AppliedBy)
+	o.appVersion = withDefault(o.appVersion, s.AppVersion)
+	o.gitSHA = withDefault(o.gitSHA, s.GitSHA)
+	o.webhookFmt = withDefault(o.webhookFmt, s.WebhookFormat)
+	o.linear = o.linear || s.Linear
+	o.unnumbered = o.unnumbered || s.IncludeUnnumbered
+	o.autoNoTx = o.autoNoTx || s.AutoNoTransaction
+	o.strict = o.strict || s.Strict
+	o.lockfile = o.lockfile || s.Lockfile
+	o.checkPriv = o.checkPriv || s.CheckPrivileges
+	if o.lockTTL == 0 {
+		o.lockTTL = s.LockTTL
+	}
+	if o.lockTO == 0 {
+		o.lockTO = s.LockTimeout
+	}
+	if o.stmtTO == 0 {
+		o.stmtTO = s.StatementTimeout
+	}
+	if o.retry == 0 {
+		o.retry = s.Retry
+	}
+	if o.wait == 0 {
+		o.wait = s.Wait
+	}
+	if o.fileTO == 0 {
+		o.fileTO = s.FileTimeout
+	}
+	if o.parallel == 0 {
+		o.parallel = s.Parallel
+	}
+
+	if len(o.order) == 0 {
+		o.order = s.Order
+	}
+	for name, value := range s.Vars {
+		if _, ok := o.vars[name]; !ok {
+			o.vars.set(name, value)
+		}
+	}
+	if len(o.skip) == 0 {
+		o.skip = s.Skip
+	}
+	for dir, d := range s.DirConfig {
+		if o.dirConfig == nil {
+			o.dirConfig = make(map[string]muz.DirOptions)
+		}
+
+		o.dirConfig[dir] = muz.DirOptions{Extension: d.Extension, Skip: d.Skip, TxMode: muz.TxMode(d.TxMode), Seed: d.Seed}
+	}
+
+	o.protected = s.Protected
+
+	return nil
+}
+
+var (
+	// stdin is read for confirmations.
+	stdin io.Reader = os.Stdin
+	// interactive reports whether confirmations can be asked.
+	interactive = func() bool {
+		fi, err := os.Stdin.Stat()
+		return err == nil && fi.Mode()&os.ModeCharDevice != 0
+	}
+)
+
+// confirm asks before a destructive action, -yes skips the question.
+// Without a terminal the action runs, unless the environment is protected.
+// Protected environments ask for the environment name instead of "y".
+func (o *options) confirm(action string) error {
+	if o.yes {
+		return nil
+	}
+
+	target := withDefault(o.env, "the database")
+
+	if !interactive() {
+		if o.protected {
+			return fmt.Errorf("%s refused: %s is protected, pass -yes to confirm", action, target)
+		}
+
+		return nil
+	}
+
+	answer := "y"
+	question := fmt.Sprintf("%s on %s? [y/N] ", action, target)
+	if o.protected {
+		answer = withDefault(o.env, "yes")
+		question = fmt.Sprintf("%s is protected, type %q to %s: ", target, answer, action)
+	}
+
+	fmt.Fprint(os.Stderr, question)
+
+	line, _ := bufio.NewReader(stdin).ReadString('\n')
+	if strings.TrimSpace(line) != answer {
+		return fmt.Errorf("%s aborted", action)
+	}
+
+	return nil
+}
+
+func (o *options) migrate() muz.Migrate {
+	m := muz.Migrate{
+		Path:              o.path,
+		Order:             o.order,
+		Skip:              o.skip,
+		Extension:         o.extension,
+		DirConfig:         o.dirConfig,
+		Reseed:            o.reseed,
+		IncludeUnnumbered: o.unnumbered,
+		Compat:            muz.Compat(o.compat),
+		AutoNoTransaction: o.autoNoTx,
+		Strict:            o.strict,
+		Lockfile:          o.lockfile,
+		MaxDepth:          o.maxDepth,
+		OutOfOrder:        muz.OutOfOrder(o.outOfOrder),
+		Checksum:          muz.ChecksumPolicy(o.checksum),
+		Match:             muz.Match(o.match),
+		Linear:            o.linear,
+		Tags:              o.tags,
+		ExcludeTags:       o.exclude,
+		MinVersion:        o.minVersion,
+		MaxVersion:        o.maxVersion,
+		Vars:              o.vars,
+		FileTimeout:       o.fileTO,
+		Parallel:          o.parallel,
+		Logger:            logger,
+	}
+
+	// The deployer is recorded with the applied files, the config file names the release.
+	m.Audit = muz.DefaultAudit()
+	m.Audit.AppliedBy = withDefault(o.appliedBy, m.Audit.AppliedBy)
+	m.Audit.AppVersion = withDefault(o.appVersion, m.Audit.AppVersion)
+	m.Audit.GitSHA = withDefault(o.gitSHA, m.Audit.GitSHA)
+
+	// The key is read when the tree is verified, so commands fail with the error of the run.
+	if o.publicKey != "" {
+		m.Signature = muz.SignatureVerifierFunc(func(manifest, signature []byte) error {
+			key, err := os.ReadFile(o.publicKey)
+			if err != nil {
+				return err
+			}
+
+			return muz.KeyVerifier{PublicKey: key}.VerifySignature(manifest, signature)
+		})
+	}
+
+	if o.decryptCmd != "" {
+		m.Decryptor = muz.CommandDecryptor{Command: strings.Fields(o.decryptCmd)}
+	}
+
+	if o.retry > 0 {
+		m.Retry = muz.Retry{MaxAttempts: o.retry + 1}
+	}
+
+	if o.webhook != "" {
+		webhook := muz.Webhook{URL: o.webhook, Format: muz.WebhookFormat(o.webhookFmt), Name: o.env, Logger: logger}
+		m.Hooks.OnEvent = webhook.OnEvent
+	}
+
+	// Placeholders are only replaced when asked for, ${env:NAME} and ${file:name} are then available.
+	if o.vars != nil || o.secretsDir != "" {
+		m.Secrets = map[string]muz.SecretResolver{"env": muz.EnvSecrets{}}
+		if o.secretsDir != "" {
+			m.Secrets["file"] = muz.FileSecrets{Dir: o.secretsDir}
+		}
+	}
+
+	return m
+}
+
+func (o *options) driver(ctx context.Context) (muz.Driver, error) {
+	if o.dsn == "" {
+		return nil, errors.New("missing -dsn or MUZ_DSN")
+	}
+
+	driver, err := muz.OpenDriver(o.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	switch d := driver.(type) {
+	case *muz.PostgresDriver:
+		d.Logger = logger
+		d.Schema = o.schema
+		d.LockTimeout = o.lockTO
+		d.StatementTimeout = o.stmtTO
+		d.Role = o.role
+		d.CheckPrivileges = o.checkPriv
+		if o.table != "" {
+			d.Table = o.table
+		}
+	case *muz.GenericSQLDriver:
+		d.Logger = logger
+		d.LockTTL = o.lockTTL
+		if o.table != "" {
+			d.Table = o.table
+		}
+	}
+
+	if w, ok := driver.(muz.Waiter); ok && o.wait > 0 {
+		if err := w.WaitForDB(ctx, o.wait); err != nil {
+			closeDriver(driver)
+			return nil, err
+		}
+	}
+
+	return driver, nil
+}
+
+// logger logs the progress of the commands to stderr.
+var logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+// closeDriver closes drivers owning a connection.
+func closeDriver(driver muz.Driver) {
+	if c, ok := driver.(interface{ Close() error }); ok {
+		c.Close()
+	}
+}
+
+// stringList is a flag accepting comma separated or repeated values.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for v := range strings.SplitSeq(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+
+	return nil
+}
+
+// varMap is a flag accepting repeated NAME=value placeholder values.
+type varMap map[string]string
+
+func (v *varMap) String() string {
+	pairs := make([]string, 0, len(*v))
+	for name, value := range *v {
+		pairs = append(pairs, name+"="+value)
+	}
+
+	slices.Sort(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+func (v *varMap) Set(value string) error {
+	name, val, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("%q is not NAME=value", value)
+	}
+
+	v.set(strings.TrimSpace(name), val)
+
+	return nil
+}
+
+func (v *varMap) set(name, value string) {
+	if *v == nil {
+		*v = make(varMap)
+	}
+
+	(*v)[name] = value
+}
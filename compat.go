@@ -0,0 +1,46 @@
+package muz
+
+import (
+	"path"
+	"strings"
+)
+
+// Compat is the file naming of another migration tool, so its trees run unchanged.
+type Compat string
+
+const (
+	// CompatNone is the muz naming, "NNN_name.sql" with the rollback file "NNN_name.down.sql".
+	CompatNone Compat = ""
+	// CompatGolangMigrate is the golang-migrate naming, "NNN_name.up.sql" with the rollback file "NNN_name.down.sql".
+	// Files without ".up" or ".down" are ignored like golang-migrate does.
+	CompatGolangMigrate Compat = "golang-migrate"
+	// CompatDbmate is the dbmate layout, "db/migrations" as the default migration path and files holding
+	// both directions in "-- migrate:up" and "-- migrate:down" blocks.
+	CompatDbmate Compat = "dbmate"
+)
+
+func (c Compat) valid() bool {
+	switch c {
+	case CompatNone, CompatGolangMigrate, CompatDbmate:
+		return true
+	}
+
+	return false
+}
+
+// isUpFile reports whether the file is a forward migration like "001_users.up.sql".
+func isUpFile(name string) bool {
+	name = strings.ToLower(trimEncoding(name))
+
+	return strings.HasSuffix(name, ".up") || strings.HasSuffix(strings.TrimSuffix(name, path.Ext(name)), ".up")
+}
+
+// forwardFile reports whether a numbered, non rollback file is a forward migration with the Compat naming.
+func (m *Migrate) forwardFile(name string) bool {
+	switch m.Compat {
+	case CompatGolangMigrate:
+		return isUpFile(name)
+	}
+
+	return true
+}
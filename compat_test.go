@@ -0,0 +1,56 @@
+package muz
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestCompatGolangMigrate(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/001_users.up.sql":    "CREATE TABLE users();",
+			"migrations/001_users.down.sql":  "DROP TABLE users;",
+			"migrations/002_orders.up.sql":   "CREATE TABLE orders();",
+			"migrations/002_orders.down.sql": "DROP TABLE orders;",
+			"migrations/003_notes.sql":       "-- not a golang-migrate file",
+		}),
+		Compat: CompatGolangMigrate,
+	}
+
+	var files []string
+	for info, err := range m.Iter() {
+		if err != nil {
+			t.Fatalf("Iter() error = %v", err)
+		}
+
+		for _, f := range info.Files {
+			files = append(files, f.Path)
+		}
+	}
+
+	if want := []string{"001_users.up.sql", "002_orders.up.sql"}; !slices.Equal(files, want) {
+		t.Errorf("files = %v, want %v", files, want)
+	}
+
+	driver := &rollbackDriver{}
+
+	if _, err := m.Migrate(context.Background(), driver); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	if _, err := m.Down(context.Background(), driver, 1); err != nil {
+		t.Fatalf("Down() error = %v", err)
+	}
+
+	if want := []string{"./002_orders.down.sql: DROP TABLE orders;"}; !slices.Equal(driver.executed, want) {
+		t.Errorf("Down() executed = %q, want %q", driver.executed, want)
+	}
+
+	m.Compat = "flyway"
+	for _, err := range m.Iter() {
+		if err == nil {
+			t.Error("Iter() with an unknown compat, error = nil")
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package muz
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// copyTarget matches the "COPY table (columns) FROM stdin" commands copyInserts can run.
+var copyTarget = regexp.MustCompile(`(?is)^\s*COPY\s+(.+?)\s+FROM\s+STDIN\s*$`)
+
+// pgxCopy returns the copy of execOptions streaming the inline data with the COPY protocol of conn.
+func pgxCopy(conn *pgconn.PgConn) func(ctx context.Context, command, data string) error {
+	return func(ctx context.Context, command, data string) error {
+		_, err := conn.CopyFrom(ctx, strings.NewReader(data), command)
+		return err
+	}
+}
+
+// copyInserts returns the copy of execOptions running the COPY statements as INSERT statements on q,
+// database/sql having no access to the COPY protocol.
+// Only the default text format is supported, options like "WITH (FORMAT csv)" need the PgxDriver.
+func copyInserts(q execer, batch int) func(ctx context.Context, command, data string) error {
+	return func(ctx context.Context, command, data string) error {
+		m := copyTarget.FindStringSubmatch(stripComments(command))
+		if m == nil {
+			return fmt.Errorf("%s: only the text format is supported without the PgxDriver: %w", firstLine(command), ErrNotSupported)
+		}
+
+		insert := "INSERT INTO " + m[1] + " VALUES "
+
+		var buf strings.Builder
+
+		flush := func() error {
+			if buf.Len() == 0 {
+				return nil
+			}
+
+			_, err := q.ExecContext(ctx, buf.String())
+			buf.Reset()
+
+			return err
+		}
+
+		for line := range strings.SplitSeq(strings.TrimSuffix(data, "\n"), "\n") {
+			if data == "" {
+				break
+			}
+
+			if buf.Len() == 0 {
+				buf.WriteString(insert)
+			} else {
+				buf.WriteString(", ")
+			}
+
+			buf.WriteString("(")
+			for i, field := range strings.Split(strings.TrimSuffix(line, "\r"), "\t") {
+				if i > 0 {
+					buf.WriteString(", ")
+				}
+
+				if field == `\N` {
+					buf.WriteString("NULL")
+					continue
+				}
+
+				buf.WriteString(quoteString(copyUnescape(field)))
+			}
+			buf.WriteString(")")
+
+			if batch > 0 && buf.Len() >= batch {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+
+		return flush()
+	}
+}
+
+// copyUnescape decodes the backslash sequences of a field of the COPY text format.
+func copyUnescape(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(field); i++ {
+		if field[i] != '\\' || i+1 == len(field) {
+			b.WriteByte(field[i])
+			continue
+		}
+
+		i++
+
+		switch c := field[i]; c {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case 'x':
+			j := i + 1
+			for j < len(field) && j < i+3 && isHexByte(field[j]) {
+				j++
+			}
+
+			if j == i+1 {
+				b.WriteByte(c)
+				continue
+			}
+
+			v, _ := strconv.ParseUint(field[i+1:j], 16, 8)
+			b.WriteByte(byte(v))
+			i = j - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i
+			for j < len(field) && j < i+3 && field[j] >= '0' && field[j] <= '7' {
+				j++
+			}
+
+			v, _ := strconv.ParseUint(field[i:j], 8, 8)
+			b.WriteByte(byte(v))
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+func isHexByte(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
@@ -0,0 +1,39 @@
+package muz
+
+import (
+	"slices"
+	"strings"
+)
+
+// Block markers of dbmate migration files, like "-- migrate:up".
+//   - The lines after "-- migrate:up" are the migration, the ones after "-- migrate:down" its rollback,
+//     read like the sections of goose files.
+//   - The "transaction:false" option of a marker is the same as DirectiveNoTransaction for its block.
+const (
+	dbmateUp            = "migrate:up"
+	dbmateDown          = "migrate:down"
+	dbmateNoTransaction = "transaction:false"
+)
+
+// dbmateMarker returns gooseUp or gooseDown for a block marker line like "-- migrate:up", "" for other lines,
+// and whether the block runs outside of a transaction.
+func dbmateMarker(line string) (string, bool) {
+	comment, ok := strings.CutPrefix(strings.TrimSpace(line), "--")
+	if !ok {
+		return "", false
+	}
+
+	fields := strings.Fields(comment)
+	if len(fields) == 0 {
+		return "", false
+	}
+
+	switch fields[0] {
+	case dbmateUp:
+		return gooseUp, slices.Contains(fields[1:], dbmateNoTransaction)
+	case dbmateDown:
+		return gooseDown, slices.Contains(fields[1:], dbmateNoTransaction)
+	}
+
+	return "", false
+}
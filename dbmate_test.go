@@ -0,0 +1,72 @@
+package muz
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCompatDbmate(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"db/migrations/20240101000000_users.sql": "-- migrate:up\nCREATE TABLE users (id int);\n\n-- migrate:down\nDROP TABLE users;\n",
+			"db/migrations/20240102000000_index.sql": "-- migrate:up transaction:false\nCREATE INDEX CONCURRENTLY users_id ON users (id);\n\n" +
+				"-- migrate:down transaction:false\nDROP INDEX CONCURRENTLY users_id;\n",
+		}),
+		Compat: CompatDbmate,
+	}
+
+	var info *Muzo
+	for dir, err := range m.Iter() {
+		if err != nil {
+			t.Fatalf("Iter() error = %v", err)
+		}
+
+		info = dir
+	}
+
+	if info == nil || len(info.Files) != 2 {
+		t.Fatalf("Iter() = %+v, want the files of db/migrations", info)
+	}
+
+	t.Run("up block", func(t *testing.T) {
+		content, err := info.ReadFile("20240101000000_users.sql")
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+
+		if strings.Contains(string(content), "DROP") || strings.Contains(string(content), "migrate:") {
+			t.Errorf("ReadFile() = %q, want only the up block", content)
+		}
+	})
+
+	t.Run("transaction false", func(t *testing.T) {
+		for _, f := range info.Files {
+			noTx, err := noTransaction(info, f)
+			if err != nil || noTx != (f.Version == 20240102000000) {
+				t.Errorf("noTransaction(%s) = %v, %v", f.Path, noTx, err)
+			}
+		}
+	})
+
+	t.Run("down block", func(t *testing.T) {
+		driver := &rollbackDriver{}
+
+		if _, err := m.Migrate(context.Background(), driver); err != nil {
+			t.Fatalf("Migrate() error = %v", err)
+		}
+
+		if _, err := m.Down(context.Background(), driver, 2); err != nil {
+			t.Fatalf("Down() error = %v", err)
+		}
+
+		want := []string{
+			"./20240102000000_index.sql: -- muz:no-transaction\nDROP INDEX CONCURRENTLY users_id;\n",
+			"./20240101000000_users.sql: DROP TABLE users;\n",
+		}
+		if !slices.Equal(driver.executed, want) {
+			t.Errorf("Down() executed = %q, want %q", driver.executed, want)
+		}
+	})
+}
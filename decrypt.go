@@ -0,0 +1,143 @@
+package muz
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// encryptedExts are the suffixes of encrypted migration files: ".age" for age, ".enc" for envelope encryption
+// like a KMS data key.
+var encryptedExts = []string{".age", ".enc"}
+
+// isEncrypted reports whether the file is encrypted, based on its name.
+func isEncrypted(name string) bool {
+	return trimEncrypted(name) != name
+}
+
+// trimEncrypted removes the encryption suffix of a file name.
+func trimEncrypted(name string) string {
+	lower := strings.ToLower(name)
+	for _, ext := range encryptedExts {
+		if strings.HasSuffix(lower, ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+
+	return name
+}
+
+// Decryptor decrypts migration files stored encrypted, named with an ".age" or ".enc" suffix like
+// "001_seed.sql.age", for migrations embedding sensitive seed data. See Migrate.Decryptor.
+type Decryptor interface {
+	// Decrypt returns the plaintext of the encrypted file name read from r.
+	Decrypt(name string, r io.Reader) ([]byte, error)
+}
+
+// DecryptorFunc adapts a function to a Decryptor, like one unwrapping a data key with AWS KMS or GCP KMS.
+type DecryptorFunc func(name string, r io.Reader) ([]byte, error)
+
+func (f DecryptorFunc) Decrypt(name string, r io.Reader) ([]byte, error) {
+	return f(name, r)
+}
+
+// CommandDecryptor decrypts files with a command reading the encrypted file on stdin and writing the plaintext
+// to stdout, like the age CLI with an identity file.
+//
+//	m.Decryptor = muz.CommandDecryptor{Command: []string{"age", "--decrypt", "-i", "/run/secrets/age.key"}}
+type CommandDecryptor struct {
+	// Command is the program and its arguments.
+	Command []string
+}
+
+func (c CommandDecryptor) Decrypt(name string, r io.Reader) ([]byte, error) {
+	if len(c.Command) == 0 {
+		return nil, fmt.Errorf("decrypting %s: no command", name)
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(c.Command[0], c.Command[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = r, &stdout, &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+
+		return nil, err
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// decryptFS reads the encrypted files of a filesystem decrypted, every file is decrypted once.
+type decryptFS struct {
+	fs.FS
+
+	decryptor Decryptor
+
+	mu    sync.Mutex
+	plain map[string][]byte
+}
+
+func (f *decryptFS) Open(name string) (fs.File, error) {
+	if !isEncrypted(name) {
+		return f.FS.Open(name)
+	}
+
+	content, err := f.decrypt(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return newMemFS(map[string][]byte{name: content}).Open(name)
+}
+
+func (f *decryptFS) decrypt(name string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if content, ok := f.plain[name]; ok {
+		return content, nil
+	}
+
+	if f.decryptor == nil {
+		return nil, fmt.Errorf("decrypting %s: no Decryptor set", name)
+	}
+
+	file, err := f.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	content, err := f.decryptor.Decrypt(name, file)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %s: %w", name, err)
+	}
+
+	f.plain[name] = content
+
+	return content, nil
+}
+
+// withDecryptor reads the encrypted files of the listed directories decrypted with Decryptor.
+func (m Migrate) withDecryptor(list iter.Seq2[*Muzo, error]) iter.Seq2[*Muzo, error] {
+	return func(yield func(*Muzo, error) bool) {
+		for info, err := range list {
+			if info != nil && info.fs != nil {
+				info.fs = &decryptFS{FS: info.fs, decryptor: m.Decryptor, plain: make(map[string][]byte)}
+			}
+
+			if !yield(info, err) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package muz
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDecryptor(t *testing.T) {
+	encrypt := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	files := MapFS(map[string]string{
+		"migrations/seed/001_users.sql":          "CREATE TABLE users (name text);",
+		"migrations/seed/002_admin.sql.enc":      encrypt("INSERT INTO users VALUES ('admin');"),
+		"migrations/seed/002_admin.down.sql.enc": encrypt("DELETE FROM users;"),
+	})
+
+	var decrypted []string
+	decryptor := DecryptorFunc(func(name string, r io.Reader) ([]byte, error) {
+		decrypted = append(decrypted, name)
+		return io.ReadAll(base64.NewDecoder(base64.StdEncoding, r))
+	})
+
+	m := Migrate{FS: files, Extension: ".sql", Decryptor: decryptor}
+
+	t.Run("files are read decrypted", func(t *testing.T) {
+		decrypted = nil
+
+		var info *Muzo
+		for dir, err := range m.Iter() {
+			if err != nil {
+				t.Fatalf("Iter() error = %v", err)
+			}
+
+			info = dir
+		}
+
+		if len(info.Files) != 2 || info.Files[1].Path != "002_admin.sql.enc" || info.Files[1].Version != 2 {
+			t.Fatalf("files = %+v", info.Files)
+		}
+
+		content, err := info.ReadFile("002_admin.sql.enc")
+		if err != nil || string(content) != "INSERT INTO users VALUES ('admin');" {
+			t.Errorf("ReadFile() = %q, %v", content, err)
+		}
+
+		sum, err := info.checksum("002_admin.sql.enc")
+		if err != nil || sum != checksum(content) {
+			t.Errorf("checksum() = %s, %v, want the checksum of the plaintext", sum, err)
+		}
+
+		if !slices.Equal(decrypted, []string{"seed/002_admin.sql.enc"}) {
+			t.Errorf("decrypted %v, want the file once", decrypted)
+		}
+	})
+
+	t.Run("rollback file", func(t *testing.T) {
+		driver := &rollbackDriver{}
+
+		if _, err := m.Migrate(context.Background(), driver); err != nil {
+			t.Fatalf("Migrate() error = %v", err)
+		}
+
+		if _, err := m.Down(context.Background(), driver, 1); err != nil {
+			t.Fatalf("Down() error = %v", err)
+		}
+
+		if want := []string{"seed/002_admin.down.sql: DELETE FROM users;"}; !slices.Equal(driver.executed, want) {
+			t.Errorf("Down() executed = %q, want %q", driver.executed, want)
+		}
+	})
+
+	t.Run("without a Decryptor", func(t *testing.T) {
+		_, err := Migrate{FS: files}.Migrate(context.Background(), &recordDriver{})
+		if err == nil || !strings.Contains(err.Error(), "002_admin.sql.enc") {
+			t.Errorf("Migrate() error = %v, want the encrypted file", err)
+		}
+	})
+}
+
+func TestCommandDecryptor(t *testing.T) {
+	if _, err := exec.LookPath("base64"); err != nil {
+		t.Skip("base64 command not found")
+	}
+
+	content, err := CommandDecryptor{Command: []string{"base64", "-d"}}.Decrypt("001_seed.sql.enc", strings.NewReader("U0VMRUNUIDE7"))
+	if err != nil || string(content) != "SELECT 1;" {
+		t.Errorf("Decrypt() = %q, %v", content, err)
+	}
+
+	if _, err := (CommandDecryptor{Command: []string{"false"}}).Decrypt("001_seed.sql.enc", strings.NewReader("")); err == nil {
+		t.Error("Decrypt() with a failing command, error = nil")
+	}
+}
@@ -0,0 +1,242 @@
+package muz
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Dialect describes the SQL differences between database/sql backends used by GenericSQLDriver.
+type Dialect interface {
+	// Placeholder returns the bind parameter for the n-th argument (starting at 1).
+	Placeholder(n int) string
+	// CreateTable returns the DDL creating the tracking table if it does not exist.
+	// The table must have version, directory, file_name and processed_at columns.
+	CreateTable(table string) string
+	// Upsert returns the statement recording an applied migration.
+	// Arguments are passed in version, directory, file_name order.
+	Upsert(table string) string
+	// Lock returns a statement locking the tracking table for the current transaction.
+	// Empty string means the backend has no such statement.
+	Lock(table string) string
+}
+
+// tableChecker is an optional Dialect extension returning a query that
+// reports whether the tracking table exists, taking the table name as argument.
+type tableChecker interface {
+	TableExists(table string) string
+}
+
+// versionWidener is an optional Dialect extension for tracking tables created with an integer version by
+// older versions. VersionWide returns a query reporting whether the version column of the table given as
+// argument is a bigint, WidenVersion the DDL widening it for timestamp versions.
+type versionWidener interface {
+	VersionWide(table string) string
+	WidenVersion(table string) string
+}
+
+// statementSplitter is an optional Dialect extension for backends executing one statement per call,
+// the files are split with SplitStatements when SplitStatements returns true.
+type statementSplitter interface {
+	SplitStatements() bool
+}
+
+// //////////////////////////////
+
+// PostgresDialect is the Dialect for PostgreSQL.
+type PostgresDialect struct{}
+
+func (PostgresDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (PostgresDialect) CreateTable(table string) string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			version bigint NOT NULL,
+			directory text NOT NULL,
+			file_name text NOT NULL,
+			processed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+			UNIQUE(version, directory)
+		)
+	`, table)
+}
+
+func (PostgresDialect) Upsert(table string) string {
+	return fmt.Sprintf(`
+		INSERT INTO %s (version, directory, file_name)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (version, directory) DO UPDATE SET file_name = EXCLUDED.file_name, processed_at = NOW()
+	`, table)
+}
+
+// postgresColumnsExist reports whether the tracking table given as argument has the audit columns and a bigint version,
+// tables created by older versions miss them.
+const postgresColumnsExist = `
+	SELECT count(*) = 3 FROM pg_attribute WHERE attrelid = to_regclass($1) AND NOT attisdropped
+		AND (attname IN ('applied_by', 'git_sha') OR (attname = 'version' AND atttypid = 'bigint'::regtype))
+`
+
+// postgresColumns returns the DDL upgrading the tracking table of PostgresDriver and PgxDriver,
+// adding the audit columns and widening version for timestamp versions.
+func postgresColumns(table string) string {
+	return fmt.Sprintf(`
+		ALTER TABLE %s
+			ALTER COLUMN version TYPE bigint,
+			ADD COLUMN IF NOT EXISTS description text NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS checksum text NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS execution_ms bigint NOT NULL DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS applied_by text NOT NULL DEFAULT current_user,
+			ADD COLUMN IF NOT EXISTS hostname text NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS app_version text NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS git_sha text NOT NULL DEFAULT ''
+	`, table)
+}
+
+// postgresInsert returns the statement recording an applied migration with its audit columns.
+// Arguments are passed in version, directory, file_name, description, execution_ms order.
+func postgresInsert(table string) string {
+	return fmt.Sprintf(`
+		INSERT INTO %s (version, directory, file_name, description, execution_ms)
+		VALUES ($1, $2, $3, $4, $5)
+	`, table)
+}
+
+// postgresHistory returns the query reading the tracking table with its audit columns.
+func postgresHistory(table string) string {
+	return fmt.Sprintf(`
+		SELECT version, directory, file_name, processed_at, description, checksum, execution_ms, applied_by,
+			hostname, app_version, git_sha
+		FROM %s ORDER BY directory, version
+	`, table)
+}
+
+// postgresAudit returns the statement storing the Audit of an applied migration, an empty
+// applied_by keeps the database user. Arguments are passed in directory, version, applied_by,
+// hostname, app_version, git_sha order.
+func postgresAudit(table string) string {
+	return fmt.Sprintf(`
+		UPDATE %s SET applied_by = COALESCE(NULLIF($3, ''), applied_by), hostname = $4, app_version = $5, git_sha = $6
+		WHERE directory = $1 AND version = $2
+	`, table)
+}
+
+// postgresProcessedAt returns the statement setting processed_at of a record, for drivers with a Now clock.
+// Arguments are passed in directory, version, processed_at order.
+func postgresProcessedAt(table string) string {
+	return fmt.Sprintf(`
+		UPDATE %s SET processed_at = $3 WHERE directory = $1 AND version = $2
+	`, table)
+}
+
+// postgresChecksum returns the statement storing the checksum of an applied migration.
+// Arguments are passed in directory, version, checksum order.
+func postgresChecksum(table string) string {
+	return fmt.Sprintf(`
+		UPDATE %s SET checksum = $3 WHERE directory = $1 AND version = $2
+	`, table)
+}
+
+func (PostgresDialect) VersionWide(string) string {
+	return "SELECT NOT EXISTS (SELECT 1 FROM pg_attribute WHERE attrelid = to_regclass($1) AND attname = 'version' AND NOT attisdropped AND atttypid <> 'bigint'::regtype)"
+}
+
+func (PostgresDialect) WidenVersion(table string) string {
+	return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN version TYPE bigint", table)
+}
+
+func (PostgresDialect) Lock(table string) string {
+	return fmt.Sprintf("LOCK TABLE %s IN EXCLUSIVE MODE", table)
+}
+
+func (PostgresDialect) TableExists(string) string {
+	return "SELECT to_regclass($1) IS NOT NULL"
+}
+
+// //////////////////////////////
+
+// MySQLDialect is the Dialect for MySQL and MariaDB.
+type MySQLDialect struct{}
+
+func (MySQLDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (MySQLDialect) CreateTable(table string) string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			version bigint NOT NULL,
+			directory varchar(255) NOT NULL,
+			file_name varchar(255) NOT NULL,
+			processed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+			UNIQUE(version, directory)
+		)
+	`, table)
+}
+
+func (MySQLDialect) Upsert(table string) string {
+	return fmt.Sprintf(`
+		INSERT INTO %s (version, directory, file_name)
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE file_name = VALUES(file_name), processed_at = CURRENT_TIMESTAMP
+	`, table)
+}
+
+func (MySQLDialect) VersionWide(string) string {
+	return "SELECT COUNT(*) = 0 FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = 'version' AND data_type <> 'bigint'"
+}
+
+func (MySQLDialect) WidenVersion(table string) string {
+	return fmt.Sprintf("ALTER TABLE %s MODIFY version bigint NOT NULL", table)
+}
+
+func (MySQLDialect) Lock(string) string {
+	// LOCK TABLES commits the active transaction in MySQL.
+	return ""
+}
+
+// SplitStatements is true, the MySQL driver refuses multiple statements without the multiStatements parameter.
+func (MySQLDialect) SplitStatements() bool {
+	return true
+}
+
+func (MySQLDialect) TableExists(string) string {
+	return "SELECT COUNT(*) > 0 FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"
+}
+
+// //////////////////////////////
+
+// SQLiteDialect is the Dialect for SQLite.
+type SQLiteDialect struct{}
+
+func (SQLiteDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (SQLiteDialect) CreateTable(table string) string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			version integer NOT NULL,
+			directory text NOT NULL,
+			file_name text NOT NULL,
+			processed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+			UNIQUE(version, directory)
+		)
+	`, table)
+}
+
+func (SQLiteDialect) Upsert(table string) string {
+	return fmt.Sprintf(`
+		INSERT INTO %s (version, directory, file_name)
+		VALUES (?, ?, ?)
+		ON CONFLICT (version, directory) DO UPDATE SET file_name = excluded.file_name, processed_at = CURRENT_TIMESTAMP
+	`, table)
+}
+
+func (SQLiteDialect) Lock(string) string {
+	// SQLite locks the whole database on the first write of a transaction.
+	return ""
+}
+
+func (SQLiteDialect) TableExists(string) string {
+	return "SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = ?"
+}
@@ -0,0 +1,85 @@
+package muz
+
+import "strings"
+
+// TxMode is how the files of a directory run with the migration transaction, see DirOptions.
+type TxMode string
+
+const (
+	// TxModeDefault follows Migrate.AutoNoTransaction and the no-transaction directive of the files.
+	TxModeDefault TxMode = ""
+	// TxModeNone runs every file of the directory outside of the transaction, as if it had the no-transaction directive.
+	// Go migrations keep their own setting.
+	TxModeNone TxMode = "none"
+	// TxModeAuto runs the files with statements needing it outside of the transaction, like AutoNoTransaction.
+	TxModeAuto TxMode = "auto"
+	// TxModeTransaction runs the files in the transaction even with AutoNoTransaction,
+	// only the no-transaction directive takes a file out of it.
+	TxModeTransaction TxMode = "transaction"
+)
+
+func (t TxMode) valid() bool {
+	switch t {
+	case TxModeDefault, TxModeNone, TxModeAuto, TxModeTransaction:
+		return true
+	}
+
+	return false
+}
+
+// DirOptions override the settings of Migrate for the files of one directory.
+type DirOptions struct {
+	// Extension replaces Migrate.Extension for the directory, like ".csv" for a seed directory.
+	//  - Default: Migrate.Extension
+	Extension string `cfg:"extension" json:"extension"`
+	// Skip patterns are added to Migrate.Skip, matched against the file names of the directory like "*_test.sql".
+	Skip []string `cfg:"skip" json:"skip"`
+	// TxMode is how the files run with the migration transaction.
+	//  - Default: TxModeDefault
+	TxMode TxMode `cfg:"tx_mode" json:"tx_mode"`
+	// Seed marks the directory as fixture data instead of schema migrations.
+	// Its files are not recorded as migrations, they run again whenever their checksum changes,
+	// every run with Migrate.Reseed. The driver must implement SeedStore and Executor.
+	//  - Default: false
+	Seed bool `cfg:"seed" json:"seed"`
+}
+
+// dirOptions returns the DirConfig of dir, the zero DirOptions when it has none.
+func (m Migrate) dirOptions(dir string) DirOptions {
+	if o, ok := m.DirConfig[dir]; ok {
+		return o
+	}
+
+	// Keys written like "/seed" or with backslashes on Windows.
+	for d, o := range m.DirConfig {
+		if cleanDir(d) == dir {
+			return o
+		}
+	}
+
+	return DirOptions{}
+}
+
+// extension returns the extension of the files of dir, empty for any file.
+func (m Migrate) extension(dir string) string {
+	return withDefault(m.dirOptions(dir).Extension, m.Extension)
+}
+
+// matchesExtension reports whether the file name of dir has the extension of the directory,
+// gzip compressed files match the extension of the inner file.
+func (m Migrate) matchesExtension(dir, name string) bool {
+	ext := m.extension(dir)
+
+	return ext == "" || strings.HasSuffix(strings.ToLower(trimEncoding(name)), strings.ToLower(ext))
+}
+
+// skipInDir reports whether the file name of dir matches a Skip pattern of the directory.
+func (m Migrate) skipInDir(dir, name string) bool {
+	for _, skip := range m.dirOptions(dir).Skip {
+		if skipMatch(skip, name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,122 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestDirConfig(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql":      "CREATE TABLE users();",
+			"migrations/schema/002_notes.csv":      "id,note",
+			"migrations/seed/001_users.csv":        "id,name",
+			"migrations/seed/002_users.sql":        "INSERT INTO users DEFAULT VALUES;",
+			"migrations/seed/003_admins_draft.csv": "id,name",
+		}),
+		Extension: ".sql",
+		DirConfig: map[string]DirOptions{
+			"/seed": {Extension: ".csv", Skip: []string{"*_draft.csv"}},
+		},
+	}
+
+	got := make(map[string][]string)
+	for info, err := range m.Iter() {
+		if err != nil {
+			t.Fatalf("Iter() error = %v", err)
+		}
+
+		for _, file := range info.Files {
+			got[info.Dir] = append(got[info.Dir], file.Path)
+		}
+	}
+
+	if want := []string{"001_users.sql"}; !slices.Equal(got["schema"], want) {
+		t.Errorf("schema files = %v, want %v", got["schema"], want)
+	}
+
+	if want := []string{"001_users.csv"}; !slices.Equal(got["seed"], want) {
+		t.Errorf("seed files = %v, want %v", got["seed"], want)
+	}
+
+	name, err := m.NextFileName("seed", "admins")
+	if err != nil {
+		t.Fatalf("NextFileName() error = %v", err)
+	}
+
+	if name != "002_admins.csv" {
+		t.Errorf("NextFileName() = %q, want %q", name, "002_admins.csv")
+	}
+}
+
+func TestDirConfigTxMode(t *testing.T) {
+	files := map[string]string{
+		"migrations/app/001_index.sql": "CREATE INDEX CONCURRENTLY users_name ON users (name);",
+		"migrations/app/002_users.sql": "CREATE TABLE users();",
+	}
+
+	tests := []struct {
+		name    string
+		auto    bool
+		mode    TxMode
+		want    []bool
+		wantErr error
+	}{
+		{name: "default", wantErr: ErrNeedsNoTransaction},
+		{name: "default with auto", auto: true, want: []bool{true, false}},
+		{name: "none", mode: TxModeNone, want: []bool{true, true}},
+		{name: "auto", mode: TxModeAuto, want: []bool{true, false}},
+		{name: "transaction with auto", auto: true, mode: TxModeTransaction, wantErr: ErrNeedsNoTransaction},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Migrate{
+				FS:                MapFS(files),
+				AutoNoTransaction: tt.auto,
+				DirConfig:         map[string]DirOptions{"app": {TxMode: tt.mode}},
+			}
+
+			var got []bool
+			for info, err := range m.Iter() {
+				if err != nil {
+					t.Fatalf("Iter() error = %v", err)
+				}
+
+				for _, file := range info.Files {
+					noTx, err := noTransaction(info.withFiles([]FileInfo{file}), file)
+					if err != nil {
+						if !errors.Is(err, tt.wantErr) {
+							t.Fatalf("noTransaction() error = %v, want %v", err, tt.wantErr)
+						}
+
+						return
+					}
+
+					got = append(got, noTx)
+				}
+			}
+
+			if tt.wantErr != nil {
+				t.Fatalf("noTransaction() error = nil, want %v", tt.wantErr)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("no transaction = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDirConfigUnknownTxMode(t *testing.T) {
+	m := Migrate{
+		FS:        MapFS(map[string]string{"migrations/app/001_users.sql": "CREATE TABLE users();"}),
+		DirConfig: map[string]DirOptions{"app": {TxMode: "never"}},
+	}
+
+	if _, err := m.Migrate(context.Background(), &recordDriver{}); err == nil {
+		t.Error("Migrate() error = nil, want the unknown transaction mode")
+	}
+}
@@ -0,0 +1,149 @@
+package muz
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// DirectiveNoTransaction in the leading comments of a file, like "-- muz:no-transaction",
+// runs it outside of the migration transaction for statements like CREATE INDEX CONCURRENTLY or VACUUM.
+// The files applied before are committed first, and a failing file cannot be rolled back.
+const DirectiveNoTransaction = "no-transaction"
+
+// DirectiveTags in the leading comments of a file, like "-- muz:tags seed,slow", tags the file
+// for Migrate.Tags and Migrate.ExcludeTags.
+const DirectiveTags = "tags"
+
+// Directives filling the Metadata of a file.
+const (
+	DirectiveDescription = "description"
+	DirectiveAuthor      = "author"
+	DirectiveTicket      = "ticket"
+)
+
+// directivePrefix starts a directive inside a comment line.
+const directivePrefix = "muz:"
+
+// parseDirectives returns the directives in the leading comment lines of r by name,
+// like "tags" to "seed,slow". Values of repeated directives are joined with a comma.
+func parseDirectives(r io.Reader) (map[string]string, error) {
+	var directives map[string]string
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		line = strings.TrimSpace(line)
+		if line != "" {
+			comment, ok := strings.CutPrefix(line, "--")
+			if !ok {
+				return directives, nil
+			}
+
+			if section, noTx := dbmateMarker(line); gooseAnnotation(line) == gooseNoTransaction || section == gooseUp && noTx {
+				if directives == nil {
+					directives = make(map[string]string)
+				}
+
+				directives[DirectiveNoTransaction] = ""
+			}
+
+			if d, ok := strings.CutPrefix(strings.TrimSpace(comment), directivePrefix); ok {
+				name, value, _ := strings.Cut(strings.TrimSpace(d), " ")
+				value = strings.TrimSpace(value)
+
+				if directives == nil {
+					directives = make(map[string]string)
+				}
+
+				if prev, ok := directives[name]; ok && prev != "" {
+					value = prev + "," + value
+				}
+
+				directives[name] = value
+			}
+		}
+
+		if err != nil {
+			return directives, nil
+		}
+	}
+}
+
+// hasDirective reports whether the leading comment lines of content carry the directive.
+func hasDirective(content []byte, name string) bool {
+	directives, _ := parseDirectives(bytes.NewReader(content))
+	_, ok := directives[name]
+
+	return ok
+}
+
+// directives returns the directives of a file, reading only its leading comment lines.
+// Go migrations have no directives.
+func (d *Muzo) directives(filePath string) (map[string]string, error) {
+	if d.GoMigration(filePath) != nil || d.fs == nil {
+		return nil, nil
+	}
+
+	f, err := d.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseDirectives(f)
+}
+
+// metadata returns the Metadata of parsed directives.
+func metadata(directives map[string]string) Metadata {
+	return Metadata{
+		Description: directives[DirectiveDescription],
+		Author:      directives[DirectiveAuthor],
+		Ticket:      directives[DirectiveTicket],
+	}
+}
+
+// noTransaction reports whether file must run outside of the migration transaction,
+// with the directive or TxModeNone or, with Migrate.AutoNoTransaction, a statement needing it.
+// A file with such a statement and without the directive is an ErrNeedsNoTransaction otherwise.
+func noTransaction(data *Muzo, file FileInfo) (bool, error) {
+	directives, err := data.directives(file.Path)
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := directives[DirectiveNoTransaction]; ok || data.GoMigration(file.Path) != nil {
+		return ok, nil
+	}
+
+	if data.noTx {
+		return true, nil
+	}
+
+	// Streamed, seed files of hundreds of megabytes are checked too.
+	var kind string
+	for statement, err := range data.statements(file.Path) {
+		if err != nil {
+			return false, err
+		}
+
+		if kind = nonTransactionalStatement(statement); kind != "" {
+			break
+		}
+	}
+
+	if kind == "" || data.autoNoTx {
+		return kind != "", nil
+	}
+
+	return false, fmt.Errorf("%w: %s in %s, add %q on top of the file or set AutoNoTransaction",
+		ErrNeedsNoTransaction, kind, path.Join(data.Dir, file.Path), "-- "+directivePrefix+DirectiveNoTransaction)
+}
@@ -0,0 +1,46 @@
+package muz
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHasDirective(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{name: "first line", content: "-- muz:no-transaction\nCREATE INDEX CONCURRENTLY idx ON users (name);", want: true},
+		{name: "after comments", content: "\n-- create the index\n--muz: no-transaction \nVACUUM;", want: true},
+		{name: "after statement", content: "VACUUM;\n-- muz:no-transaction", want: false},
+		{name: "other directive", content: "-- muz:transaction\nVACUUM;", want: false},
+		{name: "plain comment", content: "-- no-transaction\nVACUUM;", want: false},
+		{name: "empty", content: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasDirective([]byte(tt.content), DirectiveNoTransaction); got != tt.want {
+				t.Errorf("hasDirective() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrateMetadata(t *testing.T) {
+	m := Migrate{FS: MapFS(map[string]string{
+		"migrations/001_users.sql":  "-- muz:description add the users table\n-- muz:author jane\n-- muz:ticket APP-1\nCREATE TABLE users();",
+		"migrations/002_orders.sql": "CREATE TABLE orders();",
+	})}
+
+	status, err := m.Status(context.Background(), &recordDriver{})
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+
+	want := Metadata{Description: "add the users table", Author: "jane", Ticket: "APP-1"}
+	if len(status.Files) != 2 || status.Files[0].Metadata != want || status.Files[1].Metadata != (Metadata{}) {
+		t.Errorf("Status() files = %+v, want metadata %+v on the first file only", status.Files, want)
+	}
+}
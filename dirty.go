@@ -0,0 +1,188 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrDirty is returned when a directory has a failed migration not resolved by Force or Repair yet.
+var ErrDirty = errors.New("dirty migration")
+
+// DirtyRecord is a migration that failed in a previous run.
+type DirtyRecord struct {
+	Directory string    `json:"directory"`
+	Version   int64     `json:"version"`
+	FileName  string    `json:"file_name"`
+	Error     string    `json:"error"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// DirtyStore is implemented by drivers that remember the failed migration of a directory across runs,
+// the database may be left half migrated by files running outside of a transaction.
+// MarkDirty is called after End of the failed run, so it is kept when the transaction rolls back.
+// ClearDirty is called between Start and End by Force and Repair.
+type DirtyStore interface {
+	Dirty(ctx context.Context) ([]DirtyRecord, error)
+	MarkDirty(ctx context.Context, record DirtyRecord) error
+	ClearDirty(ctx context.Context, dir string) error
+}
+
+// checkDirty returns ErrDirty when the driver is a DirtyStore with a failed migration.
+func checkDirty(ctx context.Context, driver Driver) error {
+	store, ok := driver.(DirtyStore)
+	if !ok {
+		return nil
+	}
+
+	records, err := store.Dirty(ctx)
+	if err != nil || len(records) == 0 {
+		return err
+	}
+
+	failed := make([]string, 0, len(records))
+	for _, r := range records {
+		failed = append(failed, fmt.Sprintf("%s version %d (%s) failed: %s", r.Directory, r.Version, r.FileName, r.Error))
+	}
+
+	return fmt.Errorf("%w: %s; fix the database, then run force or repair", ErrDirty, strings.Join(failed, "; "))
+}
+
+// markDirty records the failed file of result when the driver is a DirtyStore.
+// Drivers processing whole directories do not report the failed file and are not marked.
+func markDirty(ctx context.Context, driver Driver, result *Result) error {
+	store, ok := driver.(DirtyStore)
+	if !ok {
+		return nil
+	}
+
+	for _, f := range result.Files {
+		if f.Outcome != OutcomeFailed {
+			continue
+		}
+
+		// The run may have failed because ctx was canceled.
+		err := store.MarkDirty(context.WithoutCancel(ctx), DirtyRecord{
+			Directory: f.Dir,
+			Version:   f.Version,
+			FileName:  f.File,
+			Error:     f.Error,
+			FailedAt:  time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("recording dirty migration: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// clearDirty removes the failed migrations of the directories accepted by match.
+func clearDirty(ctx context.Context, driver Driver, match func(dir string) bool) error {
+	store, ok := driver.(DirtyStore)
+	if !ok {
+		return nil
+	}
+
+	records, err := store.Dirty(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		if !match(r.Directory) {
+			continue
+		}
+
+		if err := store.ClearDirty(ctx, r.Directory); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// //////////////////////////////
+
+// dirtySuffix is appended to the tracking table name for the table holding the failed migrations.
+const dirtySuffix = "_dirty"
+
+// dirtyTable returns the name of the table holding the failed migrations next to the tracking table.
+func dirtyTable(table string) string {
+	return table + dirtySuffix
+}
+
+// dirtyCreateTable returns the DDL creating the dirty table, portable across the dialects.
+func dirtyCreateTable(table string) string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			directory varchar(255) NOT NULL PRIMARY KEY,
+			version bigint NOT NULL,
+			file_name varchar(255) NOT NULL,
+			error text NOT NULL,
+			failed_at TIMESTAMP NOT NULL
+		)
+	`, table)
+}
+
+// dirtyQueries returns the statements reading, deleting and inserting dirty records,
+// using placeholder for the bind parameters. Insert arguments are passed in
+// directory, version, file_name, error, failed_at order.
+func dirtyQueries(table string, placeholder func(n int) string) (query, remove, insert string) {
+	query = fmt.Sprintf(`
+		SELECT directory, version, file_name, error, failed_at FROM %s ORDER BY directory
+	`, table)
+	remove = fmt.Sprintf(`
+		DELETE FROM %s WHERE directory = %s
+	`, table, placeholder(1))
+	insert = fmt.Sprintf(`
+		INSERT INTO %s (directory, version, file_name, error, failed_at)
+		VALUES (%s, %s, %s, %s, %s)
+	`, table, placeholder(1), placeholder(2), placeholder(3), placeholder(4), placeholder(5))
+
+	return query, remove, insert
+}
+
+// markDirtySQL replaces the dirty record of the directory of r, creating the dirty table when needed.
+func markDirtySQL(ctx context.Context, q querier, table string, placeholder func(n int) string, r DirtyRecord) error {
+	if _, err := q.ExecContext(ctx, dirtyCreateTable(table)); err != nil {
+		return err
+	}
+
+	_, remove, insert := dirtyQueries(table, placeholder)
+	if _, err := q.ExecContext(ctx, remove, r.Directory); err != nil {
+		return err
+	}
+
+	_, err := q.ExecContext(ctx, insert, r.Directory, r.Version, r.FileName, r.Error, r.FailedAt.UTC())
+	return err
+}
+
+// queryDirty reads the dirty table when exists reports it exists.
+func queryDirty(ctx context.Context, q querier, table string, exists bool) ([]DirtyRecord, error) {
+	if !exists {
+		return nil, nil
+	}
+
+	query, _, _ := dirtyQueries(table, PostgresDialect{}.Placeholder)
+
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []DirtyRecord
+	for rows.Next() {
+		var r DirtyRecord
+		if err := rows.Scan(&r.Directory, &r.Version, &r.FileName, &r.Error, &r.FailedAt); err != nil {
+			return nil, err
+		}
+
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
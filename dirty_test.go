@@ -0,0 +1,120 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// dirtyDriver fails the files of fail and keeps the dirty records in memory.
+// Rollback only removes the records.
+type dirtyDriver struct {
+	recordDriver
+
+	fail  map[string]bool
+	dirty []DirtyRecord
+}
+
+func (d *dirtyDriver) Process(ctx context.Context, data *Muzo) error {
+	for _, file := range data.Files {
+		if d.fail[file.Path] {
+			return fmt.Errorf("applying %s: syntax error", file.Path)
+		}
+	}
+
+	return d.recordDriver.Process(ctx, data)
+}
+
+func (d *dirtyDriver) Rollback(ctx context.Context, data *Muzo) error {
+	for _, file := range data.Files {
+		if err := d.Unrecord(ctx, data.Dir, file.Version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *dirtyDriver) Dirty(context.Context) ([]DirtyRecord, error) {
+	return slices.Clone(d.dirty), nil
+}
+
+func (d *dirtyDriver) MarkDirty(_ context.Context, r DirtyRecord) error {
+	d.dirty = append(d.dirty, r)
+	return nil
+}
+
+func (d *dirtyDriver) ClearDirty(_ context.Context, dir string) error {
+	d.dirty = slices.DeleteFunc(d.dirty, func(r DirtyRecord) bool { return r.Directory == dir })
+	return nil
+}
+
+func TestMigrateDirty(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql":  "CREATE TABLE users();",
+			"migrations/schema/002_orders.sql": "CREATE TABLE orders(;",
+		}),
+	}
+
+	tests := []struct {
+		name    string
+		resolve func(driver Driver) error
+	}{
+		{
+			name: "force",
+			resolve: func(driver Driver) error {
+				return m.Force(context.Background(), driver, "schema", 1)
+			},
+		},
+		{
+			name: "repair",
+			resolve: func(driver Driver) error {
+				_, err := m.Repair(context.Background(), driver)
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver := &dirtyDriver{fail: map[string]bool{"002_orders.sql": true}}
+
+			if _, err := m.Migrate(context.Background(), driver); err == nil {
+				t.Fatal("Migrate() error = nil, want failed migration")
+			}
+
+			if len(driver.dirty) != 1 || driver.dirty[0].Version != 2 || driver.dirty[0].FileName != "002_orders.sql" || driver.dirty[0].Error == "" {
+				t.Fatalf("dirty = %+v, want schema version 2", driver.dirty)
+			}
+
+			driver.fail = nil
+
+			if _, err := m.Migrate(context.Background(), driver); !errors.Is(err, ErrDirty) {
+				t.Fatalf("Migrate() error = %v, want ErrDirty", err)
+			}
+
+			if _, err := m.Down(context.Background(), driver, 1); !errors.Is(err, ErrDirty) {
+				t.Fatalf("Down() error = %v, want ErrDirty", err)
+			}
+
+			if err := tt.resolve(driver); err != nil {
+				t.Fatalf("resolve error = %v", err)
+			}
+
+			if len(driver.dirty) != 0 {
+				t.Fatalf("dirty = %+v, want none", driver.dirty)
+			}
+
+			if _, err := m.Migrate(context.Background(), driver); err != nil {
+				t.Fatalf("Migrate() error = %v", err)
+			}
+
+			if got := driver.versions("schema"); !slices.Equal(got, []int64{1, 2}) {
+				t.Errorf("versions = %v, want [1 2]", got)
+			}
+		})
+	}
+}
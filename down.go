@@ -0,0 +1,204 @@
+package muz
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"time"
+)
+
+// Down rolls back the last n applied migrations, newest first, by executing their
+// rollback files like "001_users.down.sql", the "-- +goose Down" section of goose files,
+// the "-- migrate:down" block of dbmate files or the Down function of Go migrations.
+// The driver must implement Rollbacker and StatusReporter.
+func (m Migrate) Down(ctx context.Context, driver Driver, n int) (*Result, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("down: invalid number of migrations %d", n)
+	}
+
+	return m.rollback(ctx, driver, func(records []Record, order map[string]int) []Record {
+		slices.SortFunc(records, func(a, b Record) int {
+			if c := b.ProcessedAt.Compare(a.ProcessedAt); c != 0 {
+				return c
+			}
+
+			if c := cmp.Compare(order[b.Directory], order[a.Directory]); c != 0 {
+				return c
+			}
+
+			return cmp.Compare(b.Version, a.Version)
+		})
+
+		return records[:min(n, len(records))]
+	})
+}
+
+// rollback rolls back the records chosen by selectRecords, in the returned order.
+// selectRecords gets a copy of the records and the position of every directory in the tree.
+func (m Migrate) rollback(ctx context.Context, driver Driver, selectRecords func(records []Record, order map[string]int) []Record) (result *Result, err error) {
+	rb, ok := driver.(Rollbacker)
+	if !ok {
+		return nil, fmt.Errorf("rollback: %w", ErrNotSupported)
+	}
+
+	reporter, ok := driver.(StatusReporter)
+	if !ok {
+		return nil, fmt.Errorf("rollback: %w", ErrNotSupported)
+	}
+
+	start := time.Now()
+	result = &Result{Files: []FileResult{}, Dirs: []string{}}
+
+	defer func() {
+		result.Duration = time.Since(start)
+
+		if err != nil && m.Hooks.OnError != nil {
+			m.Hooks.OnError(ctx, err)
+		}
+	}()
+
+	return result, session(ctx, driver, m.log(), func() error {
+		if err := checkDirty(ctx, driver); err != nil {
+			return err
+		}
+
+		records, err := reporter.History(ctx)
+		if err != nil {
+			return err
+		}
+
+		order := make(map[string]int)
+		for info, err := range m.dirs() {
+			if err != nil {
+				return err
+			}
+
+			order[info.Dir] = len(order)
+		}
+
+		steps, err := m.rollbackSteps(selectRecords(slices.Clone(records), order))
+		if err != nil {
+			return err
+		}
+
+		for _, step := range steps {
+			file := step.Files[0]
+			fr := FileResult{Dir: step.Dir, File: file.Path, Version: file.Version}
+
+			if m.Hooks.BeforeFile != nil {
+				if err := m.Hooks.BeforeFile(ctx, step.Dir, file); err != nil {
+					return err
+				}
+			}
+
+			fileStart := time.Now()
+			err := rb.Rollback(ctx, step)
+			fr.Duration = time.Since(fileStart)
+
+			fr.Outcome = OutcomeRolledBack
+			if err != nil {
+				fr.Outcome = OutcomeFailed
+				fr.Error = err.Error()
+			}
+
+			result.add(fr)
+
+			if m.Hooks.AfterFile != nil {
+				m.Hooks.AfterFile(ctx, fr)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			result.touch(step.Dir)
+		}
+
+		return nil
+	})
+}
+
+// rollbackSteps loads the rollback files of records,
+// one single file directory per record in execution order.
+func (m Migrate) rollbackSteps(records []Record) ([]*Muzo, error) {
+	steps := make([]*Muzo, len(records))
+	for info, err := range m.dirs() {
+		if err != nil {
+			return nil, err
+		}
+
+		for i, r := range records {
+			if r.Directory != info.Dir {
+				continue
+			}
+
+			step, err := rollbackStep(info, r)
+			if err != nil {
+				return nil, err
+			}
+
+			steps[i] = step
+		}
+	}
+
+	for i, step := range steps {
+		if step == nil {
+			return nil, fmt.Errorf("down: directory %s of %s does not exist", records[i].Directory, records[i].FileName)
+		}
+	}
+
+	return steps, nil
+}
+
+// rollbackStep loads the rollback of a record, so it stays readable after the source is closed.
+func rollbackStep(info *Muzo, r Record) (*Muzo, error) {
+	if gm := info.GoMigration(r.FileName); gm != nil {
+		if gm.Down == nil {
+			return nil, fmt.Errorf("down: go migration %s - %s has no down function", r.Directory, r.FileName)
+		}
+
+		return &Muzo{
+			Dir:   info.Dir,
+			Files: []FileInfo{{Path: r.FileName, Version: r.Version}},
+			gos:   map[string]*GoMigration{r.FileName: gm},
+		}, nil
+	}
+
+	// Goose and dbmate files hold their rollback in the Down section.
+	content, ok, err := info.gooseDown(r.FileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		name := trimEncoding(r.FileName)
+
+		return NewMuzo(info.Dir, []FileInfo{{Path: name, Version: r.Version}}, MapFS(map[string]string{
+			path.Join(info.Dir, name): string(content),
+		})), nil
+	}
+
+	name := downFileName(r.FileName)
+	if name == "" {
+		return nil, fmt.Errorf("down: no rollback file for %s - %s", r.Directory, r.FileName)
+	}
+
+	content, err = info.ReadFile(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("down: rollback file %s - %s does not exist", r.Directory, name)
+		}
+
+		return nil, err
+	}
+
+	name = trimEncoding(name)
+
+	return NewMuzo(info.Dir, []FileInfo{{Path: name, Version: r.Version}}, MapFS(map[string]string{
+		path.Join(info.Dir, name): string(content),
+	})), nil
+}
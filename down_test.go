@@ -0,0 +1,104 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// rollbackDriver records the rollback files it executes.
+type rollbackDriver struct {
+	recordDriver
+
+	executed []string
+}
+
+func (d *rollbackDriver) Rollback(ctx context.Context, data *Muzo) error {
+	for _, f := range data.Files {
+		content, err := data.ReadFile(f.Path)
+		if err != nil {
+			return err
+		}
+
+		d.executed = append(d.executed, data.Dir+"/"+f.Path+": "+string(content))
+
+		if err := d.Unrecord(ctx, data.Dir, f.Version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestMigrateDown(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql":       "CREATE TABLE users();",
+			"migrations/schema/001_users.down.sql":  "DROP TABLE users;",
+			"migrations/schema/002_orders.sql":      "CREATE TABLE orders();",
+			"migrations/schema/002_orders.down.sql": "DROP TABLE orders;",
+			"migrations/seed/001_admin.sql":         "INSERT INTO users DEFAULT VALUES;",
+			"migrations/seed/001_admin.down.sql":    "DELETE FROM users;",
+		}),
+		Order: []string{"schema", "seed"},
+	}
+
+	records := []Record{
+		{Version: 1, Directory: "schema", FileName: "001_users.sql"},
+		{Version: 2, Directory: "schema", FileName: "002_orders.sql"},
+		{Version: 1, Directory: "seed", FileName: "001_admin.sql"},
+	}
+
+	driver := &rollbackDriver{recordDriver: recordDriver{records: slices.Clone(records)}}
+
+	result, err := m.Down(context.Background(), driver, 2)
+	if err != nil {
+		t.Fatalf("Down() error = %v", err)
+	}
+
+	want := []string{
+		"seed/001_admin.down.sql: DELETE FROM users;",
+		"schema/002_orders.down.sql: DROP TABLE orders;",
+	}
+
+	if !slices.Equal(driver.executed, want) {
+		t.Errorf("Down() executed = %q, want %q", driver.executed, want)
+	}
+
+	if got := driver.versions("schema"); !slices.Equal(got, []int64{1}) {
+		t.Errorf("Down() schema versions = %v, want [1]", got)
+	}
+
+	if len(result.Files) != 2 || result.Files[0].Outcome != OutcomeRolledBack {
+		t.Errorf("Down() result = %+v", result.Files)
+	}
+
+	if _, err := m.Down(context.Background(), driver, 0); err == nil {
+		t.Errorf("Down(0) expected error")
+	}
+
+	if _, err := m.Down(context.Background(), &recordDriver{}, 1); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Down() error = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestMigrateDownMissingFile(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql": "CREATE TABLE users();",
+		}),
+	}
+
+	driver := &rollbackDriver{recordDriver: recordDriver{records: []Record{
+		{Version: 1, Directory: "schema", FileName: "001_users.sql"},
+	}}}
+
+	if _, err := m.Down(context.Background(), driver, 1); err == nil {
+		t.Errorf("Down() expected error for missing rollback file")
+	}
+
+	if len(driver.executed) != 0 {
+		t.Errorf("Down() executed = %v, want nothing", driver.executed)
+	}
+}
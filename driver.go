@@ -3,7 +3,11 @@ package muz
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"time"
 )
 
 type Driver interface {
@@ -12,56 +16,198 @@ type Driver interface {
 	End(ctx context.Context, err error) error
 }
 
+// clock returns the time of now, the Now field of the drivers, or the current time when it is nil.
+func clock(now func() time.Time) time.Time {
+	if now != nil {
+		return now()
+	}
+
+	return time.Now()
+}
+
 // //////////////////////////////
 
 type PostgresDriver struct {
 	// DB is the database connection to use for migrations.
 	DB *sql.DB
 	// Table is the name of the migration tracking table.
+	//  - Default: "migrations"
+	//  - Quoted as an identifier and folded to lower case, "ops.migrations" is split into schema and table.
+	//  - Double quoted parts like ops."Migrations" keep their case.
 	Table string
+	// Schema if set, is the schema of the tracking table, for tables outside of the search_path like "ops"."migrations".
+	// It is quoted as given.
+	// Ignored with Schemas, every schema has its own tracking table.
+	Schema string
+	// TableSchema if set, overrides the statements of the tracking table.
+	TableSchema TableSchema
 	// Logger if set, used to log migration progress.
 	Logger Logger
+	// Schemas if set, applies the migrations once per schema, like one schema per tenant.
+	// Every schema gets its own tracking table and runs with its search_path, in the same transaction.
+	Schemas []string
+	// LockTimeout if set, is the lock_timeout of the migration transactions, failing a migration waiting
+	// for a lock held by the application instead of queueing its traffic behind the migration.
+	//  - Default: 0, the lock_timeout of the server.
+	LockTimeout time.Duration
+	// StatementTimeout if set, is the statement_timeout of the migration transactions.
+	//  - Default: 0, the statement_timeout of the server.
+	StatementTimeout time.Duration
+	// Role if set, the migrations run as this role with SET ROLE, owning the objects they create.
+	Role string
+	// WaitTimeout if set, Lock and Start first wait up to WaitTimeout for DB to answer, see WaitForDB.
+	//  - Default: 0, a database not reachable fails the run at once.
+	WaitTimeout time.Duration
+	// CheckPrivileges if set, Start first verifies the role, after SET ROLE, can create objects in its schema
+	// and owns the tracking table, failing with ErrPrivileges instead of halfway through a transaction.
+	CheckPrivileges bool
+	// StreamSize is the size of the files run in one call. Larger files, like seed scripts of hundreds
+	// of megabytes, are streamed: split into statements as they are read and sent in batches of about StreamSize.
+	//  - Default: 8 MiB
+	//  - Negative always runs the files in one call, reading them into memory.
+	StreamSize int
+	// Now if set, is the clock of processed_at, execution_ms and the seed, dirty and failure times, like a fixed time
+	// for tests asserting exact tracking rows.
+	//  - Default: time.Now, processed_at is set by the database.
+	Now func() time.Time
+	// DryRun if set, receives the SQL script of the run instead of executing it, tracking table changes included.
+	// The applied versions are still read from DB when it is set.
+	DryRun io.Writer
 
 	// tx is the current transaction, if any.
 	tx *sql.Tx
+	// external is set when tx is owned by the caller.
+	external bool
+	// schema is the schema of Schemas being migrated.
+	schema string
+	// lockConn holds the advisory lock between Lock and Unlock.
+	lockConn *sql.Conn
+}
+
+// NewPostgresTxDriver returns a PostgresDriver running all migrations inside the given transaction.
+// The caller owns the transaction: End neither commits nor rolls it back.
+func NewPostgresTxDriver(tx *sql.Tx) *PostgresDriver {
+	return &PostgresDriver{
+		tx:       tx,
+		external: true,
+	}
+}
+
+// defaultStreamSize is the default PostgresDriver.StreamSize.
+const defaultStreamSize = 8 << 20
+
+// streamSize returns the StreamSize of execContent.
+func (p *PostgresDriver) streamSize() int {
+	switch {
+	case p.StreamSize < 0:
+		return 0
+	case p.StreamSize == 0:
+		return defaultStreamSize
+	}
+
+	return p.StreamSize
 }
 
+// execOptions returns the options of execContent running the statements on q.
+// COPY FROM stdin statements run as inserts, see copyInserts.
+func (p *PostgresDriver) execOptions(q execer) execOptions {
+	return execOptions{batch: p.streamSize(), copy: copyInserts(q, copyChunk), logger: p.Logger, standard: true}
+}
+
+// tableName returns the quoted name of the tracking table, in the schema of Schemas being migrated.
 func (p *PostgresDriver) tableName() string {
-	if p.Table == "" {
-		return "migrations"
+	return p.relation("")
+}
+
+// relation returns the quoted name of the tracking table with suffix appended, like the dirty table.
+func (p *PostgresDriver) relation(suffix string) string {
+	schema := p.Schema
+	if p.schema != "" {
+		schema = p.schema
 	}
 
-	return p.Table
+	return postgresIdent(schema, withDefault(p.Table, "migrations"), suffix)
 }
 
 func (p *PostgresDriver) Start(ctx context.Context) error {
-	var err error
-	p.tx, err = p.DB.BeginTx(ctx, nil)
-	if err != nil {
+	if err := p.wait(ctx); err != nil {
 		return err
 	}
 
-	query := fmt.Sprintf(`
-		CREATE TABLE IF NOT EXISTS %s (
-			version integer NOT NULL,
-			directory text NOT NULL,
-			file_name text NOT NULL,
-			processed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
-			UNIQUE(version, directory)
-		)
-	`, p.tableName())
+	if p.DryRun != nil {
+		if err := p.dryRun("BEGIN"); err != nil {
+			return err
+		}
+
+		if err := p.setSettings(ctx); err != nil {
+			return err
+		}
+
+		return p.eachSchema(ctx, func() error {
+			if ddl := p.TableSchema.createTable(p.tableName()); ddl != "" {
+				if err := p.dryRun(ddl); err != nil {
+					return err
+				}
+			}
 
-	if p.Logger != nil {
-		p.Logger.Info("starting migration", "table", p.tableName())
+			return p.dryRun(postgresColumns(p.tableName()))
+		})
 	}
 
-	_, err = p.tx.ExecContext(ctx, query)
-	return err
+	if !p.external {
+		var err error
+		p.tx, err = p.DB.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := p.setSettings(ctx); err != nil {
+		return err
+	}
+
+	return p.eachSchema(ctx, func() error {
+		if p.Logger != nil {
+			p.Logger.Info("starting migration", "table", p.tableName())
+		}
+
+		if p.CheckPrivileges {
+			err := checkPostgresPrivileges(p.tableName(), p.tx.QueryRowContext(ctx, postgresPrivileges, p.tableName()).Scan)
+			if err != nil {
+				return err
+			}
+		}
+
+		if ddl := p.TableSchema.createTable(p.tableName()); ddl != "" {
+			if _, err := p.tx.ExecContext(ctx, ddl); err != nil {
+				return err
+			}
+		}
+
+		var exists bool
+		if err := p.tx.QueryRowContext(ctx, postgresColumnsExist, p.tableName()).Scan(&exists); err != nil || exists {
+			return err
+		}
+
+		_, err := p.tx.ExecContext(ctx, postgresColumns(p.tableName()))
+		return err
+	})
 }
 
 func (p *PostgresDriver) Process(ctx context.Context, data *Muzo) error {
+	return p.eachSchema(ctx, func() error {
+		if p.DryRun != nil {
+			return p.dryProcess(ctx, data)
+		}
+
+		return p.process(ctx, data)
+	})
+}
+
+// process applies the files of data above the latest applied version of the directory.
+func (p *PostgresDriver) process(ctx context.Context, data *Muzo) error {
 	directory := data.Dir
-	version := 0
+	var version int64
 
 	// Get latest applied version for the directory
 	query := fmt.Sprintf(`
@@ -74,34 +220,46 @@ func (p *PostgresDriver) Process(ctx context.Context, data *Muzo) error {
 		return err
 	}
 	if latestVersion.Valid {
-		version = int(latestVersion.Int64)
+		version = latestVersion.Int64
 	}
 
 	// Apply migrations in order
 	for _, file := range data.Files {
-		if file.Version <= version {
+		if file.Version <= version && !data.OutOfOrder() {
 			continue // already applied
 		}
 
-		content, err := data.ReadFile(file.Path)
+		if p.Logger != nil {
+			p.Logger.Info("applying migration", "version", file.Version, "directory", directory, "file", file.Path)
+		}
+
+		noTx, err := noTransaction(data, file)
 		if err != nil {
 			return err
 		}
 
-		if p.Logger != nil {
-			p.Logger.Info("applying migration", "version", file.Version, "directory", directory, "file", file.Path)
+		if noTx {
+			if err := p.processNoTx(ctx, data, file); err != nil {
+				return fmt.Errorf("applying migration %d - %s - %s: %w", file.Version, directory, file.Path, err)
+			}
+
+			version = file.Version
+			continue
 		}
 
-		// Execute migration SQL
-		if _, err := p.tx.ExecContext(ctx, string(content)); err != nil {
+		// Execute migration SQL or Go function
+		started := clock(p.Now)
+		if err := execFile(ctx, p.tx, data, file, p.execOptions(p.tx)); err != nil {
 			return fmt.Errorf("applying migration %d - %s - %s: %w", file.Version, directory, file.Path, err)
 		}
 
 		// Record applied migration
-		if _, err := p.tx.ExecContext(ctx, fmt.Sprintf(`
-			INSERT INTO %s (version, directory, file_name)
-			VALUES ($1, $2, $3)
-		`, p.tableName()), file.Version, directory, file.Path); err != nil {
+		if _, err := p.tx.ExecContext(ctx, p.TableSchema.insert(p.tableName()),
+			file.Version, directory, file.Path, file.Description, clock(p.Now).Sub(started).Milliseconds()); err != nil {
+			return err
+		}
+
+		if err := p.storeProcessedAt(ctx, p.tx, directory, file.Version); err != nil {
 			return err
 		}
 
@@ -111,20 +269,549 @@ func (p *PostgresDriver) Process(ctx context.Context, data *Muzo) error {
 	return nil
 }
 
+// processNoTx commits the work done so far, applies file directly on the connection
+// and starts a new transaction for the remaining files.
+func (p *PostgresDriver) processNoTx(ctx context.Context, data *Muzo, file FileInfo) error {
+	if p.external {
+		return errors.New("no-transaction migration inside a caller owned transaction")
+	}
+
+	tx := p.tx
+	p.tx = nil
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	started := clock(p.Now)
+	if err := p.execConn(ctx, func(q querier) error {
+		return execContent(ctx, q, data, file.Path, p.execOptions(q))
+	}); err != nil {
+		return err
+	}
+
+	if _, err := p.DB.ExecContext(ctx, p.TableSchema.insert(p.tableName()),
+		file.Version, data.Dir, file.Path, file.Description, clock(p.Now).Sub(started).Milliseconds()); err != nil {
+		return err
+	}
+
+	if err := p.storeProcessedAt(ctx, p.DB, data.Dir, file.Version); err != nil {
+		return err
+	}
+
+	var err error
+
+	p.tx, err = p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := p.setSettings(ctx); err != nil {
+		return err
+	}
+
+	return p.setSearchPath(ctx)
+}
+
+// execConn calls exec outside of a transaction, with a connection using the search_path of the current schema
+// and the session settings.
+func (p *PostgresDriver) execConn(ctx context.Context, exec func(q querier) error) (err error) {
+	settings := p.settings(false)
+	if p.schema != "" {
+		settings = append(settings, "SET search_path TO "+quoteIdent(p.schema))
+	}
+
+	if len(settings) == 0 {
+		return exec(p.DB)
+	}
+
+	conn, err := p.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Do not leave the settings to the next user of the pooled connection.
+	defer func() {
+		if _, resetErr := conn.ExecContext(context.WithoutCancel(ctx), "RESET search_path; RESET lock_timeout; RESET statement_timeout; RESET ROLE"); resetErr != nil && err == nil {
+			err = resetErr
+		}
+	}()
+
+	for _, setting := range settings {
+		if _, err := conn.ExecContext(ctx, setting); err != nil {
+			return err
+		}
+	}
+
+	return exec(conn)
+}
+
 func (p *PostgresDriver) End(ctx context.Context, err error) error {
+	if p.DryRun != nil {
+		if err != nil {
+			return p.dryRun("ROLLBACK")
+		}
+
+		return p.dryRun("COMMIT")
+	}
+
+	if p.external {
+		if err == nil && p.Logger != nil {
+			p.Logger.Info("migrations applied, transaction left to the caller")
+		}
+
+		return nil
+	}
+
 	if p.tx != nil {
+		tx := p.tx
+		p.tx = nil
+
 		if err != nil {
-			return p.tx.Rollback()
+			return tx.Rollback()
 		}
 
 		if p.Logger != nil {
 			p.Logger.Info("migrations applied successfully")
 		}
 
-		return p.tx.Commit()
+		return tx.Commit()
+	}
+
+	return nil
+}
+
+func (p *PostgresDriver) History(ctx context.Context) ([]Record, error) {
+	if p.DryRun != nil && p.DB == nil {
+		return nil, nil
+	}
+
+	if len(p.Schemas) > 0 {
+		return p.schemaHistory(ctx)
+	}
+
+	return p.history(ctx)
+}
+
+// history returns the records of the tracking table, nothing when it does not exist yet.
+func (p *PostgresDriver) history(ctx context.Context) ([]Record, error) {
+	var q querier = p.DB
+	if p.tx != nil {
+		q = p.tx
+	}
+
+	var exists bool
+	if err := q.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", p.tableName()).Scan(&exists); err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, nil
+	}
+
+	return queryPostgresHistory(ctx, q, p.TableSchema.history(p.tableName()))
+}
+
+func (p *PostgresDriver) QueryRows(ctx context.Context, query string, row func(scan func(dest ...any) error) error) error {
+	if p.DryRun != nil && p.DB == nil {
+		return nil
+	}
+
+	var q querier = p.DB
+	if p.tx != nil {
+		q = p.tx
+	}
+
+	return queryRows(ctx, q, query, row)
+}
+
+func (p *PostgresDriver) Exec(ctx context.Context, content []byte) error {
+	return p.eachSchema(ctx, func() error {
+		if p.DryRun != nil {
+			return p.dryRun(string(content))
+		}
+
+		if p.tx == nil {
+			return p.execConn(ctx, func(q querier) error {
+				_, err := q.ExecContext(ctx, string(content))
+				return err
+			})
+		}
+
+		_, err := p.tx.ExecContext(ctx, string(content))
+		return err
+	})
+}
+
+func (p *PostgresDriver) Record(ctx context.Context, dir string, file FileInfo) error {
+	return p.eachSchema(ctx, func() error {
+		if p.DryRun != nil {
+			return p.dryRun(p.TableSchema.upsert(p.tableName()), file.Version, dir, file.Path)
+		}
+
+		if _, err := p.tx.ExecContext(ctx, p.TableSchema.upsert(p.tableName()), file.Version, dir, file.Path); err != nil {
+			return err
+		}
+
+		return p.storeProcessedAt(ctx, p.tx, dir, file.Version)
+	})
+}
+
+// storeProcessedAt sets processed_at of a record to the time of Now, the database sets it without Now.
+func (p *PostgresDriver) storeProcessedAt(ctx context.Context, q execer, dir string, version int64) error {
+	if p.Now == nil {
+		return nil
+	}
+
+	_, err := q.ExecContext(ctx, postgresProcessedAt(p.tableName()), dir, version, p.Now())
+	return err
+}
+
+func (p *PostgresDriver) StoreAudit(ctx context.Context, dir string, version int64, audit Audit) error {
+	return p.eachSchema(ctx, func() error {
+		args := []any{dir, version, audit.AppliedBy, audit.Hostname, audit.AppVersion, audit.GitSHA}
+		if p.DryRun != nil {
+			return p.dryRun(postgresAudit(p.tableName()), args...)
+		}
+
+		_, err := p.tx.ExecContext(ctx, postgresAudit(p.tableName()), args...)
+		return err
+	})
+}
+
+func (p *PostgresDriver) StoreChecksum(ctx context.Context, dir string, version int64, checksum string) error {
+	return p.eachSchema(ctx, func() error {
+		if p.DryRun != nil {
+			return p.dryRun(postgresChecksum(p.tableName()), dir, version, checksum)
+		}
+
+		_, err := p.tx.ExecContext(ctx, postgresChecksum(p.tableName()), dir, version, checksum)
+		return err
+	})
+}
+
+func (p *PostgresDriver) Unrecord(ctx context.Context, dir string, version int64) error {
+	return p.eachSchema(ctx, func() error {
+		return p.unrecord(ctx, dir, version)
+	})
+}
+
+func (p *PostgresDriver) unrecord(ctx context.Context, dir string, version int64) error {
+	if p.DryRun != nil {
+		return p.dryRun(p.deleteQuery(), dir, version)
+	}
+
+	_, err := p.tx.ExecContext(ctx, p.deleteQuery(), dir, version)
+	return err
+}
+
+func (p *PostgresDriver) deleteQuery() string {
+	return fmt.Sprintf(`
+		DELETE FROM %s WHERE directory = $1 AND version = $2
+	`, p.tableName())
+}
+
+func (p *PostgresDriver) Rollback(ctx context.Context, data *Muzo) error {
+	return p.eachSchema(ctx, func() error {
+		if p.DryRun != nil {
+			return p.dryRollback(data)
+		}
+
+		return p.rollback(ctx, data)
+	})
+}
+
+// rollback executes the rollback files of data and removes their records.
+func (p *PostgresDriver) rollback(ctx context.Context, data *Muzo) error {
+	for _, file := range data.Files {
+		if p.Logger != nil {
+			p.Logger.Info("rolling back migration", "version", file.Version, "directory", data.Dir, "file", file.Path)
+		}
+
+		if err := execRollback(ctx, p.tx, data, file, p.execOptions(p.tx)); err != nil {
+			return fmt.Errorf("rolling back migration %d - %s - %s: %w", file.Version, data.Dir, file.Path, err)
+		}
+
+		if err := p.unrecord(ctx, data.Dir, file.Version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *PostgresDriver) Dirty(ctx context.Context) ([]DirtyRecord, error) {
+	if p.DryRun != nil && p.DB == nil {
+		return nil, nil
+	}
+
+	var records []DirtyRecord
+	err := p.eachSchema(ctx, func() error {
+		q, exists, err := p.tableExists(ctx, p.relation(dirtySuffix))
+		if err != nil {
+			return err
+		}
+
+		schemaRecords, err := queryDirty(ctx, q, p.relation(dirtySuffix), exists)
+		for _, r := range schemaRecords {
+			// Every schema of Schemas is marked by a failed run.
+			if !slices.ContainsFunc(records, func(d DirtyRecord) bool { return d.Directory == r.Directory }) {
+				records = append(records, r)
+			}
+		}
+
+		return err
+	})
+
+	return records, err
+}
+
+// MarkDirty records r in a new session on DB, the caller owned transaction
+// of NewPostgresTxDriver is not marked.
+func (p *PostgresDriver) MarkDirty(ctx context.Context, r DirtyRecord) error {
+	if p.DryRun != nil || p.DB == nil {
+		return nil
+	}
+
+	if p.Now != nil {
+		r.FailedAt = p.Now()
+	}
+
+	return p.eachSchema(ctx, func() error {
+		return markDirtySQL(ctx, p.DB, p.relation(dirtySuffix), PostgresDialect{}.Placeholder, r)
+	})
+}
+
+func (p *PostgresDriver) ClearDirty(ctx context.Context, dir string) error {
+	return p.eachSchema(ctx, func() error {
+		_, remove, _ := dirtyQueries(p.relation(dirtySuffix), PostgresDialect{}.Placeholder)
+		if p.DryRun != nil {
+			return p.dryRun(remove, dir)
+		}
+
+		q, exists, err := p.tableExists(ctx, p.relation(dirtySuffix))
+		if err != nil || !exists {
+			return err
+		}
+
+		_, err = q.ExecContext(ctx, remove, dir)
+		return err
+	})
+}
+
+func (p *PostgresDriver) Seeds(ctx context.Context) ([]SeedRecord, error) {
+	if p.DryRun != nil && p.DB == nil {
+		return nil, nil
+	}
+
+	var (
+		records []SeedRecord
+		schemas int
+	)
+
+	seen := make(map[SeedRecord]int)
+	err := p.eachSchema(ctx, func() error {
+		schemas++
+
+		q, exists, err := p.tableExists(ctx, p.relation(seedSuffix))
+		if err != nil {
+			return err
+		}
+
+		schemaRecords, err := querySeeds(ctx, q, p.relation(seedSuffix), exists)
+		for _, r := range schemaRecords {
+			key := SeedRecord{Directory: r.Directory, FileName: r.FileName, Checksum: r.Checksum}
+			if seen[key]++; seen[key] == 1 {
+				records = append(records, r)
+			}
+		}
+
+		return err
+	})
+
+	// A seed runs again unless every schema of Schemas holds it with the same checksum.
+	records = slices.DeleteFunc(records, func(r SeedRecord) bool {
+		return seen[SeedRecord{Directory: r.Directory, FileName: r.FileName, Checksum: r.Checksum}] != schemas
+	})
+
+	return records, err
+}
+
+func (p *PostgresDriver) StoreSeed(ctx context.Context, r SeedRecord) error {
+	if p.Now != nil {
+		r.AppliedAt = p.Now()
+	}
+
+	return p.eachSchema(ctx, func() error {
+		table := p.relation(seedSuffix)
+		if p.DryRun != nil {
+			_, remove, insert := seedQueries(table, PostgresDialect{}.Placeholder)
+			if err := p.dryRun(seedCreateTable(table)); err != nil {
+				return err
+			}
+
+			if err := p.dryRun(remove, r.Directory, r.FileName); err != nil {
+				return err
+			}
+
+			return p.dryRun(insert, r.Directory, r.FileName, r.Checksum, r.AppliedAt.UTC())
+		}
+
+		q, _, err := p.tableExists(ctx, table)
+		if err != nil {
+			return err
+		}
+
+		return storeSeedSQL(ctx, q, table, PostgresDialect{}.Placeholder, r)
+	})
+}
+
+// tableExists returns the querier of the session and whether table exists.
+func (p *PostgresDriver) tableExists(ctx context.Context, table string) (querier, bool, error) {
+	var q querier = p.DB
+	if p.tx != nil {
+		q = p.tx
+	}
+
+	var exists bool
+	err := q.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists)
+
+	return q, exists, err
+}
+
+func (p *PostgresDriver) RecordFailure(ctx context.Context, f Failure) error {
+	if p.DryRun != nil || p.DB == nil {
+		return nil
+	}
+
+	if p.Now != nil {
+		f.FailedAt = p.Now()
+	}
+
+	return p.eachSchema(ctx, func() error {
+		return recordFailureSQL(ctx, p.DB, p.relation(failureSuffix), PostgresDialect{}.Placeholder, f)
+	})
+}
+
+func (p *PostgresDriver) Failures(ctx context.Context) ([]Failure, error) {
+	if p.DryRun != nil && p.DB == nil {
+		return nil, nil
+	}
+
+	var failures []Failure
+	err := p.eachSchema(ctx, func() error {
+		q, exists, err := p.tableExists(ctx, p.relation(failureSuffix))
+		if err != nil {
+			return err
+		}
+
+		schemaFailures, err := queryFailures(ctx, q, p.relation(failureSuffix), exists)
+		failures = append(failures, schemaFailures...)
+
+		return err
+	})
+
+	return failures, err
+}
+
+// queryPostgresHistory reads the records of the tracking table with the audit columns using query.
+func queryPostgresHistory(ctx context.Context, q querier, query string) ([]Record, error) {
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for rows.Next() {
+		var (
+			r  Record
+			ms int64
+		)
+
+		if err := rows.Scan(historyDest(&r, &ms, len(columns))...); err != nil {
+			return nil, err
+		}
+
+		r.Duration = time.Duration(ms) * time.Millisecond
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
+
+func (p *PostgresDriver) WaitForDB(ctx context.Context, timeout time.Duration) error {
+	return waitForDB(ctx, timeout, p.Logger, p.DB.PingContext)
+}
+
+// CheckSyntax has the database parse every statement of content without running it: the statements are
+// only prepared, in a read-only transaction that is always rolled back. Queries are prepared as the body
+// of a PREPARE statement, so they are parsed without looking up tables created by earlier pending files.
+func (p *PostgresDriver) CheckSyntax(ctx context.Context, content []byte) error {
+	if p.DB == nil {
+		return fmt.Errorf("check syntax: %w", ErrNotSupported)
+	}
+
+	tx, err := p.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	// Nothing of the check is kept.
+	defer func() { _ = tx.Rollback() }()
+
+	for i, statement := range splitStatements(string(content), true) {
+		// The inline data of COPY is not SQL.
+		if command, _, ok := cutCopy(statement); ok {
+			statement = command
+		}
+
+		if err := parseStatement(ctx, tx, statement); err != nil {
+			return fmt.Errorf("statement %d: %w", i+1, err)
+		}
 	}
 
 	return nil
 }
 
+// wait waits for DB when WaitTimeout is set.
+func (p *PostgresDriver) wait(ctx context.Context) error {
+	if p.WaitTimeout <= 0 || p.DB == nil {
+		return nil
+	}
+
+	return p.WaitForDB(ctx, p.WaitTimeout)
+}
+
+// Clone returns a driver with the settings of p running a transaction of its own on DB, see Cloner.
+// The clone shares DB with p and must not be closed.
+func (p *PostgresDriver) Clone() (Driver, error) {
+	switch {
+	case p.external:
+		return nil, errors.New("clone: caller owned transaction")
+	case p.DryRun != nil:
+		return nil, errors.New("clone: dry run")
+	}
+
+	c := *p
+	c.tx, c.schema, c.lockConn = nil, "", nil
+
+	return &c, nil
+}
+
+// Close closes the database connection.
+// Drivers returned by OpenDriver own their connection and should be closed by the caller.
+func (p *PostgresDriver) Close() error {
+	if p.DB == nil {
+		return nil
+	}
+
+	return p.DB.Close()
+}
+
 // //////////////////////////////
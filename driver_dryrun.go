@@ -0,0 +1,179 @@
+package muz
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dryRun writes a statement of the exported script to DryRun.
+// Placeholders like $1 are replaced by the literal arguments, migration contents are written as is.
+func (p *PostgresDriver) dryRun(query string, args ...any) error {
+	if len(args) > 0 {
+		query = strings.Join(strings.Fields(query), " ")
+
+		for i := len(args); i > 0; i-- {
+			query = strings.ReplaceAll(query, "$"+strconv.Itoa(i), sqlLiteral(args[i-1]))
+		}
+	}
+
+	query = strings.TrimSpace(query)
+	if !strings.HasSuffix(query, ";") {
+		query += ";"
+	}
+
+	_, err := fmt.Fprintf(p.DryRun, "%s\n\n", query)
+	return err
+}
+
+// dryProcess writes the files above the latest applied version of the directory with their tracking inserts.
+// The applied versions are read from DB when it is set, without changing the database.
+func (p *PostgresDriver) dryProcess(ctx context.Context, data *Muzo) error {
+	version, err := p.dryVersion(ctx, data.Dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range data.Files {
+		if file.Version <= version && !data.OutOfOrder() {
+			continue // already applied
+		}
+
+		if data.GoMigration(file.Path) != nil {
+			return fmt.Errorf("exporting migration %d - %s - %s: go migrations cannot be exported as SQL", file.Version, data.Dir, file.Path)
+		}
+
+		content, err := data.ReadFile(file.Path)
+		if err != nil {
+			return err
+		}
+
+		noTx, err := noTransaction(data, file)
+		if err != nil {
+			return err
+		}
+
+		if noTx {
+			if err := p.dryRun("COMMIT"); err != nil {
+				return err
+			}
+
+			for _, setting := range p.settings(false) {
+				if err := p.dryRun(setting); err != nil {
+					return err
+				}
+			}
+		}
+
+		if _, err := fmt.Fprintf(p.DryRun, "-- %s (version %d)\n", path.Join(data.Dir, file.Path), file.Version); err != nil {
+			return err
+		}
+
+		if err := p.dryRun(string(content)); err != nil {
+			return err
+		}
+
+		if err := p.dryRun(p.TableSchema.insert(p.tableName()), file.Version, data.Dir, file.Path, file.Description, 0); err != nil {
+			return err
+		}
+
+		if p.Now != nil {
+			if err := p.dryRun(postgresProcessedAt(p.tableName()), data.Dir, file.Version, p.Now()); err != nil {
+				return err
+			}
+		}
+
+		if noTx {
+			if len(p.settings(false)) > 0 {
+				if err := p.dryRun("RESET lock_timeout; RESET statement_timeout; RESET ROLE"); err != nil {
+					return err
+				}
+			}
+
+			if err := p.dryRun("BEGIN"); err != nil {
+				return err
+			}
+
+			if err := p.setSettings(ctx); err != nil {
+				return err
+			}
+		}
+
+		version = file.Version
+	}
+
+	return nil
+}
+
+// dryVersion returns the latest applied version of dir, zero without DB or tracking table.
+func (p *PostgresDriver) dryVersion(ctx context.Context, dir string) (int64, error) {
+	if p.DB == nil {
+		return 0, nil
+	}
+
+	var exists bool
+	if err := p.DB.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", p.tableName()).Scan(&exists); err != nil {
+		return 0, err
+	}
+
+	if !exists {
+		return 0, nil
+	}
+
+	var latest sql.NullInt64
+	if err := p.DB.QueryRowContext(ctx, fmt.Sprintf("SELECT MAX(version) FROM %s WHERE directory = $1", p.tableName()), dir).Scan(&latest); err != nil {
+		return 0, err
+	}
+
+	return latest.Int64, nil
+}
+
+// dryRollback writes the rollback files with their tracking deletes.
+func (p *PostgresDriver) dryRollback(data *Muzo) error {
+	for _, file := range data.Files {
+		if data.GoMigration(file.Path) != nil {
+			return fmt.Errorf("exporting rollback %d - %s - %s: go migrations cannot be exported as SQL", file.Version, data.Dir, file.Path)
+		}
+
+		content, err := data.ReadFile(file.Path)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(p.DryRun, "-- %s (rollback version %d)\n", path.Join(data.Dir, file.Path), file.Version); err != nil {
+			return err
+		}
+
+		if err := p.dryRun(string(content)); err != nil {
+			return err
+		}
+
+		if err := p.dryRun(p.deleteQuery(), data.Dir, file.Version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sqlLiteral returns v as a SQL literal.
+func sqlLiteral(v any) string {
+	switch v := v.(type) {
+	case string:
+		return quoteString(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case time.Time:
+		return quoteString(v.Format(time.RFC3339Nano))
+	case nil:
+		return "NULL"
+	default:
+		return quoteString(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,174 @@
+package muz
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPostgresDriverDryRun(t *testing.T) {
+	m := Migrate{FS: MapFS(map[string]string{
+		"migrations/app/001_users.sql": "CREATE TABLE users();",
+		"migrations/app/002_index.sql": "-- muz:no-transaction\nCREATE INDEX CONCURRENTLY users_idx ON users (id);",
+	})}
+
+	var out bytes.Buffer
+	if _, err := m.Migrate(context.Background(), &PostgresDriver{DryRun: &out}); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	want := []string{
+		"BEGIN;",
+		"-- app/001_users.sql (version 1)\nCREATE TABLE users();",
+		"ADD COLUMN IF NOT EXISTS applied_by text NOT NULL DEFAULT current_user,",
+		"ADD COLUMN IF NOT EXISTS git_sha text NOT NULL DEFAULT '';",
+		"INSERT INTO \"migrations\" (version, directory, file_name, description, execution_ms) VALUES (1, 'app', '001_users.sql', '', 0);",
+		"COMMIT;\n\n-- app/002_index.sql (version 2)\n-- muz:no-transaction\nCREATE INDEX CONCURRENTLY users_idx ON users (id);",
+		"VALUES (2, 'app', '002_index.sql', '', 0);",
+		"BEGIN;\n\nUPDATE \"migrations\" SET checksum = '",
+	}
+
+	got := out.String()
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("dry run output missing %q, got:\n%s", w, got)
+		}
+	}
+
+	if !strings.HasSuffix(got, "COMMIT;\n\n") {
+		t.Errorf("dry run output does not end with COMMIT, got:\n%s", got)
+	}
+}
+
+func TestPostgresDriverDryRunError(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{"migrations/app/001_users.sql": "CREATE TABLE users();"}),
+		Hooks: Hooks{BeforeFile: func(context.Context, string, FileInfo) error {
+			return errors.New("boom")
+		}},
+	}
+
+	var out bytes.Buffer
+	if _, err := m.Migrate(context.Background(), &PostgresDriver{DryRun: &out}); err == nil {
+		t.Fatal("Migrate() error = nil, want the hook error")
+	}
+
+	// The script started a transaction, it must not be left open.
+	if got := out.String(); !strings.HasPrefix(got, "BEGIN;") || !strings.HasSuffix(got, "ROLLBACK;\n\n") {
+		t.Errorf("dry run output does not end with ROLLBACK, got:\n%s", got)
+	}
+}
+
+func TestPostgresDriverTableSchema(t *testing.T) {
+	m := Migrate{FS: MapFS(map[string]string{"migrations/app/001_users.sql": "CREATE TABLE users();"})}
+
+	var out bytes.Buffer
+	driver := &PostgresDriver{
+		DryRun: &out,
+		TableSchema: TableSchema{
+			CreateTable: func(string) string { return "" },
+			Insert: func(table string) string {
+				return "INSERT INTO " + table + " (version, directory, file_name, description, execution_ms, tenant_id) VALUES ($1, $2, $3, $4, $5, current_setting('app.tenant'))"
+			},
+		},
+	}
+
+	if _, err := m.Migrate(context.Background(), driver); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	got := out.String()
+	if strings.Contains(got, "CREATE TABLE IF NOT EXISTS") {
+		t.Errorf("dry run output creates the tracking table, got:\n%s", got)
+	}
+
+	if want := `INSERT INTO "migrations" (version, directory, file_name, description, execution_ms, tenant_id) VALUES (1, 'app', '001_users.sql', '', 0, current_setting('app.tenant'));`; !strings.Contains(got, want) {
+		t.Errorf("dry run output missing %q, got:\n%s", want, got)
+	}
+}
+
+func TestPostgresDriverNow(t *testing.T) {
+	m := Migrate{FS: MapFS(map[string]string{"migrations/app/001_users.sql": "CREATE TABLE users();"})}
+
+	var out bytes.Buffer
+	driver := &PostgresDriver{
+		DryRun: &out,
+		Now:    func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) },
+	}
+
+	if _, err := m.Migrate(context.Background(), driver); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	if want := `UPDATE "migrations" SET processed_at = '2024-01-02T03:04:05Z' WHERE directory = 'app' AND version = 1;`; !strings.Contains(out.String(), want) {
+		t.Errorf("dry run output missing %q, got:\n%s", want, out.String())
+	}
+}
+
+func TestPostgresDriverSettings(t *testing.T) {
+	m := Migrate{FS: MapFS(map[string]string{
+		"migrations/app/001_users.sql": "CREATE TABLE users();",
+		"migrations/app/002_index.sql": "-- muz:no-transaction\nCREATE INDEX CONCURRENTLY users_idx ON users (id);",
+	})}
+
+	var out bytes.Buffer
+	driver := &PostgresDriver{DryRun: &out, Role: "app_owner", LockTimeout: 5 * time.Second, StatementTimeout: time.Minute}
+	if _, err := m.Migrate(context.Background(), driver); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	settings := "BEGIN;\n\nSET LOCAL ROLE \"app_owner\";\n\nSET LOCAL lock_timeout = 5000;\n\nSET LOCAL statement_timeout = 60000;\n\n"
+
+	got := out.String()
+	if !strings.HasPrefix(got, settings) {
+		t.Errorf("dry run output does not start with the settings, got:\n%s", got)
+	}
+
+	// The transaction started again after the no-transaction file gets them too.
+	if n := strings.Count(got, settings); n != 2 {
+		t.Errorf("settings applied %d times, want 2, got:\n%s", n, got)
+	}
+
+	// The no-transaction file runs with the session settings.
+	if want := "COMMIT;\n\nSET ROLE \"app_owner\";\n\nSET lock_timeout = 5000;"; !strings.Contains(got, want) {
+		t.Errorf("dry run output missing %q, got:\n%s", want, got)
+	}
+}
+
+func TestPostgresDriverAudit(t *testing.T) {
+	m := Migrate{
+		FS:    MapFS(map[string]string{"migrations/app/001_users.sql": "CREATE TABLE users();"}),
+		Audit: Audit{AppliedBy: "ci", Hostname: "runner-1", AppVersion: "v1.2.0", GitSHA: "4f2a9c1"},
+	}
+
+	var out bytes.Buffer
+	if _, err := m.Migrate(context.Background(), &PostgresDriver{DryRun: &out}); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	want := "UPDATE \"migrations\" SET applied_by = COALESCE(NULLIF('ci', ''), applied_by), hostname = 'runner-1', app_version = 'v1.2.0', git_sha = '4f2a9c1' WHERE directory = 'app' AND version = 1;"
+	if got := out.String(); !strings.Contains(got, want) {
+		t.Errorf("dry run output missing %q, got:\n%s", want, got)
+	}
+}
+
+func TestSQLLiteral(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{value: 3, want: "3"},
+		{value: int64(20240101), want: "20240101"},
+		{value: "it's", want: "'it''s'"},
+		{value: nil, want: "NULL"},
+	}
+
+	for _, tt := range tests {
+		if got := sqlLiteral(tt.value); got != tt.want {
+			t.Errorf("sqlLiteral(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package muz
+
+import (
+	"context"
+	"database/sql/driver"
+	"hash/fnv"
+)
+
+// Lock takes a session level advisory lock keyed by the tracking table name on a dedicated
+// connection, so replicas starting at the same time apply the migrations one after the other.
+// Caller owned transactions and dry runs are not locked.
+func (p *PostgresDriver) Lock(ctx context.Context) error {
+	if p.DB == nil || p.DryRun != nil {
+		return nil
+	}
+
+	if err := p.wait(ctx); err != nil {
+		return err
+	}
+
+	conn, err := p.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	if p.Logger != nil {
+		p.Logger.Info("waiting for migration lock", "table", p.tableName())
+	}
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", p.lockKey()); err != nil {
+		conn.Close()
+		return err
+	}
+
+	p.lockConn = conn
+
+	return nil
+}
+
+func (p *PostgresDriver) Unlock(ctx context.Context) error {
+	if p.lockConn == nil {
+		return nil
+	}
+
+	conn := p.lockConn
+	p.lockConn = nil
+
+	// Unlock even when the run was canceled.
+	if _, err := conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", p.lockKey()); err != nil {
+		// Do not return a connection still holding the lock to the pool.
+		_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		conn.Close()
+
+		return err
+	}
+
+	return conn.Close()
+}
+
+// lockKey returns the advisory lock key of the tracking table, shared by all schemas of Schemas.
+// The resolved name is hashed, so spellings of the same table like "ops.migrations" and Schema "ops" share the lock.
+func (p *PostgresDriver) lockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte("muz:" + postgresIdent(p.Schema, withDefault(p.Table, "migrations"), "")))
+
+	return int64(h.Sum64())
+}
@@ -0,0 +1,577 @@
+package muz
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// PgxConn is the connection used by PgxDriver, satisfied by *pgxpool.Pool and *pgx.Conn.
+type PgxConn interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+
+	pgxQuerier
+}
+
+// pgxPinger is implemented by pgx pools and connections.
+type pgxPinger interface {
+	Ping(ctx context.Context) error
+}
+
+// pgxQuerier is the common query part of pgx connections and transactions.
+type pgxQuerier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// PgxDriver is a PostgreSQL driver using the native pgx API instead of database/sql.
+type PgxDriver struct {
+	// DB is the pgx pool or connection to use for migrations.
+	DB PgxConn
+	// Table is the name of the migration tracking table.
+	//  - Default: "migrations"
+	//  - Quoted as an identifier and folded to lower case, "ops.migrations" is split into schema and table.
+	//  - Double quoted parts like ops."Migrations" keep their case.
+	Table string
+	// Schema if set, is the schema of the tracking table, for tables outside of the search_path like "ops"."migrations".
+	// It is quoted as given.
+	Schema string
+	// TableSchema if set, overrides the statements of the tracking table.
+	TableSchema TableSchema
+	// Logger if set, used to log migration progress.
+	Logger Logger
+	// WaitTimeout if set, Start first waits up to WaitTimeout for DB to answer, see WaitForDB.
+	//  - Default: 0, a database not reachable fails the run at once.
+	WaitTimeout time.Duration
+	// CheckPrivileges if set, Start first verifies the role can create objects in its schema and owns
+	// the tracking table, failing with ErrPrivileges instead of halfway through a transaction.
+	CheckPrivileges bool
+	// Now if set, is the clock of processed_at, execution_ms and the seed, dirty and failure times, like a fixed time
+	// for tests asserting exact tracking rows.
+	//  - Default: time.Now, processed_at is set by the database.
+	Now func() time.Time
+
+	// tx is the current transaction, if any.
+	tx pgx.Tx
+}
+
+// tableName returns the quoted name of the tracking table.
+func (p *PgxDriver) tableName() string {
+	return p.relation("")
+}
+
+// relation returns the quoted name of the tracking table with suffix appended, like the dirty table.
+func (p *PgxDriver) relation(suffix string) string {
+	return postgresIdent(p.Schema, withDefault(p.Table, "migrations"), suffix)
+}
+
+func (p *PgxDriver) Start(ctx context.Context) error {
+	if err := p.wait(ctx); err != nil {
+		return err
+	}
+
+	var err error
+	p.tx, err = p.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if p.Logger != nil {
+		p.Logger.Info("starting migration", "table", p.tableName())
+	}
+
+	if p.CheckPrivileges {
+		if err := checkPostgresPrivileges(p.tableName(), p.tx.QueryRow(ctx, postgresPrivileges, p.tableName()).Scan); err != nil {
+			return err
+		}
+	}
+
+	if ddl := p.TableSchema.createTable(p.tableName()); ddl != "" {
+		if _, err := p.tx.Exec(ctx, ddl); err != nil {
+			return err
+		}
+	}
+
+	var exists bool
+	if err := p.tx.QueryRow(ctx, postgresColumnsExist, p.tableName()).Scan(&exists); err != nil || exists {
+		return err
+	}
+
+	_, err = p.tx.Exec(ctx, postgresColumns(p.tableName()))
+	return err
+}
+
+// WaitForDB pings DB, with its Ping method when it has one like pgxpool.Pool, with "SELECT 1" otherwise.
+func (p *PgxDriver) WaitForDB(ctx context.Context, timeout time.Duration) error {
+	return waitForDB(ctx, timeout, p.Logger, func(ctx context.Context) error {
+		if pinger, ok := p.DB.(pgxPinger); ok {
+			return pinger.Ping(ctx)
+		}
+
+		_, err := p.DB.Exec(ctx, "SELECT 1")
+		return err
+	})
+}
+
+// Clone returns a driver with the settings of p running a transaction of its own on DB, see Cloner.
+// DB must be a pool like pgxpool.Pool, a single pgx.Conn cannot run transactions at the same time.
+func (p *PgxDriver) Clone() (Driver, error) {
+	if _, ok := p.DB.(*pgx.Conn); ok {
+		return nil, errors.New("clone: a single pgx.Conn cannot run sessions at the same time")
+	}
+
+	c := *p
+	c.tx = nil
+
+	return &c, nil
+}
+
+// wait waits for DB when WaitTimeout is set.
+func (p *PgxDriver) wait(ctx context.Context) error {
+	if p.WaitTimeout <= 0 {
+		return nil
+	}
+
+	return p.WaitForDB(ctx, p.WaitTimeout)
+}
+
+func (p *PgxDriver) Process(ctx context.Context, data *Muzo) error {
+	directory := data.Dir
+	var version int64
+
+	// Get latest applied version for the directory
+	query := fmt.Sprintf(`
+		SELECT MAX(version) FROM %s WHERE directory = $1
+	`, p.tableName())
+
+	var latestVersion *int64
+	if err := p.tx.QueryRow(ctx, query, directory).Scan(&latestVersion); err != nil {
+		return err
+	}
+	if latestVersion != nil {
+		version = *latestVersion
+	}
+
+	// Tracking inserts are sent in one round trip after all files succeeded.
+	batch := &pgx.Batch{}
+	insert := p.TableSchema.insert(p.tableName())
+
+	for _, file := range data.Files {
+		if file.Version <= version && !data.OutOfOrder() {
+			continue // already applied
+		}
+
+		if data.GoMigration(file.Path) != nil {
+			return fmt.Errorf("applying migration %d - %s - %s: go migrations need a database/sql driver", file.Version, directory, file.Path)
+		}
+
+		if p.Logger != nil {
+			p.Logger.Info("applying migration", "version", file.Version, "directory", directory, "file", file.Path)
+		}
+
+		noTx, err := noTransaction(data, file)
+		if err != nil {
+			return err
+		}
+
+		if noTx {
+			if err := p.processNoTx(ctx, batch, insert, data, file); err != nil {
+				return fmt.Errorf("applying migration %d - %s - %s: %w", file.Version, directory, file.Path, err)
+			}
+
+			batch = &pgx.Batch{}
+			version = file.Version
+			continue
+		}
+
+		started := clock(p.Now)
+		if err := execContent(ctx, pgxExecer{p.tx}, data, file.Path, p.execOptions(p.tx)); err != nil {
+			return fmt.Errorf("applying migration %d - %s - %s: %w", file.Version, directory, file.Path, err)
+		}
+
+		batch.Queue(insert, file.Version, directory, file.Path, file.Description, clock(p.Now).Sub(started).Milliseconds())
+		if p.Now != nil {
+			batch.Queue(postgresProcessedAt(p.tableName()), directory, file.Version, p.Now())
+		}
+
+		version = file.Version
+	}
+
+	if batch.Len() == 0 {
+		return nil
+	}
+
+	return p.tx.SendBatch(ctx, batch).Close()
+}
+
+// processNoTx sends the pending tracking inserts, commits the work done so far,
+// applies content directly on the connection and starts a new transaction for the remaining files.
+func (p *PgxDriver) processNoTx(ctx context.Context, batch *pgx.Batch, insert string, data *Muzo, file FileInfo) error {
+	if batch.Len() > 0 {
+		if err := p.tx.SendBatch(ctx, batch).Close(); err != nil {
+			return err
+		}
+	}
+
+	tx := p.tx
+	p.tx = nil
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	started := clock(p.Now)
+	if err := execContent(ctx, pgxExecer{p.DB}, data, file.Path, p.execOptions(p.DB)); err != nil {
+		return err
+	}
+
+	if _, err := p.DB.Exec(ctx, insert, file.Version, data.Dir, file.Path, file.Description, clock(p.Now).Sub(started).Milliseconds()); err != nil {
+		return err
+	}
+
+	if err := p.storeProcessedAt(ctx, p.DB, data.Dir, file.Version); err != nil {
+		return err
+	}
+
+	var err error
+	p.tx, err = p.DB.Begin(ctx)
+	return err
+}
+
+func (p *PgxDriver) End(ctx context.Context, err error) error {
+	if p.tx != nil {
+		tx := p.tx
+		p.tx = nil
+
+		if err != nil {
+			return tx.Rollback(ctx)
+		}
+
+		if p.Logger != nil {
+			p.Logger.Info("migrations applied successfully")
+		}
+
+		return tx.Commit(ctx)
+	}
+
+	return nil
+}
+
+func (p *PgxDriver) History(ctx context.Context) ([]Record, error) {
+	var q pgxQuerier = p.DB
+	if p.tx != nil {
+		q = p.tx
+	}
+
+	var exists bool
+	if err := q.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", p.tableName()).Scan(&exists); err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, nil
+	}
+
+	rows, err := q.Query(ctx, p.TableSchema.history(p.tableName()))
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Record, error) {
+		var (
+			r  Record
+			ms int64
+		)
+
+		err := row.Scan(historyDest(&r, &ms, len(row.FieldDescriptions()))...)
+		r.Duration = time.Duration(ms) * time.Millisecond
+
+		return r, err
+	})
+}
+
+func (p *PgxDriver) QueryRows(ctx context.Context, query string, row func(scan func(dest ...any) error) error) error {
+	var q pgxQuerier = p.DB
+	if p.tx != nil {
+		q = p.tx
+	}
+
+	rows, err := q.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := row(rows.Scan); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (p *PgxDriver) Exec(ctx context.Context, content []byte) error {
+	var q pgxQuerier = p.DB
+	if p.tx != nil {
+		q = p.tx
+	}
+
+	_, err := q.Exec(ctx, string(content))
+	return err
+}
+
+func (p *PgxDriver) Record(ctx context.Context, dir string, file FileInfo) error {
+	if _, err := p.tx.Exec(ctx, p.TableSchema.upsert(p.tableName()), file.Version, dir, file.Path); err != nil {
+		return err
+	}
+
+	return p.storeProcessedAt(ctx, p.tx, dir, file.Version)
+}
+
+// storeProcessedAt sets processed_at of a record to the time of Now, the database sets it without Now.
+func (p *PgxDriver) storeProcessedAt(ctx context.Context, q pgxQuerier, dir string, version int64) error {
+	if p.Now == nil {
+		return nil
+	}
+
+	_, err := q.Exec(ctx, postgresProcessedAt(p.tableName()), dir, version, p.Now())
+	return err
+}
+
+func (p *PgxDriver) StoreAudit(ctx context.Context, dir string, version int64, audit Audit) error {
+	_, err := p.tx.Exec(ctx, postgresAudit(p.tableName()), dir, version, audit.AppliedBy, audit.Hostname, audit.AppVersion, audit.GitSHA)
+	return err
+}
+
+func (p *PgxDriver) StoreChecksum(ctx context.Context, dir string, version int64, checksum string) error {
+	_, err := p.tx.Exec(ctx, postgresChecksum(p.tableName()), dir, version, checksum)
+	return err
+}
+
+func (p *PgxDriver) Unrecord(ctx context.Context, dir string, version int64) error {
+	_, err := p.tx.Exec(ctx, fmt.Sprintf(`
+		DELETE FROM %s WHERE directory = $1 AND version = $2
+	`, p.tableName()), dir, version)
+	return err
+}
+
+func (p *PgxDriver) Rollback(ctx context.Context, data *Muzo) error {
+	for _, file := range data.Files {
+		if p.Logger != nil {
+			p.Logger.Info("rolling back migration", "version", file.Version, "directory", data.Dir, "file", file.Path)
+		}
+
+		if err := p.execRollback(ctx, data, file); err != nil {
+			return fmt.Errorf("rolling back migration %d - %s - %s: %w", file.Version, data.Dir, file.Path, err)
+		}
+
+		if err := p.Unrecord(ctx, data.Dir, file.Version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *PgxDriver) execRollback(ctx context.Context, data *Muzo, file FileInfo) error {
+	if data.GoMigration(file.Path) != nil {
+		return fmt.Errorf("go migrations need a database/sql driver")
+	}
+
+	return execContent(ctx, pgxExecer{p.tx}, data, file.Path, p.execOptions(p.tx))
+}
+
+// execOptions returns the options of execContent running the statements on q.
+// COPY FROM stdin statements stream their data with the COPY protocol, outside of a transaction
+// in a transaction of their own.
+func (p *PgxDriver) execOptions(q pgxQuerier) execOptions {
+	copyFrom := func(ctx context.Context, command, data string) error {
+		tx, err := p.DB.Begin(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := pgxCopy(tx.Conn().PgConn())(ctx, command, data); err != nil {
+			_ = tx.Rollback(ctx)
+			return err
+		}
+
+		return tx.Commit(ctx)
+	}
+
+	if tx, ok := q.(pgx.Tx); ok {
+		copyFrom = pgxCopy(tx.Conn().PgConn())
+	}
+
+	return execOptions{batch: defaultStreamSize, copy: copyFrom, logger: p.Logger, standard: true}
+}
+
+// pgxExecer is the execer of execContent on a pgx connection or transaction.
+// Without arguments pgx uses the simple protocol, allowing multiple statements.
+type pgxExecer struct {
+	q pgxQuerier
+}
+
+func (e pgxExecer) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	tag, err := e.q.Exec(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(tag.RowsAffected()), nil
+}
+
+func (p *PgxDriver) Dirty(ctx context.Context) ([]DirtyRecord, error) {
+	q, exists, err := p.tableExists(ctx, p.relation(dirtySuffix))
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	query, _, _ := dirtyQueries(p.relation(dirtySuffix), PostgresDialect{}.Placeholder)
+
+	rows, err := q.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (DirtyRecord, error) {
+		var r DirtyRecord
+		err := row.Scan(&r.Directory, &r.Version, &r.FileName, &r.Error, &r.FailedAt)
+
+		return r, err
+	})
+}
+
+func (p *PgxDriver) MarkDirty(ctx context.Context, r DirtyRecord) error {
+	if p.Now != nil {
+		r.FailedAt = p.Now()
+	}
+
+	table := p.relation(dirtySuffix)
+	if _, err := p.DB.Exec(ctx, dirtyCreateTable(table)); err != nil {
+		return err
+	}
+
+	_, remove, insert := dirtyQueries(table, PostgresDialect{}.Placeholder)
+	if _, err := p.DB.Exec(ctx, remove, r.Directory); err != nil {
+		return err
+	}
+
+	_, err := p.DB.Exec(ctx, insert, r.Directory, r.Version, r.FileName, r.Error, r.FailedAt.UTC())
+	return err
+}
+
+func (p *PgxDriver) RecordFailure(ctx context.Context, f Failure) error {
+	if p.Now != nil {
+		f.FailedAt = p.Now()
+	}
+
+	table := p.relation(failureSuffix)
+	if _, err := p.DB.Exec(ctx, failureCreateTable(table)); err != nil {
+		return err
+	}
+
+	_, insert := failureQueries(table, PostgresDialect{}.Placeholder)
+	_, err := p.DB.Exec(ctx, insert, failureArgs(f)...)
+	return err
+}
+
+func (p *PgxDriver) Failures(ctx context.Context) ([]Failure, error) {
+	table := p.relation(failureSuffix)
+
+	q, exists, err := p.tableExists(ctx, table)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	query, _ := failureQueries(table, PostgresDialect{}.Placeholder)
+
+	rows, err := q.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Failure, error) {
+		var (
+			f  Failure
+			ms int64
+		)
+
+		err := row.Scan(&f.Directory, &f.Version, &f.FileName, &f.Error, &ms, &f.FailedAt)
+		f.Duration = time.Duration(ms) * time.Millisecond
+
+		return f, err
+	})
+}
+
+func (p *PgxDriver) ClearDirty(ctx context.Context, dir string) error {
+	q, exists, err := p.tableExists(ctx, p.relation(dirtySuffix))
+	if err != nil || !exists {
+		return err
+	}
+
+	_, remove, _ := dirtyQueries(p.relation(dirtySuffix), PostgresDialect{}.Placeholder)
+	_, err = q.Exec(ctx, remove, dir)
+	return err
+}
+
+func (p *PgxDriver) Seeds(ctx context.Context) ([]SeedRecord, error) {
+	q, exists, err := p.tableExists(ctx, p.relation(seedSuffix))
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	query, _, _ := seedQueries(p.relation(seedSuffix), PostgresDialect{}.Placeholder)
+
+	rows, err := q.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (SeedRecord, error) {
+		var r SeedRecord
+		err := row.Scan(&r.Directory, &r.FileName, &r.Checksum, &r.AppliedAt)
+
+		return r, err
+	})
+}
+
+func (p *PgxDriver) StoreSeed(ctx context.Context, r SeedRecord) error {
+	if p.Now != nil {
+		r.AppliedAt = p.Now()
+	}
+
+	table := p.relation(seedSuffix)
+	if _, err := p.tx.Exec(ctx, seedCreateTable(table)); err != nil {
+		return err
+	}
+
+	_, remove, insert := seedQueries(table, PostgresDialect{}.Placeholder)
+	if _, err := p.tx.Exec(ctx, remove, r.Directory, r.FileName); err != nil {
+		return err
+	}
+
+	_, err := p.tx.Exec(ctx, insert, r.Directory, r.FileName, r.Checksum, r.AppliedAt.UTC())
+	return err
+}
+
+// tableExists returns the querier of the session and whether table exists.
+func (p *PgxDriver) tableExists(ctx context.Context, table string) (pgxQuerier, bool, error) {
+	var q pgxQuerier = p.DB
+	if p.tx != nil {
+		q = p.tx
+	}
+
+	var exists bool
+	err := q.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists)
+
+	return q, exists, err
+}
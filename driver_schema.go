@@ -0,0 +1,187 @@
+package muz
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// eachSchema runs fn once for every schema of Schemas with the search_path set to it
+// and the tracking table in it. Without Schemas fn runs once as is.
+func (p *PostgresDriver) eachSchema(ctx context.Context, fn func() error) error {
+	if len(p.Schemas) == 0 {
+		return fn()
+	}
+
+	defer func() { p.schema = "" }()
+
+	for _, schema := range p.Schemas {
+		p.schema = schema
+
+		if err := p.setSearchPath(ctx); err != nil {
+			return fmt.Errorf("schema %s: %w", schema, err)
+		}
+
+		if err := fn(); err != nil {
+			return fmt.Errorf("schema %s: %w", schema, err)
+		}
+	}
+
+	return nil
+}
+
+// setSearchPath points the search_path of the current transaction to the current schema.
+func (p *PostgresDriver) setSearchPath(ctx context.Context) error {
+	if p.schema == "" {
+		return nil
+	}
+
+	query := "SET LOCAL search_path TO " + quoteIdent(p.schema)
+
+	if p.DryRun != nil {
+		return p.dryRun(query)
+	}
+
+	if p.tx == nil {
+		return nil
+	}
+
+	_, err := p.tx.ExecContext(ctx, query)
+	return err
+}
+
+// schemaHistory returns the migrations applied in every schema of Schemas, so files
+// missing in any schema, like the ones of a new tenant, stay pending.
+func (p *PostgresDriver) schemaHistory(ctx context.Context) ([]Record, error) {
+	type key struct {
+		dir     string
+		version int64
+	}
+
+	var (
+		records []Record
+		counts  = make(map[key]int)
+	)
+
+	err := p.eachSchema(ctx, func() error {
+		schemaRecords, err := p.history(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range schemaRecords {
+			k := key{r.Directory, r.Version}
+			if counts[k]++; counts[k] == 1 {
+				records = append(records, r)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	applied := records[:0]
+	for _, r := range records {
+		if counts[key{r.Directory, r.Version}] == len(p.Schemas) {
+			applied = append(applied, r)
+		}
+	}
+
+	return applied, nil
+}
+
+// postgresIdent returns the quoted, schema qualified name of table with suffix appended.
+// A table given as "schema.table" is split when schema is empty. Unquoted parts are folded
+// to lower case like unquoted identifiers, so names used before quoting keep naming the same
+// table, parts in double quotes are kept as is. Any other name is quoted as a single identifier.
+func postgresIdent(schema, table, suffix string) string {
+	parts, ok := identParts(table)
+	if !ok || len(parts) > 2 {
+		parts = []string{strings.ToLower(table)}
+	}
+
+	if len(parts) == 2 {
+		if schema == "" {
+			schema = parts[0]
+		}
+
+		parts = parts[1:]
+	}
+
+	if schema == "" {
+		return quoteIdent(parts[0] + suffix)
+	}
+
+	return quoteIdent(schema) + "." + quoteIdent(parts[0]+suffix)
+}
+
+// identParts splits a possibly qualified identifier like ops."Migrations" into its unquoted parts,
+// folding the parts without quotes to lower case. It reports false for malformed names.
+func identParts(name string) ([]string, bool) {
+	var parts []string
+
+	for {
+		var part string
+
+		if rest, ok := strings.CutPrefix(name, `"`); ok {
+			var b strings.Builder
+			closed := false
+
+			for len(rest) > 0 {
+				c := rest[0]
+				rest = rest[1:]
+
+				if c != '"' {
+					b.WriteByte(c)
+					continue
+				}
+
+				if strings.HasPrefix(rest, `"`) {
+					b.WriteByte('"')
+					rest = rest[1:]
+
+					continue
+				}
+
+				closed = true
+				break
+			}
+
+			if !closed || b.Len() == 0 {
+				return nil, false
+			}
+
+			part, name = b.String(), rest
+		} else {
+			end := strings.IndexAny(name, `."`)
+			if end < 0 {
+				end = len(name)
+			}
+
+			part, name = strings.ToLower(name[:end]), name[end:]
+			if part == "" {
+				return nil, false
+			}
+		}
+
+		parts = append(parts, part)
+
+		if name == "" {
+			return parts, true
+		}
+
+		rest, ok := strings.CutPrefix(name, ".")
+		if !ok {
+			return nil, false
+		}
+
+		name = rest
+	}
+}
+
+// quoteIdent returns s as a quoted SQL identifier.
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
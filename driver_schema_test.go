@@ -0,0 +1,84 @@
+package muz
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPostgresDriverSchemas(t *testing.T) {
+	m := Migrate{FS: MapFS(map[string]string{"migrations/001_users.sql": "CREATE TABLE users();"})}
+
+	var out bytes.Buffer
+	driver := &PostgresDriver{DryRun: &out, Schemas: []string{"tenant_a", `tenant"b`}}
+	if _, err := m.Migrate(context.Background(), driver); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{
+		`SET LOCAL search_path TO "tenant_a";`,
+		`CREATE TABLE IF NOT EXISTS "tenant_a"."migrations"`,
+		`INSERT INTO "tenant_a"."migrations"`,
+		`SET LOCAL search_path TO "tenant""b";`,
+		`INSERT INTO "tenant""b"."migrations"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q, got:\n%s", want, got)
+		}
+	}
+
+	if n := strings.Count(got, "CREATE TABLE users();"); n != 2 {
+		t.Errorf("migration applied %d times, want once per schema", n)
+	}
+}
+
+func TestPostgresDriverTableName(t *testing.T) {
+	tests := []struct {
+		name   string
+		driver PostgresDriver
+		want   string
+	}{
+		{name: "default", want: `"migrations"`},
+		{name: "table", driver: PostgresDriver{Table: "muz_migrations"}, want: `"muz_migrations"`},
+		{name: "folded", driver: PostgresDriver{Table: "Muz_Migrations"}, want: `"muz_migrations"`},
+		{name: "schema", driver: PostgresDriver{Schema: "ops", Table: "muz_migrations"}, want: `"ops"."muz_migrations"`},
+		{name: "qualified table", driver: PostgresDriver{Table: "ops.muz_migrations"}, want: `"ops"."muz_migrations"`},
+		{name: "injection", driver: PostgresDriver{Table: `migrations"; DROP TABLE users; --`}, want: `"migrations""; drop table users; --"`},
+		{name: "injection without quotes", driver: PostgresDriver{Table: "migrations; drop table users"}, want: `"migrations; drop table users"`},
+		{name: "quoted", driver: PostgresDriver{Schema: "Ops", Table: `"Migrations"`}, want: `"Ops"."Migrations"`},
+		{name: "quoted qualified", driver: PostgresDriver{Table: `Ops."Mig""rations"`}, want: `"ops"."Mig""rations"`},
+		{name: "schemas", driver: PostgresDriver{Schema: "ops", schema: "Tenant"}, want: `"Tenant"."migrations"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.driver.tableName(); got != tt.want {
+				t.Errorf("tableName() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if got := (&PostgresDriver{Schema: "ops", Table: `"Migrations"`}).relation(dirtySuffix); got != `"ops"."Migrations_dirty"` {
+		t.Errorf("relation() = %s, want %s", got, `"ops"."Migrations_dirty"`)
+	}
+}
+
+func TestPostgresDriverLockKey(t *testing.T) {
+	want := (&PostgresDriver{Schema: "ops"}).lockKey()
+
+	for _, driver := range []*PostgresDriver{
+		{Table: "ops.migrations"},
+		{Table: "OPS.Migrations"},
+		{Schema: "ops", Table: `"migrations"`},
+	} {
+		if got := driver.lockKey(); got != want {
+			t.Errorf("lockKey() of %+v = %d, want %d", driver, got, want)
+		}
+	}
+
+	if (&PostgresDriver{Schema: "app"}).lockKey() == want {
+		t.Errorf("lockKey() of another schema is the same")
+	}
+}
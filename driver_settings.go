@@ -0,0 +1,49 @@
+package muz
+
+import (
+	"context"
+	"fmt"
+)
+
+// settings returns the statements applying LockTimeout, StatementTimeout and Role,
+// for the current transaction when local is set, for the session otherwise.
+func (p *PostgresDriver) settings(local bool) []string {
+	set := "SET "
+	if local {
+		set = "SET LOCAL "
+	}
+
+	var settings []string
+	if p.Role != "" {
+		settings = append(settings, set+"ROLE "+quoteIdent(p.Role))
+	}
+
+	if p.LockTimeout > 0 {
+		settings = append(settings, fmt.Sprintf("%slock_timeout = %d", set, p.LockTimeout.Milliseconds()))
+	}
+
+	if p.StatementTimeout > 0 {
+		settings = append(settings, fmt.Sprintf("%sstatement_timeout = %d", set, p.StatementTimeout.Milliseconds()))
+	}
+
+	return settings
+}
+
+// setSettings applies the session settings to the current transaction.
+func (p *PostgresDriver) setSettings(ctx context.Context) error {
+	for _, setting := range p.settings(true) {
+		if p.DryRun != nil {
+			if err := p.dryRun(setting); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if _, err := p.tx.ExecContext(ctx, setting); err != nil {
+			return fmt.Errorf("applying %q: %w", setting, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,508 @@
+package muz
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GenericSQLDriver is a Driver for any database/sql backend described by a Dialect.
+type GenericSQLDriver struct {
+	// DB is the database connection to use for migrations.
+	DB *sql.DB
+	// Dialect describes the SQL differences of the backend.
+	Dialect Dialect
+	// Table is the name of the migration tracking table.
+	//  - Default: "migrations"
+	Table string
+	// Logger if set, used to log migration progress.
+	Logger Logger
+	// LockTTL if set, serializes runs with a TableLock in the "<table>_lock" table,
+	// for backends where Dialect.Lock is empty like MySQL. A lock not refreshed for LockTTL is taken over.
+	//  - Default: 0, no lock table.
+	LockTTL time.Duration
+	// WaitTimeout if set, Lock and Start first wait up to WaitTimeout for DB to answer, see WaitForDB.
+	//  - Default: 0, a database not reachable fails the run at once.
+	WaitTimeout time.Duration
+	// Now if set, is the clock of processed_at, of the seed, dirty and failure times and of the heartbeats of
+	// LockTTL, like a fixed time for tests asserting exact tracking rows.
+	//  - Default: time.Now, processed_at is set by the database.
+	Now func() time.Time
+
+	// tx is the current transaction, if any.
+	tx *sql.Tx
+	// lock is the TableLock of LockTTL.
+	lock *TableLock
+}
+
+func (g *GenericSQLDriver) tableName() string {
+	if g.Table == "" {
+		return "migrations"
+	}
+
+	return g.Table
+}
+
+// tableLock returns the TableLock of LockTTL, nil when it is not set.
+func (g *GenericSQLDriver) tableLock() *TableLock {
+	if g.LockTTL <= 0 {
+		return nil
+	}
+
+	if g.lock == nil {
+		g.lock = &TableLock{DB: g.DB, Dialect: g.Dialect, Table: g.tableName() + "_lock", TTL: g.LockTTL, Logger: g.Logger, Now: g.Now}
+	}
+
+	return g.lock
+}
+
+func (g *GenericSQLDriver) Lock(ctx context.Context) error {
+	if err := g.wait(ctx); err != nil {
+		return err
+	}
+
+	if l := g.tableLock(); l != nil {
+		return l.Lock(ctx)
+	}
+
+	return nil
+}
+
+func (g *GenericSQLDriver) Unlock(ctx context.Context) error {
+	if l := g.tableLock(); l != nil {
+		return l.Unlock(ctx)
+	}
+
+	return nil
+}
+
+// ForceUnlock removes the lock of LockTTL left by another process, see TableLock.ForceUnlock.
+func (g *GenericSQLDriver) ForceUnlock(ctx context.Context) error {
+	l := g.tableLock()
+	if l == nil {
+		return errors.New("force unlock: LockTTL is not set")
+	}
+
+	return l.ForceUnlock(ctx)
+}
+
+// split reports whether the dialect executes the statements of a file one at a time.
+func (g *GenericSQLDriver) split() bool {
+	d, ok := g.Dialect.(statementSplitter)
+
+	return ok && d.SplitStatements()
+}
+
+// execOptions returns the options of execContent for the dialect.
+func (g *GenericSQLDriver) execOptions() execOptions {
+	return execOptions{split: g.split(), logger: g.Logger}
+}
+
+func (g *GenericSQLDriver) Start(ctx context.Context) error {
+	if g.Dialect == nil {
+		return errors.New("generic sql driver: dialect is required")
+	}
+
+	if err := g.wait(ctx); err != nil {
+		return err
+	}
+
+	var err error
+	g.tx, err = g.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if g.Logger != nil {
+		g.Logger.Info("starting migration", "table", g.tableName())
+	}
+
+	if _, err := g.tx.ExecContext(ctx, g.Dialect.CreateTable(g.tableName())); err != nil {
+		return err
+	}
+
+	if err := g.widenVersion(ctx); err != nil {
+		return err
+	}
+
+	if query := g.Dialect.Lock(g.tableName()); query != "" {
+		if _, err := g.tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("locking %s: %w", g.tableName(), err)
+		}
+	}
+
+	return nil
+}
+
+// widenVersion widens the version column of a tracking table created by older versions, see versionWidener.
+func (g *GenericSQLDriver) widenVersion(ctx context.Context) error {
+	d, ok := g.Dialect.(versionWidener)
+	if !ok {
+		return nil
+	}
+
+	var wide bool
+	if err := g.tx.QueryRowContext(ctx, d.VersionWide(g.tableName()), g.tableName()).Scan(&wide); err != nil || wide {
+		return err
+	}
+
+	if g.Logger != nil {
+		g.Logger.Info("widening version column", "table", g.tableName())
+	}
+
+	_, err := g.tx.ExecContext(ctx, d.WidenVersion(g.tableName()))
+	return err
+}
+
+func (g *GenericSQLDriver) Process(ctx context.Context, data *Muzo) error {
+	directory := data.Dir
+	var version int64
+
+	// Get latest applied version for the directory
+	query := fmt.Sprintf(`
+		SELECT MAX(version) FROM %s WHERE directory = %s
+	`, g.tableName(), g.Dialect.Placeholder(1))
+
+	row := g.tx.QueryRowContext(ctx, query, directory)
+	var latestVersion sql.NullInt64
+	if err := row.Scan(&latestVersion); err != nil {
+		return err
+	}
+	if latestVersion.Valid {
+		version = latestVersion.Int64
+	}
+
+	// Apply migrations in order
+	for _, file := range data.Files {
+		if file.Version <= version && !data.OutOfOrder() {
+			continue // already applied
+		}
+
+		if g.Logger != nil {
+			g.Logger.Info("applying migration", "version", file.Version, "directory", directory, "file", file.Path)
+		}
+
+		noTx, err := noTransaction(data, file)
+		if err != nil {
+			return err
+		}
+
+		if noTx {
+			if err := g.processNoTx(ctx, data, file); err != nil {
+				return fmt.Errorf("applying migration %d - %s - %s: %w", file.Version, directory, file.Path, err)
+			}
+
+			version = file.Version
+			continue
+		}
+
+		if err := execFile(ctx, g.tx, data, file, g.execOptions()); err != nil {
+			return fmt.Errorf("applying migration %d - %s - %s: %w", file.Version, directory, file.Path, err)
+		}
+
+		if _, err := g.tx.ExecContext(ctx, g.Dialect.Upsert(g.tableName()), file.Version, directory, file.Path); err != nil {
+			return err
+		}
+
+		if err := g.storeProcessedAt(ctx, g.tx, directory, file.Version); err != nil {
+			return err
+		}
+
+		version = file.Version
+	}
+
+	return nil
+}
+
+// processNoTx commits the work done so far, applies file directly on the connection
+// and starts a new transaction, locked again, for the remaining files.
+func (g *GenericSQLDriver) processNoTx(ctx context.Context, data *Muzo, file FileInfo) error {
+	tx := g.tx
+	g.tx = nil
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if err := execContent(ctx, g.DB, data, file.Path, g.execOptions()); err != nil {
+		return err
+	}
+
+	if _, err := g.DB.ExecContext(ctx, g.Dialect.Upsert(g.tableName()), file.Version, data.Dir, file.Path); err != nil {
+		return err
+	}
+
+	if err := g.storeProcessedAt(ctx, g.DB, data.Dir, file.Version); err != nil {
+		return err
+	}
+
+	var err error
+
+	g.tx, err = g.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if query := g.Dialect.Lock(g.tableName()); query != "" {
+		if _, err := g.tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("locking %s: %w", g.tableName(), err)
+		}
+	}
+
+	return nil
+}
+
+func (g *GenericSQLDriver) End(ctx context.Context, err error) error {
+	if g.tx != nil {
+		tx := g.tx
+		g.tx = nil
+
+		if err != nil {
+			return tx.Rollback()
+		}
+
+		if g.Logger != nil {
+			g.Logger.Info("migrations applied successfully")
+		}
+
+		return tx.Commit()
+	}
+
+	return nil
+}
+
+func (g *GenericSQLDriver) History(ctx context.Context) ([]Record, error) {
+	q, exists, err := g.tableExists(ctx, g.tableName())
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return queryHistory(ctx, q, g.tableName())
+}
+
+func (g *GenericSQLDriver) QueryRows(ctx context.Context, query string, row func(scan func(dest ...any) error) error) error {
+	var q querier = g.DB
+	if g.tx != nil {
+		q = g.tx
+	}
+
+	return queryRows(ctx, q, query, row)
+}
+
+func (g *GenericSQLDriver) Exec(ctx context.Context, content []byte) error {
+	var q querier = g.DB
+	if g.tx != nil {
+		q = g.tx
+	}
+
+	return execStatements(ctx, q, content, g.split(), g.Logger)
+}
+
+func (g *GenericSQLDriver) Record(ctx context.Context, dir string, file FileInfo) error {
+	if _, err := g.tx.ExecContext(ctx, g.Dialect.Upsert(g.tableName()), file.Version, dir, file.Path); err != nil {
+		return err
+	}
+
+	return g.storeProcessedAt(ctx, g.tx, dir, file.Version)
+}
+
+// storeProcessedAt sets processed_at of a record to the time of Now, the database sets it without Now.
+func (g *GenericSQLDriver) storeProcessedAt(ctx context.Context, q execer, dir string, version int64) error {
+	if g.Now == nil {
+		return nil
+	}
+
+	_, err := q.ExecContext(ctx, fmt.Sprintf(`
+		UPDATE %s SET processed_at = %s WHERE directory = %s AND version = %s
+	`, g.tableName(), g.Dialect.Placeholder(1), g.Dialect.Placeholder(2), g.Dialect.Placeholder(3)), g.Now().UTC(), dir, version)
+	return err
+}
+
+func (g *GenericSQLDriver) Unrecord(ctx context.Context, dir string, version int64) error {
+	_, err := g.tx.ExecContext(ctx, fmt.Sprintf(`
+		DELETE FROM %s WHERE directory = %s AND version = %s
+	`, g.tableName(), g.Dialect.Placeholder(1), g.Dialect.Placeholder(2)), dir, version)
+	return err
+}
+
+func (g *GenericSQLDriver) Rollback(ctx context.Context, data *Muzo) error {
+	for _, file := range data.Files {
+		if g.Logger != nil {
+			g.Logger.Info("rolling back migration", "version", file.Version, "directory", data.Dir, "file", file.Path)
+		}
+
+		if err := execRollback(ctx, g.tx, data, file, g.execOptions()); err != nil {
+			return fmt.Errorf("rolling back migration %d - %s - %s: %w", file.Version, data.Dir, file.Path, err)
+		}
+
+		if err := g.Unrecord(ctx, data.Dir, file.Version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *GenericSQLDriver) Dirty(ctx context.Context) ([]DirtyRecord, error) {
+	table := dirtyTable(g.tableName())
+
+	q, exists, err := g.tableExists(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryDirty(ctx, q, table, exists)
+}
+
+func (g *GenericSQLDriver) MarkDirty(ctx context.Context, r DirtyRecord) error {
+	if g.Now != nil {
+		r.FailedAt = g.Now()
+	}
+
+	return markDirtySQL(ctx, g.DB, dirtyTable(g.tableName()), g.Dialect.Placeholder, r)
+}
+
+func (g *GenericSQLDriver) RecordFailure(ctx context.Context, f Failure) error {
+	if g.Now != nil {
+		f.FailedAt = g.Now()
+	}
+
+	return recordFailureSQL(ctx, g.DB, failureTable(g.tableName()), g.Dialect.Placeholder, f)
+}
+
+func (g *GenericSQLDriver) Failures(ctx context.Context) ([]Failure, error) {
+	table := failureTable(g.tableName())
+
+	q, exists, err := g.tableExists(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryFailures(ctx, q, table, exists)
+}
+
+func (g *GenericSQLDriver) ClearDirty(ctx context.Context, dir string) error {
+	table := dirtyTable(g.tableName())
+
+	q, exists, err := g.tableExists(ctx, table)
+	if err != nil || !exists {
+		return err
+	}
+
+	_, remove, _ := dirtyQueries(table, g.Dialect.Placeholder)
+	_, err = q.ExecContext(ctx, remove, dir)
+	return err
+}
+
+func (g *GenericSQLDriver) Seeds(ctx context.Context) ([]SeedRecord, error) {
+	table := seedTable(g.tableName())
+
+	q, exists, err := g.tableExists(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	return querySeeds(ctx, q, table, exists)
+}
+
+func (g *GenericSQLDriver) StoreSeed(ctx context.Context, r SeedRecord) error {
+	if g.Now != nil {
+		r.AppliedAt = g.Now()
+	}
+
+	return storeSeedSQL(ctx, g.tx, seedTable(g.tableName()), g.Dialect.Placeholder, r)
+}
+
+// tableExists returns the querier of the session and whether table exists.
+// Dialects without TableExists are probed with a query reading no rows, run outside the session
+// as a failing statement aborts the transaction on some backends. The table is never created.
+func (g *GenericSQLDriver) tableExists(ctx context.Context, table string) (querier, bool, error) {
+	var q querier = g.DB
+	if g.tx != nil {
+		q = g.tx
+	}
+
+	d, ok := g.Dialect.(tableChecker)
+	if !ok {
+		_, err := g.DB.ExecContext(ctx, fmt.Sprintf("SELECT 1 FROM %s WHERE 1 = 0", table))
+		return q, err == nil, nil
+	}
+
+	var exists bool
+	err := q.QueryRowContext(ctx, d.TableExists(table), table).Scan(&exists)
+
+	return q, exists, err
+}
+
+// execer runs statements, like *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// querier is the common part of *sql.DB and *sql.Tx.
+type querier interface {
+	execer
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// queryHistory reads all rows of the tracking table.
+func queryHistory(ctx context.Context, q querier, table string) ([]Record, error) {
+	rows, err := q.QueryContext(ctx, fmt.Sprintf(`
+		SELECT version, directory, file_name, processed_at FROM %s ORDER BY directory, version
+	`, table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var r Record
+		if err := rows.Scan(&r.Version, &r.Directory, &r.FileName, &r.ProcessedAt); err != nil {
+			return nil, err
+		}
+
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
+
+// queryRows calls row for every row of query, see RowQuerier.
+func queryRows(ctx context.Context, q querier, query string, row func(scan func(dest ...any) error) error) error {
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := row(rows.Scan); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (g *GenericSQLDriver) WaitForDB(ctx context.Context, timeout time.Duration) error {
+	return waitForDB(ctx, timeout, g.Logger, g.DB.PingContext)
+}
+
+// wait waits for DB when WaitTimeout is set.
+func (g *GenericSQLDriver) wait(ctx context.Context) error {
+	if g.WaitTimeout <= 0 {
+		return nil
+	}
+
+	return g.WaitForDB(ctx, g.WaitTimeout)
+}
+
+// Close closes the database connection.
+// Drivers returned by OpenDriver own their connection and should be closed by the caller.
+func (g *GenericSQLDriver) Close() error {
+	return g.DB.Close()
+}
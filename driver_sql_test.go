@@ -0,0 +1,181 @@
+package muz_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rakunlabs/muz"
+
+	_ "modernc.org/sqlite"
+)
+
+// genericMigrations are portable across the dialects of GenericSQLDriver.
+var genericMigrations = map[string]string{
+	"migrations/app/001_users.sql":  "CREATE TABLE users (id integer PRIMARY KEY, name varchar(255) NOT NULL);",
+	"migrations/app/002_admins.sql": "INSERT INTO users (id, name) VALUES (1, 'admin');\nINSERT INTO users (id, name) VALUES (2, 'guest');",
+}
+
+// testGenericDriver runs genericMigrations on db with dialect, checking read paths leave the database untouched.
+func testGenericDriver(t *testing.T, db *sql.DB, dialect muz.Dialect) {
+	t.Helper()
+
+	m := muz.Migrate{FS: muz.MapFS(genericMigrations)}
+	driver := &muz.GenericSQLDriver{DB: db, Dialect: dialect}
+
+	status, err := m.Status(t.Context(), driver)
+	if err != nil {
+		t.Fatalf("Status() error: %v", err)
+	}
+
+	if status.Pending != 2 {
+		t.Errorf("Status() pending = %d, want 2", status.Pending)
+	}
+
+	// Status and Plan must not create the tracking tables.
+	if _, err := db.ExecContext(t.Context(), "SELECT 1 FROM migrations WHERE 1 = 0"); err == nil {
+		t.Fatalf("Status() created the tracking table")
+	}
+
+	if _, err := m.Migrate(t.Context(), driver); err != nil {
+		t.Fatalf("Migrate() error: %v", err)
+	}
+
+	// Running again must not apply anything twice.
+	result, err := m.Migrate(t.Context(), driver)
+	if err != nil {
+		t.Fatalf("second Migrate() error: %v", err)
+	}
+
+	if n := result.Applied(); n != 0 {
+		t.Errorf("second Migrate() applied %d files, want 0", n)
+	}
+
+	var users, records int
+	if err := db.QueryRowContext(t.Context(), "SELECT COUNT(*) FROM users").Scan(&users); err != nil {
+		t.Fatalf("could not query users: %v", err)
+	}
+
+	if err := db.QueryRowContext(t.Context(), "SELECT COUNT(*) FROM migrations").Scan(&records); err != nil {
+		t.Fatalf("could not query migrations table: %v", err)
+	}
+
+	if users != 2 || records != 2 {
+		t.Errorf("got %d users and %d records, want 2 and 2", users, records)
+	}
+}
+
+// probedDialect hides the TableExists of its Dialect.
+type probedDialect struct {
+	muz.Dialect
+}
+
+func TestGenericSQLDriverSQLite(t *testing.T) {
+	for name, dialect := range map[string]muz.Dialect{
+		"table checker": muz.SQLiteDialect{},
+		"probed":        probedDialect{muz.SQLiteDialect{}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "muz.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			testGenericDriver(t, db, dialect)
+		})
+	}
+}
+
+// widenedDialect widens by adding a widened column, SQLite integers being 64 bit already.
+type widenedDialect struct {
+	muz.SQLiteDialect
+}
+
+func (widenedDialect) VersionWide(string) string {
+	return "SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = 'widened'"
+}
+
+func (widenedDialect) WidenVersion(table string) string {
+	return "ALTER TABLE " + table + " ADD COLUMN widened integer"
+}
+
+func TestGenericSQLDriverWidenVersion(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "muz.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m := muz.Migrate{FS: muz.MapFS(genericMigrations)}
+	driver := &muz.GenericSQLDriver{DB: db, Dialect: widenedDialect{}}
+
+	// Widened once, the second run finds the column wide.
+	for range 2 {
+		if _, err := m.Migrate(t.Context(), driver); err != nil {
+			t.Fatalf("Migrate() error: %v", err)
+		}
+	}
+
+	var widened int
+	if err := db.QueryRowContext(t.Context(), "SELECT COUNT(*) FROM pragma_table_info('migrations') WHERE name = 'widened'").Scan(&widened); err != nil {
+		t.Fatal(err)
+	}
+
+	if widened != 1 {
+		t.Errorf("widened columns = %d, want 1", widened)
+	}
+}
+
+func TestGenericSQLDriverNow(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "muz.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	driver := &muz.GenericSQLDriver{DB: db, Dialect: muz.SQLiteDialect{}, Now: func() time.Time { return now }}
+
+	seeds := muz.Migrate{
+		FS:        muz.MapFS(map[string]string{"migrations/seed/001_users.sql": "CREATE TABLE users (id integer PRIMARY KEY);"}),
+		DirConfig: map[string]muz.DirOptions{"seed": {Seed: true}},
+	}
+
+	if _, err := seeds.Migrate(t.Context(), driver); err != nil {
+		t.Fatalf("Migrate() seeds error: %v", err)
+	}
+
+	broken := muz.Migrate{FS: muz.MapFS(map[string]string{"migrations/app/001_broken.sql": "CREATE TABLE broken (;"})}
+	if _, err := broken.Migrate(t.Context(), driver); err == nil {
+		t.Fatal("Migrate() error = nil, want the broken migration to fail")
+	}
+
+	records, err := driver.Seeds(t.Context())
+	if err != nil {
+		t.Fatalf("Seeds() error: %v", err)
+	}
+
+	if len(records) != 1 || !records[0].AppliedAt.Equal(now) {
+		t.Errorf("Seeds() = %+v, want one seed applied at %v", records, now)
+	}
+
+	dirty, err := driver.Dirty(t.Context())
+	if err != nil {
+		t.Fatalf("Dirty() error: %v", err)
+	}
+
+	if len(dirty) != 1 || !dirty[0].FailedAt.Equal(now) {
+		t.Errorf("Dirty() = %+v, want one record failed at %v", dirty, now)
+	}
+
+	failures, err := driver.Failures(t.Context())
+	if err != nil {
+		t.Fatalf("Failures() error: %v", err)
+	}
+
+	if len(failures) != 1 || !failures[0].FailedAt.Equal(now) {
+		t.Errorf("Failures() = %+v, want one failure at %v", failures, now)
+	}
+}
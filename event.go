@@ -0,0 +1,103 @@
+package muz
+
+import (
+	"context"
+	"time"
+)
+
+// Event is a step of a run passed to Hooks.OnEvent, one of RunStarted, DirStarted, FileApplied, FileSkipped,
+// FileFailed, DirFinished and RunFinished. Per file events need a driver implementing StatusReporter, like Hooks.AfterFile.
+type Event interface {
+	event()
+}
+
+// SkipReason tells why a file was not applied.
+type SkipReason string
+
+const (
+	// SkipApplied is a file already applied, reported with OutcomeModified for an edited file with ChecksumWarn.
+	SkipApplied SkipReason = "applied"
+	// SkipOutOfOrder is a file older than the latest applied version of its directory.
+	SkipOutOfOrder SkipReason = "out_of_order"
+)
+
+// RunStarted is emitted before the first directory when the number of pending files is known:
+// with a driver implementing StatusReporter, the filesystem source and without Parallel.
+// The files are listed twice for it, only when Hooks.OnEvent is set.
+type RunStarted struct {
+	// Pending is the number of files the run applies.
+	Pending int `json:"pending"`
+}
+
+// Progress is the position of an applied file in a run, zero when RunStarted was not emitted.
+type Progress struct {
+	// Done is the number of files applied so far, this one included.
+	Done int `json:"done"`
+	// Total is the number of pending files of RunStarted.
+	Total int `json:"total"`
+}
+
+// Fraction returns the completed part of the run between 0 and 1, 0 when Total is unknown.
+func (p Progress) Fraction() float64 {
+	if p.Total == 0 {
+		return 0
+	}
+
+	return float64(p.Done) / float64(p.Total)
+}
+
+// DirStarted is emitted before the files of a directory are processed.
+type DirStarted struct {
+	Dir string `json:"dir"`
+	// Files is the number of files of the directory, applied ones included.
+	Files int `json:"files"`
+}
+
+// FileApplied is emitted after a file ran, Duration being the execution time.
+type FileApplied struct {
+	FileResult
+	Progress
+}
+
+// FileSkipped is emitted for a file left unapplied.
+type FileSkipped struct {
+	FileResult
+	Reason SkipReason `json:"reason"`
+}
+
+// FileFailed is emitted for the file that stopped the run.
+type FileFailed struct {
+	FileResult
+	Progress
+	Err error `json:"-"`
+}
+
+// DirFinished is emitted after the files of a directory were processed, with the error of the directory if it failed.
+type DirFinished struct {
+	Dir string `json:"dir"`
+	// Applied is the number of files applied in the directory, zero for drivers without StatusReporter.
+	Applied  int           `json:"applied"`
+	Duration time.Duration `json:"duration"`
+	Err      error         `json:"-"`
+}
+
+// RunFinished is emitted once at the end of a run, with the error of the run if it failed.
+type RunFinished struct {
+	Result *Result `json:"result"`
+	Err    error   `json:"-"`
+}
+
+func (RunStarted) event()  {}
+func (DirStarted) event()  {}
+func (FileApplied) event() {}
+func (FileSkipped) event() {}
+func (FileFailed) event()  {}
+func (DirFinished) event() {}
+func (RunFinished) event() {}
+
+// emit passes event to Hooks.OnEvent when it is set.
+func (m Migrate) emit(ctx context.Context, event Event) {
+	if m.Hooks.OnEvent != nil {
+		m.Hooks.OnEvent(ctx, event)
+	}
+}
@@ -0,0 +1,128 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMigrateEvents(t *testing.T) {
+	var events []Event
+
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/app/001_users.sql": "CREATE TABLE users();",
+			"migrations/app/002_posts.sql": "CREATE TABLE posts();",
+		}),
+		Hooks: Hooks{OnEvent: func(_ context.Context, event Event) {
+			events = append(events, event)
+		}},
+	}
+
+	driver := &recordDriver{records: []Record{{Version: 1, Directory: "app", FileName: "001_users.sql"}}}
+	result, err := m.Migrate(context.Background(), driver)
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	if len(events) != 8 {
+		t.Fatalf("got %d events, want 8: %#v", len(events), events)
+	}
+
+	if want := (RunStarted{Pending: 1}); events[0] != want {
+		t.Errorf("event 0 = %#v, want %#v", events[0], want)
+	}
+
+	// The root directory has no files.
+	if want := (DirStarted{Dir: ".", Files: 0}); events[1] != want {
+		t.Errorf("event 1 = %#v, want %#v", events[1], want)
+	}
+
+	if finished, ok := events[2].(DirFinished); !ok || finished.Dir != "." || finished.Applied != 0 || finished.Err != nil {
+		t.Errorf("event 2 = %#v, want the root directory finished", events[2])
+	}
+
+	if want := (DirStarted{Dir: "app", Files: 2}); events[3] != want {
+		t.Errorf("event 3 = %#v, want %#v", events[3], want)
+	}
+
+	skipped, ok := events[4].(FileSkipped)
+	if !ok || skipped.File != "001_users.sql" || skipped.Reason != SkipApplied {
+		t.Errorf("event 4 = %#v, want the applied 001_users.sql skipped", events[4])
+	}
+
+	applied, ok := events[5].(FileApplied)
+	if !ok || applied.File != "002_posts.sql" || applied.Outcome != OutcomeApplied || applied.Progress != (Progress{Done: 1, Total: 1}) {
+		t.Errorf("event 5 = %#v, want 002_posts.sql applied", events[5])
+	}
+
+	if finished, ok := events[6].(DirFinished); !ok || finished.Dir != "app" || finished.Applied != 1 || finished.Err != nil {
+		t.Errorf("event 6 = %#v, want app finished with 1 applied file", events[6])
+	}
+
+	finished, ok := events[7].(RunFinished)
+	if !ok || finished.Err != nil || !reflect.DeepEqual(finished.Result, result) {
+		t.Errorf("event 7 = %#v, want the run finished with its result", events[7])
+	}
+}
+
+func TestMigrateProgress(t *testing.T) {
+	var fractions []float64
+
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/app/001_users.sql":  "CREATE TABLE users();",
+			"migrations/app/002_posts.sql":  "CREATE TABLE posts();",
+			"migrations/app/003_tags.sql":   "CREATE TABLE tags();",
+			"migrations/seed/001_admin.sql": "INSERT INTO users DEFAULT VALUES;",
+			"migrations/seed/002_posts.sql": "INSERT INTO posts DEFAULT VALUES;",
+		}),
+		Order: []string{"app", "seed"},
+		Hooks: Hooks{OnEvent: func(_ context.Context, event Event) {
+			if applied, ok := event.(FileApplied); ok {
+				fractions = append(fractions, applied.Fraction())
+			}
+		}},
+	}
+
+	driver := &recordDriver{records: []Record{{Version: 1, Directory: "app", FileName: "001_users.sql"}}}
+	if _, err := m.Migrate(context.Background(), driver); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	if want := []float64{0.25, 0.5, 0.75, 1}; !reflect.DeepEqual(fractions, want) {
+		t.Errorf("fractions = %v, want %v", fractions, want)
+	}
+
+	if got := (Progress{Done: 3}).Fraction(); got != 0 {
+		t.Errorf("Fraction() without total = %v, want 0", got)
+	}
+}
+
+func TestMigrateDirFinishedError(t *testing.T) {
+	var finished []DirFinished
+
+	errBefore := errors.New("before dir")
+
+	m := Migrate{
+		FS: MapFS(map[string]string{"migrations/app/001_users.sql": "CREATE TABLE users();"}),
+		Hooks: Hooks{
+			BeforeDir: func(context.Context, *Muzo) error { return errBefore },
+			OnEvent: func(_ context.Context, event Event) {
+				if e, ok := event.(DirFinished); ok {
+					finished = append(finished, e)
+				}
+			},
+		},
+	}
+
+	if _, err := m.Migrate(context.Background(), &recordDriver{}); !errors.Is(err, errBefore) {
+		t.Fatalf("Migrate() error = %v, want %v", err, errBefore)
+	}
+
+	// The root directory has no files and no BeforeDir call.
+	if len(finished) != 2 || finished[1].Dir != "app" || !errors.Is(finished[1].Err, errBefore) {
+		t.Errorf("DirFinished events = %#v, want app finished with the error", finished)
+	}
+}
@@ -0,0 +1,81 @@
+package muz
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ExportFormat is the output of Migrate.ExportHistory.
+type ExportFormat string
+
+const (
+	// ExportJSON writes the records as a JSON array of Record.
+	ExportJSON ExportFormat = "json"
+	// ExportCSV writes the records as CSV with a header row, times in RFC 3339 and durations in milliseconds.
+	ExportCSV ExportFormat = "csv"
+)
+
+// exportColumns is the header row of ExportCSV, named like the JSON fields of Record.
+var exportColumns = []string{
+	"directory", "version", "file_name", "checksum", "processed_at", "duration_ms",
+	"description", "applied_by", "hostname", "app_version", "git_sha",
+}
+
+// ExportHistory writes the applied migrations of the tracking table to w, as evidence for audits
+// and compliance reviews. The audit columns are empty for drivers not keeping them.
+// The driver must implement StatusReporter.
+func (m Migrate) ExportHistory(ctx context.Context, driver Driver, w io.Writer, format ExportFormat) error {
+	reporter, ok := driver.(StatusReporter)
+	if !ok {
+		return fmt.Errorf("export history: %w", ErrNotSupported)
+	}
+
+	if format != ExportJSON && format != ExportCSV {
+		return fmt.Errorf("export history: unknown format %q", format)
+	}
+
+	records, err := reporter.History(ctx)
+	if err != nil {
+		return fmt.Errorf("export history: %w", err)
+	}
+
+	if format == ExportJSON {
+		if records == nil {
+			records = []Record{}
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(records)
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportColumns); err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		var processedAt string
+		if !r.ProcessedAt.IsZero() {
+			processedAt = r.ProcessedAt.UTC().Format(time.RFC3339Nano)
+		}
+
+		err := cw.Write([]string{
+			r.Directory, strconv.FormatInt(r.Version, 10), r.FileName, r.Checksum, processedAt, strconv.FormatInt(r.Duration.Milliseconds(), 10),
+			r.Description, r.AppliedBy, r.Hostname, r.AppVersion, r.GitSHA,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
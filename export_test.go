@@ -0,0 +1,73 @@
+package muz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExportHistory(t *testing.T) {
+	appliedAt := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+
+	driver := &recordDriver{records: []Record{
+		{Version: 1, Directory: "schema", FileName: "001_users.sql", ProcessedAt: appliedAt, Checksum: "abc", Duration: 1500 * time.Millisecond, AppliedBy: "deploy"},
+		{Version: 2, Directory: "schema", FileName: "002_orders, items.sql"},
+	}}
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := (Migrate{}).ExportHistory(context.Background(), driver, &buf, ExportCSV); err != nil {
+			t.Fatalf("ExportHistory() error = %v", err)
+		}
+
+		want := "directory,version,file_name,checksum,processed_at,duration_ms,description,applied_by,hostname,app_version,git_sha\n" +
+			"schema,1,001_users.sql,abc,2024-03-01T10:30:00Z,1500,,deploy,,,\n" +
+			"schema,2,\"002_orders, items.sql\",,,0,,,,,\n"
+		if buf.String() != want {
+			t.Errorf("ExportHistory() =\n%s\nwant\n%s", buf.String(), want)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := (Migrate{}).ExportHistory(context.Background(), driver, &buf, ExportJSON); err != nil {
+			t.Fatalf("ExportHistory() error = %v", err)
+		}
+
+		var records []Record
+		if err := json.Unmarshal(buf.Bytes(), &records); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+
+		if len(records) != 2 || !records[0].ProcessedAt.Equal(appliedAt) || records[0].AppliedBy != "deploy" {
+			t.Errorf("records = %+v", records)
+		}
+	})
+
+	t.Run("empty history", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := (Migrate{}).ExportHistory(context.Background(), &recordDriver{}, &buf, ExportJSON); err != nil {
+			t.Fatalf("ExportHistory() error = %v", err)
+		}
+
+		if buf.String() != "[]\n" {
+			t.Errorf("ExportHistory() = %q, want an empty array", buf.String())
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		if err := (Migrate{}).ExportHistory(context.Background(), driver, &bytes.Buffer{}, "xml"); err == nil {
+			t.Error("ExportHistory() error = nil, want the unknown format")
+		}
+	})
+
+	t.Run("driver without StatusReporter", func(t *testing.T) {
+		err := (Migrate{}).ExportHistory(context.Background(), nopDriver{}, &bytes.Buffer{}, ExportJSON)
+		if !errors.Is(err, ErrNotSupported) {
+			t.Errorf("ExportHistory() error = %v, want ErrNotSupported", err)
+		}
+	})
+}
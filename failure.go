@@ -0,0 +1,141 @@
+package muz
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Failure is a failed attempt to apply a migration.
+type Failure struct {
+	Directory string        `json:"directory"`
+	Version   int64         `json:"version"`
+	FileName  string        `json:"file_name"`
+	Error     string        `json:"error"`
+	Duration  time.Duration `json:"duration"`
+	FailedAt  time.Time     `json:"failed_at"`
+}
+
+// FailureStore is implemented by drivers keeping every failed attempt, for operators to see what broke
+// without the logs of the run. RecordFailure is called after End of the failed run on a separate
+// connection, so the record is kept when the transaction rolls back.
+type FailureStore interface {
+	RecordFailure(ctx context.Context, failure Failure) error
+	// Failures returns the recorded attempts, the latest first.
+	Failures(ctx context.Context) ([]Failure, error)
+}
+
+// recordFailures records the failed file of result when the driver is a FailureStore.
+func recordFailures(ctx context.Context, driver Driver, result *Result) error {
+	store, ok := driver.(FailureStore)
+	if !ok {
+		return nil
+	}
+
+	for _, f := range result.Files {
+		if f.Outcome != OutcomeFailed {
+			continue
+		}
+
+		err := store.RecordFailure(context.WithoutCancel(ctx), Failure{
+			Directory: f.Dir,
+			Version:   f.Version,
+			FileName:  f.File,
+			Error:     f.Error,
+			Duration:  f.Duration,
+			FailedAt:  time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("recording failed migration: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// //////////////////////////////
+
+// failureSuffix is appended to the tracking table name for the table holding the failed attempts.
+const failureSuffix = "_failures"
+
+// failureTable returns the name of the table holding the failed attempts next to the tracking table.
+func failureTable(table string) string {
+	return table + failureSuffix
+}
+
+// failureCreateTable returns the DDL creating the failure table, portable across the dialects.
+func failureCreateTable(table string) string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			directory varchar(255) NOT NULL,
+			version bigint NOT NULL,
+			file_name varchar(255) NOT NULL,
+			error text NOT NULL,
+			execution_ms bigint NOT NULL,
+			failed_at TIMESTAMP NOT NULL
+		)
+	`, table)
+}
+
+// failureQueries returns the statements reading and inserting failed attempts, using placeholder
+// for the bind parameters. Insert arguments are passed in directory, version, file_name, error,
+// execution_ms, failed_at order.
+func failureQueries(table string, placeholder func(n int) string) (query, insert string) {
+	query = fmt.Sprintf(`
+		SELECT directory, version, file_name, error, execution_ms, failed_at FROM %s ORDER BY failed_at DESC
+	`, table)
+	insert = fmt.Sprintf(`
+		INSERT INTO %s (directory, version, file_name, error, execution_ms, failed_at)
+		VALUES (%s, %s, %s, %s, %s, %s)
+	`, table, placeholder(1), placeholder(2), placeholder(3), placeholder(4), placeholder(5), placeholder(6))
+
+	return query, insert
+}
+
+// failureArgs returns the insert arguments of f.
+func failureArgs(f Failure) []any {
+	return []any{f.Directory, f.Version, f.FileName, f.Error, f.Duration.Milliseconds(), f.FailedAt.UTC()}
+}
+
+// recordFailureSQL inserts f, creating the failure table when needed.
+func recordFailureSQL(ctx context.Context, q querier, table string, placeholder func(n int) string, f Failure) error {
+	if _, err := q.ExecContext(ctx, failureCreateTable(table)); err != nil {
+		return err
+	}
+
+	_, insert := failureQueries(table, placeholder)
+	_, err := q.ExecContext(ctx, insert, failureArgs(f)...)
+	return err
+}
+
+// queryFailures reads the failure table when exists reports it exists.
+func queryFailures(ctx context.Context, q querier, table string, exists bool) ([]Failure, error) {
+	if !exists {
+		return nil, nil
+	}
+
+	query, _ := failureQueries(table, PostgresDialect{}.Placeholder)
+
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var failures []Failure
+	for rows.Next() {
+		var (
+			f  Failure
+			ms int64
+		)
+
+		if err := rows.Scan(&f.Directory, &f.Version, &f.FileName, &f.Error, &ms, &f.FailedAt); err != nil {
+			return nil, err
+		}
+
+		f.Duration = time.Duration(ms) * time.Millisecond
+		failures = append(failures, f)
+	}
+
+	return failures, rows.Err()
+}
@@ -0,0 +1,56 @@
+package muz
+
+import (
+	"context"
+	"testing"
+)
+
+// failureDriver keeps the failed attempts in memory.
+type failureDriver struct {
+	dirtyDriver
+
+	failures []Failure
+}
+
+func (d *failureDriver) RecordFailure(_ context.Context, f Failure) error {
+	d.failures = append([]Failure{f}, d.failures...)
+	return nil
+}
+
+func (d *failureDriver) Failures(context.Context) ([]Failure, error) {
+	return d.failures, nil
+}
+
+func TestMigrateFailures(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql":  "CREATE TABLE users();",
+			"migrations/schema/002_orders.sql": "CREATE TABLE orders(;",
+		}),
+	}
+
+	driver := &failureDriver{dirtyDriver: dirtyDriver{fail: map[string]bool{"002_orders.sql": true}}}
+
+	for range 2 {
+		if _, err := m.Migrate(context.Background(), driver); err == nil {
+			t.Fatal("Migrate() error = nil, want failed migration")
+		}
+
+		if err := m.Force(context.Background(), driver, "schema", 1); err != nil {
+			t.Fatalf("Force() error = %v", err)
+		}
+	}
+
+	if len(driver.failures) != 2 {
+		t.Fatalf("failures = %+v, want 2 attempts", driver.failures)
+	}
+
+	f := driver.failures[0]
+	if f.Directory != "schema" || f.Version != 2 || f.FileName != "002_orders.sql" || f.Error == "" || f.FailedAt.IsZero() {
+		t.Errorf("failure = %+v, want schema version 2 with its error", f)
+	}
+
+	if len(driver.dirty) != 0 {
+		t.Errorf("dirty = %+v, want cleared by Force", driver.dirty)
+	}
+}
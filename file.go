@@ -1,9 +1,19 @@
 package muz
 
 import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"iter"
 	"os"
+	"path"
 	"path/filepath"
 	"slices"
 	"strconv"
@@ -17,44 +27,276 @@ type Muzo struct {
 	Files []FileInfo
 
 	fs fs.FS
+	// gos are the Go migrations of the directory by file path.
+	gos map[string]*GoMigration
+	// vars resolves the ${NAME} placeholders of the files, nil keeps them unchanged.
+	vars func(name string) (string, bool, error)
+	// autoNoTx runs files with statements needing it outside of the transaction, see Migrate.AutoNoTransaction.
+	autoNoTx bool
+	// noTx runs every file outside of the transaction, see TxModeNone.
+	noTx bool
+	// outOfOrder marks files applied below the latest applied version, see OutOfOrder.
+	outOfOrder bool
 }
 
 type FileInfo struct {
-	Path    string
-	Version int
+	Path string
+	// Version is the leading number of the file name, timestamps like 20240101120000 included.
+	Version int64
+
+	// Metadata is read from the header comments of the file.
+	Metadata
+}
+
+// Metadata describes a migration with header comments:
+//
+//	-- muz:description add the users table
+//	-- muz:author jane
+//	-- muz:ticket APP-123
+type Metadata struct {
+	Description string `json:"description,omitempty"`
+	Author      string `json:"author,omitempty"`
+	Ticket      string `json:"ticket,omitempty"`
+}
+
+// NewMuzo returns a migration directory reading its files from fsys.
+// File paths are relative to dir inside fsys.
+func NewMuzo(dir string, files []FileInfo, fsys fs.FS) *Muzo {
+	return &Muzo{
+		Dir:   cleanDir(dir),
+		Files: files,
+		fs:    fsys,
+	}
 }
 
+// OutOfOrder reports whether Files are below the latest applied version of the directory and applied by
+// OutOfOrderApply. Process applies them instead of skipping the versions up to the latest applied one.
+func (d *Muzo) OutOfOrder() bool {
+	return d.outOfOrder
+}
+
+// withFiles returns a copy of the directory limited to files.
+func (d *Muzo) withFiles(files []FileInfo) *Muzo {
+	return &Muzo{
+		Dir:      d.Dir,
+		Files:    files,
+		fs:       d.fs,
+		gos:      d.gos,
+		vars:     d.vars,
+		autoNoTx: d.autoNoTx,
+		noTx:     d.noTx,
+	}
+}
+
+// ReadFile returns the content of a migration file, .gz files are decompressed,
+// "-- muz:include" lines are replaced by the included files and ${NAME} placeholders by Migrate.Vars.
+// Go migrations have no content.
 func (d *Muzo) ReadFile(filePath string) ([]byte, error) {
-	return fs.ReadFile(d.fs, filepath.Join(d.Dir, filePath))
+	content, err := d.readSource(filePath)
+	if err != nil || d.vars == nil {
+		return content, err
+	}
+
+	return substitute(content, path.Join(d.Dir, filePath), d.vars)
+}
+
+// readSource returns the content of a migration file with its includes, before placeholders are replaced.
+// Goose files only have their Up section.
+func (d *Muzo) readSource(filePath string) ([]byte, error) {
+	if d.GoMigration(filePath) != nil {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := d.copySection(&buf, path.Join(d.Dir, filepath.ToSlash(filePath)), gooseUp); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// openSource streams the content of a migration file with its includes, before placeholders are replaced.
+// Goose files only have their Up section.
+func (d *Muzo) openSource(filePath string) io.ReadCloser {
+	r, w := io.Pipe()
+
+	go func() {
+		_, err := d.copySection(w, path.Join(d.Dir, filepath.ToSlash(filePath)), gooseUp)
+		w.CloseWithError(err)
+	}()
+
+	return r
 }
 
+// statements streams the statements of a migration file with its includes expanded and placeholders replaced.
+// Strings are read like PostgreSQL, whose statements it is used to check.
+func (d *Muzo) statements(filePath string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		for statement, err := range d.statementLines(filePath, true) {
+			if !yield(statement.text, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// statementLines is statements with the line of every statement, lines of included files counting
+// as lines of the including file. With standard strings are read like PostgreSQL, see splitState.
+func (d *Muzo) statementLines(filePath string, standard bool) iter.Seq2[lineStatement, error] {
+	return func(yield func(lineStatement, error) bool) {
+		src := d.openSource(filePath)
+		defer src.Close()
+
+		for statement, err := range splitLines(src, 64*1024, standard) {
+			if err == nil && d.vars != nil {
+				statement, err = d.substituteStatement(statement, path.Join(d.Dir, filePath))
+			}
+
+			if !yield(statement, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// substituteStatement replaces the placeholders of the text and raw text of statement,
+// keeping lead the number of bytes before the statement in its raw text.
+func (d *Muzo) substituteStatement(statement lineStatement, name string) (lineStatement, error) {
+	text, err := substitute([]byte(statement.text), name, d.vars)
+	if err != nil {
+		return statement, err
+	}
+
+	statement.text = string(text)
+
+	if statement.raw == "" {
+		return statement, nil
+	}
+
+	lead, err := substitute([]byte(statement.raw[:statement.lead]), name, d.vars)
+	if err != nil {
+		return statement, err
+	}
+
+	rest, err := substitute([]byte(statement.raw[statement.lead:]), name, d.vars)
+	if err != nil {
+		return statement, err
+	}
+
+	statement.raw, statement.lead = string(lead)+string(rest), len(lead)
+
+	return statement, nil
+}
+
+// readSmall returns the content of a migration file like ReadFile when it is at most size bytes,
+// and false without holding more than size bytes in memory otherwise.
+func (d *Muzo) readSmall(filePath string, size int) ([]byte, bool, error) {
+	src := d.openSource(filePath)
+	defer src.Close()
+
+	content, err := io.ReadAll(io.LimitReader(src, int64(size)+1))
+	if err != nil || len(content) > size {
+		return nil, false, err
+	}
+
+	if d.vars != nil {
+		content, err = substitute(content, path.Join(d.Dir, filePath), d.vars)
+	}
+
+	return content, err == nil, err
+}
+
+// checksum returns the checksum of a migration file, placeholder values are not part of it.
+func (d *Muzo) checksum(filePath string) (string, error) {
+	if d.GoMigration(filePath) != nil {
+		return checksum(nil), nil
+	}
+
+	h := sha256.New()
+	if err := d.copyPath(h, path.Join(d.Dir, filepath.ToSlash(filePath)), nil); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Open opens a migration file, reads from .gz files are decompressed.
 func (d *Muzo) Open(filePath string) (fs.File, error) {
-	return d.fs.Open(filepath.Join(d.Dir, filePath))
+	return d.openPath(path.Join(d.Dir, filepath.ToSlash(filePath)))
+}
+
+// openPath opens a file of the migration path, reads from .gz files are decompressed.
+func (d *Muzo) openPath(name string) (fs.File, error) {
+	f, err := d.fs.Open(name)
+	if err != nil || !isGzip(name) {
+		return f, err
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("decompressing %s: %w", name, err)
+	}
+
+	return &gzipFile{File: f, gz: gz}, nil
+}
+
+// gzipFile decompresses the wrapped file on read.
+// Stat reports the compressed file.
+type gzipFile struct {
+	fs.File
+
+	gz *gzip.Reader
+}
+
+func (f *gzipFile) Read(p []byte) (int, error) {
+	return f.gz.Read(p)
+}
+
+func (f *gzipFile) Close() error {
+	return errors.Join(f.gz.Close(), f.File.Close())
+}
+
+// isGzip reports whether the file is gzip compressed, based on its name, encrypted files included.
+func isGzip(name string) bool {
+	return strings.HasSuffix(strings.ToLower(trimEncrypted(name)), ".gz")
+}
+
+// isDownFile reports whether the file is a rollback file like "001_users.down.sql".
+func isDownFile(name string) bool {
+	name = strings.ToLower(trimEncoding(name))
+
+	return strings.HasSuffix(name, ".down") || strings.HasSuffix(strings.TrimSuffix(name, path.Ext(name)), ".down")
+}
+
+// trimEncoding removes the encryption and .gz suffixes so version and extension matching see the inner name.
+func trimEncoding(name string) string {
+	name = trimEncrypted(name)
+	if isGzip(name) {
+		return name[:len(name)-len(".gz")]
+	}
+
+	return name
 }
 
 // iterMigrationInfo returns an iterator over the migration files.
 // It yields slices of file paths grouped by directory, respecting Order and Skip settings.
 func (m *Migrate) iterMigrationInfo() iter.Seq2[*Muzo, error] {
 	return func(yield func(*Muzo, error) bool) {
-		path := m.Path
-		if path == "" {
-			path = "migrations"
+		if !m.Compat.valid() {
+			yield(nil, fmt.Errorf("unknown compat %q", m.Compat))
+			return
 		}
 
-		var fileSystem fs.FS
-		if m.FS != nil {
-			var err error
-			fileSystem, err = fs.Sub(m.FS, path)
-			if err != nil {
-				yield(nil, err)
-				return
-			}
-		} else {
-			fileSystem = os.DirFS(path)
+		fileSystem, closeFS, err := m.openFS(m.rootPath())
+		if err != nil {
+			yield(nil, err)
+			return
 		}
+		defer closeFS()
 
-		// Get all directories
-		dirs, err := m.getMigrationDirs(fileSystem)
+		// Walk the tree once, collecting the directories with their files
+		dirs, names, err := m.walkTree(fileSystem)
 		if err != nil {
 			yield(nil, err)
 			return
@@ -65,12 +307,15 @@ func (m *Migrate) iterMigrationInfo() iter.Seq2[*Muzo, error] {
 
 		// Iterate over each directory and yield migration files
 		for _, dir := range dirs {
-			files, err := m.getMigrationFiles(fileSystem, dir)
-			if err != nil {
-				if !yield(nil, err) {
+			files, unnumbered := m.selectFiles(dir, names[dir])
+			if hasOrder(names[dir]) {
+				// Unlisted files are reported by Validate.
+				if files, _, err = m.orderedFiles(fileSystem, dir, names[dir]); err != nil {
+					yield(nil, err)
 					return
 				}
-				continue
+			} else if m.IncludeUnnumbered {
+				files = appendUnnumbered(files, unnumbered)
 			}
 
 			if !yield(&Muzo{
@@ -84,38 +329,136 @@ func (m *Migrate) iterMigrationInfo() iter.Seq2[*Muzo, error] {
 	}
 }
 
-// getMigrationDirs returns all directories in the migration path, excluding skipped ones.
-func (m *Migrate) getMigrationDirs(fileSystem fs.FS) ([]string, error) {
-	var dirs []string
+// rootPath returns the migration path with its default applied.
+func (m *Migrate) rootPath() string {
+	if m.Path == "" && m.Compat == CompatDbmate {
+		return "db/migrations"
+	}
+
+	if m.Path == "" {
+		return "migrations"
+	}
+
+	return m.Path
+}
+
+// openFS returns the filesystem rooted at the migration path.
+// Paths pointing to a .zip file, optionally followed by a directory inside
+// the archive like "bundle.zip/migrations", are opened as a zip archive.
+func (m *Migrate) openFS(path string) (fs.FS, func() error, error) {
+	nop := func() error { return nil }
+
+	if m.held != nil && path == m.rootPath() {
+		return m.held, nop, nil
+	}
+
+	archive, inner, isZip := splitZipPath(path)
+	if !isZip {
+		if m.FS != nil {
+			fileSystem, err := fs.Sub(m.FS, path)
+			return fileSystem, nop, err
+		}
+
+		return os.DirFS(path), nop, nil
+	}
+
+	var (
+		fileSystem fs.FS
+		closeFS    = nop
+	)
+
+	if m.FS != nil {
+		data, err := fs.ReadFile(m.FS, archive)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening zip %s: %w", archive, err)
+		}
 
-	err := fs.WalkDir(fileSystem, ".", func(path string, d fs.DirEntry, err error) error {
+		fileSystem = zr
+	} else {
+		zr, err := zip.OpenReader(archive)
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening zip %s: %w", archive, err)
+		}
+
+		fileSystem, closeFS = zr, zr.Close
+	}
+
+	if inner != "" {
+		sub, err := fs.Sub(fileSystem, inner)
+		if err != nil {
+			closeFS()
+			return nil, nil, err
+		}
+
+		fileSystem = sub
+	}
+
+	return fileSystem, closeFS, nil
+}
+
+// splitZipPath splits "bundle.zip/inner/dir" into the archive and the directory inside it.
+func splitZipPath(path string) (string, string, bool) {
+	lower := strings.ToLower(filepath.ToSlash(path))
+
+	if strings.HasSuffix(lower, ".zip") {
+		return path, "", true
+	}
+
+	if i := strings.Index(lower, ".zip/"); i >= 0 {
+		return path[:i+len(".zip")], strings.Trim(filepath.ToSlash(path[i+len(".zip/"):]), "/"), true
+	}
+
+	return "", "", false
+}
+
+// walkTree walks the migration path once and returns the directories not skipped, unsorted,
+// with the names of the files directly inside each of them. Skipped subtrees are not descended into.
+func (m *Migrate) walkTree(fileSystem fs.FS) ([]string, map[string][]string, error) {
+	var (
+		dirs  []string
+		names = make(map[string][]string)
+	)
+
+	err := fs.WalkDir(fileSystem, ".", func(name string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
 		if !d.IsDir() {
+			// Only the files of the listed directories are kept
+			if parent := path.Dir(name); names[parent] != nil {
+				names[parent] = append(names[parent], d.Name())
+			}
+
 			return nil
 		}
 
 		// Check if this entire directory subtree should be skipped
-		if m.shouldSkipDir(path) {
+		if m.shouldSkipDir(name) || m.hidden(name) || m.tooDeep(name) {
+			m.log().Debug("skipping migration directory", "directory", name)
 			return fs.SkipDir
 		}
 
 		// Check if this specific directory matches a skip pattern
 		// (but we still need to walk into it for potential child matches)
-		if !m.shouldSkip(path) {
-			dirs = append(dirs, path)
+		if !m.shouldSkip(name) {
+			dirs = append(dirs, name)
+			names[name] = []string{}
 		}
 
 		return nil
 	})
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return dirs, nil
+	return dirs, names, nil
 }
 
 // sortDirs sorts directories according to the Order preference.
@@ -126,10 +469,10 @@ func (m *Migrate) sortDirs(dirs []string) []string {
 		return dirs
 	}
 
-	// Create a map for quick lookup of order priority
+	// Create a map for quick lookup of order priority, with names in the form of Muzo.Dir
 	orderMap := make(map[string]int)
 	for i, o := range m.Order {
-		orderMap[strings.TrimPrefix(o, "/")] = i
+		orderMap[cleanDir(o)] = i
 	}
 
 	slices.SortFunc(dirs, func(a, b string) int {
@@ -151,33 +494,84 @@ func (m *Migrate) sortDirs(dirs []string) []string {
 	return dirs
 }
 
-// getMigrationFiles returns all files in the given directory, sorted alphabetically.
-func (m *Migrate) getMigrationFiles(fileSystem fs.FS, dir string) ([]FileInfo, error) {
+// appendUnnumbered appends the files without numeric prefix after the sorted numbered files,
+// in alphabetical order with the versions following the highest numbered one.
+func appendUnnumbered(files []FileInfo, names []string) []FileInfo {
+	var version int64
+	if len(files) > 0 {
+		version = files[len(files)-1].Version
+	}
+
+	slices.Sort(names)
+	for _, name := range names {
+		version++
+		files = append(files, FileInfo{Path: name, Version: version})
+	}
+
+	return files
+}
+
+// listFiles returns the numbered migration files of dir, sorted, and the names
+// of candidate files that were dropped because they have no numeric prefix.
+func (m *Migrate) listFiles(fileSystem fs.FS, dir string) ([]FileInfo, []string, error) {
 	entries, err := fs.ReadDir(fileSystem, dir)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	var files []FileInfo
+	names := make([]string, 0, len(entries))
 	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
 		}
+	}
+
+	files, unnumbered := m.selectFiles(dir, names)
 
-		name := entry.Name()
+	return files, unnumbered, nil
+}
+
+// selectFiles returns the numbered migration files among the file names of dir, sorted, and the names
+// of candidate files that were dropped because they have no numeric prefix.
+func (m *Migrate) selectFiles(dir string, names []string) ([]FileInfo, []string) {
+	var (
+		files      []FileInfo
+		unnumbered []string
+	)
 
+	for _, name := range names {
 		// Build the full path for skip pattern matching
 		fullPath := name
 		if dir != "." {
-			fullPath = filepath.Join(dir, name)
+			fullPath = path.Join(dir, name)
 		}
 
 		// Check if this file should be skipped
-		if m.shouldSkip(fullPath) {
+		if m.shouldSkip(fullPath) || m.skipInDir(dir, name) || m.hidden(name) {
+			m.log().Debug("skipping migration file", "file", fullPath)
+			continue
+		}
+
+		if !m.matchesExtension(dir, name) {
+			continue
+		}
+
+		// Rollback files are not forward migrations
+		if isDownFile(name) {
+			continue
+		}
+
+		if !m.forwardFile(name) {
+			m.log().Debug("skipping migration file without up suffix", "file", fullPath, "compat", m.Compat)
+			continue
+		}
+
+		// Callback files of the root are run around the migrations
+		if dir == "." && isCallback(name) {
 			continue
 		}
 
-		if m.Extension != "" && !strings.HasSuffix(strings.ToLower(name), strings.ToLower(m.Extension)) {
+		if name == tagsFile || name == orderFile || dir == "." && (name == lockfileName || name == withDefault(m.SignatureFile, signatureFile)) {
 			continue
 		}
 
@@ -187,12 +581,14 @@ func (m *Migrate) getMigrationFiles(fileSystem fs.FS, dir string) ([]FileInfo, e
 				Path:    name,
 				Version: n,
 			})
+		} else {
+			unnumbered = append(unnumbered, name)
 		}
 	}
 
 	sortMigrationFiles(files)
 
-	return files, nil
+	return files, unnumbered
 }
 
 // sortMigrationFiles sorts files by their leading number prefix, then alphabetically.
@@ -200,20 +596,24 @@ func (m *Migrate) getMigrationFiles(fileSystem fs.FS, dir string) ([]FileInfo, e
 // If no leading number exists, it defaults to 1.
 func sortMigrationFiles(files []FileInfo) {
 	slices.SortFunc(files, func(a, b FileInfo) int {
-		aNum, aName := extractLeadingNumber(filepath.Base(a.Path))
-		bNum, bName := extractLeadingNumber(filepath.Base(b.Path))
+		aNum, aName := extractLeadingNumber(path.Base(a.Path))
+		bNum, bName := extractLeadingNumber(path.Base(b.Path))
 
-		if aNum != bNum {
-			return aNum - bNum
+		if c := cmp.Compare(aNum, bNum); c != 0 {
+			return c
 		}
 		return strings.Compare(aName, bName)
 	})
 }
 
+// timestampVersion is the smallest 14 digit version,
+// versions from it on are timestamps like 20240101120000 generated with date +%Y%m%d%H%M%S.
+const timestampVersion = 10_000_000_000_000
+
 // extractLeadingNumber extracts the leading number from a filename.
 // Returns the number and the original filename for secondary sorting.
 // If no leading number exists, returns 0 (for filtering out).
-func extractLeadingNumber(filename string) (int, string) {
+func extractLeadingNumber(filename string) (int64, string) {
 	var numStr string
 	for _, r := range filename {
 		if r >= '0' && r <= '9' {
@@ -227,7 +627,7 @@ func extractLeadingNumber(filename string) (int, string) {
 		return 0, filename
 	}
 
-	num, err := strconv.Atoi(numStr)
+	num, err := strconv.ParseInt(numStr, 10, 64)
 	if err != nil {
 		return 0, filename
 	}
@@ -242,14 +642,28 @@ func extractLeadingNumber(filename string) (int, string) {
 //   - **/*.sql matches all .sql files in any directory
 func (m *Migrate) shouldSkip(path string) bool {
 	for _, skip := range m.Skip {
-		pattern := strings.TrimPrefix(skip, "/")
-		if matched, _ := doublestar.Match(pattern, path); matched {
+		if skipMatch(skip, path) {
 			return true
 		}
 	}
 	return false
 }
 
+// hidden reports whether path is a hidden file or directory skipped by SkipHidden.
+// The migration path itself is never hidden.
+func (m *Migrate) hidden(name string) bool {
+	if m.SkipHidden != nil && !*m.SkipHidden {
+		return false
+	}
+
+	return name != "." && strings.HasPrefix(path.Base(name), ".")
+}
+
+// tooDeep reports whether the directory path is below MaxDepth.
+func (m *Migrate) tooDeep(path string) bool {
+	return m.MaxDepth > 0 && path != "." && strings.Count(path, "/")+1 > m.MaxDepth
+}
+
 // shouldSkipDir checks if a directory should be skipped entirely (including all children).
 // This is used during directory walking to skip entire subtrees.
 // A directory is fully skipped if:
@@ -257,21 +671,48 @@ func (m *Migrate) shouldSkip(path string) bool {
 //   - The pattern doesn't contain wildcards in a way that could match children differently
 func (m *Migrate) shouldSkipDir(path string) bool {
 	for _, skip := range m.Skip {
-		pattern := strings.TrimPrefix(skip, "/")
+		if skipDirMatch(skip, path) {
+			return true
+		}
+	}
+	return false
+}
 
-		// Check for exact directory match (original behavior for backward compatibility)
-		if pattern == path {
+// skipPattern returns skip relative to the migration path, with forward slashes like the walked paths.
+func skipPattern(skip string) string {
+	return strings.TrimPrefix(filepath.ToSlash(skip), "/")
+}
+
+// skipMatch reports whether a single skip pattern matches the path.
+func skipMatch(skip, path string) bool {
+	pattern := skipPattern(skip)
+	matched, _ := doublestar.Match(pattern, path)
+
+	return matched
+}
+
+// skipDirMatch reports whether a single skip pattern skips the whole directory subtree.
+func skipDirMatch(skip, path string) bool {
+	pattern := skipPattern(skip)
+
+	// Check for exact directory match (original behavior for backward compatibility)
+	if pattern == path {
+		return true
+	}
+
+	// Check for recursive glob pattern like "test/**"
+	// If pattern is "dir/**", we can skip the entire dir
+	if strings.HasSuffix(pattern, "/**") {
+		basePattern := strings.TrimSuffix(pattern, "/**")
+		if path == basePattern || strings.HasPrefix(path, basePattern+"/") {
 			return true
 		}
 
-		// Check for recursive glob pattern like "test/**"
-		// If pattern is "dir/**", we can skip the entire dir
-		if strings.HasSuffix(pattern, "/**") {
-			basePattern := strings.TrimSuffix(pattern, "/**")
-			if path == basePattern || strings.HasPrefix(path, basePattern+"/") {
-				return true
-			}
+		// Everything below a directory matching the base, like "**/testdata/**", matches the pattern
+		if matched, _ := doublestar.Match(basePattern, path); matched {
+			return true
 		}
 	}
+
 	return false
 }
@@ -1,6 +1,10 @@
 package muz
 
 import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"slices"
@@ -77,6 +81,31 @@ func TestIterMigrationInfo(t *testing.T) {
 				{Dir: "beta", Files: []FileInfo{{Path: "001_migration.sql", Version: 1}}},
 			},
 		},
+		{
+			name: "order and skip with os paths",
+			setup: func(t *testing.T, tempDir string) {
+				for _, d := range []string{filepath.Join("alpha", "nested"), "beta"} {
+					dir := filepath.Join(tempDir, d)
+					mustMkdir(t, dir)
+					mustCreateFile(t, filepath.Join(dir, "001_migration.sql"))
+					mustCreateFile(t, filepath.Join(dir, "002_seed.sql"))
+				}
+			},
+			migrate: func(tempDir string) *Migrate {
+				// Built with filepath, like a config written on Windows.
+				return &Migrate{
+					Path:  tempDir,
+					Order: []string{"/beta/", "." + string(filepath.Separator) + filepath.Join("alpha", "nested")},
+					Skip:  []string{string(filepath.Separator) + filepath.Join("alpha", "nested", "002_*.sql")},
+				}
+			},
+			want: []Muzo{
+				{Dir: "beta", Files: []FileInfo{{Path: "001_migration.sql", Version: 1}, {Path: "002_seed.sql", Version: 2}}},
+				{Dir: "alpha/nested", Files: []FileInfo{{Path: "001_migration.sql", Version: 1}}},
+				{Dir: ".", Files: []FileInfo{}},
+				{Dir: "alpha", Files: []FileInfo{}},
+			},
+		},
 		{
 			name: "skip directories",
 			setup: func(t *testing.T, tempDir string) {
@@ -136,6 +165,28 @@ func TestIterMigrationInfo(t *testing.T) {
 				{Dir: "migrations", Files: []FileInfo{{Path: "001_valid.sql", Version: 1}}},
 			},
 		},
+		{
+			name: "files without leading number are appended with IncludeUnnumbered",
+			setup: func(t *testing.T, tempDir string) {
+				dir := filepath.Join(tempDir, "migrations")
+				mustMkdir(t, dir)
+				mustCreateFile(t, filepath.Join(dir, "views.sql"))
+				mustCreateFile(t, filepath.Join(dir, "2_second.sql"))
+				mustCreateFile(t, filepath.Join(dir, "grants.sql"))
+				mustCreateFile(t, filepath.Join(dir, "readme.txt"))
+			},
+			migrate: func(tempDir string) *Migrate {
+				return &Migrate{Path: tempDir, Extension: ".sql", IncludeUnnumbered: true}
+			},
+			want: []Muzo{
+				{Dir: ".", Files: []FileInfo{}},
+				{Dir: "migrations", Files: []FileInfo{
+					{Path: "2_second.sql", Version: 2},
+					{Path: "grants.sql", Version: 3},
+					{Path: "views.sql", Version: 4},
+				}},
+			},
+		},
 		{
 			name: "files sorted by leading number",
 			setup: func(t *testing.T, tempDir string) {
@@ -153,6 +204,27 @@ func TestIterMigrationInfo(t *testing.T) {
 				{Dir: "migrations", Files: []FileInfo{{Path: "1_first.sql", Version: 1}, {Path: "2_second.sql", Version: 2}, {Path: "10_tenth.sql", Version: 10}}},
 			},
 		},
+		{
+			name: "timestamp versions sorted after sequential ones",
+			setup: func(t *testing.T, tempDir string) {
+				dir := filepath.Join(tempDir, "migrations")
+				mustMkdir(t, dir)
+				mustCreateFile(t, filepath.Join(dir, "20240315093000_add_orders.sql"))
+				mustCreateFile(t, filepath.Join(dir, "20240101120000_add_users.sql"))
+				mustCreateFile(t, filepath.Join(dir, "3_legacy.sql"))
+			},
+			migrate: func(tempDir string) *Migrate {
+				return &Migrate{Path: tempDir}
+			},
+			want: []Muzo{
+				{Dir: ".", Files: []FileInfo{}},
+				{Dir: "migrations", Files: []FileInfo{
+					{Path: "3_legacy.sql", Version: 3},
+					{Path: "20240101120000_add_users.sql", Version: 20240101120000},
+					{Path: "20240315093000_add_orders.sql", Version: 20240315093000},
+				}},
+			},
+		},
 		{
 			name: "nested directories",
 			setup: func(t *testing.T, tempDir string) {
@@ -172,6 +244,52 @@ func TestIterMigrationInfo(t *testing.T) {
 				{Dir: "parent/child", Files: []FileInfo{{Path: "001_child.sql", Version: 1}}},
 			},
 		},
+		{
+			name: "hidden files and directories are skipped",
+			setup: func(t *testing.T, tempDir string) {
+				mustMkdir(t, filepath.Join(tempDir, ".git", "objects", "12"))
+				mustCreateFile(t, filepath.Join(tempDir, ".git", "objects", "12", "34abcd"))
+				mustCreateFile(t, filepath.Join(tempDir, ".1_draft.sql"))
+				mustCreateFile(t, filepath.Join(tempDir, "1_init.sql"))
+			},
+			migrate: func(tempDir string) *Migrate {
+				return &Migrate{Path: tempDir}
+			},
+			want: []Muzo{
+				{Dir: ".", Files: []FileInfo{{Path: "1_init.sql", Version: 1}}},
+			},
+		},
+		{
+			name: "hidden files are kept with SkipHidden false",
+			setup: func(t *testing.T, tempDir string) {
+				mustMkdir(t, filepath.Join(tempDir, ".hidden"))
+				mustCreateFile(t, filepath.Join(tempDir, ".hidden", "1_init.sql"))
+			},
+			migrate: func(tempDir string) *Migrate {
+				skip := false
+				return &Migrate{Path: tempDir, SkipHidden: &skip}
+			},
+			want: []Muzo{
+				{Dir: ".", Files: []FileInfo{}},
+				{Dir: ".hidden", Files: []FileInfo{{Path: "1_init.sql", Version: 1}}},
+			},
+		},
+		{
+			name: "directories below MaxDepth are not walked",
+			setup: func(t *testing.T, tempDir string) {
+				mustMkdir(t, filepath.Join(tempDir, "schema", "nested"))
+				mustCreateFile(t, filepath.Join(tempDir, "1_root.sql"))
+				mustCreateFile(t, filepath.Join(tempDir, "schema", "1_tables.sql"))
+				mustCreateFile(t, filepath.Join(tempDir, "schema", "nested", "1_deep.sql"))
+			},
+			migrate: func(tempDir string) *Migrate {
+				return &Migrate{Path: tempDir, MaxDepth: 1}
+			},
+			want: []Muzo{
+				{Dir: ".", Files: []FileInfo{{Path: "1_root.sql", Version: 1}}},
+				{Dir: "schema", Files: []FileInfo{{Path: "1_tables.sql", Version: 1}}},
+			},
+		},
 		{
 			name: "empty directory returns no files",
 			setup: func(t *testing.T, tempDir string) {
@@ -265,6 +383,27 @@ func TestIterMigrationInfo(t *testing.T) {
 				{Dir: "keep", Files: []FileInfo{{Path: "001_keep.sql", Version: 1}}},
 			},
 		},
+		{
+			name: "skip nested subtrees with recursive glob pattern **/dir/**",
+			setup: func(t *testing.T, tempDir string) {
+				users := filepath.Join(tempDir, "users")
+				fixtures := filepath.Join(users, "testdata", "fixtures")
+				mustMkdir(t, fixtures)
+				mustCreateFile(t, filepath.Join(users, "001_users.sql"))
+				mustCreateFile(t, filepath.Join(users, "testdata", "001_test.sql"))
+				mustCreateFile(t, filepath.Join(fixtures, "001_fixture.sql"))
+			},
+			migrate: func(tempDir string) *Migrate {
+				return &Migrate{
+					Path: tempDir,
+					Skip: []string{"**/testdata/**"},
+				}
+			},
+			want: []Muzo{
+				{Dir: ".", Files: []FileInfo{}},
+				{Dir: "users", Files: []FileInfo{{Path: "001_users.sql", Version: 1}}},
+			},
+		},
 		{
 			name: "skip with single level glob pattern /*",
 			setup: func(t *testing.T, tempDir string) {
@@ -371,6 +510,81 @@ func TestIterMigrationInfo(t *testing.T) {
 				{Dir: "keep", Files: []FileInfo{{Path: "001_keep.sql", Version: 1}}},
 			},
 		},
+		{
+			name: "zip archive with order and skip",
+			setup: func(t *testing.T, tempDir string) {
+				mustWriteFile(t, filepath.Join(tempDir, "bundle.zip"), buildZip(t, map[string]string{
+					"alpha/001_alpha.sql": "",
+					"beta/001_beta.sql":   "",
+					"beta/002_skip.bak":   "",
+					"test/001_test.sql":   "",
+				}))
+			},
+			migrate: func(tempDir string) *Migrate {
+				return &Migrate{
+					Path:  filepath.Join(tempDir, "bundle.zip"),
+					Order: []string{"beta"},
+					Skip:  []string{"/test/**", "**/*.bak"},
+				}
+			},
+			want: []Muzo{
+				{Dir: "beta", Files: []FileInfo{{Path: "001_beta.sql", Version: 1}}},
+				{Dir: ".", Files: []FileInfo{}},
+				{Dir: "alpha", Files: []FileInfo{{Path: "001_alpha.sql", Version: 1}}},
+			},
+		},
+		{
+			name: "directory inside zip archive",
+			setup: func(t *testing.T, tempDir string) {
+				mustWriteFile(t, filepath.Join(tempDir, "bundle.zip"), buildZip(t, map[string]string{
+					"migrations/001_root.sql":        "",
+					"migrations/inner/002_inner.sql": "",
+					"other/001_other.sql":            "",
+				}))
+			},
+			migrate: func(tempDir string) *Migrate {
+				return &Migrate{Path: filepath.Join(tempDir, "bundle.zip", "migrations")}
+			},
+			want: []Muzo{
+				{Dir: ".", Files: []FileInfo{{Path: "001_root.sql", Version: 1}}},
+				{Dir: "inner", Files: []FileInfo{{Path: "002_inner.sql", Version: 2}}},
+			},
+		},
+		{
+			name: "gzip files match the inner extension",
+			setup: func(t *testing.T, tempDir string) {
+				dir := filepath.Join(tempDir, "seed")
+				mustMkdir(t, dir)
+				mustCreateFile(t, filepath.Join(dir, "001_users.sql.gz"))
+				mustCreateFile(t, filepath.Join(dir, "002_plain.sql"))
+				mustCreateFile(t, filepath.Join(dir, "003_other.txt.gz"))
+			},
+			migrate: func(tempDir string) *Migrate {
+				return &Migrate{
+					Path:      tempDir,
+					Extension: ".sql",
+				}
+			},
+			want: []Muzo{
+				{Dir: ".", Files: []FileInfo{}},
+				{Dir: "seed", Files: []FileInfo{{Path: "001_users.sql.gz", Version: 1}, {Path: "002_plain.sql", Version: 2}}},
+			},
+		},
+		{
+			name: "rollback files are not forward migrations",
+			setup: func(t *testing.T, tempDir string) {
+				mustCreateFile(t, filepath.Join(tempDir, "001_users.sql"))
+				mustCreateFile(t, filepath.Join(tempDir, "001_users.down.sql"))
+				mustCreateFile(t, filepath.Join(tempDir, "002_seed.sql.gz"))
+				mustCreateFile(t, filepath.Join(tempDir, "002_seed.down.sql.gz"))
+			},
+			migrate: func(tempDir string) *Migrate {
+				return &Migrate{Path: tempDir}
+			},
+			want: []Muzo{
+				{Dir: ".", Files: []FileInfo{{Path: "001_users.sql", Version: 1}, {Path: "002_seed.sql.gz", Version: 2}}},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -422,6 +636,93 @@ func TestIterMigrationInfo(t *testing.T) {
 	}
 }
 
+func TestMuzoReadFileGzip(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte("INSERT INTO users VALUES (1);"))
+	gz.Close()
+
+	fsys := MapFS(map[string]string{
+		"seed/001_users.sql.gz": buf.String(),
+		"seed/002_plain.sql":    "SELECT 1;",
+	})
+
+	d := NewMuzo("seed", nil, fsys)
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{file: "001_users.sql.gz", want: "INSERT INTO users VALUES (1);"},
+		{file: "002_plain.sql", want: "SELECT 1;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			content, err := d.ReadFile(tt.file)
+			if err != nil {
+				t.Fatalf("ReadFile error: %v", err)
+			}
+			if string(content) != tt.want {
+				t.Errorf("ReadFile = %q, want %q", content, tt.want)
+			}
+
+			f, err := d.Open(tt.file)
+			if err != nil {
+				t.Fatalf("Open error: %v", err)
+			}
+			defer f.Close()
+
+			content, err = io.ReadAll(f)
+			if err != nil {
+				t.Fatalf("read error: %v", err)
+			}
+			if string(content) != tt.want {
+				t.Errorf("Open content = %q, want %q", content, tt.want)
+			}
+		})
+	}
+}
+
+// benchmarkTree creates dirs directories holding files migration files each, and a vendor tree of the same size.
+func benchmarkTree(b *testing.B, dirs, files int) string {
+	b.Helper()
+
+	root := b.TempDir()
+	for _, base := range []string{"services", "vendor"} {
+		for d := range dirs {
+			dir := filepath.Join(root, base, fmt.Sprintf("svc%04d", d))
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				b.Fatal(err)
+			}
+
+			for f := range files {
+				if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%04d_change.sql", f+1)), nil, 0644); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+
+	return root
+}
+
+func BenchmarkIterMigrationInfo(b *testing.B) {
+	for _, size := range []struct{ dirs, files int }{{100, 100}, {1000, 20}, {10, 2000}} {
+		b.Run(fmt.Sprintf("%dx%d", size.dirs, size.files), func(b *testing.B) {
+			m := &Migrate{Path: benchmarkTree(b, size.dirs, size.files), Skip: []string{"vendor/**"}}
+
+			for b.Loop() {
+				for _, err := range m.iterMigrationInfo() {
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
 func mustMkdir(t *testing.T, path string) {
 	t.Helper()
 	if err := os.MkdirAll(path, 0755); err != nil {
@@ -437,3 +738,10 @@ func mustCreateFile(t *testing.T, path string) {
 	}
 	f.Close()
 }
+
+func mustWriteFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write file %s: %v", path, err)
+	}
+}
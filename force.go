@@ -0,0 +1,215 @@
+package muz
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Recorder is implemented by drivers that can change the tracking table without running migrations.
+// Record and Unrecord are called between Start and End.
+type Recorder interface {
+	// Record marks the file of dir as applied, replacing an existing record of the same version.
+	Record(ctx context.Context, dir string, file FileInfo) error
+	// Unrecord removes the record of version in dir.
+	Unrecord(ctx context.Context, dir string, version int64) error
+}
+
+// Force sets the applied version of dir without executing any migration.
+// Records above version are removed and files up to version are recorded as applied,
+// a version of 0 removes all records of the directory. A failed migration of the directory
+// recorded by a DirtyStore is cleared.
+// The driver must implement Recorder and StatusReporter.
+func (m Migrate) Force(ctx context.Context, driver Driver, dir string, version int64) error {
+	dir = cleanDir(dir)
+
+	var files []FileInfo
+	for info, err := range m.dirs() {
+		if err != nil {
+			return err
+		}
+
+		if info.Dir == dir {
+			files = info.Files
+			break
+		}
+	}
+
+	if version > 0 && !slices.ContainsFunc(files, func(f FileInfo) bool { return f.Version == version }) {
+		return fmt.Errorf("force: no migration with version %d in %s", version, dir)
+	}
+
+	return m.record(ctx, driver, func(rec Recorder, records []Record) error {
+		recorded := make(map[int64]bool)
+		for _, r := range records {
+			if r.Directory != dir {
+				continue
+			}
+
+			if r.Version > version {
+				if err := rec.Unrecord(ctx, dir, r.Version); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			recorded[r.Version] = true
+		}
+
+		for _, file := range files {
+			if file.Version > version || recorded[file.Version] {
+				continue
+			}
+
+			if err := rec.Record(ctx, dir, file); err != nil {
+				return err
+			}
+
+			recorded[file.Version] = true
+		}
+
+		return clearDirty(ctx, driver, func(d string) bool { return d == dir })
+	})
+}
+
+// Baseline records every migration file with a version up to version as applied,
+// in all directories, without executing them. Existing records are kept.
+// It is meant for adopting muz on a database whose schema already exists.
+// The driver must implement Recorder and StatusReporter.
+func (m Migrate) Baseline(ctx context.Context, driver Driver, version int64) error {
+	if version <= 0 {
+		return fmt.Errorf("baseline: invalid version %d", version)
+	}
+
+	return m.record(ctx, driver, func(rec Recorder, records []Record) error {
+		type key struct {
+			dir     string
+			version int64
+		}
+
+		recorded := make(map[key]bool, len(records))
+		for _, r := range records {
+			recorded[key{r.Directory, r.Version}] = true
+		}
+
+		for info, err := range m.dirs() {
+			if err != nil {
+				return err
+			}
+
+			for _, file := range info.Files {
+				k := key{info.Dir, file.Version}
+				if file.Version > version || recorded[k] {
+					continue
+				}
+
+				if err := rec.Record(ctx, info.Dir, file); err != nil {
+					return err
+				}
+
+				recorded[k] = true
+			}
+		}
+
+		return nil
+	})
+}
+
+// MarkApplied records the named files of dir as applied without executing them,
+// reconciling the history after a change was applied by hand.
+// The driver must implement Recorder and StatusReporter.
+func (m Migrate) MarkApplied(ctx context.Context, driver Driver, dir string, files ...string) error {
+	dir = cleanDir(dir)
+
+	var selected []FileInfo
+	for info, err := range m.dirs() {
+		if err != nil {
+			return err
+		}
+
+		if info.Dir != dir {
+			continue
+		}
+
+		for _, name := range files {
+			i := slices.IndexFunc(info.Files, func(f FileInfo) bool { return f.Path == name })
+			if i < 0 {
+				return fmt.Errorf("mark applied: no migration %s in %s", name, dir)
+			}
+
+			selected = append(selected, info.Files[i])
+		}
+
+		break
+	}
+
+	if len(selected) != len(files) {
+		return fmt.Errorf("mark applied: no migration directory %s", dir)
+	}
+
+	return m.record(ctx, driver, func(rec Recorder, _ []Record) error {
+		for _, file := range selected {
+			if err := rec.Record(ctx, dir, file); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Repair removes the records of migrations whose file does not exist anymore
+// and the failed migrations recorded by a DirtyStore. It returns the removed records.
+// The driver must implement Recorder and StatusReporter.
+func (m Migrate) Repair(ctx context.Context, driver Driver) ([]FileStatus, error) {
+	var removed []FileStatus
+
+	err := m.record(ctx, driver, func(rec Recorder, _ []Record) error {
+		status, err := m.Status(ctx, driver)
+		if err != nil {
+			return err
+		}
+
+		for _, f := range status.Files {
+			if f.State != StateMissing {
+				continue
+			}
+
+			if err := rec.Unrecord(ctx, f.Dir, f.Version); err != nil {
+				return err
+			}
+
+			removed = append(removed, f)
+		}
+
+		return clearDirty(ctx, driver, func(string) bool { return true })
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return removed, nil
+}
+
+// record runs fn between Start and End of the driver with the current records.
+func (m Migrate) record(ctx context.Context, driver Driver, fn func(rec Recorder, records []Record) error) error {
+	rec, ok := driver.(Recorder)
+	if !ok {
+		return fmt.Errorf("record: %w", ErrNotSupported)
+	}
+
+	reporter, ok := driver.(StatusReporter)
+	if !ok {
+		return fmt.Errorf("record: %w", ErrNotSupported)
+	}
+
+	return session(ctx, driver, m.log(), func() error {
+		records, err := reporter.History(ctx)
+		if err != nil {
+			return err
+		}
+
+		return fn(rec, records)
+	})
+}
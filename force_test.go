@@ -0,0 +1,223 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// recordDriver keeps the tracking records in memory.
+type recordDriver struct {
+	nopDriver
+
+	records []Record
+	endErr  error
+}
+
+func (d *recordDriver) History(context.Context) ([]Record, error) {
+	return slices.Clone(d.records), nil
+}
+
+// Process records the files above the latest version of the directory, like the SQL drivers.
+func (d *recordDriver) Process(_ context.Context, data *Muzo) error {
+	latest := slices.Max(append(d.versions(data.Dir), int64(0)))
+
+	for _, file := range data.Files {
+		if file.Version > latest || data.OutOfOrder() {
+			d.records = append(d.records, Record{Version: file.Version, Directory: data.Dir, FileName: file.Path})
+		}
+	}
+
+	return nil
+}
+
+func (d *recordDriver) End(_ context.Context, err error) error {
+	d.endErr = err
+	return nil
+}
+
+func (d *recordDriver) Record(_ context.Context, dir string, file FileInfo) error {
+	d.records = append(d.records, Record{Version: file.Version, Directory: dir, FileName: file.Path})
+	return nil
+}
+
+func (d *recordDriver) Unrecord(_ context.Context, dir string, version int64) error {
+	d.records = slices.DeleteFunc(d.records, func(r Record) bool {
+		return r.Directory == dir && r.Version == version
+	})
+	return nil
+}
+
+func (d *recordDriver) versions(dir string) []int64 {
+	var versions []int64
+	for _, r := range d.records {
+		if r.Directory == dir {
+			versions = append(versions, r.Version)
+		}
+	}
+
+	slices.Sort(versions)
+
+	return versions
+}
+
+func TestMigrateForce(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql":  "CREATE TABLE users();",
+			"migrations/schema/002_orders.sql": "CREATE TABLE orders();",
+			"migrations/schema/003_items.sql":  "CREATE TABLE items();",
+		}),
+	}
+
+	tests := []struct {
+		name      string
+		records   []Record
+		version   int64
+		want      []int64
+		wantError bool
+	}{
+		{
+			name:    "mark applied",
+			version: 2,
+			want:    []int64{1, 2},
+		},
+		{
+			name: "remove newer records",
+			records: []Record{
+				{Version: 1, Directory: "schema"},
+				{Version: 2, Directory: "schema"},
+				{Version: 3, Directory: "schema"},
+			},
+			version: 1,
+			want:    []int64{1},
+		},
+		{
+			name: "zero clears directory",
+			records: []Record{
+				{Version: 1, Directory: "schema"},
+				{Version: 2, Directory: "schema"},
+			},
+			version: 0,
+			want:    nil,
+		},
+		{
+			name:      "unknown version",
+			version:   7,
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver := &recordDriver{records: slices.Clone(tt.records)}
+
+			err := m.Force(context.Background(), driver, "/schema", tt.version)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("Force() error = %v, wantError %v", err, tt.wantError)
+			}
+
+			if got := driver.versions("schema"); !tt.wantError && !slices.Equal(got, tt.want) {
+				t.Errorf("Force() versions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrateRepair(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql": "CREATE TABLE users();",
+		}),
+	}
+
+	driver := &recordDriver{records: []Record{
+		{Version: 1, Directory: "schema", FileName: "001_users.sql"},
+		{Version: 2, Directory: "schema", FileName: "002_deleted.sql"},
+		{Version: 1, Directory: "gone", FileName: "001_old.sql"},
+	}}
+
+	removed, err := m.Repair(context.Background(), driver)
+	if err != nil {
+		t.Fatalf("Repair() error = %v", err)
+	}
+
+	if len(removed) != 2 {
+		t.Errorf("Repair() removed = %v, want 2 records", removed)
+	}
+
+	if got := driver.versions("schema"); !slices.Equal(got, []int64{1}) {
+		t.Errorf("Repair() schema versions = %v, want [1]", got)
+	}
+
+	if got := driver.versions("gone"); len(got) != 0 {
+		t.Errorf("Repair() gone versions = %v, want none", got)
+	}
+}
+
+func TestMigrateBaseline(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql":  "CREATE TABLE users();",
+			"migrations/schema/002_orders.sql": "CREATE TABLE orders();",
+			"migrations/schema/003_items.sql":  "CREATE TABLE items();",
+			"migrations/data/001_seed.sql":     "INSERT INTO users DEFAULT VALUES;",
+		}),
+	}
+
+	driver := &recordDriver{records: []Record{
+		{Version: 1, Directory: "schema", FileName: "001_users.sql"},
+	}}
+
+	if err := m.Baseline(context.Background(), driver, 2); err != nil {
+		t.Fatalf("Baseline() error = %v", err)
+	}
+
+	if got := driver.versions("schema"); !slices.Equal(got, []int64{1, 2}) {
+		t.Errorf("Baseline() schema versions = %v, want [1 2]", got)
+	}
+
+	if got := driver.versions("data"); !slices.Equal(got, []int64{1}) {
+		t.Errorf("Baseline() data versions = %v, want [1]", got)
+	}
+
+	if err := m.Baseline(context.Background(), driver, 0); err == nil {
+		t.Errorf("Baseline(0) expected error")
+	}
+}
+
+func TestMigrateMarkApplied(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql":  "CREATE TABLE users();",
+			"migrations/schema/002_orders.sql": "CREATE TABLE orders();",
+		}),
+	}
+
+	driver := &recordDriver{}
+	if err := m.MarkApplied(context.Background(), driver, "schema", "002_orders.sql"); err != nil {
+		t.Fatalf("MarkApplied() error = %v", err)
+	}
+
+	if got := driver.versions("schema"); !slices.Equal(got, []int64{2}) {
+		t.Errorf("MarkApplied() versions = %v, want [2]", got)
+	}
+
+	if err := m.MarkApplied(context.Background(), driver, "schema", "003_missing.sql"); err == nil {
+		t.Errorf("MarkApplied() unknown file expected error")
+	}
+
+	if err := m.MarkApplied(context.Background(), driver, "other", "001_users.sql"); err == nil {
+		t.Errorf("MarkApplied() unknown directory expected error")
+	}
+}
+
+func TestMigrateForceNotSupported(t *testing.T) {
+	m := Migrate{FS: MapFS(map[string]string{"migrations/001_a.sql": "SELECT 1;"})}
+
+	err := m.Force(context.Background(), nopDriver{}, ".", 1)
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Force() error = %v, want ErrNotSupported", err)
+	}
+}
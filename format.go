@@ -0,0 +1,141 @@
+package muz
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// TableFormat is the output of FormatStatus.
+type TableFormat string
+
+const (
+	// TableText writes the columns aligned with spaces, for terminals.
+	TableText TableFormat = "text"
+	// TableMarkdown writes a GitHub flavored markdown table, for pull request comments and chat bots.
+	TableMarkdown TableFormat = "markdown"
+)
+
+// FormatStatus writes v as a table to w, v being a *Status, a plan of Plan or a *Result.
+// A Result lists the files that were not skipped, followed by a summary line.
+// An empty format is TableText.
+//
+//	status, _ := m.Status(ctx, driver)
+//	muz.FormatStatus(status, os.Stdout, muz.TableText)
+//
+//	DIRECTORY  VERSION  FILE            STATUS   APPLIED AT           DESCRIPTION
+//	schema     1        001_users.sql   applied  2024-01-01 12:00:00  create users
+//	schema     2        002_orders.sql  pending  -
+func FormatStatus(v any, w io.Writer, format TableFormat) error {
+	var (
+		header  []string
+		rows    [][]string
+		summary string
+	)
+
+	switch v := v.(type) {
+	case *Status:
+		header = []string{"DIRECTORY", "VERSION", "FILE", "STATUS", "APPLIED AT", "DESCRIPTION"}
+		for _, f := range v.Files {
+			appliedAt := "-"
+			if f.AppliedAt != nil {
+				appliedAt = f.AppliedAt.Format(time.DateTime)
+			}
+
+			rows = append(rows, []string{f.Dir, strconv.FormatInt(f.Version, 10), f.File, string(f.State), appliedAt, f.Description})
+		}
+	case []PlannedMigration:
+		header = []string{"DIRECTORY", "VERSION", "FILE", "REASON", "DESCRIPTION"}
+		for _, p := range v {
+			rows = append(rows, []string{p.Dir, strconv.FormatInt(p.Version, 10), p.File, string(p.Reason), p.Description})
+		}
+	case *Result:
+		header = []string{"OUTCOME", "DIRECTORY", "VERSION", "FILE", "DURATION", "ERROR"}
+		n := 0
+		for _, f := range v.Files {
+			if f.Outcome == OutcomeSkipped {
+				continue
+			}
+
+			rows = append(rows, []string{
+				string(f.Outcome), f.Dir, strconv.FormatInt(f.Version, 10), f.File, f.Duration.Round(time.Millisecond).String(), f.Error,
+			})
+			n++
+		}
+
+		summary = fmt.Sprintf("%d migrations in %s", n, v.Duration.Round(time.Millisecond))
+	default:
+		return fmt.Errorf("format status: unsupported type %T", v)
+	}
+
+	// Multi-line errors would break the rows.
+	for _, row := range rows {
+		for i, cell := range row {
+			row[i] = strings.ReplaceAll(cell, "\n", " ")
+		}
+	}
+
+	switch format {
+	case TableText, "":
+		return formatText(w, header, rows, summary)
+	case TableMarkdown:
+		return formatMarkdown(w, header, rows, summary)
+	default:
+		return fmt.Errorf("format status: unknown format %q", format)
+	}
+}
+
+func formatText(w io.Writer, header []string, rows [][]string, summary string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, row := range append([][]string{header}, rows...) {
+		if _, err := fmt.Fprintln(tw, strings.Join(row, "\t")); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if summary != "" {
+		_, err := fmt.Fprintln(w, summary)
+		return err
+	}
+
+	return nil
+}
+
+func formatMarkdown(w io.Writer, header []string, rows [][]string, summary string) error {
+	var b strings.Builder
+
+	line := func(cells []string) {
+		b.WriteString("|")
+		for _, c := range cells {
+			b.WriteString(" " + strings.ReplaceAll(c, "|", `\|`) + " |")
+		}
+		b.WriteString("\n")
+	}
+
+	line(header)
+
+	sep := make([]string, len(header))
+	for i := range sep {
+		sep[i] = "---"
+	}
+	line(sep)
+
+	for _, row := range rows {
+		line(row)
+	}
+
+	if summary != "" {
+		b.WriteString("\n" + summary + "\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,80 @@
+package muz
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestFormatStatus(t *testing.T) {
+	appliedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	status := &Status{Files: []FileStatus{
+		{Dir: "schema", File: "001_users.sql", Version: 1, State: StateApplied, AppliedAt: &appliedAt, Metadata: Metadata{Description: "create users"}},
+		{Dir: "schema", File: "002_orders.sql", Version: 2, State: StatePending},
+	}}
+
+	result := &Result{
+		Files: []FileResult{
+			{Dir: "schema", File: "001_users.sql", Version: 1, Outcome: OutcomeSkipped},
+			{Dir: "schema", File: "002_orders.sql", Version: 2, Outcome: OutcomeFailed, Duration: 1500 * time.Microsecond, Error: "a | b"},
+		},
+		Duration: 2 * time.Millisecond,
+	}
+
+	tests := []struct {
+		name   string
+		v      any
+		format TableFormat
+		want   string
+	}{
+		{
+			name: "status text",
+			v:    status,
+			want: "" +
+				"DIRECTORY  VERSION  FILE            STATUS   APPLIED AT           DESCRIPTION\n" +
+				"schema     1        001_users.sql   applied  2024-01-01 12:00:00  create users\n" +
+				"schema     2        002_orders.sql  pending  -                    \n",
+		},
+		{
+			name:   "plan markdown",
+			v:      []PlannedMigration{{Dir: "schema", File: "002_orders.sql", Version: 2, Reason: ReasonNew}},
+			format: TableMarkdown,
+			want: "" +
+				"| DIRECTORY | VERSION | FILE | REASON | DESCRIPTION |\n" +
+				"| --- | --- | --- | --- | --- |\n" +
+				"| schema | 2 | 002_orders.sql | new |  |\n",
+		},
+		{
+			name:   "result markdown",
+			v:      result,
+			format: TableMarkdown,
+			want: "" +
+				"| OUTCOME | DIRECTORY | VERSION | FILE | DURATION | ERROR |\n" +
+				"| --- | --- | --- | --- | --- | --- |\n" +
+				"| failed | schema | 2 | 002_orders.sql | 2ms | a \\| b |\n" +
+				"\n1 migrations in 2ms\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := FormatStatus(tt.v, &buf, tt.format); err != nil {
+				t.Fatalf("FormatStatus() error = %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("FormatStatus() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+
+	if err := FormatStatus(status, &bytes.Buffer{}, "html"); err == nil {
+		t.Errorf("FormatStatus() expected error for an unknown format")
+	}
+
+	if err := FormatStatus("status", &bytes.Buffer{}, TableText); err == nil {
+		t.Errorf("FormatStatus() expected error for an unsupported type")
+	}
+}
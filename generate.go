@@ -0,0 +1,191 @@
+package muz
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+// GenerateConfig configures Generate.
+type GenerateConfig struct {
+	// Path of the migration root directory.
+	//  - Default: "migrations"
+	Path string
+	// Extension of the generated files.
+	//  - Default: ".sql"
+	Extension string
+	// Digits is the zero padded width of the version prefix.
+	//  - Default: width used by the latest file in the directory, or 3.
+	Digits int
+	// UpTemplate is a text/template for the migration file.
+	// Available fields are .Name, .Version, .Dir and .Time.
+	//  - Default: "-- {{.Name}}\n"
+	UpTemplate string
+	// DownTemplate is a text/template for the rollback file.
+	//  - Default: "-- rollback {{.Name}}\n"
+	DownTemplate string
+	// NoDown disables writing the rollback file.
+	NoDown bool
+}
+
+// GenerateData is passed to the templates of Generate.
+type GenerateData struct {
+	Name    string
+	Version int64
+	Dir     string
+	Time    time.Time
+}
+
+// Generate writes a new migration named name into dir, using the next free version of the directory.
+// Beside the migration file "NNN_name.sql", a rollback stub "NNN_name.down.sql" is written unless NoDown is set.
+// It returns the paths of the written files.
+func Generate(dir, name string, cfg GenerateConfig) ([]string, error) {
+	root := cfg.Path
+	if root == "" {
+		root = "migrations"
+	}
+
+	ext := cfg.Extension
+	if ext == "" {
+		ext = ".sql"
+	}
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+
+	name = sanitizeName(name)
+	if name == "" {
+		return nil, errors.New("generate: migration name is empty")
+	}
+
+	target := filepath.Join(root, filepath.FromSlash(dir))
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return nil, err
+	}
+
+	version, digits, err := nextVersion(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Digits > 0 {
+		digits = cfg.Digits
+	}
+
+	data := GenerateData{
+		Name:    name,
+		Version: version,
+		Dir:     dir,
+		Time:    time.Now(),
+	}
+
+	base := fmt.Sprintf("%0*d_%s", digits, version, name)
+
+	type output struct {
+		path string
+		tmpl string
+	}
+
+	files := []output{
+		{path: filepath.Join(target, base+ext), tmpl: withDefault(cfg.UpTemplate, "-- {{.Name}}\n")},
+	}
+
+	if !cfg.NoDown {
+		files = append(files, output{path: filepath.Join(target, base+".down"+ext), tmpl: withDefault(cfg.DownTemplate, "-- rollback {{.Name}}\n")})
+	}
+
+	written := make([]string, 0, len(files))
+	for _, f := range files {
+		if err := writeTemplate(f.path, f.tmpl, data); err != nil {
+			return written, err
+		}
+
+		written = append(written, f.path)
+	}
+
+	return written, nil
+}
+
+// nextVersion returns the version after the highest one in dir and the prefix width it used.
+func nextVersion(dir string) (int64, int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var version int64
+	digits := 3
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		n, _ := extractLeadingNumber(entry.Name())
+		if n > version {
+			version = n
+			digits = len(entry.Name()) - len(strings.TrimLeft(entry.Name(), "0123456789"))
+		}
+	}
+
+	return version + 1, digits, nil
+}
+
+func writeTemplate(path, text string, data GenerateData) error {
+	tmpl, err := template.New(filepath.Base(path)).Parse(text)
+	if err != nil {
+		return fmt.Errorf("generate: parsing template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return fmt.Errorf("generate: executing template: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("generate: %s already exists", path)
+		}
+
+		return err
+	}
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// sanitizeName turns a free text name into a file name part like "add_users_table".
+func sanitizeName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-':
+			b.WriteRune(r)
+		case r == '_' || unicode.IsSpace(r) || r == '.':
+			if !strings.HasSuffix(b.String(), "_") {
+				b.WriteRune('_')
+			}
+		}
+	}
+
+	return strings.Trim(b.String(), "_")
+}
+
+func withDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+
+	return v
+}
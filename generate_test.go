@@ -0,0 +1,106 @@
+package muz
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, root string)
+		dir   string
+		input string
+		cfg   GenerateConfig
+		want  map[string]string
+	}{
+		{
+			name:  "empty directory",
+			dir:   "schema",
+			input: "Create Users",
+			want: map[string]string{
+				"schema/001_create_users.sql":      "-- create_users\n",
+				"schema/001_create_users.down.sql": "-- rollback create_users\n",
+			},
+		},
+		{
+			name: "next version keeps padding",
+			setup: func(t *testing.T, root string) {
+				mustMkdir(t, filepath.Join(root, "schema"))
+				mustCreateFile(t, filepath.Join(root, "schema", "0001_init.sql"))
+				mustCreateFile(t, filepath.Join(root, "schema", "0009_users.sql"))
+				mustCreateFile(t, filepath.Join(root, "schema", "0009_users.down.sql"))
+			},
+			dir:   "schema",
+			input: "add index",
+			cfg:   GenerateConfig{NoDown: true},
+			want: map[string]string{
+				"schema/0010_add_index.sql": "-- add_index\n",
+			},
+		},
+		{
+			name:  "custom templates and digits",
+			dir:   ".",
+			input: "seed",
+			cfg: GenerateConfig{
+				Extension:    "psql",
+				Digits:       5,
+				UpTemplate:   "-- up {{.Version}} {{.Dir}}\n",
+				DownTemplate: "-- down {{.Name}}\n",
+			},
+			want: map[string]string{
+				"00001_seed.psql":      "-- up 1 .\n",
+				"00001_seed.down.psql": "-- down seed\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			if tt.setup != nil {
+				tt.setup(t, root)
+			}
+
+			tt.cfg.Path = root
+
+			files, err := Generate(tt.dir, tt.input, tt.cfg)
+			if err != nil {
+				t.Fatalf("Generate error: %v", err)
+			}
+
+			if len(files) != len(tt.want) {
+				t.Fatalf("got files %v, want %d files", files, len(tt.want))
+			}
+
+			for rel, content := range tt.want {
+				path := filepath.Join(root, filepath.FromSlash(rel))
+				if !slices.Contains(files, path) {
+					t.Errorf("missing %s in %v", path, files)
+				}
+
+				got, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("read %s: %v", rel, err)
+				}
+				if string(got) != content {
+					t.Errorf("%s = %q, want %q", rel, got, content)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateExisting(t *testing.T) {
+	root := t.TempDir()
+
+	if _, err := Generate(".", "", GenerateConfig{Path: root}); err == nil {
+		t.Errorf("expected error for empty name")
+	}
+
+	if _, err := Generate(".", "users", GenerateConfig{Path: root, UpTemplate: "{{.Missing"}); err == nil {
+		t.Errorf("expected error for invalid template")
+	}
+}
@@ -0,0 +1,178 @@
+package muz
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"iter"
+	"maps"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// GoMigration is a migration written in Go, registered with RegisterGo.
+type GoMigration struct {
+	Up   func(ctx context.Context, tx *sql.Tx) error
+	Down func(ctx context.Context, tx *sql.Tx) error
+}
+
+type goEntry struct {
+	name      string
+	migration *GoMigration
+}
+
+var (
+	goMu         sync.RWMutex
+	goMigrations = make(map[string]map[int64]goEntry)
+)
+
+// RegisterGo registers Go functions as the migration with version in dir, usually from an init function.
+// They run between the files of the directory by version, inside the transaction of database/sql drivers.
+// The name of the calling source file, like "003_backfill.go", is recorded in the tracking table,
+// prefixed with the version when the file name does not start with it, like "4_backfill.go".
+// Down may be nil. RegisterGo panics when the version is already registered for the directory.
+func RegisterGo(dir string, version int64, up, down func(ctx context.Context, tx *sql.Tx) error) {
+	if up == nil {
+		panic("muz: RegisterGo up function is nil")
+	}
+
+	name := fmt.Sprintf("%d.go", version)
+	if _, file, _, ok := runtime.Caller(1); ok {
+		name = goName(version, filepath.Base(file))
+	}
+
+	dir = cleanDir(dir)
+
+	goMu.Lock()
+	defer goMu.Unlock()
+
+	if _, ok := goMigrations[dir][version]; ok {
+		panic(fmt.Sprintf("muz: go migration %d registered twice in %s", version, dir))
+	}
+
+	if goMigrations[dir] == nil {
+		goMigrations[dir] = make(map[int64]goEntry)
+	}
+
+	goMigrations[dir][version] = goEntry{name: name, migration: &GoMigration{Up: up, Down: down}}
+}
+
+// goName returns the recorded name of the Go migration with version registered from the source file base.
+// Names not starting with version are prefixed with it, so migrations registered from one file do not share a name.
+func goName(version int64, base string) string {
+	if v, _ := extractLeadingNumber(base); v == version {
+		return base
+	}
+
+	return fmt.Sprintf("%d_%s", version, base)
+}
+
+// GoMigration returns the Go migration behind a file of the directory, or nil for regular files.
+func (d *Muzo) GoMigration(filePath string) *GoMigration {
+	return d.gos[filePath]
+}
+
+// withGo merges the registered Go migrations into the listed directories.
+// Directories having only Go migrations follow the listed ones, sorted like Order.
+func (m *Migrate) withGo(list iter.Seq2[*Muzo, error]) iter.Seq2[*Muzo, error] {
+	goMu.RLock()
+	registered := maps.Clone(goMigrations)
+	goMu.RUnlock()
+
+	if len(registered) == 0 {
+		return list
+	}
+
+	return func(yield func(*Muzo, error) bool) {
+		seen := make(map[string]bool)
+
+		for info, err := range list {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+
+			seen[info.Dir] = true
+
+			if !yield(m.mergeGo(info, registered[info.Dir]), nil) {
+				return
+			}
+		}
+
+		var rest []string
+		for dir := range registered {
+			if !seen[dir] && !m.shouldSkipDir(dir) && !m.shouldSkip(dir) {
+				rest = append(rest, dir)
+			}
+		}
+
+		for _, dir := range m.sortDirs(rest) {
+			if !yield(m.mergeGo(&Muzo{Dir: dir}, registered[dir]), nil) {
+				return
+			}
+		}
+	}
+}
+
+// mergeGo returns a copy of info with the Go migrations added to its files.
+func (m *Migrate) mergeGo(info *Muzo, entries map[int64]goEntry) *Muzo {
+	if len(entries) == 0 {
+		return info
+	}
+
+	merged := &Muzo{
+		Dir:   info.Dir,
+		Files: slices.Clone(info.Files),
+		fs:    info.fs,
+		gos:   make(map[string]*GoMigration, len(entries)),
+		vars:  info.vars,
+	}
+
+	for version, e := range entries {
+		if m.shouldSkip(path.Join(info.Dir, e.name)) {
+			continue
+		}
+
+		merged.Files = append(merged.Files, FileInfo{Path: e.name, Version: version})
+		merged.gos[e.name] = e.migration
+	}
+
+	slices.SortStableFunc(merged.Files, func(a, b FileInfo) int {
+		if a.Version != b.Version {
+			return cmp.Compare(a.Version, b.Version)
+		}
+
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return merged
+}
+
+// execFile applies a migration file or Go migration inside tx.
+func execFile(ctx context.Context, tx *sql.Tx, data *Muzo, file FileInfo, opts execOptions) error {
+	if gm := data.GoMigration(file.Path); gm != nil {
+		return gm.Up(ctx, tx)
+	}
+
+	return execContent(ctx, tx, data, file.Path, opts)
+}
+
+// execRollback executes the rollback file or the Down function of a Go migration inside tx.
+func execRollback(ctx context.Context, tx *sql.Tx, data *Muzo, file FileInfo, opts execOptions) error {
+	if gm := data.GoMigration(file.Path); gm != nil {
+		if gm.Down == nil {
+			return fmt.Errorf("go migration %s has no down function", file.Path)
+		}
+
+		return gm.Down(ctx, tx)
+	}
+
+	return execContent(ctx, tx, data, file.Path, opts)
+}
@@ -0,0 +1,183 @@
+package muz
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// registerGo registers a Go migration for the duration of the test.
+func registerGo(t *testing.T, dir string, version int64) {
+	t.Helper()
+
+	RegisterGo(dir, version, func(context.Context, *sql.Tx) error { return nil }, nil)
+	t.Cleanup(func() {
+		goMu.Lock()
+		delete(goMigrations, dir)
+		goMu.Unlock()
+	})
+}
+
+func TestRegisterGo(t *testing.T) {
+	registerGo(t, "schema", 2)
+	registerGo(t, "backfill", 1)
+
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql": "CREATE TABLE users();",
+			"migrations/schema/003_items.sql": "CREATE TABLE items();",
+		}),
+	}
+
+	type dir struct {
+		Dir   string
+		Files string
+	}
+
+	var got []dir
+	for info, err := range m.Iter() {
+		if err != nil {
+			t.Fatalf("Iter() error = %v", err)
+		}
+
+		for _, f := range info.Files {
+			got = append(got, dir{info.Dir, f.Path})
+
+			if gm := info.GoMigration(f.Path); gm != nil {
+				if content, err := info.ReadFile(f.Path); err != nil || content != nil {
+					t.Errorf("ReadFile(%s) = %q, %v, want no content", f.Path, content, err)
+				}
+			}
+		}
+	}
+
+	want := []dir{
+		{"schema", "001_users.sql"},
+		{"schema", "2_gomigration_test.go"},
+		{"schema", "003_items.sql"},
+		{"backfill", "1_gomigration_test.go"},
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("Iter() = %v, want %v", got, want)
+	}
+
+	report, err := m.Validate()
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	if len(report.Issues) != 0 {
+		t.Errorf("Validate() issues = %v, want none", report.Issues)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("RegisterGo() twice did not panic")
+		}
+	}()
+
+	RegisterGo("/schema", 2, func(context.Context, *sql.Tx) error { return nil }, nil)
+}
+
+// goDriver reports which files reached Process as Go migrations.
+type goDriver struct {
+	recordDriver
+
+	gos []string
+}
+
+func (d *goDriver) Process(ctx context.Context, data *Muzo) error {
+	for _, f := range data.Files {
+		if data.GoMigration(f.Path) != nil {
+			d.gos = append(d.gos, f.Path)
+		}
+	}
+
+	return d.recordDriver.Process(ctx, data)
+}
+
+func TestMigrateGo(t *testing.T) {
+	registerGo(t, "schema", 2)
+
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql": "CREATE TABLE users();",
+		}),
+	}
+
+	driver := &goDriver{}
+	if _, err := m.Migrate(context.Background(), driver); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	if !slices.Equal(driver.gos, []string{"2_gomigration_test.go"}) {
+		t.Errorf("Process() go migrations = %v, want [2_gomigration_test.go]", driver.gos)
+	}
+}
+
+func TestMigrateGoSameFile(t *testing.T) {
+	var ran []string
+	for _, version := range []int64{1, 2} {
+		RegisterGo("backfill", version, func(context.Context, *sql.Tx) error {
+			ran = append(ran, fmt.Sprint(version))
+			return nil
+		}, nil)
+	}
+	t.Cleanup(func() {
+		goMu.Lock()
+		delete(goMigrations, "backfill")
+		goMu.Unlock()
+	})
+
+	m := Migrate{FS: MapFS(map[string]string{"migrations/schema/001_users.sql": "CREATE TABLE users();"})}
+
+	var files []string
+	for info, err := range m.Iter() {
+		if err != nil {
+			t.Fatalf("Iter() error = %v", err)
+		}
+
+		for _, f := range info.Files {
+			gm := info.GoMigration(f.Path)
+			if gm == nil {
+				continue
+			}
+
+			files = append(files, f.Path)
+
+			if err := gm.Up(context.Background(), nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Each migration keeps its own name and function.
+	if want := []string{"1_gomigration_test.go", "2_gomigration_test.go"}; !slices.Equal(files, want) {
+		t.Errorf("Iter() files = %v, want %v", files, want)
+	}
+
+	if want := []string{"1", "2"}; !slices.Equal(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+}
+
+func TestGoName(t *testing.T) {
+	tests := []struct {
+		version int64
+		base    string
+		want    string
+	}{
+		{4, "004_backfill.go", "004_backfill.go"},
+		{5, "004_backfill.go", "5_004_backfill.go"},
+		{4, "backfill.go", "4_backfill.go"},
+	}
+
+	for _, tt := range tests {
+		if got := goName(tt.version, tt.base); got != tt.want {
+			t.Errorf("goName(%d, %q) = %q, want %q", tt.version, tt.base, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package muz
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Annotations of goose migration files, like "-- +goose Up".
+//   - The lines after "-- +goose Up" are the migration, the ones after "-- +goose Down" its rollback,
+//     run by Down instead of a "NNN_name.down.sql" file.
+//   - "-- +goose StatementBegin" and "-- +goose StatementEnd" keep the statements between them together
+//     when a file is split, for function bodies holding semicolons.
+//   - "-- +goose NO TRANSACTION" is the same as DirectiveNoTransaction.
+const (
+	gooseUp             = "Up"
+	gooseDown           = "Down"
+	gooseStatementBegin = "StatementBegin"
+	gooseStatementEnd   = "StatementEnd"
+	gooseNoTransaction  = "NO TRANSACTION"
+)
+
+// gooseBlock is the delimiter of the splitter between StatementBegin and StatementEnd, it never matches.
+const gooseBlock = "\x00" + gooseStatementBegin
+
+// gooseAnnotation returns the annotation of a comment line like "-- +goose Up", or "" for other lines.
+func gooseAnnotation(line string) string {
+	comment, ok := strings.CutPrefix(strings.TrimSpace(line), "--")
+	if !ok {
+		return ""
+	}
+
+	annotation, ok := strings.CutPrefix(strings.TrimSpace(comment), "+goose ")
+	if !ok {
+		return ""
+	}
+
+	return strings.TrimSpace(annotation)
+}
+
+// gooseWriter writes the lines of one section of a goose or dbmate file to w, gooseUp or gooseDown.
+// Files without annotations are an Up section as a whole, lines before "-- +goose Up" belong to it.
+type gooseWriter struct {
+	w       io.Writer
+	section string
+
+	// current is the section of the line being written.
+	current string
+	// line holds the start of a line that may be an annotation, nil while a line is passed through.
+	line []byte
+	// start is set at the start of a line.
+	start bool
+	// annotated is set once an Up or Down annotation is seen.
+	annotated bool
+}
+
+func newGooseWriter(w io.Writer, section string) *gooseWriter {
+	return &gooseWriter{w: w, section: section, current: gooseUp, start: true}
+}
+
+func (g *gooseWriter) Write(p []byte) (int, error) {
+	n := len(p)
+
+	for len(p) > 0 {
+		// Only lines starting with a comment or a space are held, long data lines are passed through.
+		if g.start && g.line == nil && (p[0] == '-' || p[0] == ' ' || p[0] == '\t') {
+			g.line = []byte{}
+		}
+
+		end := bytes.IndexByte(p, '\n') + 1
+		if end == 0 {
+			end = len(p)
+		}
+
+		chunk := p[:end]
+		p = p[end:]
+		g.start = chunk[len(chunk)-1] == '\n'
+
+		if g.line != nil {
+			g.line = append(g.line, chunk...)
+			if g.start {
+				if err := g.flush(); err != nil {
+					return 0, err
+				}
+			}
+
+			continue
+		}
+
+		if g.current == g.section {
+			if _, err := g.w.Write(chunk); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// flush writes the held line, switching the section on Up and Down annotations.
+func (g *gooseWriter) flush() error {
+	line := g.line
+	g.line = nil
+
+	switch gooseAnnotation(string(line)) {
+	case gooseUp:
+		g.current, g.annotated = gooseUp, true
+		return nil
+	case gooseDown:
+		g.current, g.annotated = gooseDown, true
+		return nil
+	}
+
+	if section, noTx := dbmateMarker(string(line)); section != "" {
+		g.current, g.annotated = section, true
+		if !noTx {
+			return nil
+		}
+
+		// The option of a dbmate block is kept as a directive of the section.
+		line = []byte("-- " + directivePrefix + DirectiveNoTransaction + "\n")
+	}
+
+	if g.current != g.section {
+		return nil
+	}
+
+	_, err := g.w.Write(line)
+
+	return err
+}
+
+// copySection writes a section of a migration file of the migration path to w, see gooseWriter.
+// It reports whether the file has goose annotations or dbmate markers.
+func (d *Muzo) copySection(w io.Writer, name, section string) (bool, error) {
+	g := newGooseWriter(w, section)
+	if err := d.copyPath(g, name, nil); err != nil {
+		return false, err
+	}
+
+	return g.annotated, g.flush()
+}
+
+// gooseDown returns the Down section of a goose or dbmate migration file with its placeholders replaced,
+// false when the file has no goose annotations or dbmate markers.
+func (d *Muzo) gooseDown(filePath string) ([]byte, bool, error) {
+	if d.GoMigration(filePath) != nil {
+		return nil, false, nil
+	}
+
+	var buf bytes.Buffer
+
+	annotated, err := d.copySection(&buf, path.Join(d.Dir, filepath.ToSlash(filePath)), gooseDown)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, false, nil
+		}
+
+		return nil, false, err
+	}
+
+	if !annotated || d.vars == nil {
+		return buf.Bytes(), annotated, nil
+	}
+
+	content, err := substitute(buf.Bytes(), path.Join(d.Dir, filePath), d.vars)
+
+	return content, true, err
+}
@@ -0,0 +1,96 @@
+package muz
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const gooseFile = `-- +goose Up
+-- +goose StatementBegin
+CREATE FUNCTION touch() RETURNS trigger AS $$
+BEGIN
+  NEW.updated = now();
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+CREATE TABLE users (id int);
+
+-- +goose Down
+DROP TABLE users;
+DROP FUNCTION touch();
+`
+
+func TestGoose(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/001_users.sql": gooseFile,
+			"migrations/002_index.sql": "-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY users_id ON users (id);\n",
+		}),
+	}
+
+	var info *Muzo
+	for dir, err := range m.Iter() {
+		if err != nil {
+			t.Fatalf("Iter() error = %v", err)
+		}
+
+		info = dir
+	}
+
+	t.Run("up section", func(t *testing.T) {
+		content, err := info.ReadFile("001_users.sql")
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+
+		if strings.Contains(string(content), "DROP") {
+			t.Errorf("ReadFile() holds the Down section:\n%s", content)
+		}
+
+		statements := SplitStatements(string(content))
+		if len(statements) != 2 || !strings.HasSuffix(statements[0], "$$ LANGUAGE plpgsql;") || statements[1] != "CREATE TABLE users (id int)" {
+			t.Errorf("SplitStatements() = %q", statements)
+		}
+
+		var streamed []string
+		for statement, err := range info.statements("001_users.sql") {
+			if err != nil {
+				t.Fatalf("statements() error = %v", err)
+			}
+
+			streamed = append(streamed, statement)
+		}
+
+		if !slices.Equal(streamed, statements) {
+			t.Errorf("statements() = %q, want %q", streamed, statements)
+		}
+	})
+
+	t.Run("no transaction", func(t *testing.T) {
+		noTx, err := noTransaction(info, info.Files[1])
+		if err != nil || !noTx {
+			t.Errorf("noTransaction() = %v, %v, want true", noTx, err)
+		}
+	})
+
+	t.Run("down section", func(t *testing.T) {
+		driver := &rollbackDriver{}
+
+		if _, err := m.Migrate(context.Background(), driver); err != nil {
+			t.Fatalf("Migrate() error = %v", err)
+		}
+
+		driver.records = slices.DeleteFunc(driver.records, func(r Record) bool { return r.Version == 2 })
+
+		if _, err := m.Down(context.Background(), driver, 1); err != nil {
+			t.Fatalf("Down() error = %v", err)
+		}
+
+		if want := []string{"./001_users.sql: DROP TABLE users;\nDROP FUNCTION touch();\n"}; !slices.Equal(driver.executed, want) {
+			t.Errorf("Down() executed = %q, want %q", driver.executed, want)
+		}
+	})
+}
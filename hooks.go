@@ -0,0 +1,18 @@
+package muz
+
+import "context"
+
+// Hooks are callbacks invoked by Migrate around the driver, all of them are optional.
+// BeforeFile and AfterFile need a driver implementing StatusReporter, see Result.
+type Hooks struct {
+	// BeforeDir is called before a directory with files is processed, an error stops the run.
+	BeforeDir func(ctx context.Context, dir *Muzo) error
+	// BeforeFile is called before a pending file is applied, an error stops the run.
+	BeforeFile func(ctx context.Context, dir string, file FileInfo) error
+	// AfterFile is called after a file was applied or failed.
+	AfterFile func(ctx context.Context, result FileResult)
+	// OnError is called with the error of a failed run, after the driver's End.
+	OnError func(ctx context.Context, err error)
+	// OnEvent is called with every step of the run, like a progress view or a deploy bot would show it, see Event.
+	OnEvent func(ctx context.Context, event Event)
+}
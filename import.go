@@ -0,0 +1,271 @@
+package muz
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// HistoryImporter reads the applied migrations of another migration tool from its state table,
+// like ImportGolangMigrate. It is used by Migrate.ImportHistory.
+type HistoryImporter interface {
+	Import(ctx context.Context, q RowQuerier) ([]ImportedMigration, error)
+}
+
+// ImportedMigration is an applied migration read by a HistoryImporter.
+type ImportedMigration struct {
+	Dir string `json:"dir"`
+	// Version is 0 for migrations without a numeric version, like the repeatable migrations of Flyway.
+	Version int64 `json:"version"`
+	// Through marks every file of Dir up to Version as applied, for tools keeping only
+	// the current version like golang-migrate or the baseline of Flyway.
+	Through bool `json:"through,omitempty"`
+	// Name and Checksum are the script and checksum recorded by the other tool, when it keeps them.
+	Name     string `json:"name,omitempty"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// ImportReport is the result of Migrate.ImportHistory.
+type ImportReport struct {
+	// Recorded are the files recorded as applied, files recorded before are left out.
+	Recorded []FileStatus `json:"recorded"`
+	// Unmatched are the imported migrations without a file of the same version in their directory.
+	Unmatched []ImportedMigration `json:"unmatched"`
+}
+
+// ImportHistory seeds the tracking table with the applied migrations of another migration tool,
+// so the runners can be swapped without executing the files again or forcing every directory by hand.
+// The files matching the imported versions are recorded as applied, records of muz are kept.
+// Drivers implementing ChecksumStore keep the checksum of the recorded files, verified from then on.
+// The driver must implement Recorder, StatusReporter and RowQuerier.
+func (m Migrate) ImportHistory(ctx context.Context, driver Driver, importer HistoryImporter) (*ImportReport, error) {
+	q, ok := driver.(RowQuerier)
+	if !ok {
+		return nil, fmt.Errorf("import history: %w", ErrNotSupported)
+	}
+
+	report := &ImportReport{Recorded: []FileStatus{}, Unmatched: []ImportedMigration{}}
+
+	err := m.record(ctx, driver, func(rec Recorder, records []Record) error {
+		imported, err := importer.Import(ctx, q)
+		if err != nil {
+			return fmt.Errorf("import history: %w", err)
+		}
+
+		dirs := make(map[string]*Muzo)
+		for info, err := range m.dirs() {
+			if err != nil {
+				return err
+			}
+
+			dirs[info.Dir] = info
+		}
+
+		recorded := make(map[appliedKey]bool, len(records))
+		for _, r := range records {
+			recorded[appliedKey{r.Directory, r.Version}] = true
+		}
+
+		for _, im := range imported {
+			dir := cleanDir(im.Dir)
+
+			info, ok := dirs[dir]
+			if !ok || !slices.ContainsFunc(info.Files, func(f FileInfo) bool { return f.Version == im.Version }) {
+				report.Unmatched = append(report.Unmatched, im)
+			}
+
+			if !ok {
+				continue
+			}
+
+			for _, file := range info.Files {
+				k := appliedKey{dir, file.Version}
+				if recorded[k] || file.Version > im.Version || file.Version < im.Version && !im.Through {
+					continue
+				}
+
+				if err := rec.Record(ctx, dir, file); err != nil {
+					return err
+				}
+
+				if err := storeChecksum(ctx, driver, info, file); err != nil {
+					return err
+				}
+
+				recorded[k] = true
+				report.Recorded = append(report.Recorded, FileStatus{Dir: dir, File: file.Path, Version: file.Version, State: StateApplied, Metadata: file.Metadata})
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// ImportGolangMigrate imports the state table of golang-migrate, holding the current version and a dirty flag.
+// Every file up to the version is applied, a dirty state is refused until it is fixed with golang-migrate.
+type ImportGolangMigrate struct {
+	// Table is the state table, used as is in the query.
+	//  - Default: "schema_migrations"
+	Table string
+	// Dir is the migration directory the versions belong to.
+	//  - Default: ".", the files of the migration path.
+	Dir string
+}
+
+func (i ImportGolangMigrate) Import(ctx context.Context, q RowQuerier) ([]ImportedMigration, error) {
+	table := withDefault(i.Table, "schema_migrations")
+
+	var imported []ImportedMigration
+
+	err := q.QueryRows(ctx, "SELECT version, dirty FROM "+table, func(scan func(dest ...any) error) error {
+		var (
+			version int64
+			dirty   bool
+		)
+
+		if err := scan(&version, &dirty); err != nil {
+			return err
+		}
+
+		if dirty {
+			return fmt.Errorf("golang-migrate version %d is dirty, fix it with golang-migrate force first", version)
+		}
+
+		imported = append(imported, ImportedMigration{Dir: withDefault(i.Dir, "."), Version: version, Through: true})
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("golang-migrate table %s: %w", table, err)
+	}
+
+	return imported, nil
+}
+
+// ImportFlyway imports the flyway_schema_history table of Flyway.
+//   - A baseline row marks every file up to its version as applied, undone versions are left out.
+//   - Repeatable migrations and dotted versions like "1.1" have no muz version, they are reported as unmatched.
+//   - Failed migrations are refused until they are repaired with Flyway.
+//
+// Flyway files like "V1__users.sql" need their "V" prefix removed to be numbered, like "1__users.sql".
+type ImportFlyway struct {
+	// Table is the history table, used as is in the query.
+	//  - Default: "flyway_schema_history"
+	Table string
+	// Dir is the migration directory the versions belong to.
+	//  - Default: ".", the files of the migration path.
+	Dir string
+}
+
+func (i ImportFlyway) Import(ctx context.Context, q RowQuerier) ([]ImportedMigration, error) {
+	table := withDefault(i.Table, "flyway_schema_history")
+
+	var imported []ImportedMigration
+
+	query := "SELECT version, script, checksum, type, success FROM " + table + " ORDER BY installed_rank"
+
+	err := q.QueryRows(ctx, query, func(scan func(dest ...any) error) error {
+		var (
+			version, script, kind sql.NullString
+			sum                   sql.NullInt64
+			success               bool
+		)
+
+		if err := scan(&version, &script, &sum, &kind, &success); err != nil {
+			return err
+		}
+
+		if !success {
+			return fmt.Errorf("flyway migration %s failed, repair it with flyway first", script.String)
+		}
+
+		im := ImportedMigration{Dir: withDefault(i.Dir, "."), Name: script.String, Through: kind.String == "BASELINE"}
+		if sum.Valid {
+			im.Checksum = strconv.FormatInt(sum.Int64, 10)
+		}
+
+		if v, err := strconv.ParseInt(version.String, 10, 64); err == nil {
+			im.Version = v
+		}
+
+		switch {
+		case kind.String == "SCHEMA":
+			// The row of the schemas created by Flyway.
+		case strings.HasPrefix(kind.String, "UNDO"):
+			imported = slices.DeleteFunc(imported, func(prev ImportedMigration) bool { return prev.Version == im.Version })
+		default:
+			imported = append(imported, im)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("flyway table %s: %w", table, err)
+	}
+
+	return imported, nil
+}
+
+// ImportGoose imports the goose_db_version table of goose, where the latest row of a version
+// tells whether it is applied.
+type ImportGoose struct {
+	// Table is the version table, used as is in the query.
+	//  - Default: "goose_db_version"
+	Table string
+	// Dir is the migration directory the versions belong to.
+	//  - Default: ".", the files of the migration path.
+	Dir string
+}
+
+func (i ImportGoose) Import(ctx context.Context, q RowQuerier) ([]ImportedMigration, error) {
+	table := withDefault(i.Table, "goose_db_version")
+
+	var (
+		versions []int64
+		applied  = make(map[int64]bool)
+	)
+
+	err := q.QueryRows(ctx, "SELECT version_id, is_applied FROM "+table+" ORDER BY id", func(scan func(dest ...any) error) error {
+		var (
+			version   int64
+			isApplied bool
+		)
+
+		if err := scan(&version, &isApplied); err != nil {
+			return err
+		}
+
+		// Version 0 is the row created with the table.
+		if version == 0 {
+			return nil
+		}
+
+		if _, ok := applied[version]; !ok {
+			versions = append(versions, version)
+		}
+
+		applied[version] = isApplied
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("goose table %s: %w", table, err)
+	}
+
+	var imported []ImportedMigration
+	for _, version := range versions {
+		if applied[version] {
+			imported = append(imported, ImportedMigration{Dir: withDefault(i.Dir, "."), Version: version})
+		}
+	}
+
+	return imported, nil
+}
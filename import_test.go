@@ -0,0 +1,230 @@
+package muz
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// tableDriver is a recordDriver answering QueryRows with fixed rows, recording the queries.
+type tableDriver struct {
+	recordDriver
+
+	rows    [][]any
+	queries []string
+}
+
+func (d *tableDriver) QueryRows(_ context.Context, query string, row func(scan func(dest ...any) error) error) error {
+	d.queries = append(d.queries, query)
+
+	for _, values := range d.rows {
+		err := row(func(dest ...any) error {
+			if len(dest) != len(values) {
+				return errors.New("wrong number of columns")
+			}
+
+			for i, v := range values {
+				reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(v))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestImportGolangMigrate(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/001_users.up.sql":    "CREATE TABLE users();",
+			"migrations/001_users.down.sql":  "DROP TABLE users;",
+			"migrations/002_orders.up.sql":   "CREATE TABLE orders();",
+			"migrations/002_orders.down.sql": "DROP TABLE orders;",
+			"migrations/003_items.up.sql":    "CREATE TABLE items();",
+		}),
+		Compat: CompatGolangMigrate,
+	}
+
+	t.Run("current version", func(t *testing.T) {
+		driver := &tableDriver{rows: [][]any{{int64(2), false}}}
+		driver.records = []Record{{Version: 1, Directory: ".", FileName: "001_users.up.sql"}}
+
+		report, err := m.ImportHistory(context.Background(), driver, ImportGolangMigrate{})
+		if err != nil {
+			t.Fatalf("ImportHistory() error = %v", err)
+		}
+
+		if len(driver.queries) != 1 || !strings.Contains(driver.queries[0], "FROM schema_migrations") {
+			t.Errorf("queries = %q", driver.queries)
+		}
+
+		if got := driver.versions("."); !slices.Equal(got, []int64{1, 2}) {
+			t.Errorf("versions = %v, want [1 2]", got)
+		}
+
+		if len(report.Recorded) != 1 || report.Recorded[0].File != "002_orders.up.sql" || len(report.Unmatched) != 0 {
+			t.Errorf("report = %+v", report)
+		}
+
+		result, err := m.Migrate(context.Background(), driver)
+		if err != nil || result.Applied() != 1 {
+			t.Errorf("Migrate() applied %d, error = %v, want only 003", result.Applied(), err)
+		}
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		driver := &tableDriver{rows: [][]any{{int64(7), false}}}
+
+		report, err := m.ImportHistory(context.Background(), driver, ImportGolangMigrate{Table: "public.migrations"})
+		if err != nil {
+			t.Fatalf("ImportHistory() error = %v", err)
+		}
+
+		if len(report.Recorded) != 3 || !slices.Equal(report.Unmatched, []ImportedMigration{{Dir: ".", Version: 7, Through: true}}) {
+			t.Errorf("report = %+v", report)
+		}
+	})
+
+	t.Run("dirty", func(t *testing.T) {
+		driver := &tableDriver{rows: [][]any{{int64(2), true}}}
+
+		if _, err := m.ImportHistory(context.Background(), driver, ImportGolangMigrate{}); err == nil {
+			t.Fatal("ImportHistory() error = nil, want the dirty version")
+		}
+
+		if len(driver.records) != 0 {
+			t.Errorf("records = %v, want none", driver.records)
+		}
+	})
+
+	t.Run("driver without RowQuerier", func(t *testing.T) {
+		if _, err := m.ImportHistory(context.Background(), &recordDriver{}, ImportGolangMigrate{}); !errors.Is(err, ErrNotSupported) {
+			t.Errorf("ImportHistory() error = %v, want ErrNotSupported", err)
+		}
+	})
+}
+
+// checksumTableDriver is a tableDriver keeping the stored checksums.
+type checksumTableDriver struct {
+	tableDriver
+
+	checksums map[appliedKey]string
+}
+
+func (d *checksumTableDriver) StoreChecksum(_ context.Context, dir string, version int64, checksum string) error {
+	if d.checksums == nil {
+		d.checksums = make(map[appliedKey]string)
+	}
+
+	d.checksums[appliedKey{dir, version}] = checksum
+
+	return nil
+}
+
+func TestImportFlyway(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/1__users.sql":  "CREATE TABLE users();",
+			"migrations/2__orders.sql": "CREATE TABLE orders();",
+			"migrations/3__items.sql":  "CREATE TABLE items();",
+			"migrations/4__prices.sql": "CREATE TABLE prices();",
+		}),
+	}
+
+	null := sql.NullString{}
+	str := func(s string) sql.NullString { return sql.NullString{String: s, Valid: true} }
+	sum := func(n int64) sql.NullInt64 { return sql.NullInt64{Int64: n, Valid: true} }
+
+	t.Run("history", func(t *testing.T) {
+		driver := &checksumTableDriver{tableDriver: tableDriver{rows: [][]any{
+			{null, str("<< Flyway Schema Creation >>"), sql.NullInt64{}, str("SCHEMA"), true},
+			{str("1"), str("<< Flyway Baseline >>"), sql.NullInt64{}, str("BASELINE"), true},
+			{str("2"), str("V2__orders.sql"), sum(-123), str("SQL"), true},
+			{str("3"), str("V3__items.sql"), sum(42), str("SQL"), true},
+			{str("3"), str("U3__items.sql"), sum(43), str("UNDO_SQL"), true},
+			{str("3.1"), str("V3_1__fix.sql"), sum(7), str("SQL"), true},
+			{null, str("R__views.sql"), sum(8), str("SQL"), true},
+		}}}
+
+		report, err := m.ImportHistory(context.Background(), driver, ImportFlyway{})
+		if err != nil {
+			t.Fatalf("ImportHistory() error = %v", err)
+		}
+
+		if len(driver.queries) != 1 || !strings.Contains(driver.queries[0], "FROM flyway_schema_history") {
+			t.Errorf("queries = %q", driver.queries)
+		}
+
+		if got := driver.versions("."); !slices.Equal(got, []int64{1, 2}) {
+			t.Errorf("versions = %v, want [1 2]", got)
+		}
+
+		want := []ImportedMigration{
+			{Dir: ".", Name: "V3_1__fix.sql", Checksum: "7"},
+			{Dir: ".", Name: "R__views.sql", Checksum: "8"},
+		}
+		if len(report.Recorded) != 2 || !slices.Equal(report.Unmatched, want) {
+			t.Errorf("report = %+v", report)
+		}
+
+		if len(driver.checksums) != 2 || driver.checksums[appliedKey{".", 2}] == "" {
+			t.Errorf("checksums = %v, want the recorded files", driver.checksums)
+		}
+	})
+
+	t.Run("failed migration", func(t *testing.T) {
+		driver := &tableDriver{rows: [][]any{{str("1"), str("V1__users.sql"), sum(1), str("SQL"), false}}}
+
+		if _, err := m.ImportHistory(context.Background(), driver, ImportFlyway{}); err == nil {
+			t.Fatal("ImportHistory() error = nil, want the failed migration")
+		}
+
+		if len(driver.records) != 0 {
+			t.Errorf("records = %v, want none", driver.records)
+		}
+	})
+}
+
+func TestImportGoose(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/20240101000000_users.sql":  "-- +goose Up\nCREATE TABLE users();",
+			"migrations/20240102000000_orders.sql": "-- +goose Up\nCREATE TABLE orders();",
+			"migrations/20240103000000_items.sql":  "-- +goose Up\nCREATE TABLE items();",
+		}),
+	}
+
+	driver := &tableDriver{rows: [][]any{
+		{int64(0), true},
+		{int64(20240101000000), true},
+		{int64(20240102000000), true},
+		{int64(20240103000000), true},
+		{int64(20240103000000), false},
+		{int64(20240109000000), true},
+	}}
+
+	report, err := m.ImportHistory(context.Background(), driver, ImportGoose{Table: "public.goose_db_version"})
+	if err != nil {
+		t.Fatalf("ImportHistory() error = %v", err)
+	}
+
+	if len(driver.queries) != 1 || !strings.Contains(driver.queries[0], "FROM public.goose_db_version") {
+		t.Errorf("queries = %q", driver.queries)
+	}
+
+	if got := driver.versions("."); !slices.Equal(got, []int64{20240101000000, 20240102000000}) {
+		t.Errorf("versions = %v, want the first two", got)
+	}
+
+	if !slices.Equal(report.Unmatched, []ImportedMigration{{Dir: ".", Version: 20240109000000}}) {
+		t.Errorf("unmatched = %+v", report.Unmatched)
+	}
+}
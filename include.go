@@ -0,0 +1,166 @@
+package muz
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DirectiveInclude as a comment line of a file, like "-- muz:include ../common/functions.sql",
+// is replaced by the content of the named file when the migration is read.
+//   - Relative paths start at the directory of the including file, paths starting with "/" at the migration path.
+//   - Included files can include others, but cannot leave the migration path or include themselves again.
+const DirectiveInclude = "include"
+
+// copyPath writes the content of a file of the migration path to w with its include lines replaced
+// by the included files, reading it line by line. stack holds the files being expanded, from the
+// migration file to the one including name, to detect cycles.
+func (d *Muzo) copyPath(w io.Writer, name string, stack []string) error {
+	f, err := d.openPath(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stack = append(stack, name)
+	r := bufio.NewReaderSize(f, 64*1024)
+
+	for {
+		line, readErr := r.ReadSlice('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) && !errors.Is(readErr, bufio.ErrBufferFull) {
+			return readErr
+		}
+
+		target, ok := includeTarget(line)
+		switch {
+		case errors.Is(readErr, bufio.ErrBufferFull):
+			// Lines longer than the buffer, like bulk inserts, are never includes.
+			if err := copyLine(w, r, line); err != nil {
+				return err
+			}
+
+			continue
+		case !ok:
+			if _, err := w.Write(line); err != nil {
+				return err
+			}
+		default:
+			if err := d.include(w, name, target, stack, bytes.HasSuffix(line, []byte("\n"))); err != nil {
+				return err
+			}
+		}
+
+		if readErr != nil {
+			return nil
+		}
+	}
+}
+
+// include writes the file target included by name to w, ending with a line break when the include line had one.
+func (d *Muzo) include(w io.Writer, name, target string, stack []string, newline bool) error {
+	included, err := resolveInclude(name, target)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range stack {
+		if s == included {
+			return fmt.Errorf("include cycle %s -> %s", strings.Join(stack, " -> "), included)
+		}
+	}
+
+	tw := &tailWriter{w: w}
+	if err := d.copyPath(tw, included, stack); err != nil {
+		return fmt.Errorf("including %s in %s: %w", target, name, err)
+	}
+
+	if tw.written && tw.last != '\n' && newline {
+		_, err = w.Write([]byte("\n"))
+	}
+
+	return err
+}
+
+// copyLine writes the start of a long line and the rest of it read from r to w.
+func copyLine(w io.Writer, r *bufio.Reader, start []byte) error {
+	if _, err := w.Write(start); err != nil {
+		return err
+	}
+
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if _, writeErr := w.Write(chunk); writeErr != nil {
+			return writeErr
+		}
+
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case err == nil || errors.Is(err, io.EOF):
+			return nil
+		default:
+			return err
+		}
+	}
+}
+
+// tailWriter remembers the last byte written to w.
+type tailWriter struct {
+	w       io.Writer
+	last    byte
+	written bool
+}
+
+func (t *tailWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		t.last, t.written = p[len(p)-1], true
+	}
+
+	return t.w.Write(p)
+}
+
+// includeTarget returns the path of an include comment line like "-- muz:include common.sql".
+func includeTarget(line []byte) (string, bool) {
+	comment, ok := strings.CutPrefix(strings.TrimSpace(string(line)), "--")
+	if !ok {
+		return "", false
+	}
+
+	d, ok := strings.CutPrefix(strings.TrimSpace(comment), directivePrefix)
+	if !ok {
+		return "", false
+	}
+
+	name, value, _ := strings.Cut(strings.TrimSpace(d), " ")
+	if name != DirectiveInclude {
+		return "", false
+	}
+
+	value = strings.TrimSpace(value)
+
+	return value, value != ""
+}
+
+// resolveInclude returns the path inside the migration path of target, included by the file name.
+func resolveInclude(name, target string) (string, error) {
+	target = filepath.ToSlash(target)
+
+	var included string
+	if rooted, ok := strings.CutPrefix(target, "/"); ok {
+		included = path.Clean(rooted)
+	} else {
+		included = path.Join(path.Dir(name), target)
+	}
+
+	if !fs.ValidPath(included) || included == "." {
+		return "", fmt.Errorf("include %s of %s is outside of the migration path", target, name)
+	}
+
+	return included, nil
+}
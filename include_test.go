@@ -0,0 +1,82 @@
+package muz
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadFileInclude(t *testing.T) {
+	common := map[string]string{
+		"common/functions.sql": "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1 $$ LANGUAGE sql;",
+		"common/all.sql":       "-- muz:include functions.sql\n-- muz:include /common/grants.sql\n",
+		"common/grants.sql":    "GRANT EXECUTE ON FUNCTION f() TO reader;\n",
+		"common/loop.sql":      "-- muz:include loop_back.sql\n",
+		"common/loop_back.sql": "-- muz:include loop.sql\n",
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "relative to the file",
+			content: "CREATE TABLE a();\n-- muz:include ../common/functions.sql\nSELECT f();",
+			want:    "CREATE TABLE a();\nCREATE FUNCTION f() RETURNS int AS $$ SELECT 1 $$ LANGUAGE sql;\nSELECT f();",
+		},
+		{
+			name:    "nested includes",
+			content: "--muz: include /common/all.sql\n",
+			want:    "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1 $$ LANGUAGE sql;\nGRANT EXECUTE ON FUNCTION f() TO reader;\n",
+		},
+		{
+			name:    "other comments are kept",
+			content: "-- muz:description add a\n-- include common.sql\nCREATE TABLE a();",
+			want:    "-- muz:description add a\n-- include common.sql\nCREATE TABLE a();",
+		},
+		{
+			name:    "cycle",
+			content: "-- muz:include ../common/loop.sql",
+			wantErr: "include cycle",
+		},
+		{
+			name:    "outside of the migration path",
+			content: "-- muz:include ../../secrets.sql",
+			wantErr: "outside of the migration path",
+		},
+		{
+			name:    "missing file",
+			content: "-- muz:include missing.sql",
+			wantErr: "including missing.sql",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{"schema/1_init.sql": tt.content}
+			for name, content := range common {
+				files[name] = content
+			}
+
+			d := NewMuzo("schema", nil, MapFS(files))
+
+			got, err := d.ReadFile("1_init.sql")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ReadFile() error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("ReadFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package muz
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"path"
+	"slices"
+)
+
+// linear orders the files of all directories by version, for Migrate.Linear.
+// Consecutive files of the same directory are yielded together, and versions
+// used in more than one directory fail the iteration.
+func (m Migrate) linear() iter.Seq2[*Muzo, error] {
+	return func(yield func(*Muzo, error) bool) {
+		// The files are yielded after the listing ended, the default source is held
+		// open until then so a zip archive is not closed under them.
+		if m.held == nil && m.Source == nil {
+			fileSystem, closeFS, err := m.openFS(m.rootPath())
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			defer closeFS()
+
+			m.held = fileSystem
+		}
+
+		type entry struct {
+			info *Muzo
+			file FileInfo
+		}
+
+		var entries []entry
+		for info, err := range m.dirs() {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			for _, file := range info.Files {
+				entries = append(entries, entry{info: info, file: file})
+			}
+		}
+
+		slices.SortStableFunc(entries, func(a, b entry) int {
+			return cmp.Compare(a.file.Version, b.file.Version)
+		})
+
+		for i := 1; i < len(entries); i++ {
+			if prev, e := entries[i-1], entries[i]; prev.file.Version == e.file.Version && prev.info.Dir != e.info.Dir {
+				yield(nil, fmt.Errorf("linear versioning: version %d used by %s and %s",
+					e.file.Version, path.Join(prev.info.Dir, prev.file.Path), path.Join(e.info.Dir, e.file.Path)))
+				return
+			}
+		}
+
+		for i := 0; i < len(entries); {
+			j := i + 1
+			for j < len(entries) && entries[j].info == entries[i].info {
+				j++
+			}
+
+			files := make([]FileInfo, 0, j-i)
+			for _, e := range entries[i:j] {
+				files = append(files, e.file)
+			}
+
+			if !yield(entries[i].info.withFiles(files), nil) {
+				return
+			}
+
+			i = j
+		}
+	}
+}
+
+// stream returns the key the applied versions of dir are compared in,
+// the whole tree shares one key with Linear.
+func (m Migrate) stream(dir string) string {
+	if m.Linear {
+		return ""
+	}
+
+	return dir
+}
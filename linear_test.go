@@ -0,0 +1,146 @@
+package muz
+
+import (
+	"context"
+	"iter"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMigrateLinear(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql":  "CREATE TABLE users();",
+			"migrations/schema/003_orders.sql": "CREATE TABLE orders();",
+			"migrations/data/002_seed.sql":     "INSERT INTO users DEFAULT VALUES;",
+			"migrations/data/004_backfill.sql": "UPDATE users SET name = '';",
+		}),
+		Linear: true,
+	}
+
+	driver := &execDriver{}
+	if _, err := m.Migrate(context.Background(), driver); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	want := []string{"schema/001_users.sql", "data/002_seed.sql", "schema/003_orders.sql", "data/004_backfill.sql"}
+	if !slices.Equal(driver.calls, want) {
+		t.Errorf("applied = %v, want %v", driver.calls, want)
+	}
+
+	// 002 is older than the applied 004 of another directory.
+	driver = &execDriver{recordDriver: recordDriver{records: []Record{{Version: 4, Directory: "data", FileName: "004_backfill.sql"}}}}
+	m.OutOfOrder = OutOfOrderError
+	if _, err := m.Migrate(context.Background(), driver); err == nil {
+		t.Error("Migrate() error = nil, want out of order across directories")
+	}
+}
+
+func TestMigrateLinearCollision(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql": "CREATE TABLE users();",
+			"migrations/data/001_seed.sql":    "INSERT INTO users DEFAULT VALUES;",
+		}),
+		Linear: true,
+	}
+
+	if _, err := m.Migrate(context.Background(), &execDriver{}); err == nil {
+		t.Error("Migrate() error = nil, want version collision")
+	}
+
+	report, err := m.Validate()
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	if !slices.ContainsFunc(report.Issues, func(i ValidationIssue) bool { return i.Kind == IssueVersionCollision }) {
+		t.Errorf("Validate() issues = %+v, want %s", report.Issues, IssueVersionCollision)
+	}
+}
+
+func TestMigrateLinearZip(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "bundle.zip")
+	mustWriteFile(t, bundle, buildZip(t, map[string]string{
+		"schema/001_users.sql": "CREATE TABLE users();",
+		"data/002_seed.sql":    "INSERT INTO users DEFAULT VALUES;",
+	}))
+
+	m := Migrate{Path: bundle, Linear: true}
+
+	var dirs []string
+	for info, err := range m.Iter() {
+		if err != nil {
+			t.Fatalf("Iter() error = %v", err)
+		}
+
+		dirs = append(dirs, info.Dir)
+
+		for _, file := range info.Files {
+			if _, err := info.ReadFile(file.Path); err != nil {
+				t.Errorf("ReadFile(%s/%s) error = %v", info.Dir, file.Path, err)
+			}
+		}
+	}
+
+	if want := []string{"schema", "data"}; !slices.Equal(dirs, want) {
+		t.Errorf("Iter() dirs = %v, want %v", dirs, want)
+	}
+}
+
+func TestMigrateLinearForce(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql":  "CREATE TABLE users();",
+			"migrations/data/002_seed.sql":     "INSERT INTO users DEFAULT VALUES;",
+			"migrations/schema/003_orders.sql": "CREATE TABLE orders();",
+		}),
+		Linear: true,
+	}
+
+	driver := &recordDriver{}
+	if err := m.Force(context.Background(), driver, "schema", 3); err != nil {
+		t.Fatalf("Force() error = %v", err)
+	}
+
+	if got := driver.versions("schema"); !slices.Equal(got, []int64{1, 3}) {
+		t.Errorf("recorded versions = %v, want [1 3]", got)
+	}
+}
+
+func TestMigrateLinearListsOnce(t *testing.T) {
+	files := Migrate{FS: MapFS(map[string]string{
+		"migrations/schema/001_users.sql": "CREATE TABLE users();",
+		"migrations/data/002_seed.sql":    "INSERT INTO users DEFAULT VALUES;",
+	})}.Iter()
+
+	var lists int
+
+	m := Migrate{
+		Source: SourceFunc(func() iter.Seq2[*Muzo, error] {
+			return func(yield func(*Muzo, error) bool) {
+				lists++
+				files(yield)
+			}
+		}),
+		Linear: true,
+	}
+
+	var dirs []string
+	for info, err := range m.Iter() {
+		if err != nil {
+			t.Fatalf("Iter() error = %v", err)
+		}
+
+		dirs = append(dirs, info.Dir)
+	}
+
+	if want := []string{"schema", "data"}; !slices.Equal(dirs, want) {
+		t.Errorf("Iter() dirs = %v, want %v", dirs, want)
+	}
+
+	if lists != 1 {
+		t.Errorf("source listed %d times, want once", lists)
+	}
+}
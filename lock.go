@@ -0,0 +1,263 @@
+package muz
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrLockLost is returned by TableLock.Unlock when the lock was taken over during the run,
+// after its heartbeat stopped for longer than the TTL.
+var ErrLockLost = errors.New("migration lock lost")
+
+// TableLock is a Locker for backends without advisory locks, holding the lock as a row
+// of a lock table. The row is refreshed by a heartbeat while the lock is held, and a row not
+// refreshed for TTL, like the one of a crashed process, is taken over.
+// Stale locks are judged with the clocks of the processes, which should be in sync.
+type TableLock struct {
+	// DB is the database connection holding the lock table.
+	DB *sql.DB
+	// Dialect gives the placeholders of the backend.
+	Dialect Dialect
+	// Table is the name of the lock table.
+	//  - Default: "migrations_lock"
+	Table string
+	// Owner identifies the holder of the lock in the lock table.
+	//  - Default: hostname, process id and a random suffix.
+	Owner string
+	// TTL is the time after which a lock without heartbeat is stale.
+	//  - Default: 1 minute
+	TTL time.Duration
+	// RetryInterval is the time between attempts while the lock is held by another owner.
+	//  - Default: 1 second
+	RetryInterval time.Duration
+	// Logger if set, used to log waiting for and taking over the lock.
+	Logger Logger
+	// Now if set, is the clock of the heartbeats and of the stale lock check, like a fake clock in TTL tests.
+	//  - Default: time.Now
+	Now func() time.Time
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+	lost bool
+}
+
+func (l *TableLock) table() string {
+	if l.Table == "" {
+		return "migrations_lock"
+	}
+
+	return l.Table
+}
+
+func (l *TableLock) ttl() time.Duration {
+	if l.TTL <= 0 {
+		return time.Minute
+	}
+
+	return l.TTL
+}
+
+func (l *TableLock) retryInterval() time.Duration {
+	if l.RetryInterval <= 0 {
+		return time.Second
+	}
+
+	return l.RetryInterval
+}
+
+// owner returns Owner, generating it on first use.
+func (l *TableLock) owner() string {
+	if l.Owner == "" {
+		host, _ := os.Hostname()
+
+		suffix := make([]byte, 4)
+		_, _ = rand.Read(suffix)
+
+		l.Owner = fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(suffix))
+	}
+
+	return l.Owner
+}
+
+// Lock waits until the lock is free or stale and takes it, then starts the heartbeat.
+func (l *TableLock) Lock(ctx context.Context) error {
+	if l.Dialect == nil {
+		return errors.New("table lock: dialect is required")
+	}
+
+	if _, err := l.DB.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id integer NOT NULL PRIMARY KEY,
+			owner varchar(255) NOT NULL,
+			heartbeat_at TIMESTAMP NOT NULL
+		)
+	`, l.table())); err != nil {
+		return err
+	}
+
+	waiting := false
+	for {
+		holder, err := l.acquire(ctx)
+		if err != nil {
+			return err
+		}
+
+		if holder == "" {
+			break
+		}
+
+		if !waiting && l.Logger != nil {
+			l.Logger.Info("waiting for migration lock", "table", l.table(), "owner", holder)
+		}
+		waiting = true
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(l.retryInterval()):
+		}
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+
+	l.mu.Lock()
+	l.stop, l.done, l.lost = stop, done, false
+	l.mu.Unlock()
+
+	go l.heartbeat(stop, done)
+
+	return nil
+}
+
+// acquire removes a stale lock and tries to insert the row of the lock,
+// it returns the current owner when the lock is held by someone else.
+func (l *TableLock) acquire(ctx context.Context) (string, error) {
+	p := l.Dialect.Placeholder
+	now := clock(l.Now).UTC()
+
+	res, err := l.DB.ExecContext(ctx, fmt.Sprintf(`
+		DELETE FROM %s WHERE id = 1 AND heartbeat_at < %s
+	`, l.table(), p(1)), now.Add(-l.ttl()))
+	if err != nil {
+		return "", err
+	}
+
+	if n, _ := res.RowsAffected(); n > 0 && l.Logger != nil {
+		l.Logger.Warn("took over stale migration lock", "table", l.table())
+	}
+
+	_, insertErr := l.DB.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO %s (id, owner, heartbeat_at) VALUES (1, %s, %s)
+	`, l.table(), p(1), p(2)), l.owner(), now)
+	if insertErr == nil {
+		return "", nil
+	}
+
+	// The insert fails on the primary key while the lock is held, any other error is returned.
+	var holder string
+	err = l.DB.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT owner FROM %s WHERE id = 1
+	`, l.table())).Scan(&holder)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", insertErr
+	}
+
+	return holder, err
+}
+
+// heartbeat refreshes the lock row until Unlock, a row no longer owned means the lock was taken over.
+func (l *TableLock) heartbeat(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(l.ttl() / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		res, err := l.DB.Exec(fmt.Sprintf(`
+			UPDATE %s SET heartbeat_at = %s WHERE id = 1 AND owner = %s
+		`, l.table(), l.Dialect.Placeholder(1), l.Dialect.Placeholder(2)), clock(l.Now).UTC(), l.owner())
+		if err != nil {
+			// Retried on the next tick, the lock is only lost after TTL.
+			if l.Logger != nil {
+				l.Logger.Warn("refreshing migration lock", "table", l.table(), "error", err)
+			}
+
+			continue
+		}
+
+		// MySQL counts changed rows, an unchanged heartbeat_at affects none while the lock is held.
+		if n, err := res.RowsAffected(); err == nil && n == 0 && !l.held() {
+			l.mu.Lock()
+			l.lost = true
+			l.mu.Unlock()
+
+			if l.Logger != nil {
+				l.Logger.Error("migration lock taken over by another process", "table", l.table())
+			}
+
+			return
+		}
+	}
+}
+
+// held reports whether the lock row is still owned, errors count as held until the next heartbeat.
+func (l *TableLock) held() bool {
+	var n int
+	err := l.DB.QueryRow(fmt.Sprintf(`
+		SELECT COUNT(*) FROM %s WHERE id = 1 AND owner = %s
+	`, l.table(), l.Dialect.Placeholder(1)), l.owner()).Scan(&n)
+
+	return err != nil || n > 0
+}
+
+// Unlock stops the heartbeat and removes the row of the lock.
+// It returns ErrLockLost when another process took the lock over meanwhile.
+func (l *TableLock) Unlock(ctx context.Context) error {
+	l.mu.Lock()
+	stop, done := l.stop, l.done
+	l.stop = nil
+	l.mu.Unlock()
+
+	if stop == nil {
+		return nil
+	}
+
+	close(stop)
+	<-done
+
+	l.mu.Lock()
+	lost := l.lost
+	l.mu.Unlock()
+
+	if lost {
+		return ErrLockLost
+	}
+
+	_, err := l.DB.ExecContext(context.WithoutCancel(ctx), fmt.Sprintf(`
+		DELETE FROM %s WHERE id = 1 AND owner = %s
+	`, l.table(), l.Dialect.Placeholder(1)), l.owner())
+	return err
+}
+
+// ForceUnlock removes the lock whoever holds it, for a lock left by a process that cannot
+// come back. A process still running with it gets ErrLockLost from Unlock.
+func (l *TableLock) ForceUnlock(ctx context.Context) error {
+	_, err := l.DB.ExecContext(ctx, fmt.Sprintf(`
+		DELETE FROM %s WHERE id = 1
+	`, l.table()))
+	return err
+}
@@ -0,0 +1,59 @@
+package muz_test
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rakunlabs/muz"
+)
+
+func TestTableLockUnchangedHeartbeat(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "muz.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	newLock := func() *muz.TableLock {
+		return &muz.TableLock{DB: db, Dialect: muz.SQLiteDialect{}, TTL: 30 * time.Millisecond, Now: func() time.Time { return now }}
+	}
+
+	lock := newLock()
+	if err := lock.Lock(t.Context()); err != nil {
+		t.Fatalf("Lock() error: %v", err)
+	}
+
+	// Like MySQL, heartbeats writing the same heartbeat_at affect no rows.
+	if _, err := db.ExecContext(t.Context(), `
+		CREATE TRIGGER unchanged BEFORE UPDATE ON migrations_lock
+		WHEN NEW.heartbeat_at = OLD.heartbeat_at BEGIN SELECT RAISE(IGNORE); END
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if err := lock.Unlock(t.Context()); err != nil {
+		t.Fatalf("Unlock() error: %v", err)
+	}
+
+	// A lock removed by another process is still reported lost.
+	lock = newLock()
+	if err := lock.Lock(t.Context()); err != nil {
+		t.Fatalf("Lock() error: %v", err)
+	}
+
+	if err := newLock().ForceUnlock(t.Context()); err != nil {
+		t.Fatalf("ForceUnlock() error: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if err := lock.Unlock(t.Context()); !errors.Is(err, muz.ErrLockLost) {
+		t.Errorf("Unlock() error = %v, want muz.ErrLockLost", err)
+	}
+}
@@ -0,0 +1,133 @@
+package muz
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"path"
+	"strings"
+)
+
+// ErrNotLocked is returned with Migrate.Lockfile for a file missing from the lockfile or edited since it was written.
+var ErrNotLocked = errors.New("file does not match the lockfile")
+
+// lockfileName is the lockfile at the root of the migration path, written by WriteLock.
+const lockfileName = "muz.lock"
+
+// lockfileHeader starts the content written by WriteLock.
+const lockfileHeader = "# muz.lock, written by muz lock: the migration files in the order they are applied.\n"
+
+// WriteLock writes the lockfile of the migration tree to w, to be saved as muz.lock at the root of the
+// migration path and checked with Lockfile. It has a "<sha256>  <dir>/<file>" line for every file in the
+// order they are applied, with the checksums of Checksum. Go migrations are not part of it.
+func (m Migrate) WriteLock(w io.Writer) error {
+	entries, err := fileSums(m.listed())
+	if err != nil {
+		return fmt.Errorf("lockfile: %w", err)
+	}
+
+	var b strings.Builder
+	b.WriteString(lockfileHeader)
+
+	for _, e := range entries {
+		b.WriteString(e.sum + "  " + e.name + "\n")
+	}
+
+	_, err = io.WriteString(w, b.String())
+
+	return err
+}
+
+// readLockfile returns the checksums of the muz.lock file by file path.
+func (m *Migrate) readLockfile() (map[string]string, error) {
+	fileSystem, closeFS, err := m.openFS(m.rootPath())
+	if err != nil {
+		return nil, err
+	}
+	defer closeFS()
+
+	content, err := fs.ReadFile(fileSystem, lockfileName)
+	if err != nil {
+		return nil, fmt.Errorf("lockfile: %w", err)
+	}
+
+	sums := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			return nil, fmt.Errorf("lockfile: invalid line %q", line)
+		}
+
+		sums[name] = sum
+	}
+
+	return sums, scanner.Err()
+}
+
+// withLockfile refuses the files missing from the lockfile or edited since it was written, with Lockfile.
+// Files the run skips without reading them are not checked.
+func (m Migrate) withLockfile(list iter.Seq2[*Muzo, error]) iter.Seq2[*Muzo, error] {
+	if !m.Lockfile {
+		return list
+	}
+
+	return func(yield func(*Muzo, error) bool) {
+		sums, err := m.readLockfile()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		for info, err := range list {
+			if err == nil {
+				err = m.checkLocked(info, sums)
+			}
+
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			if !yield(info, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (m Migrate) checkLocked(info *Muzo, sums map[string]string) error {
+	for _, file := range info.Files {
+		if m.settled.skipped(info.Dir, file) || info.GoMigration(file.Path) != nil {
+			continue
+		}
+
+		name := path.Join(info.Dir, file.Path)
+
+		locked, ok := sums[name]
+		if !ok {
+			return fmt.Errorf("%w: %s is not in %s", ErrNotLocked, name, lockfileName)
+		}
+
+		sum, err := info.checksum(file.Path)
+		if err != nil {
+			return err
+		}
+
+		if sum != locked {
+			return fmt.Errorf("%w: %s was edited since %s was written", ErrNotLocked, name, lockfileName)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package muz
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLockfile(t *testing.T) {
+	files := map[string]string{
+		"migrations/schema/001_users.sql":  "CREATE TABLE users();",
+		"migrations/schema/002_orders.sql": "CREATE TABLE orders();",
+		"migrations/data/001_seed.sql":     "INSERT INTO users DEFAULT VALUES;",
+	}
+
+	var lock bytes.Buffer
+	if err := (Migrate{FS: MapFS(files), Order: []string{"schema"}}).WriteLock(&lock); err != nil {
+		t.Fatalf("WriteLock() error = %v", err)
+	}
+
+	want := lockfileHeader +
+		checksum([]byte("CREATE TABLE users();")) + "  schema/001_users.sql\n" +
+		checksum([]byte("CREATE TABLE orders();")) + "  schema/002_orders.sql\n" +
+		checksum([]byte("INSERT INTO users DEFAULT VALUES;")) + "  data/001_seed.sql\n"
+	if lock.String() != want {
+		t.Fatalf("WriteLock() =\n%s\nwant\n%s", lock.String(), want)
+	}
+
+	locked := func(edits map[string]string) Migrate {
+		fsys := map[string]string{"migrations/muz.lock": lock.String()}
+		for name, content := range files {
+			fsys[name] = content
+		}
+		for name, content := range edits {
+			fsys[name] = content
+		}
+
+		return Migrate{FS: MapFS(fsys), Order: []string{"schema"}, Lockfile: true}
+	}
+
+	t.Run("locked files run", func(t *testing.T) {
+		result, err := locked(nil).Migrate(context.Background(), &recordDriver{})
+		if err != nil || result.Applied() != 3 {
+			t.Errorf("Migrate() applied %d, error = %v", result.Applied(), err)
+		}
+	})
+
+	t.Run("file not in the lockfile", func(t *testing.T) {
+		m := locked(map[string]string{"migrations/schema/003_items.sql": "CREATE TABLE items();"})
+
+		_, err := m.Migrate(context.Background(), &recordDriver{})
+		if !errors.Is(err, ErrNotLocked) || !strings.Contains(err.Error(), "schema/003_items.sql") {
+			t.Errorf("Migrate() error = %v, want ErrNotLocked for 003_items.sql", err)
+		}
+	})
+
+	t.Run("edited file", func(t *testing.T) {
+		m := locked(map[string]string{"migrations/data/001_seed.sql": "DELETE FROM users;"})
+
+		if _, err := m.Migrate(context.Background(), &recordDriver{}); !errors.Is(err, ErrNotLocked) {
+			t.Errorf("Migrate() error = %v, want ErrNotLocked", err)
+		}
+	})
+
+	t.Run("applied files are not checked", func(t *testing.T) {
+		m := locked(map[string]string{"migrations/schema/001_users.sql": "CREATE TABLE users(id int);"})
+		driver := &recordDriver{records: []Record{{Version: 1, Directory: "schema", FileName: "001_users.sql"}}}
+
+		result, err := m.Migrate(context.Background(), driver)
+		if err != nil || result.Applied() != 2 {
+			t.Errorf("Migrate() applied %d, error = %v", result.Applied(), err)
+		}
+	})
+
+	t.Run("missing lockfile", func(t *testing.T) {
+		m := Migrate{FS: MapFS(files), Lockfile: true}
+
+		if _, err := m.Migrate(context.Background(), &recordDriver{}); err == nil {
+			t.Error("Migrate() error = nil, want the missing lockfile")
+		}
+	})
+}
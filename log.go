@@ -1,8 +1,28 @@
 package muz
 
+import "log/slog"
+
+// Logger is the logger of Migrate and the drivers, a *slog.Logger fits.
 type Logger interface {
 	Error(msg string, keysAndValues ...any)
 	Info(msg string, keysAndValues ...any)
 	Debug(msg string, keysAndValues ...any)
 	Warn(msg string, keysAndValues ...any)
 }
+
+// discard is the logger used when none is set.
+var discard Logger = slog.New(slog.DiscardHandler)
+
+// log returns the Logger of m, discarding the messages when it is not set.
+func (m *Migrate) log() Logger {
+	return orDiscard(m.Logger)
+}
+
+// orDiscard returns l, or a logger discarding the messages when l is nil.
+func orDiscard(l Logger) Logger {
+	if l == nil {
+		return discard
+	}
+
+	return l
+}
@@ -0,0 +1,42 @@
+package muz
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestMigrateLogger(t *testing.T) {
+	var out bytes.Buffer
+
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/app/001_users.sql": "CREATE TABLE users();",
+			"migrations/app/002_posts.sql": "CREATE TABLE posts();",
+			"migrations/app/003_seed.sql":  "-- muz:tags seed\nINSERT INTO users DEFAULT VALUES;",
+		}),
+		Logger: slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}
+
+	driver := &recordDriver{records: []Record{{Version: 1, Directory: "app", FileName: "001_users.sql"}}}
+	if _, err := m.Migrate(context.Background(), driver); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	want := []string{
+		`level=DEBUG msg="skipping migration by tags" directory=app file=003_seed.sql`,
+		`level=DEBUG msg="found migration directory" directory=app files=2`,
+		`level=DEBUG msg="skipping applied migration" directory=app file=001_users.sql version=1`,
+		`level=DEBUG msg="running migration" directory=app file=002_posts.sql version=2`,
+		`level=INFO msg="applied migration" directory=app file=002_posts.sql version=2 duration=`,
+	}
+
+	got := out.String()
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("log missing %q, got:\n%s", w, got)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package muz
+
+import (
+	"errors"
+	"fmt"
+	"path"
+)
+
+// ErrVersionRecorded is returned with MatchFileName and MatchChecksum for a pending file whose version
+// is recorded for another file of its directory, the tracking table keeps one file per version.
+var ErrVersionRecorded = errors.New("version recorded for another file")
+
+// Match decides which files of the tree are the applied ones recorded in the tracking table.
+type Match string
+
+const (
+	// MatchVersion treats a file as applied when its version is recorded for its directory.
+	MatchVersion Match = ""
+	// MatchFileName treats a file as applied when its file name is recorded for its directory,
+	// a renamed file is pending again.
+	MatchFileName Match = "file_name"
+	// MatchChecksum treats a file as applied when its file name or its checksum is recorded for its directory,
+	// a renamed file with the same content stays applied. Checksums are kept by drivers implementing ChecksumStore.
+	MatchChecksum Match = "checksum"
+)
+
+func (m Match) valid() bool {
+	switch m {
+	case MatchVersion, MatchFileName, MatchChecksum:
+		return true
+	}
+
+	return false
+}
+
+// fileKey is a file name, or a checksum, of a directory.
+type fileKey struct {
+	dir  string
+	name string
+}
+
+// appliedRecords finds the records of the applied files with a Match policy.
+type appliedRecords struct {
+	match    Match
+	versions map[appliedKey]Record
+	names    map[fileKey]Record
+	sums     map[fileKey]Record
+}
+
+func newAppliedRecords(match Match, records []Record) *appliedRecords {
+	a := &appliedRecords{match: match, versions: make(map[appliedKey]Record, len(records))}
+	if match != MatchVersion {
+		a.names = make(map[fileKey]Record, len(records))
+		a.sums = make(map[fileKey]Record)
+	}
+
+	for _, r := range records {
+		a.versions[appliedKey{r.Directory, r.Version}] = r
+
+		if match == MatchVersion {
+			continue
+		}
+
+		a.names[fileKey{r.Directory, r.FileName}] = r
+
+		// Go migrations and empty files share the checksum of no content.
+		if match == MatchChecksum && r.Checksum != "" && r.Checksum != checksum(nil) {
+			a.sums[fileKey{r.Directory, r.Checksum}] = r
+		}
+	}
+
+	return a
+}
+
+// lookup returns the record of file of info, false when the file is not applied.
+// With MatchChecksum the file is read when its name is not recorded.
+func (a *appliedRecords) lookup(info *Muzo, file FileInfo) (Record, bool, error) {
+	if a.match == MatchVersion {
+		r, ok := a.versions[appliedKey{info.Dir, file.Version}]
+		return r, ok, nil
+	}
+
+	if r, ok := a.names[fileKey{info.Dir, file.Path}]; ok {
+		return r, true, nil
+	}
+
+	if len(a.sums) == 0 || info.GoMigration(file.Path) != nil {
+		return Record{}, false, nil
+	}
+
+	sum, err := info.checksum(file.Path)
+	if err != nil {
+		return Record{}, false, err
+	}
+
+	r, ok := a.sums[fileKey{info.Dir, sum}]
+
+	return r, ok, nil
+}
+
+// recorded reports whether the version of dir is known to be applied without reading the file.
+func (a *appliedRecords) recorded(dir string, file FileInfo) bool {
+	if a.match == MatchVersion {
+		_, ok := a.versions[appliedKey{dir, file.Version}]
+		return ok
+	}
+
+	_, ok := a.names[fileKey{dir, file.Path}]
+
+	return ok
+}
+
+// conflict returns ErrVersionRecorded when the version of the pending file of dir is recorded for another file.
+func (a *appliedRecords) conflict(dir string, file FileInfo) error {
+	if a.match == MatchVersion {
+		return nil
+	}
+
+	if r, ok := a.versions[appliedKey{dir, file.Version}]; ok {
+		return fmt.Errorf("%w: %s version %d is recorded for %s", ErrVersionRecorded, path.Join(dir, file.Path), file.Version, r.FileName)
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestMigrateMatch(t *testing.T) {
+	files := map[string]string{
+		"migrations/app/001_users.sql":   "CREATE TABLE users();",
+		"migrations/app/002_hotfix.sql":  "CREATE INDEX users_name ON users (name);",
+		"migrations/app/003_orders.sql":  "CREATE TABLE orders();",
+		"migrations/app/004_renamed.sql": "CREATE TABLE items();",
+	}
+
+	// 004_renamed.sql was applied as 002_items.sql, the hotfix is inserted below the applied versions.
+	records := []Record{
+		{Version: 1, Directory: "app", FileName: "001_users.sql", Checksum: checksum([]byte("CREATE TABLE users();"))},
+		{Version: 2, Directory: "app", FileName: "002_items.sql", Checksum: checksum([]byte("CREATE TABLE items();"))},
+		{Version: 3, Directory: "app", FileName: "003_orders.sql", Checksum: checksum([]byte("CREATE TABLE orders();"))},
+	}
+
+	tests := []struct {
+		name         string
+		match        Match
+		wantErr      error
+		wantOutcomes []Outcome
+		wantFiles    []string
+	}{
+		{
+			name:         "version",
+			wantOutcomes: []Outcome{OutcomeSkipped, OutcomeSkipped, OutcomeSkipped, OutcomeApplied},
+			wantFiles:    []string{"001_users.sql", "002_items.sql", "003_orders.sql", "004_renamed.sql"},
+		},
+		{
+			name:    "file name",
+			match:   MatchFileName,
+			wantErr: ErrVersionRecorded,
+		},
+		{
+			name:  "checksum",
+			match: MatchChecksum,
+			// The hotfix takes the version of the renamed file.
+			wantErr: ErrVersionRecorded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Migrate{FS: MapFS(files), OutOfOrder: OutOfOrderApply, Match: tt.match}
+
+			driver := &execDriver{recordDriver: recordDriver{records: slices.Clone(records)}}
+
+			result, err := m.Migrate(context.Background(), driver)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Migrate() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			var outcomes []Outcome
+			for _, f := range result.Files {
+				outcomes = append(outcomes, f.Outcome)
+			}
+
+			if !slices.Equal(outcomes, tt.wantOutcomes) {
+				t.Errorf("outcomes = %v, want %v", outcomes, tt.wantOutcomes)
+			}
+
+			var got []string
+			for _, r := range driver.records {
+				got = append(got, r.FileName)
+			}
+
+			slices.Sort(got)
+
+			if !slices.Equal(got, tt.wantFiles) {
+				t.Errorf("recorded files = %v, want %v", got, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestMigrateMatchHotfix(t *testing.T) {
+	files := map[string]string{
+		"migrations/app/001_users.sql":   "CREATE TABLE users();",
+		"migrations/app/002_hotfix.sql":  "CREATE INDEX users_name ON users (name);",
+		"migrations/app/003_orders.sql":  "CREATE TABLE orders();",
+		"migrations/app/005_renamed.sql": "CREATE TABLE items();",
+	}
+
+	records := []Record{
+		{Version: 1, Directory: "app", FileName: "001_users.sql", Checksum: checksum([]byte("CREATE TABLE users();"))},
+		{Version: 3, Directory: "app", FileName: "003_orders.sql", Checksum: checksum([]byte("CREATE TABLE orders();"))},
+		{Version: 4, Directory: "app", FileName: "004_items.sql", Checksum: checksum([]byte("CREATE TABLE items();"))},
+	}
+
+	tests := []struct {
+		name         string
+		match        Match
+		wantOutcomes []Outcome
+		wantStates   []State
+	}{
+		{
+			name:         "file name",
+			match:        MatchFileName,
+			wantOutcomes: []Outcome{OutcomeSkipped, OutcomeApplied, OutcomeSkipped, OutcomeApplied},
+			wantStates:   []State{StateApplied, StatePending, StateApplied, StatePending, StateMissing},
+		},
+		{
+			name:         "checksum",
+			match:        MatchChecksum,
+			wantOutcomes: []Outcome{OutcomeSkipped, OutcomeApplied, OutcomeSkipped, OutcomeSkipped},
+			wantStates:   []State{StateApplied, StatePending, StateApplied, StateApplied},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Migrate{FS: MapFS(files), OutOfOrder: OutOfOrderApply, Match: tt.match}
+
+			driver := &execDriver{recordDriver: recordDriver{records: slices.Clone(records)}}
+
+			status, err := m.Status(context.Background(), driver)
+			if err != nil {
+				t.Fatalf("Status() error = %v", err)
+			}
+
+			var states []State
+			for _, f := range status.Files {
+				states = append(states, f.State)
+			}
+
+			if !slices.Equal(states, tt.wantStates) {
+				t.Errorf("states = %v, want %v", states, tt.wantStates)
+			}
+
+			result, err := m.Migrate(context.Background(), driver)
+			if err != nil {
+				t.Fatalf("Migrate() error = %v", err)
+			}
+
+			var outcomes []Outcome
+			for _, f := range result.Files {
+				outcomes = append(outcomes, f.Outcome)
+			}
+
+			if !slices.Equal(outcomes, tt.wantOutcomes) {
+				t.Errorf("outcomes = %v, want %v", outcomes, tt.wantOutcomes)
+			}
+		})
+	}
+}
+
+func TestMigrateMatchNotSupported(t *testing.T) {
+	m := Migrate{FS: MapFS(map[string]string{"migrations/app/001_users.sql": "CREATE TABLE users();"}), Match: MatchFileName}
+
+	if _, err := m.Migrate(context.Background(), &nopDriver{}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Migrate() error = %v, want %v", err, ErrNotSupported)
+	}
+}
@@ -0,0 +1,200 @@
+package muz
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MapFS returns an in-memory filesystem from file paths to contents,
+// useful for generated migrations and unit tests.
+//
+//	m := muz.Migrate{
+//		Path: ".",
+//		FS: muz.MapFS(map[string]string{
+//			"1_create.sql":        "CREATE TABLE users (id int);",
+//			"seed/1_users.sql":    "INSERT INTO users VALUES (1);",
+//		}),
+//	}
+//
+// A testing/fstest.MapFS can be used in the same way.
+func MapFS(files map[string]string) fs.FS {
+	data := make(map[string][]byte, len(files))
+	for name, content := range files {
+		data[name] = []byte(content)
+	}
+
+	return newMemFS(data)
+}
+
+// memFS is a read-only in-memory filesystem.
+// Parent directories of the given files are created implicitly.
+type memFS struct {
+	files map[string][]byte
+	dirs  map[string][]string
+}
+
+var (
+	_ fs.ReadFileFS = (*memFS)(nil)
+	_ fs.ReadDirFS  = (*memFS)(nil)
+)
+
+func newMemFS(files map[string][]byte) *memFS {
+	m := &memFS{
+		files: make(map[string][]byte, len(files)),
+		dirs:  map[string][]string{".": nil},
+	}
+
+	for name, data := range files {
+		name = path.Clean(strings.TrimPrefix(name, "/"))
+		m.files[name] = data
+		m.addParents(name)
+	}
+
+	for dir := range m.dirs {
+		slices.Sort(m.dirs[dir])
+		m.dirs[dir] = slices.Compact(m.dirs[dir])
+	}
+
+	return m
+}
+
+// addParents registers name and all of its parent directories.
+func (m *memFS) addParents(name string) {
+	for name != "." {
+		dir := path.Dir(name)
+
+		_, exists := m.dirs[dir]
+		m.dirs[dir] = append(m.dirs[dir], path.Base(name))
+		if exists {
+			return
+		}
+
+		name = dir
+	}
+}
+
+func (m *memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if data, ok := m.files[name]; ok {
+		return &memFile{
+			info:   memInfo{name: path.Base(name), size: int64(len(data))},
+			Reader: bytes.NewReader(data),
+		}, nil
+	}
+
+	if _, ok := m.dirs[name]; ok {
+		entries, _ := m.ReadDir(name)
+
+		return &memDir{
+			info:    memInfo{name: path.Base(name), dir: true},
+			entries: entries,
+		}, nil
+	}
+
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (m *memFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+
+	data, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return slices.Clone(data), nil
+}
+
+func (m *memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	children, ok := m.dirs[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, child := range children {
+		full := path.Join(name, child)
+		if data, ok := m.files[full]; ok {
+			entries = append(entries, fs.FileInfoToDirEntry(memInfo{name: child, size: int64(len(data))}))
+		} else {
+			entries = append(entries, fs.FileInfoToDirEntry(memInfo{name: child, dir: true}))
+		}
+	}
+
+	return entries, nil
+}
+
+// //////////////////////////////
+
+type memInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) ModTime() time.Time { return time.Time{} }
+func (i memInfo) IsDir() bool        { return i.dir }
+func (i memInfo) Sys() any           { return nil }
+
+func (i memInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+
+	return 0o444
+}
+
+type memFile struct {
+	info memInfo
+
+	*bytes.Reader
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+type memDir struct {
+	info    memInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+
+	n = min(n, len(rest))
+	d.offset += n
+
+	return rest[:n], nil
+}
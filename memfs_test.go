@@ -0,0 +1,59 @@
+package muz
+
+import (
+	"io/fs"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMemFS(t *testing.T) {
+	files := map[string][]byte{}
+	for name, content := range archiveFiles {
+		files[name] = []byte(content)
+	}
+
+	if err := fstest.TestFS(newMemFS(files), "migrations/1_create.sql", "migrations/inner/1_data.sql"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMapFS(t *testing.T) {
+	fsys := MapFS(map[string]string{
+		"2_users.sql":      "CREATE TABLE users (id int);",
+		"1_init.sql":       "CREATE SCHEMA app;",
+		"seed/1_users.sql": "INSERT INTO users VALUES (1);",
+	})
+
+	content, err := fs.ReadFile(fsys, "seed/1_users.sql")
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	if string(content) != "INSERT INTO users VALUES (1);" {
+		t.Errorf("ReadFile = %q", content)
+	}
+
+	m := Migrate{Path: ".", FS: fsys}
+
+	var got []Muzo
+	for info, err := range m.Iter() {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, *info)
+	}
+
+	want := []Muzo{
+		{Dir: ".", Files: []FileInfo{{Path: "1_init.sql", Version: 1}, {Path: "2_users.sql", Version: 2}}},
+		{Dir: "seed", Files: []FileInfo{{Path: "1_users.sql", Version: 1}}},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Dir != want[i].Dir || !slices.Equal(got[i].Files, want[i].Files) {
+			t.Errorf("result[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package muz
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// MultiFS merges several filesystems into one, so migrations embedded by
+// different modules can be applied in a single run.
+//
+// Directories are merged. When the same file exists in more than one
+// filesystem, the one given first wins.
+//
+//	m := muz.Migrate{
+//		Path: "migrations",
+//		FS:   muz.MultiFS(app.Migrations, library.Migrations),
+//	}
+func MultiFS(fsys ...fs.FS) fs.FS {
+	return multiFS(slices.Clone(fsys))
+}
+
+type multiFS []fs.FS
+
+var _ fs.ReadDirFS = multiFS(nil)
+
+func (m multiFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	isDir := false
+	for _, fsys := range m {
+		info, err := fs.Stat(fsys, name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			if isDir {
+				// a directory with this name was already found first
+				continue
+			}
+
+			return fsys.Open(name)
+		}
+
+		isDir = true
+	}
+
+	if !isDir {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	entries, err := m.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &memDir{
+		info:    memInfo{name: path.Base(name), dir: true},
+		entries: entries,
+	}, nil
+}
+
+func (m multiFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	var (
+		entries []fs.DirEntry
+		seen    = map[string]bool{}
+		found   bool
+	)
+
+	for _, fsys := range m {
+		list, err := fs.ReadDir(fsys, name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		found = true
+
+		for _, entry := range list {
+			if seen[entry.Name()] {
+				continue
+			}
+
+			seen[entry.Name()] = true
+			entries = append(entries, entry)
+		}
+	}
+
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	return entries, nil
+}
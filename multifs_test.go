@@ -0,0 +1,57 @@
+package muz
+
+import (
+	"io/fs"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMultiFS(t *testing.T) {
+	app := MapFS(map[string]string{
+		"migrations/1_app.sql":        "app",
+		"migrations/shared/1_app.sql": "app override",
+	})
+	library := fstest.MapFS{
+		"migrations/shared/1_app.sql":  {Data: []byte("library")},
+		"migrations/shared/2_lib.sql":  {Data: []byte("library")},
+		"migrations/library/1_lib.sql": {Data: []byte("library")},
+	}
+
+	fsys := MultiFS(app, library)
+
+	if err := fstest.TestFS(fsys, "migrations/1_app.sql", "migrations/shared/2_lib.sql", "migrations/library/1_lib.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := fs.ReadFile(fsys, "migrations/shared/1_app.sql")
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	if string(content) != "app override" {
+		t.Errorf("conflicting file = %q, want the first filesystem to win", content)
+	}
+
+	var got []Muzo
+	for info, err := range (Migrate{FS: fsys}).Iter() {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, *info)
+	}
+
+	want := []Muzo{
+		{Dir: ".", Files: []FileInfo{{Path: "1_app.sql", Version: 1}}},
+		{Dir: "library", Files: []FileInfo{{Path: "1_lib.sql", Version: 1}}},
+		{Dir: "shared", Files: []FileInfo{{Path: "1_app.sql", Version: 1}, {Path: "2_lib.sql", Version: 2}}},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Dir != want[i].Dir || !slices.Equal(got[i].Files, want[i].Files) {
+			t.Errorf("result[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Target is a named database migrated by MultiTarget.
+type Target struct {
+	Name   string
+	Driver Driver
+}
+
+// MultiTarget applies the same migrations to many databases, like the shards of a deployment.
+// Hooks and a custom Source of Migrate are called from several goroutines.
+type MultiTarget struct {
+	Migrate Migrate
+	Targets []Target
+	// Workers is the number of targets migrated at the same time.
+	//  - Default: 4
+	Workers int
+	// FailFast stops starting new targets after the first failure.
+	// Targets already running are finished.
+	FailFast bool
+}
+
+// TargetResult is the outcome of a single target.
+type TargetResult struct {
+	Name   string  `json:"name"`
+	Result *Result `json:"result,omitempty"`
+	// Error of the target, empty on success.
+	Error string `json:"error,omitempty"`
+	// Started is false for targets not run because of FailFast.
+	Started bool `json:"started"`
+}
+
+// MultiResult collects the results of a MultiTarget run, in the order of Targets.
+type MultiResult struct {
+	Targets  []TargetResult `json:"targets"`
+	Duration time.Duration  `json:"duration"`
+}
+
+// Failed returns the targets that failed or did not start.
+func (r *MultiResult) Failed() []TargetResult {
+	var failed []TargetResult
+	for _, t := range r.Targets {
+		if t.Error != "" || !t.Started {
+			failed = append(failed, t)
+		}
+	}
+
+	return failed
+}
+
+// Run applies the pending migrations to every target, with at most Workers at the same time.
+// All targets are attempted unless FailFast is set, the returned error joins the errors of the
+// failed targets.
+func (mt MultiTarget) Run(ctx context.Context) (*MultiResult, error) {
+	start := time.Now()
+
+	workers := mt.Workers
+	if workers <= 0 {
+		workers = 4
+	}
+
+	result := &MultiResult{Targets: make([]TargetResult, len(mt.Targets))}
+	errs := make([]error, len(mt.Targets))
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed bool
+		sem    = make(chan struct{}, workers)
+	)
+
+	for i, target := range mt.Targets {
+		result.Targets[i] = TargetResult{Name: target.Name}
+
+		sem <- struct{}{}
+
+		mu.Lock()
+		stop := failed && mt.FailFast
+		mu.Unlock()
+
+		if stop || ctx.Err() != nil {
+			<-sem
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			r, err := mt.Migrate.Migrate(ctx, target.Driver)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			result.Targets[i].Started = true
+			result.Targets[i].Result = r
+			if err != nil {
+				failed = true
+				result.Targets[i].Error = err.Error()
+				errs[i] = fmt.Errorf("target %s: %w", target.Name, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	result.Duration = time.Since(start)
+
+	for i, t := range result.Targets {
+		if !t.Started && errs[i] == nil {
+			err := ctx.Err()
+			if err == nil {
+				err = errors.New("not started after a failed target")
+			}
+
+			errs[i] = fmt.Errorf("target %s: %w", t.Name, err)
+		}
+	}
+
+	return result, errors.Join(errs...)
+}
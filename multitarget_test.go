@@ -0,0 +1,80 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMultiTargetRun(t *testing.T) {
+	m := Migrate{FS: MapFS(map[string]string{"migrations/app/001_users.sql": "CREATE TABLE users();"})}
+
+	failing := &execDriver{failDir: "app"}
+
+	tests := []struct {
+		name        string
+		failFast    bool
+		targets     []Target
+		wantErr     bool
+		wantFailed  int
+		wantStarted int
+	}{
+		{
+			name:        "all succeed",
+			targets:     []Target{{Name: "a", Driver: &recordDriver{}}, {Name: "b", Driver: &recordDriver{}}},
+			wantStarted: 2,
+		},
+		{
+			name:        "one fails",
+			targets:     []Target{{Name: "a", Driver: failing}, {Name: "b", Driver: &recordDriver{}}},
+			wantErr:     true,
+			wantFailed:  1,
+			wantStarted: 2,
+		},
+		{
+			name:        "fail fast",
+			failFast:    true,
+			targets:     []Target{{Name: "a", Driver: failing}, {Name: "b", Driver: &recordDriver{}}},
+			wantErr:     true,
+			wantFailed:  2,
+			wantStarted: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt := MultiTarget{Migrate: m, Targets: tt.targets, Workers: 1, FailFast: tt.failFast}
+
+			result, err := mt.Run(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := len(result.Failed()); got != tt.wantFailed {
+				t.Errorf("Failed() = %d targets, want %d", got, tt.wantFailed)
+			}
+
+			started := 0
+			for _, target := range result.Targets {
+				if target.Started {
+					started++
+				}
+			}
+
+			if started != tt.wantStarted {
+				t.Errorf("started targets = %d, want %d", started, tt.wantStarted)
+			}
+		})
+	}
+}
+
+func TestMultiTargetRunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mt := MultiTarget{Targets: []Target{{Name: "a", Driver: &recordDriver{}}}}
+
+	if _, err := mt.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want context.Canceled", err)
+	}
+}
@@ -1,25 +1,43 @@
 package muz
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
 	"io/fs"
 	"iter"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 )
 
 // /////////////////////////////////
 
+// Migrate is the configuration of a migration tree. Its methods never change it, every run works on a copy
+// with a state of its own, so a single Migrate can be shared by goroutines migrating many drivers or tenants.
+// Hooks, Logger, a custom Source and the other interfaces it holds are then called concurrently.
 type Migrate struct {
 	// Path to the directory containing migration files.
-	//  - Default: "migrations"
+	//  - Default: "migrations", "db/migrations" with CompatDbmate
+	//  - A path to a .zip file is opened as an archive, a directory inside
+	//    the archive can be selected with "bundle.zip/migrations".
 	Path string `cfg:"path" json:"path"`
 	// FS if set, use this embedded filesystem instead of reading from Path.
 	FS fs.FS `cfg:"-" json:"-"`
+	// Source if set, lists migrations instead of walking Path or FS.
+	//  - Default: filesystem walker using Path, FS, Order, Skip and Extension.
+	//  - Use NewMuzo to build directories from custom sources.
+	Source Source `cfg:"-" json:"-"`
 
 	// Order of directory names to apply migrations from.
 	//  - Default: []string{}
 	//  - If empty, all directories are applied in alphabetical order.
 	//  - If set, give priority to the listed directories in the specified order.
 	//    Directories not listed will be applied afterwards in alphabetical order.
+	//  - Names are matched like Muzo.Dir, "/app/", "./app" and "app" being the same directory.
 	Order []string `cfg:"order" json:"order"`
 	// Skip patterns to ignore during migration (supports glob patterns).
 	//  - Default: []string{}
@@ -29,34 +47,769 @@ type Migrate struct {
 	//    - **/*.sql matches all .sql files in any directory
 	//  - Can skip both files and directories.
 	//  - Paths should be given in /test/dir1 format, relative to the migration path.
+	//    Backslashes, like the ones of filepath.Join on Windows, are matched as forward slashes.
 	Skip []string `cfg:"skip" json:"skip"`
 
+	// SkipHidden skips files and directories starting with a dot, like .git or .DS_Store.
+	//  - Default: nil, hidden entries are skipped.
+	//  - Set to a pointer to false to consider them.
+	SkipHidden *bool `cfg:"skip_hidden" json:"skip_hidden"`
+	// MaxDepth limits the directory levels below Path walked for migrations.
+	//  - Default: 0, no limit.
+	//  - 1 only walks the direct subdirectories of Path, the files of Path itself are always considered.
+	MaxDepth int `cfg:"max_depth" json:"max_depth"`
+
 	// Extension of migration files.
 	//  - Default: none (all files are considered)
 	//  - Only files with this extension will be considered as migration files.
+	//  - Gzip compressed files (.sql.gz) match the extension of the inner file.
 	Extension string `cfg:"extension" json:"extension"`
+	// DirConfig overrides Extension, Skip and the transaction of single directories, by directory like "seed".
+	//  - Default: nil, every directory uses the settings of Migrate.
+	//  - A seed directory can take ".csv" files while the schema directory stays ".sql" only, see DirOptions.
+	DirConfig map[string]DirOptions `cfg:"dir_config" json:"dir_config"`
+	// Reseed runs every file of the seed directories, not only the ones changed since they last ran.
+	//  - Default: false
+	//  - See DirOptions.Seed.
+	Reseed bool `cfg:"reseed" json:"reseed"`
+	// IncludeUnnumbered applies files without a leading number after the numbered files of their directory.
+	//  - Default: false, such files are ignored and reported by Validate.
+	//  - They run in alphabetical order with the versions after the highest numbered file,
+	//    so a numbered file added later takes the version of an applied unnumbered one.
+	IncludeUnnumbered bool `cfg:"include_unnumbered" json:"include_unnumbered"`
+	// Compat reads the file naming of another migration tool, see Compat.
+	//  - Default: CompatNone, muz naming with "NNN_name.sql" and "NNN_name.down.sql" files.
+	//  - CompatGolangMigrate only applies "NNN_name.up.sql" files, rolled back by "NNN_name.down.sql".
+	//  - CompatDbmate reads "db/migrations" by default, files hold "-- migrate:up" and "-- migrate:down" blocks.
+	Compat Compat `cfg:"compat" json:"compat"`
+
+	// Signature if set, verifies the detached signature of the manifest of the tree before it is used,
+	// refusing unsigned or tampered trees with ErrSignature, like bundles fetched from remote sources.
+	//  - Default: nil, the tree is not verified.
+	//  - The manifest is made with the Path, FS, Source, Skip and Extension settings, see Manifest.
+	Signature SignatureVerifier `cfg:"-" json:"-"`
+	// SignatureFile is the detached signature in the root of the migration path.
+	//  - Default: "muz.sig"
+	SignatureFile string `cfg:"signature_file" json:"signature_file"`
+
+	// Decryptor reads encrypted migration files, named with an ".age" or ".enc" suffix like "001_seed.sql.age".
+	//  - Default: nil, encrypted files fail when they are read.
+	//  - Checksums are computed over the plaintext, files are decrypted once per listing and kept in memory.
+	Decryptor Decryptor `cfg:"-" json:"-"`
+
+	// Lockfile refuses to run files missing from the muz.lock file at the root of the migration path,
+	// or edited since it was written by WriteLock, with ErrNotLocked before they are applied.
+	//  - Default: false, the lockfile is not read.
+	//  - Applied files the run skips without reading them are not checked.
+	Lockfile bool `cfg:"lockfile" json:"lockfile"`
+
+	// OutOfOrder is the policy for files older than the latest applied version of their directory.
+	//  - Default: OutOfOrderIgnore, such files are skipped.
+	//  - Policies other than the default need a driver implementing StatusReporter.
+	OutOfOrder OutOfOrder `cfg:"out_of_order" json:"out_of_order"`
+
+	// Checksum is the policy for applied files edited since they ran, compared with the checksum in the tracking table.
+	//  - Default: ChecksumIgnore
+	//  - Policies other than the default need a driver implementing StatusReporter.
+	Checksum ChecksumPolicy `cfg:"checksum" json:"checksum"`
+
+	// Match decides which files are applied, by the version, the file name or the checksum recorded for their directory.
+	//  - Default: MatchVersion
+	//  - MatchFileName and MatchChecksum keep a renamed or inserted file from being taken for the applied file
+	//    of its version, and a pending file whose version is recorded for another file fails with ErrVersionRecorded.
+	//  - Policies other than the default need a driver implementing StatusReporter.
+	Match Match `cfg:"match" json:"match"`
+
+	// Strict turns on the safest policies at once, a single knob for new projects.
+	//  - Default: false
+	//  - Checksum and OutOfOrder become ChecksumError and OutOfOrderError when not set,
+	//    for drivers implementing StatusReporter.
+	//  - Runs are validated first: duplicate versions, gaps, files without numeric prefix
+	//    and Skip patterns matching nothing fail them before the driver is started.
+	Strict bool `cfg:"strict" json:"strict"`
+
+	// MinVersion and MaxVersion limit the files considered to a range of versions, both included.
+	//  - Default: 0, no limit.
+	//  - Files outside of the range are not listed, applied or reported, like a past state of the schema.
+	MinVersion int64 `cfg:"min_version" json:"min_version"`
+	MaxVersion int64 `cfg:"max_version" json:"max_version"`
+	// DirVersions limits the versions of single directories, overriding MinVersion and MaxVersion.
+	DirVersions map[string]VersionRange `cfg:"dir_versions" json:"dir_versions"`
+
+	// Tags selects the tagged files to run, with a "-- muz:tags seed,slow" header or a muz.tags file in the directory.
+	//  - Default: []string{}, only files without tags run.
+	//  - Tagged files run when one of their tags is listed, untagged files always run.
+	Tags []string `cfg:"tags" json:"tags"`
+	// ExcludeTags never runs files with one of these tags, even when listed in Tags.
+	ExcludeTags []string `cfg:"exclude_tags" json:"exclude_tags"`
+
+	// Linear treats versions as one sequence across the whole tree instead of one per directory.
+	//  - Default: false
+	//  - Files are applied by version, a version used in two directories is an error.
+	//  - Directories are only used for organization, a file older than the latest applied
+	//    version of any directory is out of order.
+	Linear bool `cfg:"linear" json:"linear"`
+
+	// Vars are the values of ${NAME} placeholders in the migration files, like schema or role names.
+	//  - Default: nil, placeholders are not replaced.
+	//  - Names missing from Vars are read from the environment, a name set in neither is an error.
+	//  - Write $${NAME} for a literal ${NAME}.
+	//  - Checksums are computed before the replacement, a changed value does not change an applied file.
+	Vars map[string]string `cfg:"vars" json:"vars"`
+	// Secrets resolve ${scheme:name} placeholders by scheme, like "env", "file" or "vault".
+	//  - Default: nil
+	//  - Placeholders with a scheme missing from Secrets, like ${VAR:-default}, are kept unchanged.
+	//  - Secrets are resolved once per run and never logged, but are part of a dry run script.
+	Secrets map[string]SecretResolver `cfg:"-" json:"-"`
+
+	// AutoNoTransaction runs files with statements that cannot run inside a transaction, like CREATE INDEX
+	// CONCURRENTLY, VACUUM or CREATE DATABASE, as if they had the no-transaction directive.
+	//  - Default: false, such files without the directive fail with ErrNeedsNoTransaction before they run.
+	AutoNoTransaction bool `cfg:"auto_no_transaction" json:"auto_no_transaction"`
+
+	// FileTimeout if set, limits the time of every file, canceling its queries when it runs longer,
+	// so a runaway backfill fails the run with ErrFileTimeout instead of hanging the deployment.
+	//  - Default: 0, no limit.
+	//  - Drivers without StatusReporter process a directory at once, the limit applies to the directory.
+	FileTimeout time.Duration `cfg:"file_timeout" json:"file_timeout"`
+
+	// Parallel is the number of directories applied at the same time, each in a session of its own
+	// on a clone of a driver implementing Cloner, for trees of many independent directories.
+	//  - Default: 0, directories are applied one after another in a single session.
+	//  - Directories listed in Order are applied first, one after another, the others may depend on them.
+	//  - The driver holds the lock for its clones, the callbacks run in sessions of their own.
+	//  - Hooks and a custom Source are called from several goroutines. Not supported with Linear.
+	Parallel int `cfg:"parallel" json:"parallel"`
+
+	// Audit is recorded with every applied file by drivers implementing AuditStore, see DefaultAudit.
+	//  - Default: empty, only the database user is recorded.
+	Audit Audit `cfg:"audit" json:"audit"`
+
+	// Retry starts a run failing with a transient error again, see Retry.
+	//  - Default: no retry.
+	Retry Retry `cfg:"-" json:"-"`
+
+	// Hooks are called around the processing of the driver.
+	Hooks Hooks `cfg:"-" json:"-"`
+
+	// Logger if set, used to log the discovered directories, the skipped files, the migration lock
+	// and the start and finish of every file with its duration, like a *slog.Logger.
+	//  - Default: nil, nothing is logged. Drivers have a Logger of their own.
+	//  - Skip decisions and the lock are logged at debug level, applied files at info level.
+	Logger Logger `cfg:"-" json:"-"`
+
+	// settled is filled with the history of the driver by Migrate, files it skips are not read.
+	// It is set on the copy of every run, runs sharing a Migrate do not share it.
+	settled *settled
+	// held is the migration path opened for the whole run by holdSource, shared by openFS.
+	held fs.FS
+}
+
+// Iter returns the migration directories with their files in the order they are applied,
+// respecting Source, Order, Skip, Extension, the version limits and tags. Nothing is executed,
+// it is the base for custom runners, reports or linters. Go migrations registered with
+// RegisterGo are included.
+// With Linear, a directory is yielded once for every run of consecutive versions it holds.
+func (m Migrate) Iter() iter.Seq2[*Muzo, error] {
+	if m.Linear {
+		return m.linear()
+	}
+
+	return m.dirs()
 }
 
+// dirs returns every directory once with its files selected by the version range and tags,
+// regardless of Linear. The Metadata of the files is filled.
+func (m Migrate) dirs() iter.Seq2[*Muzo, error] {
+	return m.withLockfile(m.withHeaders(m.withVersions(m.all())))
+}
+
+// all returns every directory once with all of its files.
+func (m Migrate) all() iter.Seq2[*Muzo, error] {
+	return m.withAutoNoTransaction(m.withVars(m.withGo(m.withSignature(m.listed()))))
+}
+
+// Migrations is the same as Iter.
+//
+// Deprecated: use Iter.
 func (m Migrate) Migrations() iter.Seq2[*Muzo, error] {
-	return m.iterMigrationInfo()
+	return m.Iter()
 }
 
-func (m Migrate) Migrate(ctx context.Context, driver Driver) (err error) {
-	if err := driver.Start(ctx); err != nil {
+func (m *Migrate) source() Source {
+	if m.Source != nil {
+		return m.Source
+	}
+
+	return fileSource{m: m}
+}
+
+// listed returns the directories of the source, encrypted files are read decrypted.
+func (m *Migrate) listed() iter.Seq2[*Muzo, error] {
+	return m.withDecryptor(m.source().List())
+}
+
+// Migrate applies all pending migrations and reports what happened.
+// On failure the result is returned together with the error, showing the failed file.
+// With a driver implementing StatusReporter, the headers of applied files are not read.
+func (m Migrate) Migrate(ctx context.Context, driver Driver) (*Result, error) {
+	m.settled = &settled{}
+
+	closeSource := m.holdSource()
+	defer closeSource()
+
+	return m.process(ctx, driver, m.Iter())
+}
+
+// MigrateTo brings a single directory to version: pending files up to and including
+// version are applied, and when the directory is past version, the newer migrations
+// are rolled back if the driver implements Rollbacker. Other directories are not touched.
+func (m Migrate) MigrateTo(ctx context.Context, driver Driver, dir string, version int64) (*Result, error) {
+	dir = cleanDir(dir)
+
+	found := false
+	for info, err := range m.dirs() {
+		if err != nil {
+			return nil, err
+		}
+
+		if info.Dir == dir {
+			found = slices.ContainsFunc(info.Files, func(f FileInfo) bool { return f.Version == version })
+			break
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("migrate to: no migration with version %d in %s", version, dir)
+	}
+
+	if reporter, ok := driver.(StatusReporter); ok {
+		records, err := reporter.History(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if slices.ContainsFunc(records, func(r Record) bool { return r.Directory == dir && r.Version > version }) {
+			return m.rollback(ctx, driver, func(records []Record, _ map[string]int) []Record {
+				records = slices.DeleteFunc(records, func(r Record) bool { return r.Directory != dir || r.Version <= version })
+				slices.SortFunc(records, func(a, b Record) int { return cmp.Compare(b.Version, a.Version) })
+
+				return records
+			})
+		}
+	}
+
+	closeSource := m.holdSource()
+	defer closeSource()
+
+	return m.process(ctx, driver, func(yield func(*Muzo, error) bool) {
+		for info, err := range m.dirs() {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			if info.Dir != dir {
+				continue
+			}
+
+			files := slices.DeleteFunc(slices.Clone(info.Files), func(f FileInfo) bool { return f.Version > version })
+			yield(info.withFiles(files), nil)
+
+			return
+		}
+	})
+}
+
+// Up applies at most n pending migrations across the ordered directories and stops.
+// The driver must implement StatusReporter to know which files are pending.
+func (m Migrate) Up(ctx context.Context, driver Driver, n int) (*Result, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("up: invalid number of migrations %d", n)
+	}
+
+	plan, err := m.Plan(ctx, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		dir     string
+		version int64
+	}
+
+	selected := make(map[key]bool, n)
+	for _, p := range plan[:min(n, len(plan))] {
+		selected[key{p.Dir, p.Version}] = true
+	}
+
+	closeSource := m.holdSource()
+	defer closeSource()
+
+	return m.process(ctx, driver, func(yield func(*Muzo, error) bool) {
+		for info, err := range m.Iter() {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			files := slices.DeleteFunc(slices.Clone(info.Files), func(f FileInfo) bool {
+				return !selected[key{info.Dir, f.Version}]
+			})
+			if len(files) == 0 {
+				continue
+			}
+
+			if !yield(info.withFiles(files), nil) {
+				return
+			}
+		}
+	})
+}
+
+// process runs every directory of dirs through the driver between Start and End.
+// End receives the error of the run, so drivers roll back failed migrations.
+//
+// Drivers implementing StatusReporter get one file per Process call, which gives
+// per file outcomes and durations in the result.
+// The failed file is then recorded by drivers implementing FailureStore and DirtyStore,
+// runs fail with ErrDirty until Force or Repair resolves it.
+func (m Migrate) process(ctx context.Context, driver Driver, dirs iter.Seq2[*Muzo, error]) (result *Result, err error) {
+	start := time.Now()
+	result = &Result{Files: []FileResult{}, Dirs: []string{}}
+
+	defer func() {
+		result.Duration = time.Since(start)
+
+		if err != nil && m.Hooks.OnError != nil {
+			m.Hooks.OnError(ctx, err)
+		}
+
+		m.emit(ctx, RunFinished{Result: result, Err: err})
+	}()
+
+	if m.Strict {
+		m = m.strictPolicies(driver)
+
+		if err := m.checkStrict(); err != nil {
+			return result, err
+		}
+	}
+
+	if !m.OutOfOrder.valid() {
+		return result, fmt.Errorf("unknown out of order policy %q", m.OutOfOrder)
+	}
+
+	if !m.Checksum.valid() {
+		return result, fmt.Errorf("unknown checksum policy %q", m.Checksum)
+	}
+
+	if !m.Match.valid() {
+		return result, fmt.Errorf("unknown match %q", m.Match)
+	}
+
+	for dir, o := range m.DirConfig {
+		if !o.TxMode.valid() {
+			return result, fmt.Errorf("unknown transaction mode %q of directory %s", o.TxMode, dir)
+		}
+	}
+
+	callbacks, err := m.callbacks()
+	if err != nil {
+		return result, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		result.Files, result.Dirs = []FileResult{}, []string{}
+
+		if m.Parallel > 1 {
+			err = m.processParallel(ctx, driver, dirs, result, callbacks)
+		} else {
+			err = m.processSession(ctx, driver, dirs, result, callbacks)
+		}
+		if err == nil {
+			return result, nil
+		}
+
+		if !m.Retry.retryable(ctx, attempt, err) {
+			break
+		}
+
+		// Every attempt is kept, the directory is only marked dirty when the last one fails.
+		if recordErr := recordFailures(ctx, driver, result); recordErr != nil {
+			return result, errors.Join(err, recordErr)
+		}
+
+		m.log().Warn("retrying migration", "attempt", attempt+1, "error", err)
+
+		if m.Retry.wait(ctx, attempt+1, err) != nil {
+			break
+		}
+	}
+
+	return result, errors.Join(err, runCallback(ctx, driver, callbacks, CallbackAfterError),
+		recordFailures(ctx, driver, result), markDirty(ctx, driver, result))
+}
+
+// processSession applies dirs in a single session of driver, between the migrate callbacks.
+func (m Migrate) processSession(ctx context.Context, driver Driver, dirs iter.Seq2[*Muzo, error], result *Result, callbacks map[string][]byte) error {
+	return session(ctx, driver, m.log(), func() error {
+		if err := checkDirty(ctx, driver); err != nil {
+			return err
+		}
+
+		if err := runCallback(ctx, driver, callbacks, CallbackBeforeMigrate); err != nil {
+			return err
+		}
+
+		if err := m.processDirs(ctx, driver, dirs, result); err != nil {
+			return err
+		}
+
+		return runCallback(ctx, driver, callbacks, CallbackAfterMigrate)
+	})
+}
+
+// processDirs applies dirs inside a driver session, filling result.
+func (m Migrate) processDirs(ctx context.Context, driver Driver, dirs iter.Seq2[*Muzo, error], result *Result) error {
+	// Read inside Start, so the applied versions are seen under the driver's transaction or lock.
+	h, err := m.history(ctx, driver)
+	if err != nil {
 		return err
 	}
 
-	defer driver.End(ctx, err)
+	// Counted only when listening and cheap, the filesystem is listed once more.
+	if h != nil && m.Hooks.OnEvent != nil && m.Source == nil {
+		if h.total, err = m.countPending(dirs, h); err != nil {
+			return err
+		}
+
+		m.emit(ctx, RunStarted{Pending: h.total})
+	}
 
-	for info, err := range m.Migrations() {
+	for info, err := range dirs {
 		if err != nil {
 			return err
 		}
 
-		if err := driver.Process(ctx, info); err != nil {
+		if err := m.processDir(ctx, driver, info, h, result); err != nil {
 			return err
 		}
 	}
 
 	return nil
 }
+
+// appliedKey is a version of a directory.
+type appliedKey struct {
+	dir     string
+	version int64
+}
+
+// appliedState is the applied state of a session, nil for drivers without StatusReporter.
+type appliedState struct {
+	// latest is the highest applied version of every version stream.
+	latest map[string]int64
+	// records are the records of the applied files.
+	records *appliedRecords
+	// total is the number of pending files of the session, zero when not counted.
+	total int
+	// done is the number of files applied in the session.
+	done int
+}
+
+// progress returns the Progress of the session after a file, zero when the total is not counted.
+func (h *appliedState) progress() Progress {
+	if h == nil || h.total == 0 {
+		return Progress{}
+	}
+
+	return Progress{Done: h.done, Total: h.total}
+}
+
+// countPending returns the number of files of dirs the session applies, with the applied state h.
+func (m Migrate) countPending(dirs iter.Seq2[*Muzo, error], h *appliedState) (int, error) {
+	n := 0
+	for info, err := range dirs {
+		if err != nil {
+			return 0, err
+		}
+
+		// Seeds are tracked apart from the migrations.
+		if m.seed(info.Dir) {
+			continue
+		}
+
+		for _, file := range info.Files {
+			_, ok, err := h.records.lookup(info, file)
+			if err != nil {
+				return 0, err
+			}
+
+			if ok {
+				continue
+			}
+
+			// Files of a stream run in version order, only the ones below the initial latest version are out of order.
+			if file.Version <= h.latest[m.stream(info.Dir)] && m.OutOfOrder != OutOfOrderApply {
+				continue
+			}
+
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// history reads the applied versions of driver.
+func (m Migrate) history(ctx context.Context, driver Driver) (*appliedState, error) {
+	reporter, ok := driver.(StatusReporter)
+	if !ok {
+		switch {
+		case m.OutOfOrder != OutOfOrderIgnore:
+			return nil, fmt.Errorf("out of order policy %q: %w", m.OutOfOrder, ErrNotSupported)
+		case m.Checksum != ChecksumIgnore:
+			return nil, fmt.Errorf("checksum policy %q: %w", m.Checksum, ErrNotSupported)
+		case m.Match != MatchVersion:
+			return nil, fmt.Errorf("match %q: %w", m.Match, ErrNotSupported)
+		}
+
+		return nil, nil
+	}
+
+	records, err := reporter.History(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &appliedState{latest: make(map[string]int64), records: newAppliedRecords(m.Match, records)}
+	for _, r := range records {
+		h.latest[m.stream(r.Directory)] = max(h.latest[m.stream(r.Directory)], r.Version)
+	}
+
+	m.settled.set(m, h)
+
+	return h, nil
+}
+
+// processDir applies the pending files of info with the applied state h, filling result.
+func (m Migrate) processDir(ctx context.Context, driver Driver, info *Muzo, h *appliedState, result *Result) error {
+	m.log().Debug("found migration directory", "directory", info.Dir, "files", len(info.Files))
+	m.emit(ctx, DirStarted{Dir: info.Dir, Files: len(info.Files)})
+
+	dirStart := time.Now()
+	files := len(result.Files)
+
+	err := m.processFiles(ctx, driver, info, h, result)
+
+	applied := (&Result{Files: result.Files[files:]}).Applied()
+	m.emit(ctx, DirFinished{Dir: info.Dir, Applied: applied, Duration: time.Since(dirStart), Err: err})
+
+	return err
+}
+
+// processFiles applies the files of info between DirStarted and DirFinished, see processDir.
+func (m Migrate) processFiles(ctx context.Context, driver Driver, info *Muzo, h *appliedState, result *Result) error {
+	if m.Hooks.BeforeDir != nil && len(info.Files) > 0 {
+		if err := m.Hooks.BeforeDir(ctx, info); err != nil {
+			return err
+		}
+	}
+
+	if m.seed(info.Dir) {
+		return m.processSeeds(ctx, driver, info, h, result)
+	}
+
+	if h == nil {
+		dirStart := time.Now()
+		if err := m.withFileTimeout(ctx, func(ctx context.Context) error { return driver.Process(ctx, info) }); err != nil {
+			m.log().Error("migration directory failed", "directory", info.Dir, "duration", time.Since(dirStart), "error", err)
+			return err
+		}
+
+		m.log().Info("processed migration directory", "directory", info.Dir, "files", len(info.Files), "duration", time.Since(dirStart))
+
+		result.touch(info.Dir)
+
+		return nil
+	}
+
+	for _, file := range info.Files {
+		fr := FileResult{
+			Dir:     info.Dir,
+			File:    file.Path,
+			Version: file.Version,
+			Outcome: OutcomeSkipped,
+		}
+
+		recorded, isApplied, err := h.records.lookup(info, file)
+		if err != nil {
+			return err
+		}
+
+		if isApplied {
+			if err := m.verifyChecksum(info, file, recorded.Checksum, &fr); err != nil {
+				return err
+			}
+
+			result.add(fr)
+
+			if fr.Outcome == OutcomeModified {
+				m.log().Warn("applied migration modified", "directory", info.Dir, "file", file.Path, "version", file.Version)
+			} else {
+				m.log().Debug("skipping applied migration", "directory", info.Dir, "file", file.Path, "version", file.Version)
+			}
+
+			m.emit(ctx, FileSkipped{FileResult: fr, Reason: SkipApplied})
+
+			if fr.Outcome == OutcomeModified && m.Hooks.AfterFile != nil {
+				m.Hooks.AfterFile(ctx, fr)
+			}
+
+			continue
+		}
+
+		if err := h.records.conflict(info.Dir, file); err != nil {
+			return err
+		}
+
+		outOfOrder := file.Version <= h.latest[m.stream(info.Dir)]
+		if outOfOrder && m.OutOfOrder == OutOfOrderIgnore {
+			m.log().Debug("skipping out of order migration", "directory", info.Dir, "file", file.Path, "version", file.Version)
+			m.emit(ctx, FileSkipped{FileResult: fr, Reason: SkipOutOfOrder})
+			result.add(fr)
+			continue
+		}
+
+		if outOfOrder {
+			switch m.OutOfOrder {
+			case OutOfOrderError:
+				return fmt.Errorf("%w: %s version %d is below the applied version %d", ErrOutOfOrder, path.Join(info.Dir, file.Path), file.Version, h.latest[m.stream(info.Dir)])
+			case OutOfOrderWarn:
+				m.log().Warn("out of order migration not applied", "directory", info.Dir, "file", file.Path, "version", file.Version)
+				fr.Outcome = OutcomeOutOfOrder
+				result.add(fr)
+				m.emit(ctx, FileSkipped{FileResult: fr, Reason: SkipOutOfOrder})
+
+				if m.Hooks.AfterFile != nil {
+					m.Hooks.AfterFile(ctx, fr)
+				}
+
+				continue
+			}
+		}
+
+		if m.Hooks.BeforeFile != nil {
+			if err := m.Hooks.BeforeFile(ctx, info.Dir, file); err != nil {
+				return err
+			}
+		}
+
+		m.log().Debug("running migration", "directory", info.Dir, "file", file.Path, "version", file.Version)
+
+		fileStart := time.Now()
+		err = m.withFileTimeout(ctx, func(ctx context.Context) error {
+			if outOfOrder {
+				return applyOutOfOrder(ctx, driver, info, file)
+			}
+
+			return driver.Process(ctx, info.withFiles([]FileInfo{file}))
+		})
+		fr.Duration = time.Since(fileStart)
+
+		if err == nil {
+			err = storeChecksum(ctx, driver, info, file)
+		}
+
+		if err == nil {
+			err = storeAudit(ctx, driver, info.Dir, file, m.Audit)
+		}
+
+		fr.Outcome = OutcomeApplied
+		if err != nil {
+			fr.Outcome = OutcomeFailed
+			fr.Error = err.Error()
+
+			m.log().Error("migration failed", "directory", info.Dir, "file", file.Path, "version", file.Version, "duration", fr.Duration, "error", err)
+		} else {
+			m.log().Info("applied migration", "directory", info.Dir, "file", file.Path, "version", file.Version, "duration", fr.Duration)
+		}
+
+		result.add(fr)
+
+		if err != nil {
+			m.emit(ctx, FileFailed{FileResult: fr, Progress: h.progress(), Err: err})
+		} else {
+			h.done++
+			m.emit(ctx, FileApplied{FileResult: fr, Progress: h.progress()})
+		}
+
+		if m.Hooks.AfterFile != nil {
+			m.Hooks.AfterFile(ctx, fr)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		result.touch(info.Dir)
+
+		h.latest[m.stream(info.Dir)] = max(h.latest[m.stream(info.Dir)], file.Version)
+	}
+
+	return nil
+}
+
+// session runs fn between Start and End of the driver, End receives the error of fn.
+// Drivers implementing Locker are locked around the whole session, the lock is logged to logger.
+func session(ctx context.Context, driver Driver, logger Logger, fn func() error) error {
+	return locked(ctx, driver, logger, func() error { return started(ctx, driver, fn) })
+}
+
+// locked runs fn with the lock of drivers implementing Locker, the lock is logged to logger.
+func locked(ctx context.Context, driver Driver, logger Logger, fn func() error) (err error) {
+	if l, ok := driver.(Locker); ok {
+		lockStart := time.Now()
+		if err := l.Lock(ctx); err != nil {
+			return fmt.Errorf("acquiring migration lock: %w", err)
+		}
+
+		logger.Debug("acquired migration lock", "wait", time.Since(lockStart))
+
+		defer func() {
+			if unlockErr := l.Unlock(ctx); unlockErr != nil && err == nil {
+				err = fmt.Errorf("releasing migration lock: %w", unlockErr)
+			}
+
+			logger.Debug("released migration lock")
+		}()
+	}
+
+	return fn()
+}
+
+// started runs fn between Start and End of the driver, End receives the error of fn.
+func started(ctx context.Context, driver Driver, fn func() error) (err error) {
+	if err := driver.Start(ctx); err != nil {
+		return err
+	}
+
+	defer func() {
+		if endErr := driver.End(ctx, err); endErr != nil && err == nil {
+			err = endErr
+		}
+	}()
+
+	return fn()
+}
+
+// cleanDir normalizes a directory name given by the user, like "/schema", to the form of Muzo.Dir.
+func cleanDir(dir string) string {
+	dir = strings.Trim(path.Clean("/"+filepath.ToSlash(dir)), "/")
+	if dir == "" {
+		return "."
+	}
+
+	return dir
+}
@@ -1,113 +1,309 @@
 package muz
 
 import (
-	"database/sql"
-	"embed"
-	"fmt"
-	"log/slog"
-	"net"
-	"os"
+	"context"
+	"errors"
+	"slices"
+	"sync"
 	"testing"
-
-	_ "github.com/jackc/pgx/v5/stdlib"
-	"github.com/testcontainers/testcontainers-go"
-	"github.com/testcontainers/testcontainers-go/wait"
 )
 
-//go:embed testdata
-var testMigrationsFS embed.FS
+func TestMigrateTo(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql":  "CREATE TABLE users();",
+			"migrations/schema/002_orders.sql": "CREATE TABLE orders();",
+			"migrations/schema/003_items.sql":  "CREATE TABLE items();",
+			"migrations/data/001_seed.sql":     "INSERT INTO users DEFAULT VALUES;",
+		}),
+	}
+
+	driver := &recordDriver{}
+	if _, err := m.MigrateTo(context.Background(), driver, "schema", 2); err != nil {
+		t.Fatalf("MigrateTo() error = %v", err)
+	}
 
-var DefaultPostgresImage = "postgres:15-alpine"
+	if got := driver.versions("schema"); !slices.Equal(got, []int64{1, 2}) {
+		t.Errorf("MigrateTo() schema versions = %v, want [1 2]", got)
+	}
 
-type testDB struct {
-	db *sql.DB
+	if got := driver.versions("data"); len(got) != 0 {
+		t.Errorf("MigrateTo() data versions = %v, want none", got)
+	}
+
+	if _, err := m.MigrateTo(context.Background(), driver, "schema", 7); err == nil {
+		t.Errorf("MigrateTo() unknown version expected error")
+	}
+
+	if _, err := m.MigrateTo(context.Background(), driver, "schema", 1); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("MigrateTo() rollback error = %v, want ErrNotSupported", err)
+	}
 }
 
-func (tt *testDB) Close() {
-	tt.db.Close()
+func TestMigrateToRollback(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql":       "CREATE TABLE users();",
+			"migrations/schema/002_orders.sql":      "CREATE TABLE orders();",
+			"migrations/schema/002_orders.down.sql": "DROP TABLE orders;",
+			"migrations/schema/003_items.sql":       "CREATE TABLE items();",
+			"migrations/schema/003_items.down.sql":  "DROP TABLE items;",
+		}),
+	}
+
+	driver := &rollbackDriver{recordDriver: recordDriver{records: []Record{
+		{Version: 1, Directory: "schema", FileName: "001_users.sql"},
+		{Version: 2, Directory: "schema", FileName: "002_orders.sql"},
+		{Version: 3, Directory: "schema", FileName: "003_items.sql"},
+	}}}
+
+	if _, err := m.MigrateTo(context.Background(), driver, "schema", 1); err != nil {
+		t.Fatalf("MigrateTo() error = %v", err)
+	}
+
+	want := []string{
+		"schema/003_items.down.sql: DROP TABLE items;",
+		"schema/002_orders.down.sql: DROP TABLE orders;",
+	}
+
+	if !slices.Equal(driver.executed, want) {
+		t.Errorf("MigrateTo() executed = %q, want %q", driver.executed, want)
+	}
 }
 
-func NewTestPostgresDB(t *testing.T) *testDB {
-	t.Helper()
+type lockDriver struct {
+	recordDriver
+
+	calls []string
+}
 
-	image := DefaultPostgresImage
-	if v := os.Getenv("TEST_IMAGE_POSTGRES"); v != "" {
-		image = v
+func (d *lockDriver) Lock(context.Context) error {
+	d.calls = append(d.calls, "lock")
+	return nil
+}
+
+func (d *lockDriver) Unlock(context.Context) error {
+	d.calls = append(d.calls, "unlock")
+	return nil
+}
+
+func (d *lockDriver) Start(context.Context) error {
+	d.calls = append(d.calls, "start")
+	return nil
+}
+
+func (d *lockDriver) End(context.Context, error) error {
+	d.calls = append(d.calls, "end")
+	return nil
+}
+
+func TestMigrateLocker(t *testing.T) {
+	m := Migrate{FS: MapFS(map[string]string{"migrations/001_users.sql": "CREATE TABLE users();"})}
+
+	driver := &lockDriver{}
+	if _, err := m.Migrate(context.Background(), driver); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
 	}
 
-	container, err := testcontainers.GenericContainer(t.Context(), testcontainers.GenericContainerRequest{
-		ContainerRequest: testcontainers.ContainerRequest{
-			Image:        image,
-			ExposedPorts: []string{"5432/tcp"},
-			Env: map[string]string{
-				"POSTGRES_HOST_AUTH_METHOD": "trust",
-			},
-			WaitingFor: wait.ForLog("database system is ready to accept connections").WithOccurrence(2),
-		},
-		Started: true,
-	})
+	if want := []string{"lock", "start", "end", "unlock"}; !slices.Equal(driver.calls, want) {
+		t.Errorf("driver calls = %v, want %v", driver.calls, want)
+	}
+}
 
-	if container == nil {
-		t.Fatalf("could not create postgres container: %v", err)
+func TestMigrateUp(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql":  "CREATE TABLE users();",
+			"migrations/schema/002_orders.sql": "CREATE TABLE orders();",
+			"migrations/data/001_seed.sql":     "INSERT INTO users DEFAULT VALUES;",
+		}),
+		Order: []string{"schema"},
 	}
 
-	port, err := container.MappedPort(t.Context(), "5432")
+	driver := &recordDriver{records: []Record{{Version: 1, Directory: "schema", FileName: "001_users.sql"}}}
+
+	result, err := m.Up(context.Background(), driver, 1)
 	if err != nil {
-		t.Fatalf("could not get mapped port: %v", err)
+		t.Fatalf("Up() error = %v", err)
 	}
 
-	host, err := container.Host(t.Context())
-	if err != nil {
-		t.Fatalf("could not get host: %v", err)
+	if result.Applied() != 1 || !slices.Equal(result.Dirs, []string{"schema"}) {
+		t.Errorf("Up(1) result = %+v, want 1 applied in schema", result)
 	}
 
-	dsn := fmt.Sprintf("postgres://postgres@%s/postgres", net.JoinHostPort(host, port.Port()))
-	t.Log("dsn", dsn)
+	if got := driver.versions("schema"); !slices.Equal(got, []int64{1, 2}) {
+		t.Errorf("Up(1) schema versions = %v, want [1 2]", got)
+	}
 
-	db, err := sql.Open("pgx", dsn)
-	if err != nil {
-		t.Fatalf("could not connect to postgres: %v", err)
+	if got := driver.versions("data"); len(got) != 0 {
+		t.Errorf("Up(1) data versions = %v, want none", got)
 	}
 
-	if err := db.PingContext(t.Context()); err != nil {
-		t.Fatalf("could not ping postgres: %v", err)
+	if _, err := m.Up(context.Background(), driver, 5); err != nil {
+		t.Fatalf("Up() error = %v", err)
 	}
 
-	return &testDB{db: db}
+	if got := driver.versions("data"); !slices.Equal(got, []int64{1}) {
+		t.Errorf("Up(5) data versions = %v, want [1]", got)
+	}
+
+	if _, err := m.Up(context.Background(), driver, 0); err == nil {
+		t.Errorf("Up(0) expected error")
+	}
 }
 
-func TestMuz(t *testing.T) {
-	tt := NewTestPostgresDB(t)
-	defer tt.Close()
+type failDriver struct {
+	recordDriver
 
-	tt.TestMuz(t)
+	fail string
 }
 
-func (tt *testDB) TestMuz(t *testing.T) {
+func (d *failDriver) Process(ctx context.Context, data *Muzo) error {
+	if data.Files[0].Path == d.fail {
+		return errors.New("syntax error")
+	}
+
+	return d.recordDriver.Process(ctx, data)
+}
+
+func TestMigrateResult(t *testing.T) {
 	m := Migrate{
-		Path: "testdata",
-		FS:   testMigrationsFS,
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql":  "CREATE TABLE users();",
+			"migrations/schema/002_orders.sql": "CREATE TABLE orders();",
+			"migrations/schema/003_items.sql":  "CREATE TABLE items();",
+		}),
 	}
 
-	driver := &PostgresDriver{
-		DB:     tt.db,
-		Table:  "muz_migrations",
-		Logger: slog.Default(),
+	driver := &failDriver{
+		recordDriver: recordDriver{records: []Record{{Version: 1, Directory: "schema", FileName: "001_users.sql"}}},
+		fail:         "003_items.sql",
 	}
 
-	if err := m.Migrate(t.Context(), driver); err != nil {
-		t.Fatalf("Migrate() error: %v", err)
+	result, err := m.Migrate(context.Background(), driver)
+	if err == nil {
+		t.Fatal("Migrate() expected error")
 	}
 
-	// Verify that migrations were applied
-	var count int
-	err := tt.db.QueryRowContext(t.Context(), "SELECT COUNT(*) FROM muz_migrations").Scan(&count)
-	if err != nil {
-		t.Fatalf("could not query migrations table: %v", err)
+	var got []Outcome
+	for _, f := range result.Files {
+		got = append(got, f.Outcome)
+	}
+
+	if want := []Outcome{OutcomeSkipped, OutcomeApplied, OutcomeFailed}; !slices.Equal(got, want) {
+		t.Errorf("Migrate() outcomes = %v, want %v", got, want)
+	}
+
+	if result.Files[2].Error != "syntax error" {
+		t.Errorf("Migrate() failed file error = %q", result.Files[2].Error)
+	}
+
+	if driver.endErr == nil {
+		t.Errorf("Migrate() End did not receive the error")
+	}
+}
+
+func TestMigrateHooks(t *testing.T) {
+	var calls []string
+
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql":  "CREATE TABLE users();",
+			"migrations/schema/002_orders.sql": "CREATE TABLE orders();",
+			"migrations/schema/003_items.sql":  "CREATE TABLE items();",
+		}),
+		Hooks: Hooks{
+			BeforeDir: func(_ context.Context, dir *Muzo) error {
+				calls = append(calls, "dir "+dir.Dir)
+				return nil
+			},
+			BeforeFile: func(_ context.Context, _ string, file FileInfo) error {
+				calls = append(calls, "before "+file.Path)
+				if file.Version == 3 {
+					return errors.New("guard")
+				}
+				return nil
+			},
+			AfterFile: func(_ context.Context, r FileResult) {
+				calls = append(calls, "after "+r.File+" "+string(r.Outcome))
+			},
+			OnError: func(_ context.Context, err error) {
+				calls = append(calls, "error "+err.Error())
+			},
+		},
+	}
+
+	driver := &recordDriver{records: []Record{{Version: 1, Directory: "schema", FileName: "001_users.sql"}}}
+	if _, err := m.Migrate(context.Background(), driver); err == nil {
+		t.Fatal("Migrate() expected guard error")
+	}
+
+	want := []string{
+		"dir schema",
+		"before 002_orders.sql",
+		"after 002_orders.sql applied",
+		"before 003_items.sql",
+		"error guard",
+	}
+
+	if !slices.Equal(calls, want) {
+		t.Errorf("hook calls = %q, want %q", calls, want)
+	}
+}
+
+// TestMigrateConcurrent shares one Migrate between runs against several drivers, run it with -race.
+func TestMigrateConcurrent(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/app/001_users.sql":  "CREATE TABLE ${table}();",
+			"migrations/app/002_posts.sql":  "-- muz:tags seed\nCREATE TABLE posts();",
+			"migrations/data/001_seed.sql":  "INSERT INTO users DEFAULT VALUES;",
+			"migrations/data/002_admin.sql": "INSERT INTO users DEFAULT VALUES;",
+		}),
+		Order:    []string{"data", "app"},
+		Vars:     map[string]string{"table": "users"},
+		Tags:     []string{"seed"},
+		Checksum: ChecksumWarn,
+		Match:    MatchChecksum,
+	}
+
+	drivers := make([]*recordDriver, 8)
+	errs := make([]error, len(drivers))
+
+	var wg sync.WaitGroup
+	for i := range drivers {
+		drivers[i] = &recordDriver{records: []Record{{Version: 1, Directory: "data", FileName: "001_seed.sql"}}}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := m.Migrate(context.Background(), drivers[i]); err != nil {
+				errs[i] = err
+				return
+			}
+
+			_, errs[i] = m.Status(context.Background(), drivers[i])
+		}()
+	}
+
+	wg.Wait()
+
+	for i, d := range drivers {
+		if errs[i] != nil {
+			t.Fatalf("driver %d: %v", i, errs[i])
+		}
+
+		if got := d.versions("app"); !slices.Equal(got, []int64{1, 2}) {
+			t.Errorf("driver %d: app versions = %v, want [1 2]", i, got)
+		}
+
+		if got := d.versions("data"); !slices.Equal(got, []int64{1, 2}) {
+			t.Errorf("driver %d: data versions = %v, want [1 2]", i, got)
+		}
 	}
 
-	expectedMigrations := 4 // Total number of migration files in testdata
-	if count != expectedMigrations {
-		t.Fatalf("expected %d migrations applied, got %d", expectedMigrations, count)
+	if m.settled != nil {
+		t.Errorf("Migrate changed the shared value")
 	}
 }
@@ -0,0 +1,125 @@
+package muztest
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var (
+	// PostgresImage is the image of NewPostgres, the TEST_IMAGE_POSTGRES environment variable overrides it.
+	PostgresImage = "postgres:15-alpine"
+	// MySQLImage is the image of NewMySQL, the TEST_IMAGE_MYSQL environment variable overrides it.
+	MySQLImage = "mysql:8.4"
+)
+
+// Database is a disposable database running in a container.
+type Database struct {
+	// DB is connected with the "pgx" driver for Postgres and the "mysql" driver for MySQL.
+	DB *sql.DB
+	// DSN is the connection string of DB, without scheme for MySQL.
+	DSN string
+}
+
+// NewPostgres starts a Postgres container and connects to its postgres database as the postgres superuser.
+// The connection is closed and the container removed when the test ends. It needs a Docker daemon.
+//
+//	db := muztest.NewPostgres(t)
+//
+//	_, err := muz.Migrate{FS: migrationsFS}.Migrate(t.Context(), &muz.PostgresDriver{DB: db.DB})
+func NewPostgres(t testing.TB) *Database {
+	t.Helper()
+
+	hostPort := startContainer(t, testcontainers.ContainerRequest{
+		Image:        image(PostgresImage, "TEST_IMAGE_POSTGRES"),
+		ExposedPorts: []string{"5432/tcp"},
+		Env: map[string]string{
+			"POSTGRES_HOST_AUTH_METHOD": "trust",
+		},
+		// The entrypoint restarts the server once after initializing the data directory.
+		WaitingFor: wait.ForLog("database system is ready to accept connections").WithOccurrence(2),
+	})
+
+	return connect(t, "pgx", fmt.Sprintf("postgres://postgres@%s/postgres", hostPort))
+}
+
+// NewMySQL starts a MySQL container and connects to its muz database as root, multiStatements and parseTime are enabled.
+// The connection is closed and the container removed when the test ends. It needs a Docker daemon.
+//
+//	db := muztest.NewMySQL(t)
+//
+//	_, err := muz.Migrate{FS: migrationsFS}.Migrate(t.Context(), &muz.GenericSQLDriver{DB: db.DB, Dialect: muz.MySQLDialect{}})
+func NewMySQL(t testing.TB) *Database {
+	t.Helper()
+
+	hostPort := startContainer(t, testcontainers.ContainerRequest{
+		Image:        image(MySQLImage, "TEST_IMAGE_MYSQL"),
+		ExposedPorts: []string{"3306/tcp"},
+		Env: map[string]string{
+			"MYSQL_ALLOW_EMPTY_PASSWORD": "yes",
+			"MYSQL_DATABASE":             "muz",
+		},
+		// The entrypoint runs a temporary server without networking first, on port 0.
+		WaitingFor: wait.ForLog(`ready for connections.*port: 3306`).AsRegexp().WithStartupTimeout(2 * time.Minute),
+	})
+
+	return connect(t, "mysql", fmt.Sprintf("root@tcp(%s)/muz?multiStatements=true&parseTime=true", hostPort))
+}
+
+// image returns the value of the environment variable env, or def when it is empty.
+func image(def, env string) string {
+	if v := os.Getenv(env); v != "" {
+		return v
+	}
+
+	return def
+}
+
+// startContainer starts req, removed at the end of the test, and returns the host:port of its exposed port.
+func startContainer(t testing.TB, req testcontainers.ContainerRequest) string {
+	t.Helper()
+
+	container, err := testcontainers.GenericContainer(t.Context(), testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	testcontainers.CleanupContainer(t, container)
+
+	if err != nil {
+		t.Fatalf("could not start %s container: %v", req.Image, err)
+	}
+
+	hostPort, err := container.Endpoint(t.Context(), "")
+	if err != nil {
+		t.Fatalf("could not get mapped port: %v", err)
+	}
+
+	return hostPort
+}
+
+// connect opens and pings dsn, the connection is closed at the end of the test.
+func connect(t testing.TB, driver, dsn string) *Database {
+	t.Helper()
+
+	t.Log("dsn", dsn)
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		t.Fatalf("could not connect to %s: %v", driver, err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.PingContext(t.Context()); err != nil {
+		t.Fatalf("could not ping %s: %v", driver, err)
+	}
+
+	return &Database{DB: db, DSN: dsn}
+}
@@ -0,0 +1,242 @@
+// Package muztest provides helpers to test applications using muz without a database.
+package muztest
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/rakunlabs/muz"
+)
+
+// Call is a method call received by RecordingDriver.
+type Call struct {
+	// Method is the name of the called method, like "Start", "Process", "End" or "Rollback".
+	Method string
+	// Dir is the directory given to Process and Rollback.
+	Dir string
+	// Files are the files given to Process and Rollback.
+	Files []muz.FileInfo
+	// Err is the error given to End.
+	Err error
+}
+
+// Applied is a migration file executed by RecordingDriver.
+type Applied struct {
+	Dir     string
+	Version int64
+	Path    string
+	// Content is the file as the database would receive it, placeholders replaced.
+	// Nil for Go migrations, they are recorded without running.
+	Content []byte
+}
+
+// RecordingDriver is a muz.Driver keeping everything in memory, it captures the calls of a run
+// and the files it applies. It behaves like the SQL drivers: only files above the latest applied
+// version of a directory are applied, and the records of a session are dropped when End gets an error.
+// It implements muz.StatusReporter, muz.Recorder and muz.Rollbacker.
+//
+//	driver := &muztest.RecordingDriver{
+//		Fail: muztest.FailOn("schema", 2, errors.New("syntax error")),
+//	}
+//
+//	_, err := muz.Migrate{FS: migrationsFS}.Migrate(ctx, driver)
+type RecordingDriver struct {
+	// Records are the tracking records, set them to start from applied migrations.
+	Records []muz.Record
+	// Fail if set, is called before a file is applied, a returned error fails Process or Rollback
+	// without applying the file.
+	Fail func(dir string, file muz.FileInfo) error
+	// StartErr if set, is returned by Start.
+	StartErr error
+	// EndErr if set, is returned by End.
+	EndErr error
+
+	// Calls are the method calls in order.
+	Calls []Call
+	// Applied are the files executed by Process, failed runs included.
+	Applied []Applied
+	// RolledBack are the rollback files executed by Rollback.
+	RolledBack []Applied
+
+	mu sync.Mutex
+	// started are the records at Start, restored when End gets an error.
+	started []muz.Record
+}
+
+// FailOn returns a RecordingDriver.Fail function failing the file with version in dir with err.
+func FailOn(dir string, version int64, err error) func(string, muz.FileInfo) error {
+	return func(d string, file muz.FileInfo) error {
+		if d == dir && file.Version == version {
+			return err
+		}
+
+		return nil
+	}
+}
+
+func (d *RecordingDriver) Start(context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.Calls = append(d.Calls, Call{Method: "Start"})
+	d.started = slices.Clone(d.Records)
+
+	return d.StartErr
+}
+
+func (d *RecordingDriver) Process(_ context.Context, data *muz.Muzo) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.Calls = append(d.Calls, Call{Method: "Process", Dir: data.Dir, Files: slices.Clone(data.Files)})
+
+	latest := d.latest(data.Dir)
+
+	for _, file := range data.Files {
+		if file.Version <= latest && !data.OutOfOrder() {
+			continue // already applied
+		}
+
+		if err := d.apply(data, file, &d.Applied); err != nil {
+			return fmt.Errorf("applying migration %d - %s - %s: %w", file.Version, data.Dir, file.Path, err)
+		}
+
+		d.record(data.Dir, file)
+		latest = file.Version
+	}
+
+	return nil
+}
+
+func (d *RecordingDriver) End(_ context.Context, err error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.Calls = append(d.Calls, Call{Method: "End", Err: err})
+
+	if err != nil {
+		d.Records = d.started
+	}
+
+	d.started = nil
+
+	return d.EndErr
+}
+
+// Rollback executes the rollback files and removes their records.
+func (d *RecordingDriver) Rollback(_ context.Context, data *muz.Muzo) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.Calls = append(d.Calls, Call{Method: "Rollback", Dir: data.Dir, Files: slices.Clone(data.Files)})
+
+	for _, file := range data.Files {
+		if err := d.apply(data, file, &d.RolledBack); err != nil {
+			return fmt.Errorf("rolling back migration %d - %s - %s: %w", file.Version, data.Dir, file.Path, err)
+		}
+
+		d.unrecord(data.Dir, file.Version)
+	}
+
+	return nil
+}
+
+func (d *RecordingDriver) History(context.Context) ([]muz.Record, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	records := slices.Clone(d.Records)
+	slices.SortFunc(records, func(a, b muz.Record) int {
+		return cmp.Or(cmp.Compare(a.Directory, b.Directory), cmp.Compare(a.Version, b.Version))
+	})
+
+	return records, nil
+}
+
+func (d *RecordingDriver) Record(_ context.Context, dir string, file muz.FileInfo) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.unrecord(dir, file.Version)
+	d.record(dir, file)
+
+	return nil
+}
+
+func (d *RecordingDriver) Unrecord(_ context.Context, dir string, version int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.unrecord(dir, version)
+
+	return nil
+}
+
+// Versions returns the applied versions of dir in order.
+func (d *RecordingDriver) Versions(dir string) []int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var versions []int64
+	for _, r := range d.Records {
+		if r.Directory == dir {
+			versions = append(versions, r.Version)
+		}
+	}
+
+	slices.Sort(versions)
+
+	return versions
+}
+
+// apply checks Fail, reads the file and appends it to applied.
+func (d *RecordingDriver) apply(data *muz.Muzo, file muz.FileInfo, applied *[]Applied) error {
+	if d.Fail != nil {
+		if err := d.Fail(data.Dir, file); err != nil {
+			return err
+		}
+	}
+
+	var content []byte
+	if data.GoMigration(file.Path) == nil {
+		var err error
+
+		content, err = data.ReadFile(file.Path)
+		if err != nil {
+			return err
+		}
+	}
+
+	*applied = append(*applied, Applied{Dir: data.Dir, Version: file.Version, Path: file.Path, Content: content})
+
+	return nil
+}
+
+func (d *RecordingDriver) latest(dir string) int64 {
+	var latest int64
+	for _, r := range d.Records {
+		if r.Directory == dir {
+			latest = max(latest, r.Version)
+		}
+	}
+
+	return latest
+}
+
+func (d *RecordingDriver) record(dir string, file muz.FileInfo) {
+	d.Records = append(d.Records, muz.Record{
+		Version:     file.Version,
+		Directory:   dir,
+		FileName:    file.Path,
+		Description: file.Description,
+	})
+}
+
+func (d *RecordingDriver) unrecord(dir string, version int64) {
+	d.Records = slices.DeleteFunc(d.Records, func(r muz.Record) bool {
+		return r.Directory == dir && r.Version == version
+	})
+}
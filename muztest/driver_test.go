@@ -0,0 +1,105 @@
+package muztest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/rakunlabs/muz"
+)
+
+func testMigrate() muz.Migrate {
+	return muz.Migrate{
+		FS: muz.MapFS(map[string]string{
+			"migrations/schema/001_users.sql":       "CREATE TABLE ${PREFIX}users();",
+			"migrations/schema/001_users.down.sql":  "DROP TABLE ${PREFIX}users;",
+			"migrations/schema/002_orders.sql":      "CREATE TABLE orders();",
+			"migrations/schema/002_orders.down.sql": "DROP TABLE orders;",
+		}),
+		Vars: map[string]string{"PREFIX": "app_"},
+	}
+}
+
+func methods(calls []Call) []string {
+	var names []string
+	for _, c := range calls {
+		names = append(names, c.Method)
+	}
+
+	return names
+}
+
+func TestRecordingDriver(t *testing.T) {
+	driver := &RecordingDriver{}
+
+	if _, err := testMigrate().Migrate(context.Background(), driver); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	if got, want := methods(driver.Calls), []string{"Start", "Process", "Process", "End"}; !slices.Equal(got, want) {
+		t.Errorf("Calls = %q, want %q", got, want)
+	}
+
+	if len(driver.Applied) != 2 || string(driver.Applied[0].Content) != "CREATE TABLE app_users();" {
+		t.Errorf("Applied = %+v", driver.Applied)
+	}
+
+	if got := driver.Versions("schema"); !slices.Equal(got, []int64{1, 2}) {
+		t.Errorf("Versions() = %v, want [1 2]", got)
+	}
+
+	// applied files are not applied again
+	if _, err := testMigrate().Migrate(context.Background(), driver); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	if len(driver.Applied) != 2 {
+		t.Errorf("Applied = %+v, want 2 files", driver.Applied)
+	}
+
+	if _, err := testMigrate().Down(context.Background(), driver, 1); err != nil {
+		t.Fatalf("Down() error = %v", err)
+	}
+
+	if len(driver.RolledBack) != 1 || string(driver.RolledBack[0].Content) != "DROP TABLE orders;" {
+		t.Errorf("RolledBack = %+v", driver.RolledBack)
+	}
+
+	if got := driver.Versions("schema"); !slices.Equal(got, []int64{1}) {
+		t.Errorf("Versions() = %v, want [1]", got)
+	}
+}
+
+func TestRecordingDriverFail(t *testing.T) {
+	errSyntax := errors.New("syntax error")
+
+	driver := &RecordingDriver{Fail: FailOn("schema", 2, errSyntax)}
+
+	_, err := testMigrate().Migrate(context.Background(), driver)
+	if !errors.Is(err, errSyntax) {
+		t.Fatalf("Migrate() error = %v, want %v", err, errSyntax)
+	}
+
+	if len(driver.Applied) != 1 || driver.Applied[0].Version != 1 {
+		t.Errorf("Applied = %+v, want version 1", driver.Applied)
+	}
+
+	// the failed session is rolled back
+	if got := driver.Versions("schema"); len(got) != 0 {
+		t.Errorf("Versions() = %v, want none", got)
+	}
+
+	if end := driver.Calls[len(driver.Calls)-1]; end.Method != "End" || !errors.Is(end.Err, errSyntax) {
+		t.Errorf("last call = %+v, want End with the error", end)
+	}
+
+	driver = &RecordingDriver{StartErr: errSyntax}
+	if _, err := testMigrate().Migrate(context.Background(), driver); !errors.Is(err, errSyntax) {
+		t.Errorf("Migrate() error = %v, want %v", err, errSyntax)
+	}
+
+	if len(driver.Applied) != 0 {
+		t.Errorf("Applied = %+v, want nothing", driver.Applied)
+	}
+}
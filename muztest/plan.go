@@ -0,0 +1,85 @@
+package muztest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rakunlabs/muz"
+)
+
+// UpdateGoldenEnv is the environment variable making AssertPlan write the golden file instead of comparing it.
+const UpdateGoldenEnv = "MUZ_UPDATE_GOLDEN"
+
+// RenderPlan writes the plan in a canonical text form, one line per directory followed by its files
+// with their versions, in apply order. Out of order files are marked.
+//
+//	schema
+//	  1 001_users.sql
+//	  2 002_orders.sql
+//	seed
+//	  1 001_admin.sql (out of order)
+func RenderPlan(plan []muz.PlannedMigration) []byte {
+	var buf bytes.Buffer
+
+	for i, p := range plan {
+		if i == 0 || plan[i-1].Dir != p.Dir {
+			fmt.Fprintf(&buf, "%s\n", p.Dir)
+		}
+
+		fmt.Fprintf(&buf, "  %d %s", p.Version, p.File)
+		if p.Reason == muz.ReasonOutOfOrder {
+			buf.WriteString(" (out of order)")
+		}
+
+		buf.WriteString("\n")
+	}
+
+	return buf.Bytes()
+}
+
+// AssertPlan fails the test when the rendered plan of m differs from the golden file, see RenderPlan.
+// A nil driver plans against an empty database, every file is pending. Set a RecordingDriver with the
+// records of the main branch to assert the migrations a change adds.
+// With MUZ_UPDATE_GOLDEN=1 the golden file is written instead.
+//
+//	func TestPlan(t *testing.T) {
+//		muztest.AssertPlan(t, muz.Migrate{FS: migrationsFS}, nil, "testdata/plan.golden")
+//	}
+func AssertPlan(t testing.TB, m muz.Migrate, driver muz.Driver, golden string) {
+	t.Helper()
+
+	if driver == nil {
+		driver = &RecordingDriver{}
+	}
+
+	plan, err := m.Plan(t.Context(), driver)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+
+	got := RenderPlan(plan)
+
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+			t.Fatalf("could not create golden directory: %v", err)
+		}
+
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatalf("could not write golden file: %v", err)
+		}
+
+		return
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("could not read golden file, run with %s=1 to create it: %v", UpdateGoldenEnv, err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("plan differs from %s, run with %s=1 to update it\ngot:\n%s\nwant:\n%s", golden, UpdateGoldenEnv, got, want)
+	}
+}
@@ -0,0 +1,39 @@
+package muztest
+
+import (
+	"testing"
+
+	"github.com/rakunlabs/muz"
+)
+
+func TestAssertPlan(t *testing.T) {
+	m := muz.Migrate{
+		FS: muz.MapFS(map[string]string{
+			"migrations/schema/001_users.sql":  "CREATE TABLE users();",
+			"migrations/schema/002_orders.sql": "CREATE TABLE orders();",
+			"migrations/seed/001_admin.sql":    "INSERT INTO users DEFAULT VALUES;",
+		}),
+		Order: []string{"schema", "seed"},
+	}
+
+	AssertPlan(t, m, nil, "testdata/plan.golden")
+
+	driver := &RecordingDriver{Records: []muz.Record{{Version: 1, Directory: "schema", FileName: "001_users.sql"}}}
+	AssertPlan(t, m, driver, "testdata/plan_applied.golden")
+}
+
+func TestRenderPlan(t *testing.T) {
+	plan := []muz.PlannedMigration{
+		{Dir: "schema", File: "001_users.sql", Version: 1, Reason: muz.ReasonOutOfOrder},
+		{Dir: "schema", File: "003_items.sql", Version: 3, Reason: muz.ReasonNew},
+	}
+
+	want := "schema\n  1 001_users.sql (out of order)\n  3 003_items.sql\n"
+	if got := string(RenderPlan(plan)); got != want {
+		t.Errorf("RenderPlan() = %q, want %q", got, want)
+	}
+
+	if got := RenderPlan(nil); len(got) != 0 {
+		t.Errorf("RenderPlan(nil) = %q, want empty", got)
+	}
+}
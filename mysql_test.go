@@ -0,0 +1,53 @@
+package muz_test
+
+import (
+	"testing"
+
+	"github.com/rakunlabs/muz"
+	"github.com/rakunlabs/muz/muztest"
+)
+
+// The tests of this file need a Docker daemon.
+
+func TestGenericSQLDriverMySQL(t *testing.T) {
+	tt := muztest.NewMySQL(t)
+
+	testGenericDriver(t, tt.DB, muz.MySQLDialect{})
+}
+
+func TestGenericSQLDriverMySQLWidenVersion(t *testing.T) {
+	tt := muztest.NewMySQL(t)
+
+	// Tracking table created by older versions.
+	if _, err := tt.DB.ExecContext(t.Context(), `
+		CREATE TABLE migrations (
+			version integer NOT NULL,
+			directory varchar(255) NOT NULL,
+			file_name varchar(255) NOT NULL,
+			processed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+			UNIQUE(version, directory)
+		)
+	`); err != nil {
+		t.Fatalf("could not create tracking table: %v", err)
+	}
+
+	m := muz.Migrate{FS: muz.MapFS(map[string]string{
+		"migrations/app/20240101120000_users.sql": "CREATE TABLE users (id integer PRIMARY KEY);",
+	})}
+
+	if _, err := m.Migrate(t.Context(), &muz.GenericSQLDriver{DB: tt.DB, Dialect: muz.MySQLDialect{}}); err != nil {
+		t.Fatalf("Migrate() error: %v", err)
+	}
+
+	var dataType string
+	if err := tt.DB.QueryRowContext(t.Context(), `
+		SELECT data_type FROM information_schema.columns
+		WHERE table_schema = DATABASE() AND table_name = 'migrations' AND column_name = 'version'
+	`).Scan(&dataType); err != nil {
+		t.Fatalf("could not query column type: %v", err)
+	}
+
+	if dataType != "bigint" {
+		t.Errorf("version column type = %s, want bigint", dataType)
+	}
+}
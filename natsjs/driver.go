@@ -0,0 +1,331 @@
+// Package natsjs provides a muz driver that applies JetStream stream and
+// consumer definitions from migration files.
+package natsjs
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/rakunlabs/muz"
+)
+
+// Definition is the content of a migration file, given as JSON.
+//
+//	{
+//	  "streams": [{"name": "ORDERS", "subjects": ["orders.>"]}],
+//	  "consumers": [{"stream": "ORDERS", "durable_name": "worker"}],
+//	  "delete_consumers": [{"stream": "ORDERS", "name": "old_worker"}],
+//	  "delete_streams": ["LEGACY"]
+//	}
+//
+// Deletions are applied first, then streams are created or updated,
+// then consumers are created or updated.
+type Definition struct {
+	Streams         []jetstream.StreamConfig `json:"streams"`
+	Consumers       []Consumer               `json:"consumers"`
+	DeleteStreams   []string                 `json:"delete_streams"`
+	DeleteConsumers []ConsumerRef            `json:"delete_consumers"`
+}
+
+// Consumer is a consumer configuration bound to a stream.
+type Consumer struct {
+	Stream string `json:"stream"`
+
+	jetstream.ConsumerConfig
+}
+
+// ConsumerRef points to an existing consumer of a stream.
+type ConsumerRef struct {
+	Stream string `json:"stream"`
+	Name   string `json:"name"`
+}
+
+// record is the value stored in the tracking bucket for each applied file.
+type record struct {
+	Version     int64     `json:"version"`
+	Directory   string    `json:"directory"`
+	FileName    string    `json:"file_name"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+// //////////////////////////////
+
+type Driver struct {
+	// JetStream is the JetStream instance to apply definitions to.
+	JetStream jetstream.JetStream
+	// Bucket is the name of the KV bucket used to track applied versions.
+	//  - Default: "muz_migrations"
+	Bucket string
+	// Logger if set, used to log migration progress.
+	Logger muz.Logger
+	// Now if set, is the clock of processed_at, like a fixed time for tests asserting exact records.
+	//  - Default: time.Now
+	Now func() time.Time
+
+	// kv is the tracking bucket, opened in Start.
+	kv jetstream.KeyValue
+}
+
+var (
+	_ muz.Driver         = (*Driver)(nil)
+	_ muz.StatusReporter = (*Driver)(nil)
+)
+
+func (d *Driver) bucketName() string {
+	if d.Bucket == "" {
+		return "muz_migrations"
+	}
+
+	return d.Bucket
+}
+
+// now returns the time of Now in UTC.
+func (d *Driver) now() time.Time {
+	if d.Now != nil {
+		return d.Now().UTC()
+	}
+
+	return time.Now().UTC()
+}
+
+func (d *Driver) Start(ctx context.Context) error {
+	if d.Logger != nil {
+		d.Logger.Info("starting migration", "bucket", d.bucketName())
+	}
+
+	kv, err := d.JetStream.KeyValue(ctx, d.bucketName())
+	if errors.Is(err, jetstream.ErrBucketNotFound) {
+		kv, err = d.JetStream.CreateKeyValue(ctx, jetstream.KeyValueConfig{
+			Bucket:      d.bucketName(),
+			Description: "muz applied migrations",
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("opening tracking bucket %s: %w", d.bucketName(), err)
+	}
+
+	d.kv = kv
+
+	return nil
+}
+
+func (d *Driver) Process(ctx context.Context, data *muz.Muzo) error {
+	directory := data.Dir
+
+	version, err := d.latestVersion(ctx, directory)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range data.Files {
+		if file.Version <= version && !data.OutOfOrder() {
+			continue // already applied
+		}
+
+		if data.GoMigration(file.Path) != nil {
+			return fmt.Errorf("applying migration %d - %s - %s: go migrations are not supported", file.Version, directory, file.Path)
+		}
+
+		content, err := data.ReadFile(file.Path)
+		if err != nil {
+			return err
+		}
+
+		var def Definition
+		if err := json.Unmarshal(content, &def); err != nil {
+			return fmt.Errorf("parsing migration %d - %s - %s: %w", file.Version, directory, file.Path, err)
+		}
+
+		if d.Logger != nil {
+			d.Logger.Info("applying migration", "version", file.Version, "directory", directory, "file", file.Path)
+		}
+
+		if err := d.apply(ctx, &def); err != nil {
+			return fmt.Errorf("applying migration %d - %s - %s: %w", file.Version, directory, file.Path, err)
+		}
+
+		value, err := json.Marshal(record{
+			Version:     file.Version,
+			Directory:   directory,
+			FileName:    file.Path,
+			ProcessedAt: d.now(),
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err := d.kv.Create(ctx, recordKey(directory, file.Version), value); err != nil {
+			return err
+		}
+
+		version = file.Version
+	}
+
+	return nil
+}
+
+func (d *Driver) End(_ context.Context, err error) error {
+	// JetStream has no transactions; every applied file is already recorded.
+	if err == nil && d.Logger != nil {
+		d.Logger.Info("migrations applied successfully")
+	}
+
+	return nil
+}
+
+func (d *Driver) History(ctx context.Context) ([]muz.Record, error) {
+	kv := d.kv
+	if kv == nil {
+		var err error
+		kv, err = d.JetStream.KeyValue(ctx, d.bucketName())
+		if errors.Is(err, jetstream.ErrBucketNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lister, err := kv.ListKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer lister.Stop()
+
+	var records []muz.Record
+	for key := range lister.Keys() {
+		entry, err := kv.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+
+		var r record
+		if err := json.Unmarshal(entry.Value(), &r); err != nil {
+			return nil, fmt.Errorf("decoding record %s: %w", key, err)
+		}
+
+		records = append(records, muz.Record{
+			Version:     r.Version,
+			Directory:   r.Directory,
+			FileName:    r.FileName,
+			ProcessedAt: r.ProcessedAt,
+		})
+	}
+
+	slices.SortFunc(records, func(a, b muz.Record) int {
+		if c := strings.Compare(a.Directory, b.Directory); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Version, b.Version)
+	})
+
+	return records, nil
+}
+
+func (d *Driver) Record(ctx context.Context, dir string, file muz.FileInfo) error {
+	value, err := json.Marshal(record{
+		Version:     file.Version,
+		Directory:   dir,
+		FileName:    file.Path,
+		ProcessedAt: d.now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = d.kv.Put(ctx, recordKey(dir, file.Version), value)
+	return err
+}
+
+func (d *Driver) Unrecord(ctx context.Context, dir string, version int64) error {
+	return d.kv.Delete(ctx, recordKey(dir, version))
+}
+
+// apply executes a single definition against JetStream.
+func (d *Driver) apply(ctx context.Context, def *Definition) error {
+	for _, ref := range def.DeleteConsumers {
+		if err := d.JetStream.DeleteConsumer(ctx, ref.Stream, ref.Name); err != nil && !errors.Is(err, jetstream.ErrConsumerNotFound) {
+			return fmt.Errorf("deleting consumer %s/%s: %w", ref.Stream, ref.Name, err)
+		}
+	}
+
+	for _, name := range def.DeleteStreams {
+		if err := d.JetStream.DeleteStream(ctx, name); err != nil && !errors.Is(err, jetstream.ErrStreamNotFound) {
+			return fmt.Errorf("deleting stream %s: %w", name, err)
+		}
+	}
+
+	for _, cfg := range def.Streams {
+		if _, err := d.JetStream.CreateOrUpdateStream(ctx, cfg); err != nil {
+			return fmt.Errorf("stream %s: %w", cfg.Name, err)
+		}
+	}
+
+	for _, c := range def.Consumers {
+		if _, err := d.JetStream.CreateOrUpdateConsumer(ctx, c.Stream, c.ConsumerConfig); err != nil {
+			return fmt.Errorf("consumer %s/%s: %w", c.Stream, c.Durable, err)
+		}
+	}
+
+	return nil
+}
+
+// latestVersion returns the highest applied version recorded for the directory.
+func (d *Driver) latestVersion(ctx context.Context, directory string) (int64, error) {
+	lister, err := d.kv.ListKeysFiltered(ctx, dirKey(directory)+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer lister.Stop()
+
+	var version int64
+	for key := range lister.Keys() {
+		_, v, ok := parseRecordKey(key)
+		if ok && v > version {
+			version = v
+		}
+	}
+
+	return version, nil
+}
+
+// dirKey encodes a directory name into a valid KV key token.
+// Directory names may contain dots and slashes which are not usable as tokens.
+func dirKey(directory string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(directory))
+}
+
+// recordKey returns the tracking key for a version inside a directory.
+func recordKey(directory string, version int64) string {
+	return dirKey(directory) + "." + strconv.FormatInt(version, 10)
+}
+
+// parseRecordKey is the reverse of recordKey.
+func parseRecordKey(key string) (string, int64, bool) {
+	enc, v, ok := strings.Cut(key, ".")
+	if !ok {
+		return "", 0, false
+	}
+
+	dir, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil {
+		return "", 0, false
+	}
+
+	version, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return string(dir), version, true
+}
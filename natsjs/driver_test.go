@@ -0,0 +1,58 @@
+package natsjs
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRecordKey(t *testing.T) {
+	tests := []struct {
+		directory string
+		version   int64
+	}{
+		{directory: ".", version: 1},
+		{directory: "inner/folder", version: 2},
+		{directory: "schema.v2", version: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.directory, func(t *testing.T) {
+			key := recordKey(tt.directory, tt.version)
+
+			dir, version, ok := parseRecordKey(key)
+			if !ok {
+				t.Fatalf("parseRecordKey(%q) failed", key)
+			}
+			if dir != tt.directory || version != tt.version {
+				t.Errorf("parseRecordKey(%q) = %q, %d; want %q, %d", key, dir, version, tt.directory, tt.version)
+			}
+		})
+	}
+}
+
+func TestDefinitionUnmarshal(t *testing.T) {
+	content := `{
+		"streams": [{"name": "ORDERS", "subjects": ["orders.>"]}],
+		"consumers": [{"stream": "ORDERS", "durable_name": "worker", "ack_policy": "explicit"}],
+		"delete_consumers": [{"stream": "ORDERS", "name": "old"}],
+		"delete_streams": ["LEGACY"]
+	}`
+
+	var def Definition
+	if err := json.Unmarshal([]byte(content), &def); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+
+	if len(def.Streams) != 1 || def.Streams[0].Name != "ORDERS" {
+		t.Errorf("unexpected streams: %+v", def.Streams)
+	}
+	if len(def.Consumers) != 1 || def.Consumers[0].Stream != "ORDERS" || def.Consumers[0].Durable != "worker" {
+		t.Errorf("unexpected consumers: %+v", def.Consumers)
+	}
+	if len(def.DeleteConsumers) != 1 || def.DeleteConsumers[0].Name != "old" {
+		t.Errorf("unexpected delete consumers: %+v", def.DeleteConsumers)
+	}
+	if len(def.DeleteStreams) != 1 || def.DeleteStreams[0] != "LEGACY" {
+		t.Errorf("unexpected delete streams: %+v", def.DeleteStreams)
+	}
+}
@@ -0,0 +1,92 @@
+package muz
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NextVersion returns the version a new file of dir takes, the one after the highest version of dir,
+// or of the whole tree with Linear. Go migrations are counted, the version range and tags are not applied.
+// A directory without files starts at 1.
+func (m Migrate) NextVersion(dir string) (int64, error) {
+	latest, err := m.latestFile(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	return latest.Version + 1, nil
+}
+
+// NextTimestampVersion returns the version of a new file of dir created at t, like 20240101120000.
+// When the latest version of dir, or of the whole tree with Linear, is not older, the version after it is returned,
+// so files created within the same second stay ordered.
+func (m Migrate) NextTimestampVersion(dir string, t time.Time) (int64, error) {
+	latest, err := m.latestFile(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	version, err := strconv.ParseInt(t.UTC().Format("20060102150405"), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return max(version, latest.Version+1), nil
+}
+
+// NextFileName returns the file name of a new migration of dir, like "004_add_users.sql".
+// The version is the one of NextVersion, zero padded to the width of the latest file or 3 digits,
+// with the Extension of dir or ".sql".
+func (m Migrate) NextFileName(dir, name string) (string, error) {
+	name = sanitizeName(name)
+	if name == "" {
+		return "", errors.New("next file name: migration name is empty")
+	}
+
+	latest, err := m.latestFile(dir)
+	if err != nil {
+		return "", err
+	}
+
+	digits := 3
+	if latest.Path != "" {
+		base := path.Base(latest.Path)
+		digits = len(base) - len(strings.TrimLeft(base, "0123456789"))
+	}
+
+	ext := withDefault(m.extension(cleanDir(dir)), ".sql")
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+
+	return fmt.Sprintf("%0*d_%s%s", digits, latest.Version+1, name, ext), nil
+}
+
+// latestFile returns the file with the highest version of dir, or of the whole tree with Linear.
+// The zero FileInfo is returned when there is none.
+func (m Migrate) latestFile(dir string) (FileInfo, error) {
+	dir = cleanDir(dir)
+
+	var latest FileInfo
+	for info, err := range m.withGo(m.listed()) {
+		if err != nil {
+			return FileInfo{}, err
+		}
+
+		if !m.Linear && info.Dir != dir {
+			continue
+		}
+
+		for _, file := range info.Files {
+			if file.Version > latest.Version {
+				latest = file
+			}
+		}
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,78 @@
+package muz
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextVersion(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/0001_users.sql":       "CREATE TABLE users();",
+			"migrations/schema/0002_orders.sql":      "CREATE TABLE orders();",
+			"migrations/schema/0002_orders.down.sql": "DROP TABLE orders;",
+			"migrations/seed/5_admin.sql":            "INSERT INTO users DEFAULT VALUES;",
+		}),
+	}
+
+	tests := []struct {
+		name     string
+		linear   bool
+		dir      string
+		version  int64
+		fileName string
+	}{
+		{name: "directory", dir: "schema", version: 3, fileName: "0003_add_items.sql"},
+		{name: "other directory", dir: "seed/", version: 6, fileName: "6_add_items.sql"},
+		{name: "new directory", dir: "audit", version: 1, fileName: "001_add_items.sql"},
+		{name: "linear", linear: true, dir: "schema", version: 6, fileName: "6_add_items.sql"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := m
+			m.Linear = tt.linear
+
+			version, err := m.NextVersion(tt.dir)
+			if err != nil {
+				t.Fatalf("NextVersion() error = %v", err)
+			}
+
+			if version != tt.version {
+				t.Errorf("NextVersion() = %d, want %d", version, tt.version)
+			}
+
+			fileName, err := m.NextFileName(tt.dir, "Add items")
+			if err != nil {
+				t.Fatalf("NextFileName() error = %v", err)
+			}
+
+			if fileName != tt.fileName {
+				t.Errorf("NextFileName() = %q, want %q", fileName, tt.fileName)
+			}
+		})
+	}
+
+	if _, err := m.NextFileName("schema", " "); err == nil {
+		t.Errorf("NextFileName() expected error for an empty name")
+	}
+}
+
+func TestNextTimestampVersion(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/20240101120000_users.sql": "CREATE TABLE users();",
+		}),
+	}
+
+	now := time.Date(2024, 2, 1, 8, 30, 0, 0, time.UTC)
+	if got, err := m.NextTimestampVersion("schema", now); err != nil || got != 20240201083000 {
+		t.Errorf("NextTimestampVersion() = %d, %v; want 20240201083000", got, err)
+	}
+
+	// a clock behind the latest file still gives a newer version
+	past := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got, err := m.NextTimestampVersion("schema", past); err != nil || got != 20240101120001 {
+		t.Errorf("NextTimestampVersion() = %d, %v; want 20240101120001", got, err)
+	}
+}
@@ -0,0 +1,97 @@
+package muz
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// orderFile in a directory lists its migration files in the order they are applied, instead of their numeric prefixes.
+// The version of a file is its position in the list counting from 1, new files are appended.
+//
+//	# migrations/schema/order.yaml
+//	- create_users.sql
+//	- add_orders.sql
+const orderFile = "order.yaml"
+
+// readOrder returns the file names listed by the order file of dir.
+func readOrder(fileSystem fs.FS, dir string) ([]string, error) {
+	name := path.Join(dir, orderFile)
+
+	content, err := fs.ReadFile(fileSystem, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var listed []string
+	if err := yaml.Unmarshal(content, &listed); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", name, err)
+	}
+
+	seen := make(map[string]bool, len(listed))
+	for i, file := range listed {
+		file = strings.TrimSpace(file)
+		if file == "" || strings.Contains(file, "/") {
+			return nil, fmt.Errorf("%s: entry %d %q is not a file name of the directory", name, i+1, file)
+		}
+
+		if seen[file] {
+			return nil, fmt.Errorf("%s: %s is listed twice", name, file)
+		}
+
+		seen[file] = true
+		listed[i] = file
+	}
+
+	return listed, nil
+}
+
+// orderedFiles returns the migration files among the file names of dir in the order of its order file,
+// with their position as version, and the names of the migration files it does not list.
+// Listed files dropped by Skip or Extension keep their position.
+func (m *Migrate) orderedFiles(fileSystem fs.FS, dir string, names []string) ([]FileInfo, []string, error) {
+	listed, err := readOrder(fileSystem, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	numbered, unnumbered := m.selectFiles(dir, names)
+
+	candidates := make(map[string]bool, len(numbered)+len(unnumbered))
+	for _, file := range numbered {
+		candidates[file.Path] = true
+	}
+	for _, name := range unnumbered {
+		candidates[name] = true
+	}
+
+	files := make([]FileInfo, 0, len(listed))
+	for i, name := range listed {
+		if !slices.Contains(names, name) {
+			return nil, nil, fmt.Errorf("%s: %w", path.Join(dir, orderFile), &fs.PathError{Op: "open", Path: path.Join(dir, name), Err: fs.ErrNotExist})
+		}
+
+		if candidates[name] {
+			files = append(files, FileInfo{Path: name, Version: int64(i + 1)})
+			delete(candidates, name)
+		}
+	}
+
+	unlisted := make([]string, 0, len(candidates))
+	for name := range candidates {
+		unlisted = append(unlisted, name)
+	}
+
+	slices.Sort(unlisted)
+
+	return files, unlisted, nil
+}
+
+// hasOrder reports whether the file names of a directory include an order file.
+func hasOrder(names []string) bool {
+	return slices.Contains(names, orderFile)
+}
@@ -0,0 +1,107 @@
+package muz
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestOrderFile(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/order.yaml":       "- create_users.sql\n- 010_seed.sql\n- draft.sql\n- add_orders.sql\n",
+			"migrations/schema/create_users.sql": "CREATE TABLE users();",
+			"migrations/schema/add_orders.sql":   "CREATE TABLE orders();",
+			"migrations/schema/010_seed.sql":     "INSERT INTO users DEFAULT VALUES;",
+			"migrations/schema/draft.sql":        "CREATE TABLE drafts();",
+			"migrations/schema/notes.sql":        "-- not listed",
+			"migrations/other/001_users.sql":     "CREATE TABLE users();",
+		}),
+		Skip: []string{"schema/draft.sql"},
+	}
+
+	var got []FileInfo
+	for info, err := range m.Iter() {
+		if err != nil {
+			t.Fatalf("Iter() error = %v", err)
+		}
+
+		if info.Dir == "schema" {
+			got = info.Files
+		}
+	}
+
+	// The skipped draft keeps its position.
+	want := []FileInfo{
+		{Path: "create_users.sql", Version: 1},
+		{Path: "010_seed.sql", Version: 2},
+		{Path: "add_orders.sql", Version: 4},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("schema files = %v, want %v", got, want)
+	}
+
+	report, err := m.Validate()
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	notListed := ValidationIssue{Kind: IssueNotListed, Severity: SeverityError, Dir: "schema", File: "notes.sql", Message: "file is not listed in order.yaml and is ignored"}
+	if !slices.Contains(report.Issues, notListed) {
+		t.Errorf("Validate() issues = %v, want %v", report.Issues, notListed)
+	}
+
+	// The numbering of a directory with an order file is not checked.
+	if slices.ContainsFunc(report.Issues, func(i ValidationIssue) bool { return i.Kind == IssueMissingPrefix }) {
+		t.Errorf("Validate() issues = %v, want no missing prefix", report.Issues)
+	}
+}
+
+func TestOrderFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   string
+		wantErr error
+	}{
+		{name: "missing file", order: "- create_users.sql\n- add_orders.sql\n", wantErr: fs.ErrNotExist},
+		{name: "listed twice", order: "- create_users.sql\n- create_users.sql\n"},
+		{name: "path", order: "- ../create_users.sql\n"},
+		{name: "not a list", order: "files: create_users.sql\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Migrate{
+				FS: MapFS(map[string]string{
+					"migrations/schema/order.yaml":       tt.order,
+					"migrations/schema/create_users.sql": "CREATE TABLE users();",
+				}),
+			}
+
+			var err error
+			for _, err = range m.Iter() {
+				if err != nil {
+					break
+				}
+			}
+
+			if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Iter() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSquashOrderFile(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "schema")
+	mustMkdir(t, dir)
+	mustWriteFile(t, filepath.Join(dir, "order.yaml"), []byte("- users.sql\n"))
+	mustWriteFile(t, filepath.Join(dir, "users.sql"), []byte("CREATE TABLE users();\n"))
+
+	if _, err := Squash("schema", 1, SquashConfig{Path: root}); err == nil {
+		t.Error("Squash() error = nil, want directories with an order file refused")
+	}
+}
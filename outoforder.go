@@ -0,0 +1,43 @@
+package muz
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrOutOfOrder is returned by OutOfOrderError for a file older than the latest applied version of its directory.
+var ErrOutOfOrder = errors.New("out of order migration")
+
+// OutOfOrder is the policy for files whose version is lower than the latest applied version
+// of their directory, usually merged after a newer migration was already deployed.
+type OutOfOrder string
+
+const (
+	// OutOfOrderIgnore skips out of order files silently.
+	OutOfOrderIgnore OutOfOrder = ""
+	// OutOfOrderError fails the run with ErrOutOfOrder.
+	OutOfOrderError OutOfOrder = "error"
+	// OutOfOrderWarn leaves the files unapplied and reports them as OutcomeOutOfOrder.
+	OutOfOrderWarn OutOfOrder = "warn"
+	// OutOfOrderApply applies the files, the driver must apply the files of Process marked with Muzo.OutOfOrder
+	// like the built-in drivers do.
+	OutOfOrderApply OutOfOrder = "apply"
+)
+
+func (o OutOfOrder) valid() bool {
+	switch o {
+	case OutOfOrderIgnore, OutOfOrderError, OutOfOrderWarn, OutOfOrderApply:
+		return true
+	}
+
+	return false
+}
+
+// applyOutOfOrder applies file with Process like the files in order, marked with Muzo.OutOfOrder
+// as drivers skip the versions up to the latest applied one otherwise.
+func applyOutOfOrder(ctx context.Context, driver Driver, info *Muzo, file FileInfo) error {
+	data := info.withFiles([]FileInfo{file})
+	data.outOfOrder = true
+
+	return driver.Process(ctx, data)
+}
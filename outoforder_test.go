@@ -0,0 +1,114 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestMigrateOutOfOrder(t *testing.T) {
+	files := map[string]string{
+		"migrations/app/001_users.sql":  "CREATE TABLE users();",
+		"migrations/app/002_orders.sql": "CREATE TABLE orders();",
+		"migrations/app/003_items.sql":  "CREATE TABLE items();",
+	}
+
+	tests := []struct {
+		name         string
+		policy       OutOfOrder
+		wantErr      error
+		wantOutcomes []Outcome
+		wantVersions []int64
+		wantCalls    []string
+	}{
+		{
+			name:         "ignore",
+			wantOutcomes: []Outcome{OutcomeSkipped, OutcomeSkipped, OutcomeApplied},
+			wantVersions: []int64{2, 3},
+		},
+		{
+			name:         "error",
+			policy:       OutOfOrderError,
+			wantErr:      ErrOutOfOrder,
+			wantVersions: []int64{2},
+		},
+		{
+			name:         "warn",
+			policy:       OutOfOrderWarn,
+			wantOutcomes: []Outcome{OutcomeOutOfOrder, OutcomeSkipped, OutcomeApplied},
+			wantVersions: []int64{2, 3},
+		},
+		{
+			name:         "apply",
+			policy:       OutOfOrderApply,
+			wantOutcomes: []Outcome{OutcomeApplied, OutcomeSkipped, OutcomeApplied},
+			wantVersions: []int64{1, 2, 3},
+			wantCalls:    []string{"app/001_users.sql", "app/003_items.sql"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Migrate{FS: MapFS(files), OutOfOrder: tt.policy}
+
+			driver := &execDriver{recordDriver: recordDriver{records: []Record{{Version: 2, Directory: "app", FileName: "002_orders.sql"}}}}
+
+			result, err := m.Migrate(context.Background(), driver)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Migrate() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantOutcomes != nil {
+				var outcomes []Outcome
+				for _, f := range result.Files {
+					outcomes = append(outcomes, f.Outcome)
+				}
+
+				if !slices.Equal(outcomes, tt.wantOutcomes) {
+					t.Errorf("outcomes = %v, want %v", outcomes, tt.wantOutcomes)
+				}
+			}
+
+			if tt.wantErr == nil {
+				if got := driver.versions("app"); !slices.Equal(got, tt.wantVersions) {
+					t.Errorf("recorded versions = %v, want %v", got, tt.wantVersions)
+				}
+			}
+
+			// Out of order files are applied with Process like the files in order.
+			if tt.wantCalls != nil && !slices.Equal(driver.calls, tt.wantCalls) {
+				t.Errorf("Process() calls = %v, want %v", driver.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestMigrateOutOfOrderPlan(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/app/001_users.sql":  "CREATE TABLE users();",
+			"migrations/app/002_orders.sql": "CREATE TABLE orders();",
+		}),
+		OutOfOrder: OutOfOrderApply,
+	}
+
+	driver := &recordDriver{records: []Record{{Version: 2, Directory: "app", FileName: "002_orders.sql"}}}
+
+	plan, err := m.Plan(context.Background(), driver)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+
+	if len(plan) != 1 || plan[0].Version != 1 || plan[0].Reason != ReasonOutOfOrder {
+		t.Errorf("Plan() = %+v, want version 1 out of order", plan)
+	}
+}
+
+func TestMigrateOutOfOrderInvalid(t *testing.T) {
+	m := Migrate{FS: MapFS(map[string]string{"migrations/001_users.sql": "CREATE TABLE users();"}), OutOfOrder: "later"}
+
+	if _, err := m.Migrate(context.Background(), &recordDriver{}); err == nil {
+		t.Error("Migrate() error = nil, want unknown policy error")
+	}
+}
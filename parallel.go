@@ -0,0 +1,174 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"sync"
+)
+
+// processParallel applies dirs with up to Parallel clones of driver at the same time.
+// A first session on driver checks the dirty state and creates the tracking table,
+// so the clones do not race for it.
+func (m Migrate) processParallel(ctx context.Context, driver Driver, dirs iter.Seq2[*Muzo, error], result *Result, callbacks map[string][]byte) error {
+	cloner, ok := driver.(Cloner)
+	if !ok {
+		return fmt.Errorf("parallel: %w", ErrNotSupported)
+	}
+
+	if m.Linear {
+		return errors.New("parallel: directories of Linear are not independent")
+	}
+
+	return locked(ctx, driver, m.log(), func() error {
+		if err := started(ctx, driver, func() error {
+			if err := checkDirty(ctx, driver); err != nil {
+				return err
+			}
+
+			// The files skipped without being read come from this history, the clones read their own.
+			if _, err := m.history(ctx, driver); err != nil {
+				return err
+			}
+
+			return runCallback(ctx, driver, callbacks, CallbackBeforeMigrate)
+		}); err != nil {
+			return err
+		}
+
+		if err := m.parallelDirs(ctx, cloner, dirs, result); err != nil {
+			return err
+		}
+
+		if _, ok := callbacks[CallbackAfterMigrate]; !ok {
+			return nil
+		}
+
+		return started(ctx, driver, func() error {
+			return runCallback(ctx, driver, callbacks, CallbackAfterMigrate)
+		})
+	})
+}
+
+// parallelDirs applies every directory of dirs in a session of a clone, the directories of Order
+// one after another before the others. No directory is started after a failure, the ones running
+// are finished. The results are added to result in the order of dirs.
+func (m Migrate) parallelDirs(ctx context.Context, cloner Cloner, dirs iter.Seq2[*Muzo, error], result *Result) error {
+	ordered := make(map[string]bool, len(m.Order))
+	for _, dir := range m.Order {
+		ordered[cleanDir(dir)] = true
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		errs    []error
+		results []*Result
+		sem     = make(chan struct{}, m.Parallel)
+	)
+
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		errs = append(errs, err)
+	}
+
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return len(errs) > 0
+	}
+
+	for info, err := range dirs {
+		if err == nil {
+			err = ctx.Err()
+		}
+
+		if err != nil {
+			fail(err)
+			break
+		}
+
+		r := &Result{}
+		results = append(results, r)
+
+		if ordered[info.Dir] {
+			wg.Wait()
+
+			if err := m.cloneDir(ctx, cloner, info, r); err != nil {
+				fail(err)
+			}
+		} else {
+			sem <- struct{}{}
+
+			if failed() {
+				<-sem
+				break
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				if err := m.cloneDir(ctx, cloner, info, r); err != nil {
+					fail(err)
+				}
+			}()
+		}
+
+		if failed() {
+			break
+		}
+	}
+
+	wg.Wait()
+
+	for _, r := range results {
+		result.Files = append(result.Files, r.Files...)
+		for _, dir := range r.Dirs {
+			result.touch(dir)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// holdSource opens the migration path for the whole run with Parallel, openFS returns it until the returned
+// function closes it. The clones read the files of the last directories after the iteration listing them
+// ended, a zip archive closed by the iteration would fail their reads.
+// Errors are left to the iteration opening the path again.
+func (m *Migrate) holdSource() func() error {
+	if m.Parallel <= 1 || m.Source != nil {
+		return func() error { return nil }
+	}
+
+	fileSystem, closeFS, err := m.openFS(m.rootPath())
+	if err != nil {
+		return func() error { return nil }
+	}
+
+	m.held = fileSystem
+
+	return closeFS
+}
+
+// cloneDir applies info in a session of a new clone, filling r.
+func (m Migrate) cloneDir(ctx context.Context, cloner Cloner, info *Muzo, r *Result) error {
+	driver, err := cloner.Clone()
+	if err != nil {
+		return err
+	}
+
+	return started(ctx, driver, func() error {
+		h, err := m.history(ctx, driver)
+		if err != nil {
+			return err
+		}
+
+		return m.processDir(ctx, driver, info, h, r)
+	})
+}
@@ -0,0 +1,160 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// parallelDriver is a Cloner whose clones share the tracking records, counting the sessions running at once.
+type parallelDriver struct {
+	nopDriver
+
+	mu      *sync.Mutex
+	records *[]Record
+	running *int
+	most    *int
+	fail    string
+}
+
+func newParallelDriver() *parallelDriver {
+	return &parallelDriver{mu: &sync.Mutex{}, records: &[]Record{}, running: new(int), most: new(int)}
+}
+
+func (d *parallelDriver) Clone() (Driver, error) {
+	c := *d
+	return &c, nil
+}
+
+func (d *parallelDriver) History(context.Context) ([]Record, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return slices.Clone(*d.records), nil
+}
+
+func (d *parallelDriver) Process(_ context.Context, data *Muzo) error {
+	d.mu.Lock()
+	*d.running++
+	*d.most = max(*d.most, *d.running)
+	d.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	*d.running--
+
+	if data.Dir == d.fail {
+		return errors.New("boom")
+	}
+
+	for _, file := range data.Files {
+		if _, err := data.ReadFile(file.Path); err != nil {
+			return err
+		}
+
+		*d.records = append(*d.records, Record{Version: file.Version, Directory: data.Dir, FileName: file.Path})
+	}
+
+	return nil
+}
+
+func TestMigrateParallel(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_base.sql":  "SELECT 1;",
+			"migrations/billing/001_a.sql":    "SELECT 1;",
+			"migrations/billing/002_b.sql":    "SELECT 1;",
+			"migrations/orders/001_a.sql":     "SELECT 1;",
+			"migrations/users/001_a.sql":      "SELECT 1;",
+			"migrations/inventory/001_a.sql":  "SELECT 1;",
+			"migrations/reporting/001_a.sql":  "SELECT 1;",
+			"migrations/reporting/002_b.sql":  "SELECT 1;",
+			"migrations/reporting/003_c.sql":  "SELECT 1;",
+			"migrations/inventory/002_b.sql":  "SELECT 1;",
+			"migrations/schema/002_types.sql": "SELECT 1;",
+		}),
+		Order:    []string{"schema"},
+		Parallel: 3,
+	}
+
+	t.Run("applies directories at the same time", func(t *testing.T) {
+		driver := newParallelDriver()
+
+		result, err := m.Migrate(context.Background(), driver)
+		if err != nil {
+			t.Fatalf("Migrate() error = %v", err)
+		}
+
+		if want := []string{"schema", "billing", "inventory", "orders", "reporting", "users"}; !slices.Equal(result.Dirs, want) {
+			t.Errorf("Dirs = %v, want %v", result.Dirs, want)
+		}
+
+		if result.Applied() != 11 {
+			t.Errorf("Applied() = %d, want 11", result.Applied())
+		}
+
+		if (*driver.records)[0].Directory != "schema" || (*driver.records)[1].Directory != "schema" {
+			t.Errorf("schema not applied first: %v", *driver.records)
+		}
+
+		if *driver.most < 2 || *driver.most > 3 {
+			t.Errorf("%d sessions at the same time, want 2 or 3", *driver.most)
+		}
+
+		// A second run has nothing to apply.
+		result, err = m.Migrate(context.Background(), driver)
+		if err != nil || result.Applied() != 0 {
+			t.Errorf("second Migrate() applied %d, error = %v", result.Applied(), err)
+		}
+	})
+
+	t.Run("failed directory", func(t *testing.T) {
+		driver := newParallelDriver()
+		driver.fail = "schema"
+
+		result, err := m.Migrate(context.Background(), driver)
+		if err == nil {
+			t.Fatal("Migrate() error = nil, want the schema failure")
+		}
+
+		if len(result.Dirs) != 0 || len(*driver.records) != 0 {
+			t.Errorf("directories after the failed schema were applied: %v", *driver.records)
+		}
+	})
+
+	t.Run("driver without Cloner", func(t *testing.T) {
+		if _, err := m.Migrate(context.Background(), &recordDriver{}); !errors.Is(err, ErrNotSupported) {
+			t.Errorf("Migrate() error = %v, want ErrNotSupported", err)
+		}
+	})
+}
+
+func TestMigrateParallelZip(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "bundle.zip")
+	mustWriteFile(t, bundle, buildZip(t, map[string]string{
+		"billing/001_a.sql": "SELECT 1;",
+		"orders/001_a.sql":  "SELECT 1;",
+		"users/001_a.sql":   "SELECT 1;",
+		"users/002_b.sql":   "SELECT 1;",
+	}))
+
+	// The clones read the last directories after the iteration listing them ended.
+	m := Migrate{Path: bundle, Parallel: 4}
+	driver := newParallelDriver()
+
+	result, err := m.Migrate(context.Background(), driver)
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	if result.Applied() != 4 {
+		t.Errorf("Applied() = %d, want 4", result.Applied())
+	}
+}
@@ -0,0 +1,126 @@
+package muz
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// StatementError is the error of a failed statement of a migration file, locating it in the file,
+// so the failing line of a long migration is found without counting.
+// Lines of included files count as lines of the including file.
+type StatementError struct {
+	// File is the path of the file in the migration tree, like "schema/001_users.sql".
+	File string
+	// Index is the number of the statement in the file, counting from 1.
+	Index int
+	// Line is the line of the error counting from 1, the first line of the statement
+	// when the database does not report a position.
+	Line int
+	// Column is the column of the error in characters counting from 1, zero when the database
+	// does not report a position. PostgreSQL does.
+	Column int
+	Err    error
+}
+
+func (e *StatementError) Error() string {
+	if e.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: statement %d: %v", e.File, e.Line, e.Column, e.Index, e.Err)
+	}
+
+	return fmt.Sprintf("%s:%d: statement %d: %v", e.File, e.Line, e.Index, e.Err)
+}
+
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+// batchedStatement is a statement run together with others in one call.
+type batchedStatement struct {
+	// offset is the byte offset of the statement in the call.
+	offset int
+	index  int
+	line   int
+}
+
+// statementError returns err of the statement number index of file, starting on line, as a StatementError.
+// offset is the byte offset of the error in text, the statement, or negative when it is unknown.
+func statementError(err error, file string, index, line int, text string, offset int) error {
+	if err == nil {
+		return nil
+	}
+
+	e := &StatementError{File: file, Index: index, Line: line, Err: err}
+	if offset >= 0 {
+		before := text[:offset]
+		e.Line += strings.Count(before, "\n")
+		e.Column = utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:]) + 1
+	}
+
+	return e
+}
+
+// contentError locates err of a whole file run in one call, it is returned as is
+// when the database does not report a position.
+func contentError(err error, file, content string) error {
+	offset := errorOffset(err, content)
+	if offset < 0 {
+		return err
+	}
+
+	// The statements up to the failing character, the failing one included.
+	_, size := utf8.DecodeRuneInString(content[offset:])
+	index := len(splitStatements(content[:offset+size], true))
+
+	return statementError(err, file, max(index, 1), 1, content, offset)
+}
+
+// batchError locates err of the statements run in one call as text, it is returned as is
+// when the failing statement is not known.
+func batchError(err error, file, text string, statements []batchedStatement) error {
+	if err == nil || len(statements) == 0 {
+		return err
+	}
+
+	offset := errorOffset(err, text)
+	if offset < 0 {
+		if len(statements) == 1 {
+			return statementError(err, file, statements[0].index, statements[0].line, "", -1)
+		}
+
+		return err
+	}
+
+	s := statements[0]
+	for _, next := range statements[1:] {
+		if next.offset > offset {
+			break
+		}
+
+		s = next
+	}
+
+	return statementError(err, file, s.index, s.line, text[s.offset:], offset-s.offset)
+}
+
+// errorOffset returns the byte offset in text of the position reported by a PostgreSQL error,
+// -1 without one.
+func errorOffset(err error, text string) int {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Position <= 0 {
+		return -1
+	}
+
+	// The position counts characters from 1.
+	n := int32(0)
+	for i := range text {
+		if n++; n == pgErr.Position {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,77 @@
+package muz
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// positionExecer fails the calls holding "bad" like PostgreSQL, with the position of "bad" when pg is set.
+type positionExecer struct {
+	pg bool
+}
+
+func (e positionExecer) ExecContext(_ context.Context, query string, _ ...any) (sql.Result, error) {
+	i := strings.Index(query, "bad")
+	if i < 0 {
+		return driverResult(1), nil
+	}
+
+	if !e.pg {
+		return nil, errors.New("syntax error")
+	}
+
+	return nil, &pgconn.PgError{Severity: "ERROR", Message: "syntax error", Code: "42601", Position: int32(utf8.RuneCountInString(query[:i]) + 1)}
+}
+
+func TestStatementError(t *testing.T) {
+	content := "-- users\nCREATE TABLE users();\n\nCREATE TABLE posts (\n  title text, -- é\n  bad int\n);\nCREATE TABLE tags();\n"
+	data := NewMuzo("schema", []FileInfo{{Path: "001_init.sql", Version: 1}}, MapFS(map[string]string{"schema/001_init.sql": content}))
+
+	copyNop := func(context.Context, string, string) error { return nil }
+
+	tests := []struct {
+		name string
+		pg   bool
+		opts execOptions
+		want StatementError
+	}{
+		{name: "one call", pg: true, want: StatementError{Index: 2, Line: 6, Column: 3}},
+		{name: "split", pg: true, opts: execOptions{split: true}, want: StatementError{Index: 2, Line: 6, Column: 3}},
+		{name: "batches", pg: true, opts: execOptions{batch: 40, copy: copyNop}, want: StatementError{Index: 2, Line: 6, Column: 3}},
+		{name: "split without position", opts: execOptions{split: true}, want: StatementError{Index: 2, Line: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := execContent(context.Background(), positionExecer{pg: tt.pg}, data, "001_init.sql", tt.opts)
+
+			var se *StatementError
+			if !errors.As(err, &se) {
+				t.Fatalf("execContent() error = %v, want a StatementError", err)
+			}
+
+			if se.File != "schema/001_init.sql" || se.Index != tt.want.Index || se.Line != tt.want.Line || se.Column != tt.want.Column {
+				t.Errorf("execContent() error = %+v, want %+v", se, tt.want)
+			}
+		})
+	}
+
+	// Without a position a file run in one call has nothing to locate.
+	err := execContent(context.Background(), positionExecer{}, data, "001_init.sql", execOptions{})
+
+	var se *StatementError
+	if err == nil || errors.As(err, &se) {
+		t.Errorf("execContent() error = %v, want the plain error", err)
+	}
+
+	want := "schema/001_init.sql:6:3: statement 2: ERROR: syntax error (SQLSTATE 42601)"
+	if err := execContent(context.Background(), positionExecer{pg: true}, data, "001_init.sql", execOptions{}); err == nil || err.Error() != want {
+		t.Errorf("execContent() error = %v, want %q", err, want)
+	}
+}
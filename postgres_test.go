@@ -0,0 +1,248 @@
+package muz_test
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rakunlabs/muz"
+	"github.com/rakunlabs/muz/muztest"
+)
+
+// The tests of this file need a Docker daemon.
+
+//go:embed testdata
+var testMigrationsFS embed.FS
+
+func TestMuz(t *testing.T) {
+	tt := muztest.NewPostgres(t)
+
+	m := muz.Migrate{
+		Path: "testdata",
+		FS:   testMigrationsFS,
+	}
+
+	driver := &muz.PostgresDriver{
+		DB:     tt.DB,
+		Table:  "muz_migrations",
+		Logger: slog.Default(),
+	}
+
+	if _, err := m.Migrate(t.Context(), driver); err != nil {
+		t.Fatalf("Migrate() error: %v", err)
+	}
+
+	// Verify that migrations were applied
+	var count int
+	err := tt.DB.QueryRowContext(t.Context(), "SELECT COUNT(*) FROM muz_migrations").Scan(&count)
+	if err != nil {
+		t.Fatalf("could not query migrations table: %v", err)
+	}
+
+	expectedMigrations := 4 // Total number of migration files in testdata
+	if count != expectedMigrations {
+		t.Fatalf("expected %d migrations applied, got %d", expectedMigrations, count)
+	}
+}
+
+func TestPostgresDriverConcurrent(t *testing.T) {
+	tt := muztest.NewPostgres(t)
+
+	m := muz.Migrate{
+		Path: "testdata",
+		FS:   testMigrationsFS,
+	}
+
+	// Replicas starting together wait for the advisory lock instead of applying twice.
+	errs := make(chan error, 3)
+	for range cap(errs) {
+		go func() {
+			driver := &muz.PostgresDriver{
+				DB:     tt.DB,
+				Table:  "muz_migrations",
+				Logger: slog.Default(),
+			}
+
+			_, err := m.Migrate(t.Context(), driver)
+			errs <- err
+		}()
+	}
+
+	for range cap(errs) {
+		if err := <-errs; err != nil {
+			t.Fatalf("Migrate() error: %v", err)
+		}
+	}
+
+	var count int
+	if err := tt.DB.QueryRowContext(t.Context(), "SELECT COUNT(*) FROM muz_migrations").Scan(&count); err != nil {
+		t.Fatalf("could not query migrations table: %v", err)
+	}
+
+	expectedMigrations := 4 // Total number of migration files in testdata
+	if count != expectedMigrations {
+		t.Fatalf("expected %d migrations applied, got %d", expectedMigrations, count)
+	}
+}
+
+func TestPostgresDriverCheckPrivileges(t *testing.T) {
+	tt := muztest.NewPostgres(t)
+
+	m := muz.Migrate{
+		Path: "testdata",
+		FS:   testMigrationsFS,
+	}
+
+	driver := &muz.PostgresDriver{
+		DB:              tt.DB,
+		Table:           "muz_migrations",
+		Logger:          slog.Default(),
+		CheckPrivileges: true,
+	}
+
+	// The superuser creates the tracking table on the first run and owns it on the second.
+	for range 2 {
+		if _, err := m.Migrate(t.Context(), driver); err != nil {
+			t.Fatalf("Migrate() error: %v", err)
+		}
+	}
+}
+
+func TestPgxDriver(t *testing.T) {
+	tt := muztest.NewPostgres(t)
+
+	pool, err := pgxpool.New(t.Context(), tt.DSN)
+	if err != nil {
+		t.Fatalf("could not create pgx pool: %v", err)
+	}
+	defer pool.Close()
+
+	m := muz.Migrate{
+		Path: "testdata",
+		FS:   testMigrationsFS,
+	}
+
+	driver := &muz.PgxDriver{
+		DB:     pool,
+		Table:  "muz_migrations",
+		Logger: slog.Default(),
+	}
+
+	if _, err := m.Migrate(t.Context(), driver); err != nil {
+		t.Fatalf("Migrate() error: %v", err)
+	}
+
+	// Running again must not apply anything twice
+	if _, err := m.Migrate(t.Context(), driver); err != nil {
+		t.Fatalf("second Migrate() error: %v", err)
+	}
+
+	var count int
+	if err := pool.QueryRow(t.Context(), "SELECT COUNT(*) FROM muz_migrations").Scan(&count); err != nil {
+		t.Fatalf("could not query migrations table: %v", err)
+	}
+
+	expectedMigrations := 4 // Total number of migration files in testdata
+	if count != expectedMigrations {
+		t.Fatalf("expected %d migrations applied, got %d", expectedMigrations, count)
+	}
+}
+
+func TestPostgresTxDriver(t *testing.T) {
+	tt := muztest.NewPostgres(t)
+
+	tx, err := tt.DB.BeginTx(t.Context(), nil)
+	if err != nil {
+		t.Fatalf("could not begin transaction: %v", err)
+	}
+
+	m := muz.Migrate{
+		Path: "testdata",
+		FS:   testMigrationsFS,
+	}
+
+	driver := muz.NewPostgresTxDriver(tx)
+	driver.Table = "muz_migrations"
+
+	if _, err := m.Migrate(t.Context(), driver); err != nil {
+		t.Fatalf("Migrate() error: %v", err)
+	}
+
+	// Rolling back the caller's transaction must discard the migrations
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("rollback error: %v", err)
+	}
+
+	var exists bool
+	if err := tt.DB.QueryRowContext(t.Context(), "SELECT to_regclass('muz_migrations') IS NOT NULL").Scan(&exists); err != nil {
+		t.Fatalf("could not query catalog: %v", err)
+	}
+
+	if exists {
+		t.Fatalf("expected tracking table to be rolled back with the caller's transaction")
+	}
+}
+
+func TestTableLock(t *testing.T) {
+	tt := muztest.NewPostgres(t)
+
+	newLock := func(owner string) *muz.TableLock {
+		return &muz.TableLock{DB: tt.DB, Dialect: muz.PostgresDialect{}, Owner: owner, TTL: time.Second, RetryInterval: 50 * time.Millisecond}
+	}
+
+	a, b := newLock("a"), newLock("b")
+	if err := a.Lock(t.Context()); err != nil {
+		t.Fatalf("Lock() error: %v", err)
+	}
+
+	// The heartbeat keeps the lock past its TTL.
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Second)
+	defer cancel()
+
+	if err := b.Lock(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Lock() of a held lock error = %v, want deadline exceeded", err)
+	}
+
+	if err := a.Unlock(t.Context()); err != nil {
+		t.Fatalf("Unlock() error: %v", err)
+	}
+
+	if err := b.Lock(t.Context()); err != nil {
+		t.Fatalf("Lock() of a released lock error: %v", err)
+	}
+
+	if err := a.ForceUnlock(t.Context()); err != nil {
+		t.Fatalf("ForceUnlock() error: %v", err)
+	}
+
+	if err := a.Lock(t.Context()); err != nil {
+		t.Fatalf("Lock() after ForceUnlock error: %v", err)
+	}
+
+	time.Sleep(time.Second)
+
+	if err := b.Unlock(t.Context()); !errors.Is(err, muz.ErrLockLost) {
+		t.Errorf("Unlock() of a removed lock error = %v, want muz.ErrLockLost", err)
+	}
+
+	if err := a.Unlock(t.Context()); err != nil {
+		t.Errorf("Unlock() error: %v", err)
+	}
+
+	// A lock without heartbeat, left by a crashed process, is taken over after its TTL.
+	if _, err := tt.DB.ExecContext(t.Context(), "INSERT INTO migrations_lock VALUES (1, 'crashed', $1)", time.Now().UTC().Add(-time.Minute)); err != nil {
+		t.Fatalf("could not insert stale lock: %v", err)
+	}
+
+	if err := a.Lock(t.Context()); err != nil {
+		t.Fatalf("Lock() of a stale lock error: %v", err)
+	}
+
+	if err := a.Unlock(t.Context()); err != nil {
+		t.Errorf("Unlock() error: %v", err)
+	}
+}
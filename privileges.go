@@ -0,0 +1,53 @@
+package muz
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrPrivileges is returned by drivers checking privileges before a run, when the role misses one it needs.
+var ErrPrivileges = errors.New("missing privileges")
+
+// postgresPrivileges reads the privileges a run needs for the tracking table given as argument: CREATE on the
+// current schema for the migrations, CREATE on the schema of the tracking table when it does not exist yet,
+// and its ownership when it does, for the upgrades of its columns.
+const postgresPrivileges = `
+	WITH t AS (
+		SELECT to_regclass($1::text) AS oid,
+			CASE WHEN array_length(parse_ident($1::text), 1) = 2 THEN (parse_ident($1::text))[1] ELSE current_schema() END AS schema
+	)
+	SELECT current_user::text,
+		COALESCE(current_schema(), ''),
+		COALESCE(has_schema_privilege(current_schema(), 'CREATE'), false),
+		COALESCE(t.schema, ''),
+		EXISTS (SELECT 1 FROM pg_namespace n WHERE n.nspname = t.schema AND has_schema_privilege(n.oid, 'CREATE')),
+		COALESCE(pg_get_userbyid(c.relowner)::text, ''),
+		COALESCE(pg_has_role(c.relowner, 'USAGE'), false)
+	FROM t LEFT JOIN pg_class c ON c.oid = t.oid
+`
+
+// checkPostgresPrivileges runs postgresPrivileges with scan, the row of the query for the tracking table,
+// and explains the first missing privilege.
+func checkPostgresPrivileges(table string, scan func(dest ...any) error) error {
+	var (
+		role, schema, tableSchema, owner string
+		create, createTable, owns        bool
+	)
+
+	if err := scan(&role, &schema, &create, &tableSchema, &createTable, &owner, &owns); err != nil {
+		return fmt.Errorf("checking privileges: %w", err)
+	}
+
+	switch {
+	case schema == "":
+		return fmt.Errorf("%w: no schema of the search_path of role %s exists to create objects in", ErrPrivileges, role)
+	case !create:
+		return fmt.Errorf("%w: role %s lacks CREATE on schema %s", ErrPrivileges, role, schema)
+	case owner == "" && !createTable:
+		return fmt.Errorf("%w: role %s lacks CREATE on schema %s to create the tracking table %s", ErrPrivileges, role, tableSchema, table)
+	case owner != "" && !owns:
+		return fmt.Errorf("%w: role %s does not own the tracking table %s, owned by %s", ErrPrivileges, role, table, owner)
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package muz
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckPostgresPrivileges(t *testing.T) {
+	tests := []struct {
+		name string
+		row  []any
+		want string
+	}{
+		{name: "owner", row: []any{"app", "public", true, "public", true, "app", true}},
+		{name: "new table", row: []any{"app", "public", true, "public", true, "", false}},
+		{name: "no schema", row: []any{"app", "", false, "", false, "", false}, want: "no schema of the search_path"},
+		{name: "no create", row: []any{"app", "public", false, "public", false, "app", true}, want: "lacks CREATE on schema public"},
+		{name: "no create for the table", row: []any{"app", "public", true, "ops", false, "", false}, want: `on schema ops to create the tracking table "ops"."migrations"`},
+		{name: "not the owner", row: []any{"app", "public", true, "ops", false, "admin", false}, want: "owned by admin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scan := func(dest ...any) error {
+				for i, v := range tt.row {
+					reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(v))
+				}
+
+				return nil
+			}
+
+			err := checkPostgresPrivileges(`"ops"."migrations"`, scan)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("checkPostgresPrivileges() error = %v", err)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, ErrPrivileges) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("checkPostgresPrivileges() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
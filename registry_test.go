@@ -0,0 +1,84 @@
+package muz
+
+import (
+	"context"
+	"testing"
+)
+
+type nopDriver struct {
+	dsn string
+}
+
+func (nopDriver) Start(context.Context) error          { return nil }
+func (nopDriver) Process(context.Context, *Muzo) error { return nil }
+func (nopDriver) End(context.Context, error) error     { return nil }
+
+func TestOpenDriver(t *testing.T) {
+	RegisterDriver("nop", func(dsn string) (Driver, error) {
+		return nopDriver{dsn: dsn}, nil
+	})
+	defer RegisterDriver("nop", nil)
+
+	tests := []struct {
+		name      string
+		dsn       string
+		check     func(t *testing.T, d Driver)
+		wantError bool
+	}{
+		{
+			name: "registered scheme",
+			dsn:  "nop://host/db",
+			check: func(t *testing.T, d Driver) {
+				if v, ok := d.(nopDriver); !ok || v.dsn != "nop://host/db" {
+					t.Errorf("got %#v, want nopDriver with dsn", d)
+				}
+			},
+		},
+		{
+			name: "scheme is case insensitive",
+			dsn:  "NOP://host/db",
+			check: func(t *testing.T, d Driver) {
+				if _, ok := d.(nopDriver); !ok {
+					t.Errorf("got %T, want nopDriver", d)
+				}
+			},
+		},
+		{
+			name: "postgres builtin",
+			dsn:  "postgres://user@localhost/db",
+			check: func(t *testing.T, d Driver) {
+				if _, ok := d.(*PostgresDriver); !ok {
+					t.Errorf("got %T, want *PostgresDriver", d)
+				}
+			},
+		},
+		{
+			name:      "unknown scheme",
+			dsn:       "unknown://host",
+			wantError: true,
+		},
+		{
+			name:      "missing scheme",
+			dsn:       "host/db",
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := OpenDriver(tt.dsn)
+			if tt.wantError {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			tt.check(t, d)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package muz
+
+import "time"
+
+// Outcome of a migration file in a run.
+type Outcome string
+
+const (
+	// OutcomeApplied is a file executed by the run.
+	OutcomeApplied Outcome = "applied"
+	// OutcomeSkipped is a file that was already applied, or older than the latest applied version.
+	OutcomeSkipped Outcome = "skipped"
+	// OutcomeOutOfOrder is a file older than the latest applied version, left unapplied by OutOfOrderWarn.
+	OutcomeOutOfOrder Outcome = "out_of_order"
+	// OutcomeModified is an applied file edited since it ran, reported by ChecksumWarn.
+	OutcomeModified Outcome = "modified"
+	// OutcomeFailed is the file that stopped the run.
+	OutcomeFailed Outcome = "failed"
+	// OutcomeRolledBack is a file rolled back by Down.
+	OutcomeRolledBack Outcome = "rolled_back"
+)
+
+// FileResult is the outcome of a single migration file.
+type FileResult struct {
+	Dir      string        `json:"dir"`
+	File     string        `json:"file"`
+	Version  int64         `json:"version"`
+	Outcome  Outcome       `json:"outcome"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Result summarizes a migration run.
+//
+// Per file outcomes need a driver implementing StatusReporter, other drivers
+// process whole directories and only Dirs is filled.
+// When the run fails, transactional drivers roll back the applied files.
+type Result struct {
+	Files []FileResult `json:"files"`
+	// Dirs are the directories with applied files, in the order they ran.
+	Dirs []string `json:"dirs"`
+	// Duration is the total time of the run.
+	Duration time.Duration `json:"duration"`
+}
+
+// Applied returns the number of applied files.
+func (r *Result) Applied() int {
+	n := 0
+	for _, f := range r.Files {
+		if f.Outcome == OutcomeApplied {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (r *Result) add(f FileResult) {
+	r.Files = append(r.Files, f)
+}
+
+func (r *Result) touch(dir string) {
+	if len(r.Dirs) == 0 || r.Dirs[len(r.Dirs)-1] != dir {
+		r.Dirs = append(r.Dirs, dir)
+	}
+}
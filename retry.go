@@ -0,0 +1,123 @@
+package muz
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Retry is the policy of Migrate for transient errors, like serialization failures, deadlocks
+// or a connection dropped during a long run.
+//
+// A failed run is started again after the backoff: Start is called again and the run resumes
+// at the failed file. Files applied before the failure in the same transaction are rolled back by the driver
+// and applied again, like the files of drivers without StatusReporter. Result reports the last attempt.
+type Retry struct {
+	// MaxAttempts is the number of runs, the first one included.
+	//  - Default: 0, a failed run is not retried.
+	MaxAttempts int
+	// Backoff returns the wait before the attempt, the first retry being attempt 2.
+	//  - Default: ExponentialBackoff(time.Second, 30*time.Second)
+	Backoff func(attempt int) time.Duration
+	// Retryable reports whether the error of a run is transient.
+	//  - Default: IsTransient
+	Retryable func(err error) bool
+	// OnRetry if set, is called before waiting for the attempt.
+	OnRetry func(ctx context.Context, attempt int, err error)
+}
+
+// ExponentialBackoff returns a Backoff doubling the wait from base on every attempt, up to limit.
+func ExponentialBackoff(base, limit time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		wait := base
+		for i := 2; i < attempt && wait < limit; i++ {
+			wait *= 2
+		}
+
+		return min(wait, limit)
+	}
+}
+
+// retryable reports whether a run failing with err on attempt is started again.
+func (r Retry) retryable(ctx context.Context, attempt int, err error) bool {
+	if attempt >= r.MaxAttempts || ctx.Err() != nil {
+		return false
+	}
+
+	if r.Retryable != nil {
+		return r.Retryable(err)
+	}
+
+	return IsTransient(err)
+}
+
+// wait sleeps before attempt, returning early with the error of ctx.
+func (r Retry) wait(ctx context.Context, attempt int, err error) error {
+	if r.OnRetry != nil {
+		r.OnRetry(ctx, attempt, err)
+	}
+
+	backoff := r.Backoff
+	if backoff == nil {
+		backoff = ExponentialBackoff(time.Second, 30*time.Second)
+	}
+
+	timer := time.NewTimer(backoff(attempt))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// transientStates are the SQLSTATE codes of IsTransient, classes ending with a zero match the whole class.
+var transientStates = []string{
+	"40001", // serialization_failure
+	"40P01", // deadlock_detected
+	"55P03", // lock_not_available, like PostgresDriver.LockTimeout
+	"08000", // connection_exception
+	"57P01", // admin_shutdown
+	"57P02", // crash_shutdown
+	"57P03", // cannot_connect_now
+}
+
+// IsTransient reports whether err is worth retrying: serialization failures, deadlocks, lock timeouts
+// and connection errors. SQLSTATE codes are read from errors with a SQLState method, like the ones of
+// pgx and lib/pq, drivers reporting errors otherwise need a Retry.Retryable of their own.
+// Canceled and expired contexts, like a file running past Migrate.FileTimeout, are never transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrFileTimeout) {
+		return false
+	}
+
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) {
+		code := state.SQLState()
+		for _, s := range transientStates {
+			if code == s || (strings.HasSuffix(s, "000") && strings.HasPrefix(code, s[:2])) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
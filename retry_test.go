@@ -0,0 +1,145 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+// stateError is a database error with a SQLSTATE code, like the errors of pgx.
+type stateError string
+
+func (e stateError) Error() string    { return "sqlstate " + string(e) }
+func (e stateError) SQLState() string { return string(e) }
+
+// flakyDriver fails the files in fail with their error once.
+type flakyDriver struct {
+	recordDriver
+
+	fail   map[string]error
+	starts int
+}
+
+func (d *flakyDriver) Start(context.Context) error {
+	d.starts++
+	return nil
+}
+
+func (d *flakyDriver) Process(ctx context.Context, data *Muzo) error {
+	for _, file := range data.Files {
+		if err, ok := d.fail[file.Path]; ok {
+			delete(d.fail, file.Path)
+			return fmt.Errorf("applying migration %d: %w", file.Version, err)
+		}
+	}
+
+	return d.recordDriver.Process(ctx, data)
+}
+
+func TestMigrateRetry(t *testing.T) {
+	fsys := MapFS(map[string]string{
+		"migrations/app/001_users.sql":  "CREATE TABLE users();",
+		"migrations/app/002_orders.sql": "CREATE TABLE orders();",
+	})
+	noWait := func(int) time.Duration { return 0 }
+
+	tests := []struct {
+		name       string
+		retry      Retry
+		fail       error
+		wantErr    bool
+		wantStarts int
+	}{
+		{name: "deadlock", retry: Retry{MaxAttempts: 3, Backoff: noWait}, fail: stateError("40P01"), wantStarts: 2},
+		{name: "connection", retry: Retry{MaxAttempts: 3, Backoff: noWait}, fail: io.ErrUnexpectedEOF, wantStarts: 2},
+		{name: "not transient", retry: Retry{MaxAttempts: 3, Backoff: noWait}, fail: stateError("42P01"), wantErr: true, wantStarts: 1},
+		{name: "no retry", fail: stateError("40001"), wantErr: true, wantStarts: 1},
+		{
+			name:       "classifier",
+			retry:      Retry{MaxAttempts: 2, Backoff: noWait, Retryable: func(err error) bool { return errors.Is(err, io.EOF) }},
+			fail:       io.EOF,
+			wantStarts: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver := &flakyDriver{fail: map[string]error{"002_orders.sql": tt.fail}}
+
+			var retried []int
+			tt.retry.OnRetry = func(_ context.Context, attempt int, _ error) { retried = append(retried, attempt) }
+
+			result, err := Migrate{FS: fsys, Retry: tt.retry}.Migrate(context.Background(), driver)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Migrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if driver.starts != tt.wantStarts || len(retried) != tt.wantStarts-1 {
+				t.Errorf("started %d times, retried %v, want %d starts", driver.starts, retried, tt.wantStarts)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			// The second attempt resumes at the failed file.
+			if len(driver.records) != 2 || len(result.Files) != 2 || result.Files[0].Outcome != OutcomeSkipped || result.Files[1].Outcome != OutcomeApplied {
+				t.Errorf("records %+v, result %+v, want 001 skipped and 002 applied by the last attempt", driver.records, result.Files)
+			}
+		})
+	}
+}
+
+func TestMigrateRetryCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	driver := &flakyDriver{fail: map[string]error{"001_users.sql": stateError("40001")}}
+	m := Migrate{
+		FS: MapFS(map[string]string{"migrations/app/001_users.sql": "CREATE TABLE users();"}),
+		Retry: Retry{
+			MaxAttempts: 3,
+			Backoff:     func(int) time.Duration { return time.Hour },
+			OnRetry:     func(context.Context, int, error) { cancel() },
+		},
+	}
+
+	if _, err := m.Migrate(ctx, driver); err == nil || driver.starts != 1 {
+		t.Errorf("Migrate() error = %v after %d starts, want the error of the first attempt", err, driver.starts)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, 5*time.Second)
+
+	for attempt, want := range map[int]time.Duration{2: time.Second, 3: 2 * time.Second, 4: 4 * time.Second, 5: 5 * time.Second, 10: 5 * time.Second} {
+		if got := backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: stateError("40001"), want: true},
+		{err: fmt.Errorf("applying: %w", stateError("40P01")), want: true},
+		{err: stateError("08006"), want: true},
+		{err: stateError("23505"), want: false},
+		{err: io.ErrUnexpectedEOF, want: true},
+		{err: context.Canceled, want: false},
+		{err: errors.New("syntax error"), want: false},
+		{err: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.want {
+			t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
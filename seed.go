@@ -0,0 +1,222 @@
+package muz
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+)
+
+// SeedRecord is a seed file that ran, see DirOptions.Seed.
+type SeedRecord struct {
+	Directory string    `json:"directory"`
+	FileName  string    `json:"file_name"`
+	Checksum  string    `json:"checksum"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// SeedStore is implemented by drivers that keep the seed files that ran apart from the migrations.
+// Seeds and StoreSeed are called between Start and End, StoreSeed after the seed file ran in the session.
+type SeedStore interface {
+	Seeds(ctx context.Context) ([]SeedRecord, error)
+	StoreSeed(ctx context.Context, record SeedRecord) error
+}
+
+// seed reports whether the files of dir are seeds.
+func (m Migrate) seed(dir string) bool {
+	return m.dirOptions(dir).Seed
+}
+
+// processSeeds runs the seed files of info whose checksum differs from the one stored when they last ran,
+// every seed file with Reseed. Seed files are run with Executor and not recorded as migrations.
+func (m Migrate) processSeeds(ctx context.Context, driver Driver, info *Muzo, h *appliedState, result *Result) error {
+	exec, ok := driver.(Executor)
+	if !ok {
+		return fmt.Errorf("seed directory %s: %w", info.Dir, ErrNotSupported)
+	}
+
+	store, ok := driver.(SeedStore)
+	if !ok {
+		return fmt.Errorf("seed directory %s: %w", info.Dir, ErrNotSupported)
+	}
+
+	records, err := store.Seeds(ctx)
+	if err != nil {
+		return err
+	}
+
+	stored := make(map[string]string, len(records))
+	for _, r := range records {
+		if r.Directory == info.Dir {
+			stored[r.FileName] = r.Checksum
+		}
+	}
+
+	for _, file := range info.Files {
+		fr := FileResult{
+			Dir:     info.Dir,
+			File:    file.Path,
+			Version: file.Version,
+			Outcome: OutcomeSkipped,
+		}
+
+		if info.GoMigration(file.Path) != nil {
+			return fmt.Errorf("seed directory %s: go migration %s cannot be a seed", info.Dir, file.Path)
+		}
+
+		sum, err := info.checksum(file.Path)
+		if err != nil {
+			return err
+		}
+
+		if recorded, ok := stored[file.Path]; ok && recorded == sum && !m.Reseed {
+			m.log().Debug("skipping unchanged seed", "directory", info.Dir, "file", file.Path)
+			m.emit(ctx, FileSkipped{FileResult: fr, Reason: SkipApplied})
+			result.add(fr)
+			continue
+		}
+
+		if m.Hooks.BeforeFile != nil {
+			if err := m.Hooks.BeforeFile(ctx, info.Dir, file); err != nil {
+				return err
+			}
+		}
+
+		m.log().Debug("running seed", "directory", info.Dir, "file", file.Path)
+
+		fileStart := time.Now()
+		err = m.withFileTimeout(ctx, func(ctx context.Context) error {
+			content, err := info.ReadFile(file.Path)
+			if err != nil {
+				return err
+			}
+
+			return exec.Exec(ctx, content)
+		})
+		fr.Duration = time.Since(fileStart)
+
+		if err == nil {
+			err = store.StoreSeed(ctx, SeedRecord{
+				Directory: info.Dir,
+				FileName:  file.Path,
+				Checksum:  sum,
+				AppliedAt: time.Now(),
+			})
+		}
+
+		fr.Outcome = OutcomeApplied
+		if err != nil {
+			err = fmt.Errorf("seed %s: %w", path.Join(info.Dir, file.Path), err)
+			fr.Outcome = OutcomeFailed
+			fr.Error = err.Error()
+
+			m.log().Error("seed failed", "directory", info.Dir, "file", file.Path, "duration", fr.Duration, "error", err)
+		} else {
+			m.log().Info("applied seed", "directory", info.Dir, "file", file.Path, "duration", fr.Duration)
+		}
+
+		result.add(fr)
+
+		// Seeds are not counted in the pending files of the run.
+		if err != nil {
+			m.emit(ctx, FileFailed{FileResult: fr, Progress: h.progress(), Err: err})
+		} else {
+			m.emit(ctx, FileApplied{FileResult: fr, Progress: h.progress()})
+		}
+
+		if m.Hooks.AfterFile != nil {
+			m.Hooks.AfterFile(ctx, fr)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		result.touch(info.Dir)
+	}
+
+	return nil
+}
+
+// //////////////////////////////
+
+// seedSuffix is appended to the tracking table name for the table holding the seed files that ran.
+const seedSuffix = "_seeds"
+
+// seedTable returns the name of the table holding the seed files next to the tracking table.
+func seedTable(table string) string {
+	return table + seedSuffix
+}
+
+// seedCreateTable returns the DDL creating the seed table, portable across the dialects.
+func seedCreateTable(table string) string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			directory varchar(255) NOT NULL,
+			file_name varchar(255) NOT NULL,
+			checksum varchar(64) NOT NULL,
+			applied_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (directory, file_name)
+		)
+	`, table)
+}
+
+// seedQueries returns the statements reading, deleting and inserting seed records,
+// using placeholder for the bind parameters. Insert arguments are passed in
+// directory, file_name, checksum, applied_at order.
+func seedQueries(table string, placeholder func(n int) string) (query, remove, insert string) {
+	query = fmt.Sprintf(`
+		SELECT directory, file_name, checksum, applied_at FROM %s ORDER BY directory, file_name
+	`, table)
+	remove = fmt.Sprintf(`
+		DELETE FROM %s WHERE directory = %s AND file_name = %s
+	`, table, placeholder(1), placeholder(2))
+	insert = fmt.Sprintf(`
+		INSERT INTO %s (directory, file_name, checksum, applied_at)
+		VALUES (%s, %s, %s, %s)
+	`, table, placeholder(1), placeholder(2), placeholder(3), placeholder(4))
+
+	return query, remove, insert
+}
+
+// storeSeedSQL replaces the seed record of the file of r, creating the seed table when needed.
+func storeSeedSQL(ctx context.Context, q querier, table string, placeholder func(n int) string, r SeedRecord) error {
+	if _, err := q.ExecContext(ctx, seedCreateTable(table)); err != nil {
+		return err
+	}
+
+	_, remove, insert := seedQueries(table, placeholder)
+	if _, err := q.ExecContext(ctx, remove, r.Directory, r.FileName); err != nil {
+		return err
+	}
+
+	_, err := q.ExecContext(ctx, insert, r.Directory, r.FileName, r.Checksum, r.AppliedAt.UTC())
+	return err
+}
+
+// querySeeds reads the seed table when exists reports it exists.
+func querySeeds(ctx context.Context, q querier, table string, exists bool) ([]SeedRecord, error) {
+	if !exists {
+		return nil, nil
+	}
+
+	query, _, _ := seedQueries(table, PostgresDialect{}.Placeholder)
+
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []SeedRecord
+	for rows.Next() {
+		var r SeedRecord
+		if err := rows.Scan(&r.Directory, &r.FileName, &r.Checksum, &r.AppliedAt); err != nil {
+			return nil, err
+		}
+
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
@@ -0,0 +1,109 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// seedDriver keeps the seed records in memory.
+type seedDriver struct {
+	execDriver
+
+	seeds []SeedRecord
+}
+
+func (d *seedDriver) Seeds(context.Context) ([]SeedRecord, error) {
+	return slices.Clone(d.seeds), nil
+}
+
+func (d *seedDriver) StoreSeed(_ context.Context, r SeedRecord) error {
+	d.seeds = slices.DeleteFunc(d.seeds, func(s SeedRecord) bool {
+		return s.Directory == r.Directory && s.FileName == r.FileName
+	})
+	d.seeds = append(d.seeds, r)
+
+	return nil
+}
+
+func TestMigrateSeed(t *testing.T) {
+	files := map[string]string{
+		"migrations/app/001_users.sql":   "CREATE TABLE users();",
+		"migrations/seed/001_admins.sql": "INSERT INTO users VALUES ('admin');",
+		"migrations/seed/002_guests.sql": "INSERT INTO users VALUES ('guest');",
+	}
+
+	driver := &seedDriver{}
+	run := func(t *testing.T, m Migrate) []string {
+		t.Helper()
+
+		driver.calls = nil
+
+		result, err := m.Migrate(context.Background(), driver)
+		if err != nil {
+			t.Fatalf("Migrate() error = %v", err)
+		}
+
+		var applied []string
+		for _, f := range result.Files {
+			if f.Outcome == OutcomeApplied {
+				applied = append(applied, f.Dir+"/"+f.File)
+			}
+		}
+
+		return applied
+	}
+
+	m := Migrate{FS: MapFS(files), DirConfig: map[string]DirOptions{"seed": {Seed: true}}}
+
+	if got, want := run(t, m), []string{"app/001_users.sql", "seed/001_admins.sql", "seed/002_guests.sql"}; !slices.Equal(got, want) {
+		t.Errorf("first run applied %v, want %v", got, want)
+	}
+
+	// Seeds are run as scripts, not recorded as migrations.
+	if want := []string{"app/001_users.sql", files["migrations/seed/001_admins.sql"], files["migrations/seed/002_guests.sql"]}; !slices.Equal(driver.calls, want) {
+		t.Errorf("calls = %v, want %v", driver.calls, want)
+	}
+
+	if slices.ContainsFunc(driver.records, func(r Record) bool { return r.Directory == "seed" }) {
+		t.Errorf("records = %v, want no seed records", driver.records)
+	}
+
+	if got := run(t, m); len(got) != 0 {
+		t.Errorf("unchanged run applied %v, want nothing", got)
+	}
+
+	files["migrations/seed/002_guests.sql"] = "INSERT INTO users VALUES ('guest'), ('visitor');"
+	m.FS = MapFS(files)
+
+	if got, want := run(t, m), []string{"seed/002_guests.sql"}; !slices.Equal(got, want) {
+		t.Errorf("changed run applied %v, want %v", got, want)
+	}
+
+	m.Reseed = true
+
+	if got, want := run(t, m), []string{"seed/001_admins.sql", "seed/002_guests.sql"}; !slices.Equal(got, want) {
+		t.Errorf("reseed run applied %v, want %v", got, want)
+	}
+
+	status, err := m.Status(context.Background(), driver)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+
+	if len(status.Files) != 1 || status.Files[0].Dir != "app" {
+		t.Errorf("Status() files = %v, want only the migrations", status.Files)
+	}
+}
+
+func TestMigrateSeedNotSupported(t *testing.T) {
+	m := Migrate{
+		FS:        MapFS(map[string]string{"migrations/seed/001_admins.sql": "INSERT INTO users VALUES ('admin');"}),
+		DirConfig: map[string]DirOptions{"seed": {Seed: true}},
+	}
+
+	if _, err := m.Migrate(context.Background(), &execDriver{}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Migrate() error = %v, want %v", err, ErrNotSupported)
+	}
+}
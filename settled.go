@@ -0,0 +1,61 @@
+package muz
+
+import "sync"
+
+// settled holds the versions a run skips, read from the history of the driver before the directories are
+// listed, so the headers of services with thousands of applied migrations are not read on every run.
+// Applied files are still checksummed when the Checksum policy verifies them.
+type settled struct {
+	mu sync.Mutex
+	// records are the records of the applied files.
+	records *appliedRecords
+	// latest is the highest applied version of every version stream, nil when older files are not skipped.
+	latest map[string]int64
+	// stream returns the version stream of a directory, see Migrate.stream.
+	stream func(dir string) string
+}
+
+// set records the applied state h of a session, older files are skipped with OutOfOrderIgnore.
+func (s *settled) set(m Migrate, h *appliedState) {
+	if s == nil || h == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The records are not changed by the session.
+	s.records = h.records
+
+	s.latest = nil
+	if m.OutOfOrder == OutOfOrderIgnore {
+		s.latest = make(map[string]int64, len(h.latest))
+		for stream, version := range h.latest {
+			s.latest[stream] = version
+		}
+	}
+
+	s.stream = m.stream
+}
+
+// skipped reports whether file of dir is skipped by the run without being read.
+func (s *settled) skipped(dir string, file FileInfo) bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.records != nil && s.records.recorded(dir, file) {
+		return true
+	}
+
+	if s.latest == nil {
+		return false
+	}
+
+	latest, ok := s.latest[s.stream(dir)]
+
+	return ok && file.Version <= latest
+}
@@ -0,0 +1,86 @@
+package muz
+
+import (
+	"context"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// openFS records the migration files opened through it.
+type openFS struct {
+	fs.FS
+
+	opened []string
+}
+
+func (f *openFS) Open(name string) (fs.File, error) {
+	if strings.HasSuffix(name, ".sql") {
+		f.opened = append(f.opened, name)
+	}
+
+	return f.FS.Open(name)
+}
+
+func TestMigrateSettled(t *testing.T) {
+	fsys := &openFS{FS: MapFS(map[string]string{
+		"migrations/schema/001_users.sql":  "CREATE TABLE users();",
+		"migrations/schema/002_orders.sql": "CREATE TABLE orders();",
+		"migrations/schema/003_items.sql":  "-- muz:description items\nCREATE TABLE items();",
+		"migrations/data/001_seed.sql":     "INSERT INTO users DEFAULT VALUES;",
+	})}
+
+	driver := &recordDriver{records: []Record{
+		{Version: 1, Directory: "schema", FileName: "001_users.sql"},
+		{Version: 2, Directory: "schema", FileName: "002_orders.sql"},
+	}}
+
+	t.Run("applied files are not read", func(t *testing.T) {
+		fsys.opened = nil
+
+		result, err := Migrate{FS: fsys}.Migrate(context.Background(), driver)
+		if err != nil {
+			t.Fatalf("Migrate() error = %v", err)
+		}
+
+		if result.Applied() != 2 {
+			t.Errorf("Applied() = %d, want 2", result.Applied())
+		}
+
+		if want := []string{"migrations/data/001_seed.sql", "migrations/schema/003_items.sql"}; !slices.Equal(fsys.opened, want) {
+			t.Errorf("opened %v, want %v", fsys.opened, want)
+		}
+	})
+
+	t.Run("checksums are verified", func(t *testing.T) {
+		fsys.opened = nil
+
+		driver := &recordDriver{records: []Record{
+			{Version: 1, Directory: "schema", FileName: "001_users.sql", Checksum: "edited"},
+			{Version: 2, Directory: "schema", FileName: "002_orders.sql", Checksum: "edited"},
+		}}
+
+		if _, err := (Migrate{FS: fsys, Checksum: ChecksumWarn}).Migrate(context.Background(), driver); err != nil {
+			t.Fatalf("Migrate() error = %v", err)
+		}
+
+		if len(fsys.opened) != 4 {
+			t.Errorf("opened %v, want the applied files checksummed", fsys.opened)
+		}
+	})
+
+	t.Run("Iter reads every file", func(t *testing.T) {
+		fsys.opened = nil
+
+		for _, err := range (Migrate{FS: fsys}).Iter() {
+			if err != nil {
+				t.Fatalf("Iter() error = %v", err)
+			}
+		}
+
+		if len(fsys.opened) != 4 {
+			t.Errorf("opened %v, want 4 files", fsys.opened)
+		}
+	})
+}
@@ -0,0 +1,195 @@
+package muz
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ErrSignature is returned when the migration tree has no signature or its signature does not verify.
+var ErrSignature = errors.New("signature verification failed")
+
+// signatureFile is the default Migrate.SignatureFile.
+const signatureFile = "muz.sig"
+
+// SignatureVerifier verifies a detached signature of the manifest of the migration tree, see Migrate.Manifest.
+// KeyVerifier verifies cosign signatures, OpenPGP verifiers plug in with SignatureVerifierFunc.
+type SignatureVerifier interface {
+	VerifySignature(manifest, signature []byte) error
+}
+
+// SignatureVerifierFunc adapts a function to a SignatureVerifier.
+type SignatureVerifierFunc func(manifest, signature []byte) error
+
+func (f SignatureVerifierFunc) VerifySignature(manifest, signature []byte) error {
+	return f(manifest, signature)
+}
+
+// KeyVerifier verifies signatures made with a private key, like the ones of "cosign sign-blob --key".
+// ECDSA and RSA PKCS #1 v1.5 signatures are made over the SHA-256 of the manifest, Ed25519 ones over
+// the manifest. Base64 encoded signatures, like the output of cosign, are decoded first.
+//
+//	m.Signature = muz.KeyVerifier{PublicKey: cosignPub}
+type KeyVerifier struct {
+	// PublicKey is the PEM encoded PKIX public key, like cosign.pub.
+	PublicKey []byte
+}
+
+func (k KeyVerifier) VerifySignature(manifest, signature []byte) error {
+	block, _ := pem.Decode(k.PublicKey)
+	if block == nil {
+		return errors.New("public key: no PEM block")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("public key: %w", err)
+	}
+
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature))); err == nil {
+		signature = decoded
+	}
+
+	digest := sha256.Sum256(manifest)
+
+	var ok bool
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(key, digest[:], signature)
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(key, manifest, signature)
+	case *rsa.PublicKey:
+		ok = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	default:
+		return fmt.Errorf("public key: unsupported type %T", key)
+	}
+
+	if !ok {
+		return ErrSignature
+	}
+
+	return nil
+}
+
+// Manifest returns the checksum manifest of the migration tree, the content signed for Signature.
+// It has a "<sha256>  <dir>/<file>" line for every listed file sorted by path, the format of sha256sum,
+// with the checksums of Checksum computed before placeholders are replaced. Go migrations are not part of it.
+func (m Migrate) Manifest() ([]byte, error) {
+	content, err := manifest(m.listed())
+	if err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+
+	return content, nil
+}
+
+func manifest(list iter.Seq2[*Muzo, error]) ([]byte, error) {
+	entries, err := fileSums(list)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(entries, func(a, b fileSum) int { return strings.Compare(a.name, b.name) })
+
+	var b strings.Builder
+	for _, e := range entries {
+		b.WriteString(e.sum + "  " + e.name + "\n")
+	}
+
+	return []byte(b.String()), nil
+}
+
+// fileSum is the checksum of a migration file named by its path in the migration path.
+type fileSum struct{ sum, name string }
+
+// fileSums returns the checksums of the listed files in the listed order, Go migrations are left out.
+func fileSums(list iter.Seq2[*Muzo, error]) ([]fileSum, error) {
+	var entries []fileSum
+	for info, err := range list {
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range info.Files {
+			if info.GoMigration(file.Path) != nil {
+				continue
+			}
+
+			sum, err := info.checksum(file.Path)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path.Join(info.Dir, file.Path), err)
+			}
+
+			entries = append(entries, fileSum{sum: sum, name: path.Join(info.Dir, file.Path)})
+		}
+	}
+
+	return entries, nil
+}
+
+// withSignature verifies the Signature of the tree before its directories are listed, reading the tree twice.
+func (m *Migrate) withSignature(list iter.Seq2[*Muzo, error]) iter.Seq2[*Muzo, error] {
+	if m.Signature == nil {
+		return list
+	}
+
+	return func(yield func(*Muzo, error) bool) {
+		if err := m.verifySignature(list); err != nil {
+			yield(nil, err)
+			return
+		}
+
+		for info, err := range list {
+			if !yield(info, err) {
+				return
+			}
+		}
+	}
+}
+
+func (m *Migrate) verifySignature(list iter.Seq2[*Muzo, error]) error {
+	name := withDefault(m.SignatureFile, signatureFile)
+
+	fileSystem, closeFS, err := m.openFS(m.rootPath())
+	if err != nil {
+		return err
+	}
+
+	signature, err := fs.ReadFile(fileSystem, name)
+	closeFS()
+
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("migration tree: %w, no signature file %s", ErrSignature, name)
+		}
+
+		return fmt.Errorf("migration tree: %w", err)
+	}
+
+	content, err := manifest(list)
+	if err != nil {
+		return fmt.Errorf("manifest: %w", err)
+	}
+
+	if err := m.Signature.VerifySignature(content, signature); err != nil {
+		if errors.Is(err, ErrSignature) {
+			return fmt.Errorf("migration tree: %w", err)
+		}
+
+		return fmt.Errorf("migration tree: %w: %w", ErrSignature, err)
+	}
+
+	return nil
+}
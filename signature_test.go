@@ -0,0 +1,149 @@
+package muz
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"testing"
+)
+
+func publicKeyPEM(t *testing.T, key any) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey() error = %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestManifest(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql": "CREATE TABLE users();",
+			"migrations/data/001_seed.sql":    "INSERT INTO users DEFAULT VALUES;",
+			"migrations/muz.sig":              "signature",
+		}),
+		Order: []string{"schema"},
+	}
+
+	got, err := m.Manifest()
+	if err != nil {
+		t.Fatalf("Manifest() error = %v", err)
+	}
+
+	want := checksum([]byte("INSERT INTO users DEFAULT VALUES;")) + "  data/001_seed.sql\n" +
+		checksum([]byte("CREATE TABLE users();")) + "  schema/001_users.sql\n"
+	if string(got) != want {
+		t.Errorf("Manifest() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSignature(t *testing.T) {
+	files := map[string]string{
+		"migrations/schema/001_users.sql":  "CREATE TABLE users();",
+		"migrations/schema/002_orders.sql": "CREATE TABLE orders();",
+	}
+
+	content, err := Migrate{FS: MapFS(files)}.Manifest()
+	if err != nil {
+		t.Fatalf("Manifest() error = %v", err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256(content)
+	ecSignature, err := ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := func(signature string, edits map[string]string) Migrate {
+		fsys := map[string]string{"migrations/muz.sig": signature}
+		for name, content := range files {
+			fsys[name] = content
+		}
+		for name, content := range edits {
+			fsys[name] = content
+		}
+
+		return Migrate{FS: MapFS(fsys)}
+	}
+
+	t.Run("cosign signature", func(t *testing.T) {
+		m := signed(base64.StdEncoding.EncodeToString(ecSignature)+"\n", nil)
+		m.Signature = KeyVerifier{PublicKey: publicKeyPEM(t, &ecKey.PublicKey)}
+
+		result, err := m.Migrate(context.Background(), &recordDriver{})
+		if err != nil || result.Applied() != 2 {
+			t.Fatalf("Migrate() applied %d, error = %v", result.Applied(), err)
+		}
+	})
+
+	t.Run("ed25519 signature", func(t *testing.T) {
+		m := signed(string(ed25519.Sign(edKey, content)), nil)
+		m.Signature = KeyVerifier{PublicKey: publicKeyPEM(t, edPublic)}
+
+		for _, err := range m.Iter() {
+			if err != nil {
+				t.Fatalf("Iter() error = %v", err)
+			}
+		}
+	})
+
+	tests := []struct {
+		name  string
+		m     Migrate
+		key   []byte
+		check func(error) bool
+	}{
+		{
+			name: "tampered file",
+			m:    signed(base64.StdEncoding.EncodeToString(ecSignature), map[string]string{"migrations/schema/002_orders.sql": "DROP TABLE users;"}),
+		},
+		{
+			name: "added file",
+			m:    signed(base64.StdEncoding.EncodeToString(ecSignature), map[string]string{"migrations/schema/003_items.sql": "CREATE TABLE items();"}),
+		},
+		{
+			name: "unsigned",
+			m:    Migrate{FS: MapFS(files)},
+		},
+		{
+			name: "other key",
+			m:    signed(string(ed25519.Sign(edKey, content)), nil),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := tt.m
+			m.Signature = KeyVerifier{PublicKey: publicKeyPEM(t, &ecKey.PublicKey)}
+
+			driver := &recordDriver{}
+			if _, err := m.Migrate(context.Background(), driver); !errors.Is(err, ErrSignature) {
+				t.Errorf("Migrate() error = %v, want ErrSignature", err)
+			}
+
+			if len(driver.records) != 0 {
+				t.Errorf("records = %v, want none", driver.records)
+			}
+		})
+	}
+}
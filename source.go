@@ -0,0 +1,25 @@
+package muz
+
+import "iter"
+
+// Source lists migration directories with their files, in the order they are applied.
+// The default source walks Path or FS using Order, Skip and Extension.
+type Source interface {
+	List() iter.Seq2[*Muzo, error]
+}
+
+// SourceFunc adapts a function to a Source.
+type SourceFunc func() iter.Seq2[*Muzo, error]
+
+func (f SourceFunc) List() iter.Seq2[*Muzo, error] {
+	return f()
+}
+
+// fileSource is the default Source walking a filesystem.
+type fileSource struct {
+	m *Migrate
+}
+
+func (s fileSource) List() iter.Seq2[*Muzo, error] {
+	return s.m.iterMigrationInfo()
+}
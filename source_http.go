@@ -0,0 +1,209 @@
+package muz
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// HTTPArchive downloads migrations distributed as a tar.gz or zip release artifact.
+//
+//	archive := muz.HTTPArchive{
+//		URL:    "https://example.com/releases/migrations-v1.2.0.tar.gz",
+//		SHA256: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+//	}
+//
+//	fsys, err := archive.FS(ctx)
+//	...
+//	m := muz.Migrate{FS: fsys, Path: "migrations"}
+type HTTPArchive struct {
+	// URL of the archive, must be https unless Insecure is set.
+	URL string
+	// SHA256 if set, hex encoded checksum the downloaded archive must match.
+	SHA256 string
+	// Client used for the download.
+	//  - Default: http.DefaultClient
+	Client *http.Client
+	// MaxSize is the maximum accepted archive size in bytes.
+	//  - Default: 100 MiB
+	MaxSize int64
+	// MaxUnpackedSize is the maximum total size of the unpacked files in bytes,
+	// refusing archives that decompress to far more than they weigh.
+	//  - Default: 1 GiB
+	MaxUnpackedSize int64
+	// Insecure allows plain http URLs.
+	Insecure bool
+}
+
+// FS downloads and unpacks the archive into an in-memory filesystem.
+// The format is detected from the content, gzip compressed tar and zip are supported.
+func (a HTTPArchive) FS(ctx context.Context) (fs.FS, error) {
+	u, err := url.Parse(a.URL)
+	if err != nil {
+		return nil, fmt.Errorf("http archive: %w", err)
+	}
+
+	if u.Scheme != "https" && !(a.Insecure && u.Scheme == "http") {
+		return nil, fmt.Errorf("http archive: unsupported scheme %q", u.Scheme)
+	}
+
+	data, err := a.download(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("http archive: %w", err)
+	}
+
+	if a.SHA256 != "" {
+		sum := sha256.Sum256(data)
+		if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, a.SHA256) {
+			return nil, fmt.Errorf("http archive: checksum mismatch, got %s want %s", got, a.SHA256)
+		}
+	}
+
+	limit := a.MaxUnpackedSize
+	if limit <= 0 {
+		limit = 1 << 30
+	}
+
+	fsys, err := unpackArchive(data, limit)
+	if err != nil {
+		return nil, fmt.Errorf("http archive: %w", err)
+	}
+
+	return fsys, nil
+}
+
+func (a HTTPArchive) download(ctx context.Context) ([]byte, error) {
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	maxSize := a.MaxSize
+	if maxSize <= 0 {
+		maxSize = 100 << 20
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("archive larger than %d bytes", maxSize)
+	}
+
+	return data, nil
+}
+
+// unpackArchive detects the archive format from its magic bytes.
+// Archives unpacking to more than limit bytes are refused.
+func unpackArchive(data []byte, limit int64) (fs.FS, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return unpackZip(data, limit)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		return unpackTarGz(bytes.NewReader(data), limit)
+	default:
+		return nil, errors.New("unknown archive format, expected tar.gz or zip")
+	}
+}
+
+// unpackedSizeError is the error of an archive unpacking to more than limit bytes.
+func unpackedSizeError(limit int64) error {
+	return fmt.Errorf("archive unpacks to more than %d bytes", limit)
+}
+
+// unpackZip opens a zip archive whose files add up to at most limit bytes. The files are decompressed
+// when read, archive/zip fails reads past the size of their header.
+func unpackZip(data []byte, limit int64) (fs.FS, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	var total uint64
+	for _, f := range zr.File {
+		if f.UncompressedSize64 > uint64(limit)-total {
+			return nil, unpackedSizeError(limit)
+		}
+
+		total += f.UncompressedSize64
+	}
+
+	return zr, nil
+}
+
+func unpackTarGz(r io.Reader, limit int64) (fs.FS, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+
+	// Reading past limit means the archive is too large.
+	unpacked := &io.LimitedReader{R: gz, N: limit + 1}
+
+	tr := tar.NewReader(unpacked)
+	for {
+		hdr, err := tr.Next()
+		if unpacked.N <= 0 {
+			return nil, unpackedSizeError(limit)
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if !fs.ValidPath(name) {
+			return nil, fmt.Errorf("invalid path %q in archive", hdr.Name)
+		}
+
+		content, err := io.ReadAll(tr)
+		if unpacked.N <= 0 {
+			return nil, unpackedSizeError(limit)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		files[name] = content
+	}
+
+	return newMemFS(files), nil
+}
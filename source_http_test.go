@@ -0,0 +1,197 @@
+package muz
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var archiveFiles = map[string]string{
+	"migrations/1_create.sql":        "CREATE TABLE a();",
+	"migrations/inner/1_data.sql":    "INSERT INTO a DEFAULT VALUES;",
+	"migrations/inner/2_more.sql":    "INSERT INTO a DEFAULT VALUES;",
+	"migrations/inner/readme.txt":    "not a migration",
+	"migrations/other/001_other.sql": "SELECT 1;",
+}
+
+func buildTarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("tar write: %v", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar close: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("gzip close: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip create: %v", err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("zip write: %v", err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestHTTPArchive(t *testing.T) {
+	tarGz := buildTarGz(t, archiveFiles)
+	zipData := buildZip(t, archiveFiles)
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/migrations.tar.gz":
+			w.Write(tarGz)
+		case "/migrations.zip":
+			w.Write(zipData)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	sum := sha256.Sum256(tarGz)
+
+	tests := []struct {
+		name      string
+		archive   HTTPArchive
+		wantError bool
+	}{
+		{
+			name:    "tar.gz with checksum",
+			archive: HTTPArchive{URL: srv.URL + "/migrations.tar.gz", SHA256: hex.EncodeToString(sum[:])},
+		},
+		{
+			name:    "zip",
+			archive: HTTPArchive{URL: srv.URL + "/migrations.zip"},
+		},
+		{
+			name:      "checksum mismatch",
+			archive:   HTTPArchive{URL: srv.URL + "/migrations.tar.gz", SHA256: "00"},
+			wantError: true,
+		},
+		{
+			name:      "not found",
+			archive:   HTTPArchive{URL: srv.URL + "/missing.zip"},
+			wantError: true,
+		},
+		{
+			name:      "too large",
+			archive:   HTTPArchive{URL: srv.URL + "/migrations.zip", MaxSize: 10},
+			wantError: true,
+		},
+		{
+			name:      "plain http rejected",
+			archive:   HTTPArchive{URL: "http://example.com/migrations.zip"},
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.archive.Client = srv.Client()
+
+			fsys, err := tt.archive.FS(t.Context())
+			if tt.wantError {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			m := Migrate{FS: fsys}
+
+			var got []string
+			for info, err := range m.Iter() {
+				if err != nil {
+					t.Fatalf("unexpected iteration error: %v", err)
+				}
+				for _, f := range info.Files {
+					got = append(got, info.Dir+"/"+f.Path)
+				}
+			}
+
+			want := []string{"./1_create.sql", "inner/1_data.sql", "inner/2_more.sql", "other/001_other.sql"}
+			if len(got) != len(want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("got[%d] = %q, want %q", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPArchiveUnpackedSize(t *testing.T) {
+	// 4 MiB of zeros compress to a few KiB.
+	bomb := map[string]string{"migrations/1_seed.sql": strings.Repeat("\x00", 4<<20)}
+
+	archives := map[string][]byte{
+		"/bomb.tar.gz": buildTarGz(t, bomb),
+		"/bomb.zip":    buildZip(t, bomb),
+	}
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archives[r.URL.Path])
+	}))
+	defer srv.Close()
+
+	for name := range archives {
+		t.Run(name, func(t *testing.T) {
+			archive := HTTPArchive{URL: srv.URL + name, Client: srv.Client(), MaxUnpackedSize: 1 << 20}
+
+			_, err := archive.FS(t.Context())
+			if err == nil || !strings.Contains(err.Error(), "unpacks to more than") {
+				t.Errorf("FS() error = %v, want the archive refused", err)
+			}
+
+			archive.MaxUnpackedSize = 8 << 20
+
+			if _, err := archive.FS(t.Context()); err != nil {
+				t.Errorf("FS() with a larger limit error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package muz
+
+import (
+	"iter"
+	"testing"
+)
+
+func TestCustomSource(t *testing.T) {
+	fsys := MapFS(map[string]string{
+		"generated/1_a.sql": "SELECT 1;",
+	})
+
+	m := Migrate{
+		Source: SourceFunc(func() iter.Seq2[*Muzo, error] {
+			return func(yield func(*Muzo, error) bool) {
+				yield(NewMuzo("generated", []FileInfo{{Path: "1_a.sql", Version: 1}}, fsys), nil)
+			}
+		}),
+	}
+
+	var got []*Muzo
+	for info, err := range m.Iter() {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, info)
+	}
+
+	if len(got) != 1 || got[0].Dir != "generated" {
+		t.Fatalf("got %+v, want the custom source directory", got)
+	}
+
+	content, err := got[0].ReadFile("1_a.sql")
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	if string(content) != "SELECT 1;" {
+		t.Errorf("ReadFile = %q", content)
+	}
+}
@@ -0,0 +1,653 @@
+package muz
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"path"
+	"strings"
+	"time"
+)
+
+// DirectiveDelimiter as a comment line, like "-- muz:delimiter $$", changes the delimiter
+// of SplitStatements for the rest of the file, like the "DELIMITER $$" command of the MySQL client.
+const DirectiveDelimiter = "delimiter"
+
+// SplitStatements splits content into single statements on semicolons, for backends
+// executing one statement per call like MySQL or ClickHouse.
+//   - Semicolons inside quoted strings and identifiers, dollar-quoted bodies and comments do not split.
+//   - Backslash escapes inside quotes are honored, like in MySQL.
+//   - Statements are trimmed and have no trailing delimiter, parts holding only comments are dropped.
+//   - "DELIMITER $$" lines between statements and "-- muz:delimiter $$" comments change the delimiter, for procedure
+//     and trigger bodies holding semicolons. "DELIMITER ;" switches back.
+//   - Statements between "-- +goose StatementBegin" and "-- +goose StatementEnd" comments are kept together,
+//     like in goose files, ending with the last semicolon of the block.
+//   - "COPY ... FROM stdin;" keeps its delimiter and is followed by its inline data up to the "\." line,
+//     like "COPY t (a, b) FROM stdin;\n1\tone\n". Data of more than 1 MiB comes in several such statements.
+func SplitStatements(content string) []string {
+	return splitStatements(content, false)
+}
+
+// splitStatements is SplitStatements, with the string syntax of PostgreSQL when standard is set.
+func splitStatements(content string, standard bool) []string {
+	var (
+		statements []string
+		state      = splitState{delimiter: ";", standard: standard}
+	)
+
+	for i := 0; i < len(content); {
+		statement, end, next, _ := splitNext(content, i, state, false)
+		if statement != "" {
+			statements = append(statements, statement)
+		}
+
+		i, state = end, next
+	}
+
+	if state.copy != "" {
+		statements = append(statements, copyStatement(state.copy, ""))
+	}
+
+	return statements
+}
+
+// SplitReader is SplitStatements reading from r, yielding every statement as soon as it is read,
+// so files of hundreds of megabytes are split without holding them in memory.
+func SplitReader(r io.Reader) iter.Seq2[string, error] {
+	return splitReader(r, 64*1024)
+}
+
+// splitReader is SplitReader reading at least size bytes at a time.
+func splitReader(r io.Reader, size int) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		for statement, err := range splitLines(r, size, false) {
+			if !yield(statement.text, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// lineStatement is a statement of a file with the line it starts on, counting from 1.
+type lineStatement struct {
+	text string
+	line int
+	// raw is the text of the file the statement was split from, with the spaces and comments
+	// before it and its delimiter. It is empty for COPY statements, whose data comes in chunks.
+	raw string
+	// lead is the number of bytes of raw before the statement.
+	lead int
+}
+
+// splitLines is splitReader yielding the statements with their line,
+// with the string syntax of PostgreSQL when standard is set.
+func splitLines(r io.Reader, size int, standard bool) iter.Seq2[lineStatement, error] {
+	return func(yield func(lineStatement, error) bool) {
+		var (
+			pending string
+			from    int
+			state   = splitState{delimiter: ";", standard: standard}
+			eof     bool
+			// line is the line of pending[counted], counted only moves forward.
+			line    = 1
+			counted int
+		)
+
+		lineAt := func(i int) int {
+			line += strings.Count(pending[counted:i], "\n")
+			counted = i
+
+			return line
+		}
+
+		for {
+			if from < len(pending) {
+				statement, end, next, ok := splitNext(pending, from, state, !eof)
+				if ok {
+					if statement != "" {
+						part := pending[from:end]
+						start := from + len(part) - len(strings.TrimLeft(part, " \t\r\n\f\v"))
+
+						s := lineStatement{text: statement, line: lineAt(start)}
+						if state.copy == "" && next.copy == "" {
+							s.raw, s.lead = part, start-from
+						}
+
+						if !yield(s, nil) {
+							return
+						}
+					}
+
+					from, state = end, next
+
+					continue
+				}
+			}
+
+			if eof {
+				if state.copy != "" {
+					yield(lineStatement{text: copyStatement(state.copy, ""), line: lineAt(len(pending))}, nil)
+				}
+
+				return
+			}
+
+			// Keep the byte before the statement, telling whether it starts a line.
+			if from > 1 {
+				lineAt(from - 1)
+				pending, from, counted = pending[from-1:], 1, 0
+			}
+
+			// Reading at least as much as pending keeps the rescans of a long statement linear.
+			buf := make([]byte, max(size, len(pending)))
+
+			n, err := io.ReadFull(r, buf)
+			switch {
+			case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+				eof = true
+			case err != nil:
+				yield(lineStatement{}, err)
+				return
+			}
+
+			pending += string(buf[:n])
+		}
+	}
+}
+
+// copyChunk is the size of the inline data of COPY statements returned by splitNext.
+const copyChunk = 1 << 20
+
+// splitState is the state of splitNext carried from one statement to the next.
+type splitState struct {
+	// delimiter ends the statements.
+	delimiter string
+	// copy is the COPY FROM stdin command whose inline data is being read.
+	copy string
+	// standard reads strings like PostgreSQL with standard_conforming_strings,
+	// backslashes only escape in E'...' strings.
+	standard bool
+}
+
+// splitNext scans content from the index from for the end of the next statement.
+// It returns the trimmed statement, empty when it only holds comments, the index after it and
+// the state for the rest of content. With more, content may continue and ok is false
+// when the end of the statement is not certain yet.
+func splitNext(content string, from int, state splitState, more bool) (statement string, end int, next splitState, ok bool) {
+	if state.copy != "" {
+		return splitCopy(content, from, state, more)
+	}
+
+	delimiter := state.delimiter
+	hasCode := false
+
+	part := func(i int) string {
+		if !hasCode {
+			return ""
+		}
+
+		return strings.TrimSpace(content[from:i])
+	}
+
+	for i := from; i < len(content); {
+		// A token may continue after the end of content.
+		if more && len(content)-i <= len(delimiter) {
+			return "", 0, state, false
+		}
+
+		c := content[i]
+
+		// DELIMITER is a command of the MySQL client, only read between statements.
+		if !hasCode && !state.standard && (i == 0 || content[i-1] == '\n') {
+			if more && strings.IndexByte(content[i:], '\n') < 0 {
+				return "", 0, state, false
+			}
+
+			if d, lineEnd, isCommand := delimiterCommand(content, i); isCommand {
+				state.delimiter = d
+
+				return "", lineEnd, state, true
+			}
+		}
+
+		switch {
+		case strings.HasPrefix(content[i:], delimiter):
+			statement := part(i)
+			if !isCopyFromStdin(statement) {
+				return statement, i + len(delimiter), state, true
+			}
+
+			// The data starts on the next line.
+			lineEnd := skipLine(content, i)
+			if more && lineEnd == len(content) {
+				return "", 0, state, false
+			}
+
+			state.copy = statement
+
+			return "", min(lineEnd+1, len(content)), state, true
+		case strings.HasPrefix(content[i:], "--"):
+			lineEnd := skipLine(content, i)
+			if more && lineEnd == len(content) {
+				return "", 0, state, false
+			}
+
+			if d, ok := delimiterDirective(content[i:lineEnd]); ok {
+				delimiter, state.delimiter = d, d
+			}
+
+			switch gooseAnnotation(content[i:lineEnd]) {
+			case gooseStatementBegin:
+				delimiter, state.delimiter = gooseBlock, gooseBlock
+			case gooseStatementEnd:
+				if delimiter == gooseBlock {
+					state.delimiter = ";"
+					return part(i), lineEnd, state, true
+				}
+			}
+			i = lineEnd
+		case strings.HasPrefix(content[i:], "/*"):
+			i = skipBlockComment(content, i)
+		case c == '\'' || c == '"' || c == '`':
+			i = skipQuoted(content, i, state.standard)
+			hasCode = true
+		case c == '$' && (i == 0 || !isIdentByte(content[i-1])):
+			if end, ok := skipDollarQuoted(content, i); ok {
+				i = end
+			} else {
+				i++
+			}
+			hasCode = true
+		default:
+			if !isSpace(c) {
+				hasCode = true
+			}
+			i++
+		}
+
+		// Unterminated comments, quotes and dollar-quoted bodies run to the end of content.
+		if more && i >= len(content) {
+			return "", 0, state, false
+		}
+	}
+
+	if more {
+		return "", 0, state, false
+	}
+
+	return part(len(content)), len(content), state, true
+}
+
+// splitCopy reads the inline data of the COPY command of state from the index from, up to the "\." line.
+// It returns the command with the data read so far once it reaches copyChunk bytes.
+func splitCopy(content string, from int, state splitState, more bool) (string, int, splitState, bool) {
+	i := from
+	for i < len(content) {
+		lineEnd := skipLine(content, i)
+		if more && lineEnd == len(content) {
+			// The line may continue.
+			return "", 0, state, false
+		}
+
+		next := min(lineEnd+1, len(content))
+
+		if strings.TrimSuffix(content[i:lineEnd], "\r") == `\.` {
+			statement := copyStatement(state.copy, content[from:i])
+			state.copy = ""
+
+			return statement, next, state, true
+		}
+
+		i = next
+
+		if i-from >= copyChunk {
+			return copyStatement(state.copy, content[from:i]), i, state, true
+		}
+	}
+
+	if more {
+		return "", 0, state, false
+	}
+
+	// Data without the "\." line runs to the end of content, like in psql.
+	statement := copyStatement(state.copy, content[from:])
+	state.copy = ""
+
+	return statement, len(content), state, true
+}
+
+// copyStatement returns the COPY command with its inline data, as returned by SplitStatements.
+func copyStatement(command, data string) string {
+	return command + ";\n" + data
+}
+
+// isCopyFromStdin reports whether statement is a "COPY ... FROM stdin" command.
+func isCopyFromStdin(statement string) bool {
+	words := strings.Fields(stripComments(statement))
+	if len(words) == 0 || !strings.EqualFold(words[0], "COPY") {
+		return false
+	}
+
+	for i := 1; i+1 < len(words); i++ {
+		if strings.EqualFold(words[i], "FROM") && strings.EqualFold(words[i+1], "STDIN") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cutCopy splits a COPY statement returned by SplitStatements into its command and inline data.
+func cutCopy(statement string) (command, data string, ok bool) {
+	_, end, state, _ := splitNext(statement, 0, splitState{delimiter: ";"}, false)
+	if state.copy == "" {
+		return "", "", false
+	}
+
+	return state.copy, statement[end:], true
+}
+
+// delimiterCommand parses a "DELIMITER $$" line starting at i,
+// returning the new delimiter and the index after the line.
+func delimiterCommand(content string, i int) (string, int, bool) {
+	end := skipLine(content, i)
+
+	fields := strings.Fields(content[i:end])
+	if len(fields) != 2 || !strings.EqualFold(fields[0], "DELIMITER") {
+		return "", 0, false
+	}
+
+	return fields[1], end, true
+}
+
+// delimiterDirective parses a "-- muz:delimiter $$" comment line.
+func delimiterDirective(line string) (string, bool) {
+	d, ok := strings.CutPrefix(strings.TrimSpace(strings.TrimPrefix(line, "--")), directivePrefix)
+	if !ok {
+		return "", false
+	}
+
+	fields := strings.Fields(d)
+	if len(fields) != 2 || fields[0] != DirectiveDelimiter {
+		return "", false
+	}
+
+	return fields[1], true
+}
+
+// skipLine returns the index of the line break ending the line at i, or the end of content.
+func skipLine(content string, i int) int {
+	if end := strings.IndexByte(content[i:], '\n'); end >= 0 {
+		return i + end
+	}
+
+	return len(content)
+}
+
+// skipBlockComment returns the index after the "/* */" comment starting at i.
+func skipBlockComment(content string, i int) int {
+	if end := strings.Index(content[i+2:], "*/"); end >= 0 {
+		return i + 2 + end + 2
+	}
+
+	return len(content)
+}
+
+// skipQuoted returns the index after the quoted string or identifier starting at i.
+// Doubled quotes and backslash escapes do not end it. With standard, backslashes only escape
+// in E'...' strings, like in PostgreSQL.
+func skipQuoted(content string, i int, standard bool) int {
+	quote := content[i]
+
+	escapes := quote != '`'
+	if standard {
+		escapes = quote == '\'' && i > 0 && (content[i-1] == 'E' || content[i-1] == 'e') &&
+			(i == 1 || !isIdentByte(content[i-2]))
+	}
+
+	for j := i + 1; j < len(content); j++ {
+		switch content[j] {
+		case '\\':
+			if escapes {
+				j++
+			}
+		case quote:
+			if j+1 < len(content) && content[j+1] == quote {
+				j++
+				continue
+			}
+
+			return j + 1
+		}
+	}
+
+	return len(content)
+}
+
+// skipDollarQuoted returns the index after the dollar-quoted body like $$ ... $$ or $fn$ ... $fn$ starting at i.
+// It reports false when i does not start a tag, like the $1 placeholder.
+func skipDollarQuoted(content string, i int) (int, bool) {
+	j := i + 1
+	for j < len(content) && isIdentByte(content[j]) {
+		j++
+	}
+
+	if j >= len(content) || content[j] != '$' || (j > i+1 && content[i+1] >= '0' && content[i+1] <= '9') {
+		return 0, false
+	}
+
+	tag := content[i : j+1]
+	if end := strings.Index(content[j+1:], tag); end >= 0 {
+		return j + 1 + end + len(tag), true
+	}
+
+	return len(content), true
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+// execStatements executes content in one call, or one statement at a time when split is set.
+// Split statements are logged at debug level with their first line, duration and affected rows,
+// to find the slow statement of a long file.
+func execStatements(ctx context.Context, q execer, content []byte, split bool, logger Logger) error {
+	if !split {
+		_, err := q.ExecContext(ctx, string(content))
+		return err
+	}
+
+	logger = orDiscard(logger)
+
+	for _, statement := range SplitStatements(string(content)) {
+		if err := execStatement(ctx, q, statement, logger); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// execOptions tell execContent how a driver runs the statements of a file.
+type execOptions struct {
+	// split runs one statement at a time.
+	split bool
+	// batch is the size of the calls, 0 runs files in one call apart from their COPY statements.
+	batch int
+	// copy if set, runs the "COPY ... FROM stdin" statements with their inline data.
+	copy func(ctx context.Context, command, data string) error
+	// logger logs the split statements.
+	logger Logger
+	// standard splits with the string syntax of PostgreSQL, see splitState.
+	standard bool
+}
+
+// execContent executes a migration file on q without holding large files in memory.
+//   - Split files run one statement at a time as they are read, see execStatements.
+//   - Other files up to batch bytes run in one call, larger ones in calls of statements
+//     adding up to about batch bytes, sent as written. With a batch of 0 files always run in one call.
+//   - With copy, files holding "COPY ... FROM stdin" are read statement by statement
+//     and the COPY statements are passed to copy, the statements around them batched as above.
+func execContent(ctx context.Context, q execer, data *Muzo, filePath string, opts execOptions) error {
+	file := path.Join(data.Dir, filePath)
+
+	if !opts.split {
+		var (
+			content []byte
+			ok      = true
+			err     error
+		)
+
+		if opts.batch <= 0 {
+			content, err = data.ReadFile(filePath)
+		} else {
+			content, ok, err = data.readSmall(filePath, opts.batch)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if ok && (opts.copy == nil || !hasCopy(content, opts.standard)) {
+			_, err = q.ExecContext(ctx, string(content))
+			return contentError(err, file, string(content))
+		}
+	}
+
+	var (
+		buf strings.Builder
+		// batched are the statements of buf.
+		batched []batchedStatement
+		index   int
+	)
+
+	flush := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+
+		text := buf.String()
+		buf.Reset()
+
+		_, err := q.ExecContext(ctx, text)
+
+		statements := batched
+		batched = batched[:0]
+
+		return batchError(err, file, text, statements)
+	}
+
+	logger := orDiscard(opts.logger)
+
+	for statement, err := range data.statementLines(filePath, opts.standard) {
+		if err != nil {
+			return err
+		}
+
+		index++
+
+		if opts.copy != nil {
+			if command, rows, ok := cutCopy(statement.text); ok {
+				if err := flush(); err != nil {
+					return err
+				}
+
+				if err := opts.copy(ctx, command, rows); err != nil {
+					return statementError(err, file, index, statement.line, "", -1)
+				}
+
+				continue
+			}
+		}
+
+		if opts.split {
+			if err := execStatement(ctx, q, statement.text, logger); err != nil {
+				return statementError(err, file, index, statement.line, statement.text, errorOffset(err, statement.text))
+			}
+
+			continue
+		}
+
+		batched = append(batched, batchedStatement{offset: buf.Len() + statement.lead, index: index, line: statement.line})
+
+		// The statements are sent as written, only COPY statements without copy are rebuilt.
+		if statement.raw != "" {
+			buf.WriteString(statement.raw)
+		} else {
+			buf.WriteString(statement.text)
+			buf.WriteString(";\n")
+		}
+
+		if opts.batch > 0 && buf.Len() >= opts.batch {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return flush()
+}
+
+// hasCopy reports whether a statement of content is a "COPY ... FROM stdin" command,
+// not only mentions stdin in a comment or string.
+func hasCopy(content []byte, standard bool) bool {
+	// Most files do not mention it at all.
+	if !bytes.Contains(bytes.ToUpper(content), []byte("STDIN")) {
+		return false
+	}
+
+	state := splitState{delimiter: ";", standard: standard}
+	for i := 0; i < len(content); {
+		_, end, next, _ := splitNext(string(content), i, state, false)
+		if next.copy != "" {
+			return true
+		}
+
+		i, state = end, next
+	}
+
+	return false
+}
+
+// execStatement executes a single statement, logged with its first line, duration and affected rows.
+func execStatement(ctx context.Context, q execer, statement string, logger Logger) error {
+	start := time.Now()
+
+	res, err := q.ExecContext(ctx, statement)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		rows = -1
+	}
+
+	logger.Debug("executed statement", "statement", firstLine(statement), "duration", time.Since(start), "rows", rows)
+
+	return nil
+}
+
+// firstLine returns the first line of statement that is not a comment, shortened for logging.
+func firstLine(statement string) string {
+	for line := range strings.SplitSeq(statement, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "--") {
+			continue
+		}
+
+		if len(line) > 80 {
+			return line[:77] + "..."
+		}
+
+		return line
+	}
+
+	return ""
+}
@@ -0,0 +1,333 @@
+package muz
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "statements",
+			content: "CREATE TABLE a (id int);\nCREATE TABLE b (id int);\n",
+			want:    []string{"CREATE TABLE a (id int)", "CREATE TABLE b (id int)"},
+		},
+		{
+			name:    "last statement without semicolon",
+			content: "SELECT 1; SELECT 2",
+			want:    []string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			name:    "quoted semicolons",
+			content: "INSERT INTO a VALUES ('x;y', \"p;q\", `c;d`);\nINSERT INTO a VALUES ('it''s;', 'it\\'s;');",
+			want:    []string{"INSERT INTO a VALUES ('x;y', \"p;q\", `c;d`)", "INSERT INTO a VALUES ('it''s;', 'it\\'s;')"},
+		},
+		{
+			name:    "comments",
+			content: "-- muz:description a; b\nSELECT 1; /* SELECT 2; */ SELECT 3;\n-- trailing; comment\n",
+			want:    []string{"-- muz:description a; b\nSELECT 1", "/* SELECT 2; */ SELECT 3"},
+		},
+		{
+			name:    "dollar quoting",
+			content: "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql;\nDO $body$ BEGIN PERFORM 1; END $body$;\nSELECT $1;",
+			want:    []string{"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql", "DO $body$ BEGIN PERFORM 1; END $body$", "SELECT $1"},
+		},
+		{
+			name:    "dollar inside identifiers",
+			content: "SELECT a$b$c; SELECT 2;",
+			want:    []string{"SELECT a$b$c", "SELECT 2"},
+		},
+		{
+			name:    "delimiter command",
+			content: "DROP PROCEDURE IF EXISTS p;\nDELIMITER $$\nCREATE PROCEDURE p()\nBEGIN\n  SELECT 1;\n  SELECT 2;\nEND$$\ndelimiter ;\nCALL p();\n",
+			want: []string{
+				"DROP PROCEDURE IF EXISTS p",
+				"CREATE PROCEDURE p()\nBEGIN\n  SELECT 1;\n  SELECT 2;\nEND",
+				"CALL p()",
+			},
+		},
+		{
+			name:    "column named delimiter",
+			content: "CREATE TABLE t (\n  id int,\n  delimiter text\n);\nSELECT 1;",
+			want:    []string{"CREATE TABLE t (\n  id int,\n  delimiter text\n)", "SELECT 1"},
+		},
+		{
+			name:    "delimiter directive",
+			content: "-- muz:delimiter //\nCREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW BEGIN SET NEW.x = 1; END //\nSELECT 1; SELECT 2 //",
+			want:    []string{"-- muz:delimiter //\nCREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW BEGIN SET NEW.x = 1; END", "SELECT 1; SELECT 2"},
+		},
+		{
+			name:    "empty statements",
+			content: ";; \n ;",
+			want:    nil,
+		},
+		{
+			name:    "copy from stdin",
+			content: "COPY t (a, b) FROM stdin;\n1\tx;y\n2\t'z\n\\.\nSELECT 1;\ncopy t from STDIN;\n3\tw",
+			want:    []string{"COPY t (a, b) FROM stdin;\n1\tx;y\n2\t'z\n", "SELECT 1", "copy t from STDIN;\n3\tw"},
+		},
+		{
+			name:    "copy without data",
+			content: "COPY t FROM stdin;",
+			want:    []string{"COPY t FROM stdin;\n"},
+		},
+		{
+			name:    "copy to stdout",
+			content: "COPY t TO stdout;\nSELECT 1;",
+			want:    []string{"COPY t TO stdout", "SELECT 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitStatements(tt.content); !slices.Equal(got, tt.want) {
+				t.Errorf("SplitStatements() = %q, want %q", got, tt.want)
+			}
+
+			// Reading one byte at a time cuts every token.
+			var got []string
+			for statement, err := range splitReader(strings.NewReader(tt.content), 1) {
+				if err != nil {
+					t.Fatalf("SplitReader() error = %v", err)
+				}
+
+				got = append(got, statement)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("SplitReader() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// execQuerier records the executed statements, every one affecting two rows.
+type execQuerier struct {
+	querier
+
+	statements []string
+}
+
+func (q *execQuerier) ExecContext(_ context.Context, query string, _ ...any) (sql.Result, error) {
+	q.statements = append(q.statements, query)
+	return driverResult(2), nil
+}
+
+type driverResult int64
+
+func (r driverResult) LastInsertId() (int64, error) { return 0, nil }
+func (r driverResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+func TestExecStatementsLogging(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	q := &execQuerier{}
+	content := "-- seed the users\nINSERT INTO users VALUES (1);\n\nUPDATE users SET name = 'a';"
+	if err := execStatements(context.Background(), q, []byte(content), true, logger); err != nil {
+		t.Fatalf("execStatements() error = %v", err)
+	}
+
+	if len(q.statements) != 2 {
+		t.Fatalf("executed %d statements, want 2", len(q.statements))
+	}
+
+	for _, want := range []string{
+		`msg="executed statement" statement="INSERT INTO users VALUES (1)" duration=`,
+		`msg="executed statement" statement="UPDATE users SET name = 'a'" duration=`,
+		"rows=2",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("log missing %q, got:\n%s", want, out.String())
+		}
+	}
+
+	// Statements run in one call are not logged.
+	out.Reset()
+	if err := execStatements(context.Background(), q, []byte(content), false, logger); err != nil || out.Len() != 0 {
+		t.Errorf("execStatements() without split = %v, logged %q", err, out.String())
+	}
+}
+
+func TestExecContentStreaming(t *testing.T) {
+	fsys := MapFS(map[string]string{
+		"app/001_seed.sql": "-- muz:include common.sql\nINSERT INTO t VALUES ('${NAME}');\nSELECT 3;\nSELECT 4;\n",
+		"app/common.sql":   "SELECT 1;\nSELECT 2;",
+	})
+
+	data := NewMuzo("app", []FileInfo{{Path: "001_seed.sql", Version: 1}}, fsys)
+	data.vars = func(name string) (string, bool, error) { return "x;y", true, nil }
+
+	tests := []struct {
+		name  string
+		batch int
+		want  []string
+	}{
+		{
+			name:  "small file at once",
+			batch: 1024,
+			want:  []string{"SELECT 1;\nSELECT 2;\nINSERT INTO t VALUES ('x;y');\nSELECT 3;\nSELECT 4;\n"},
+		},
+		{
+			name:  "large file in batches",
+			batch: 20,
+			// Sent as written, without the trailing line break.
+			want: []string{
+				"SELECT 1;\nSELECT 2;\nINSERT INTO t VALUES ('x;y');",
+				"\nSELECT 3;\nSELECT 4;",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &execQuerier{}
+			if err := execContent(context.Background(), q, data, "001_seed.sql", execOptions{batch: tt.batch}); err != nil {
+				t.Fatalf("execContent() error = %v", err)
+			}
+
+			if !slices.Equal(q.statements, tt.want) {
+				t.Errorf("executed %q, want %q", q.statements, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecContentStreamingBackslash(t *testing.T) {
+	content := "INSERT INTO paths VALUES ('C:\\');\n-- a; b\nINSERT INTO notes VALUES ('a; b',  E'x\\'; y');\n"
+	data := NewMuzo("app", []FileInfo{{Path: "001_paths.sql", Version: 1}}, MapFS(map[string]string{"app/001_paths.sql": content}))
+
+	q := &execQuerier{}
+	if err := execContent(context.Background(), q, data, "001_paths.sql", execOptions{batch: 10, standard: true}); err != nil {
+		t.Fatalf("execContent() error = %v", err)
+	}
+
+	// Every call is a part of the file between two statements.
+	want := []string{"INSERT INTO paths VALUES ('C:\\');", "\n-- a; b\nINSERT INTO notes VALUES ('a; b',  E'x\\'; y');"}
+	if !slices.Equal(q.statements, want) {
+		t.Errorf("executed %q, want %q", q.statements, want)
+	}
+}
+
+func TestExecContentCopy(t *testing.T) {
+	fsys := MapFS(map[string]string{
+		"app/001_seed.sql": "CREATE TABLE t (a int, b text);\nCOPY t (a, b) FROM stdin;\n1\tit's\n2\t\\N\n3\ta\\tb\\\\c\n\\.\nSELECT 1;\n",
+	})
+
+	data := NewMuzo("app", []FileInfo{{Path: "001_seed.sql", Version: 1}}, fsys)
+
+	t.Run("copy protocol", func(t *testing.T) {
+		q := &execQuerier{}
+
+		var copied []string
+		opts := execOptions{batch: 1024, copy: func(_ context.Context, command, data string) error {
+			copied = append(copied, command, data)
+			return nil
+		}}
+
+		if err := execContent(context.Background(), q, data, "001_seed.sql", opts); err != nil {
+			t.Fatalf("execContent() error = %v", err)
+		}
+
+		if want := []string{"COPY t (a, b) FROM stdin", "1\tit's\n2\t\\N\n3\ta\\tb\\\\c\n"}; !slices.Equal(copied, want) {
+			t.Errorf("copied %q, want %q", copied, want)
+		}
+
+		if want := []string{"CREATE TABLE t (a int, b text);", "SELECT 1;"}; !slices.Equal(q.statements, want) {
+			t.Errorf("executed %q, want %q", q.statements, want)
+		}
+	})
+
+	t.Run("inserts", func(t *testing.T) {
+		q := &execQuerier{}
+
+		if err := execContent(context.Background(), q, data, "001_seed.sql", execOptions{copy: copyInserts(q, 0)}); err != nil {
+			t.Fatalf("execContent() error = %v", err)
+		}
+
+		want := []string{
+			"CREATE TABLE t (a int, b text);",
+			"INSERT INTO t (a, b) VALUES ('1', 'it''s'), ('2', NULL), ('3', 'a\tb\\c')",
+			"SELECT 1;",
+		}
+		if !slices.Equal(q.statements, want) {
+			t.Errorf("executed %q, want %q", q.statements, want)
+		}
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		insert := copyInserts(&execQuerier{}, 0)
+		if err := insert(context.Background(), "COPY t FROM stdin WITH (FORMAT csv)", "1,2\n"); !errors.Is(err, ErrNotSupported) {
+			t.Errorf("copyInserts() error = %v, want ErrNotSupported", err)
+		}
+	})
+}
+
+func TestSplitStandardStrings(t *testing.T) {
+	content := "INSERT INTO paths VALUES ('C:\\'); INSERT INTO notes VALUES ('a; b', E'it\\'s; x', \"q\\\");\n"
+
+	want := []string{
+		"INSERT INTO paths VALUES ('C:\\')",
+		"INSERT INTO notes VALUES ('a; b', E'it\\'s; x', \"q\\\")",
+	}
+	if got := splitStatements(content, true); !slices.Equal(got, want) {
+		t.Errorf("splitStatements() = %q, want %q", got, want)
+	}
+}
+
+func TestSplitStandardDelimiter(t *testing.T) {
+	// DELIMITER is no PostgreSQL command, the option of COPY stays in its statement.
+	content := "COPY t FROM '/tmp/x.csv' WITH CSV\n  DELIMITER ',';\nDELIMITER //\nSELECT 1;"
+
+	want := []string{"COPY t FROM '/tmp/x.csv' WITH CSV\n  DELIMITER ','", "DELIMITER //\nSELECT 1"}
+	if got := splitStatements(content, true); !slices.Equal(got, want) {
+		t.Errorf("splitStatements() = %q, want %q", got, want)
+	}
+}
+
+func TestHasCopy(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{name: "copy", content: "SELECT 1;\nCOPY t FROM stdin;\n1\n\\.\n", want: true},
+		{name: "comment", content: "INSERT INTO t VALUES (1); -- loaded from stdin\n"},
+		{name: "string", content: "INSERT INTO t VALUES ('COPY t FROM stdin');\n"},
+		{name: "backslash before the comment", content: "INSERT INTO paths VALUES ('C:\\'); INSERT INTO notes VALUES ('a; b'); -- from stdin\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasCopy([]byte(tt.content), true); got != tt.want {
+				t.Errorf("hasCopy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecContentMentionsStdin(t *testing.T) {
+	content := "INSERT INTO paths VALUES ('C:\\'); INSERT INTO notes VALUES ('a; b'); -- from stdin\n"
+	data := NewMuzo("app", []FileInfo{{Path: "001_paths.sql", Version: 1}}, MapFS(map[string]string{"app/001_paths.sql": content}))
+
+	q := &execQuerier{}
+	opts := execOptions{batch: 1024, copy: copyInserts(q, 0), standard: true}
+
+	if err := execContent(context.Background(), q, data, "001_paths.sql", opts); err != nil {
+		t.Fatalf("execContent() error = %v", err)
+	}
+
+	if want := []string{content}; !slices.Equal(q.statements, want) {
+		t.Errorf("executed %q, want %q", q.statements, want)
+	}
+}
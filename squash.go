@@ -0,0 +1,171 @@
+package muz
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// SquashConfig configures Squash.
+type SquashConfig struct {
+	// Path of the migration root directory.
+	//  - Default: "migrations"
+	Path string
+	// Name of the squashed file, written as "NNN_name.ext".
+	//  - Default: "squashed"
+	Name string
+	// Extension only squashes files with this extension, like Migrate.Extension.
+	Extension string
+	// Skip leaves out the files matching these patterns, like Migrate.Skip.
+	Skip []string
+	// Table is the tracking table used in the generated SQL, quoted like the PostgreSQL drivers do.
+	//  - Default: "migrations"
+	Table string
+	// Schema of the tracking table, like PostgresDriver.Schema.
+	Schema string
+}
+
+// SquashResult describes the changes done by Squash.
+type SquashResult struct {
+	// File is the path of the written baseline file.
+	File string
+	// Removed are the paths of the squashed files and their rollback files.
+	Removed []string
+	// SQL updates the tracking table of databases that already applied all squashed migrations.
+	SQL string
+}
+
+// Squash concatenates the migrations of dir with versions up to version into a single file
+// carrying that version, and removes the squashed files with their rollback files.
+//
+// Databases that already applied version keep working without changes, the returned SQL
+// removes the now unused records and points the record of version to the squashed file and its checksum.
+// Databases behind version would apply the squashed file on top of their partial schema, bring them
+// to version before squashing. Goose and dbmate files are refused, their Down sections would be lost.
+func Squash(dir string, version int64, cfg SquashConfig) (*SquashResult, error) {
+	root := withDefault(cfg.Path, "migrations")
+	name := sanitizeName(withDefault(cfg.Name, "squashed"))
+	table := postgresIdent(cfg.Schema, withDefault(cfg.Table, "migrations"), "")
+
+	dir = cleanDir(dir)
+
+	target := filepath.Join(root, filepath.FromSlash(dir))
+
+	m := Migrate{Path: root, Extension: cfg.Extension, Skip: cfg.Skip}
+
+	fileSystem, closeFS, err := m.openFS(root)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFS()
+
+	// The versions of an order file are positions, squashing would shift them.
+	if _, err := fs.Stat(fileSystem, path.Join(dir, orderFile)); err == nil {
+		return nil, fmt.Errorf("squash %s: directories with an %s are not supported", dir, orderFile)
+	}
+
+	files, _, err := m.listFiles(fileSystem, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	info := NewMuzo(dir, files, fileSystem)
+
+	var (
+		content bytes.Buffer
+		removed []string
+		last    FileInfo
+	)
+
+	for _, file := range files {
+		if file.Version > version {
+			break
+		}
+
+		var data bytes.Buffer
+
+		annotated, err := info.copySection(&data, path.Join(info.Dir, file.Path), gooseUp)
+		if err != nil {
+			return nil, err
+		}
+
+		if annotated {
+			return nil, fmt.Errorf("squash %s: %s has goose or dbmate sections, its Down section would be lost", dir, file.Path)
+		}
+
+		fmt.Fprintf(&content, "-- %s\n%s\n\n", file.Path, bytes.TrimSpace(data.Bytes()))
+
+		removed = append(removed, filepath.Join(target, file.Path))
+		if down := downFileName(file.Path); down != "" {
+			if _, err := os.Stat(filepath.Join(target, down)); err == nil {
+				removed = append(removed, filepath.Join(target, down))
+			}
+		}
+
+		last = file
+	}
+
+	if last.Version != version {
+		return nil, fmt.Errorf("squash: no migration with version %d in %s", version, dir)
+	}
+
+	base := trimEncoding(last.Path)
+	digits := len(base) - len(strings.TrimLeft(base, "0123456789"))
+	output := filepath.Join(target, fmt.Sprintf("%0*d_%s%s", digits, version, name, path.Ext(base)))
+
+	// Write next to the old files first, so a failure does not lose migrations.
+	tmp := output + ".tmp"
+	data := bytes.TrimRight(content.Bytes(), "\n")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return nil, err
+	}
+
+	for _, f := range removed {
+		if err := os.Remove(f); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, errors.Join(err, os.Remove(tmp))
+		}
+	}
+
+	if err := os.Rename(tmp, output); err != nil {
+		return nil, err
+	}
+
+	return &SquashResult{
+		File:    output,
+		Removed: removed,
+		SQL: fmt.Sprintf(
+			"DELETE FROM %s WHERE directory = %s AND version < %d;\nUPDATE %s SET file_name = %s, checksum = %s WHERE directory = %s AND version = %d;\n",
+			table, quoteString(dir), version, table, quoteString(filepath.Base(output)), quoteString(checksum(data)), quoteString(dir), version,
+		),
+	}, nil
+}
+
+// downFileName returns the rollback file name of a migration like "001_users.down.sql",
+// the one of "001_users.up.sql" of golang-migrate included. The .gz and encryption suffixes are kept.
+func downFileName(name string) string {
+	inner := trimEncoding(name)
+	suffix := name[len(inner):]
+	name = inner
+
+	ext := path.Ext(name)
+	if ext == "" {
+		return ""
+	}
+
+	base := strings.TrimSuffix(name, ext)
+	if strings.HasSuffix(strings.ToLower(base), ".up") {
+		base = base[:len(base)-len(".up")]
+	}
+
+	return base + ".down" + ext + suffix
+}
+
+// quoteString returns s as a SQL string literal.
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
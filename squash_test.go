@@ -0,0 +1,130 @@
+package muz
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSquash(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "schema")
+	mustMkdir(t, dir)
+	mustWriteFile(t, filepath.Join(dir, "001_users.sql"), []byte("CREATE TABLE users();\n"))
+	mustWriteFile(t, filepath.Join(dir, "001_users.down.sql"), []byte("DROP TABLE users;\n"))
+	mustWriteFile(t, filepath.Join(dir, "002_orders.sql"), []byte("CREATE TABLE orders();\n"))
+	mustWriteFile(t, filepath.Join(dir, "003_items.sql"), []byte("CREATE TABLE items();\n"))
+
+	result, err := Squash("schema", 2, SquashConfig{Path: root})
+	if err != nil {
+		t.Fatalf("Squash() error = %v", err)
+	}
+
+	var names []string
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	if want := []string{"002_squashed.sql", "003_items.sql"}; !slices.Equal(names, want) {
+		t.Errorf("Squash() directory = %v, want %v", names, want)
+	}
+
+	content, err := os.ReadFile(result.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "-- 001_users.sql\nCREATE TABLE users();\n\n-- 002_orders.sql\nCREATE TABLE orders();"
+	if string(content) != want {
+		t.Errorf("Squash() content = %q, want %q", content, want)
+	}
+
+	if len(result.Removed) != 3 {
+		t.Errorf("Squash() removed = %v, want 3 files", result.Removed)
+	}
+
+	if !strings.Contains(result.SQL, "version < 2") || !strings.Contains(result.SQL, "'002_squashed.sql'") {
+		t.Errorf("Squash() SQL = %q", result.SQL)
+	}
+
+	// The record keeps matching the squashed file when checksums are verified.
+	if sum := "checksum = '" + checksum(content) + "'"; !strings.Contains(result.SQL, sum) {
+		t.Errorf("Squash() SQL = %q, want %s", result.SQL, sum)
+	}
+
+	if !strings.Contains(result.SQL, `DELETE FROM "migrations"`) {
+		t.Errorf("Squash() SQL = %q, want the quoted table", result.SQL)
+	}
+
+	if _, err := Squash("schema", 5, SquashConfig{Path: root}); err == nil {
+		t.Errorf("Squash() unknown version expected error")
+	}
+}
+
+func TestSquashSkip(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "schema")
+	mustMkdir(t, dir)
+	mustWriteFile(t, filepath.Join(dir, "001_users.sql"), []byte("CREATE TABLE users();\n"))
+	mustWriteFile(t, filepath.Join(dir, "001_users_draft.sql"), []byte("DROP TABLE users;\n"))
+	mustWriteFile(t, filepath.Join(dir, "002_orders.sql"), []byte("CREATE TABLE orders();\n"))
+
+	result, err := Squash("schema", 2, SquashConfig{Path: root, Skip: []string{"schema/*_draft.sql"}})
+	if err != nil {
+		t.Fatalf("Squash() error = %v", err)
+	}
+
+	content, err := os.ReadFile(result.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(content), "DROP TABLE") {
+		t.Errorf("Squash() content = %q, want the skipped file left out", content)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "001_users_draft.sql")); err != nil {
+		t.Errorf("skipped file removed: %v", err)
+	}
+}
+
+func TestSquashAnnotated(t *testing.T) {
+	for name, content := range map[string]string{
+		"goose":  "-- +goose Up\nCREATE TABLE users();\n-- +goose Down\nDROP TABLE users;\n",
+		"dbmate": "-- migrate:up\nCREATE TABLE users();\n-- migrate:down\nDROP TABLE users;\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "schema")
+			mustMkdir(t, dir)
+			mustWriteFile(t, filepath.Join(dir, "001_users.sql"), []byte(content))
+			mustWriteFile(t, filepath.Join(dir, "002_orders.sql"), []byte("CREATE TABLE orders();\n"))
+
+			if _, err := Squash("schema", 2, SquashConfig{Path: root}); err == nil {
+				t.Fatal("Squash() error = nil, want the Down section refused")
+			}
+
+			// Nothing is written or removed.
+			if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+				t.Errorf("directory has %d entries, want 2", len(entries))
+			}
+		})
+	}
+}
+
+func TestDownFileName(t *testing.T) {
+	tests := map[string]string{
+		"001_users.sql":    "001_users.down.sql",
+		"001_users.sql.gz": "001_users.down.sql.gz",
+		"001_users":        "",
+	}
+
+	for name, want := range tests {
+		if got := downFileName(name); got != want {
+			t.Errorf("downFileName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package muz
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrNotSupported is returned when the driver lacks a capability an operation needs.
+var ErrNotSupported = errors.New("not supported by driver")
+
+// Record is a row of the migration tracking table.
+type Record struct {
+	Version     int64     `json:"version"`
+	Directory   string    `json:"directory"`
+	FileName    string    `json:"file_name"`
+	ProcessedAt time.Time `json:"processed_at"`
+
+	// Audit columns, filled by drivers keeping them like PostgresDriver and PgxDriver.
+	Description string        `json:"description,omitempty"`
+	Checksum    string        `json:"checksum,omitempty"`
+	Duration    time.Duration `json:"duration,omitempty"`
+	AppliedBy   string        `json:"applied_by,omitempty"`
+	Hostname    string        `json:"hostname,omitempty"`
+	AppVersion  string        `json:"app_version,omitempty"`
+	GitSHA      string        `json:"git_sha,omitempty"`
+}
+
+// StatusReporter is implemented by drivers that can list applied migrations.
+// History can be called outside of Start and End.
+type StatusReporter interface {
+	History(ctx context.Context) ([]Record, error)
+}
+
+// State of a migration file.
+type State string
+
+const (
+	// StateApplied is a file recorded in the tracking table.
+	StateApplied State = "applied"
+	// StatePending is a file that runs on the next migration.
+	StatePending State = "pending"
+	// StateSkipped is a file older than the latest applied version of its directory, it is never applied.
+	StateSkipped State = "skipped"
+	// StateMissing is a recorded migration whose file does not exist anymore.
+	StateMissing State = "missing"
+)
+
+// FileStatus is the state of a single migration.
+type FileStatus struct {
+	Dir       string     `json:"dir"`
+	File      string     `json:"file"`
+	Version   int64      `json:"version"`
+	State     State      `json:"state"`
+	Checksum  string     `json:"checksum,omitempty"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+
+	Metadata
+}
+
+// Status is the migration state of a database.
+type Status struct {
+	Applied int          `json:"applied"`
+	Pending int          `json:"pending"`
+	Files   []FileStatus `json:"files"`
+}
+
+// DirStatus is the migration state of a single directory.
+type DirStatus struct {
+	Dir string `json:"dir"`
+	// Version is the latest applied version of the directory.
+	Version int64 `json:"version"`
+	// Applied are the recorded migrations, including the missing ones.
+	Applied []FileStatus `json:"applied"`
+	// Pending are the files the next migration applies.
+	Pending []FileStatus `json:"pending"`
+}
+
+// ByDir groups the files by directory, in the order directories are applied.
+// Skipped files are left out.
+func (s *Status) ByDir() []DirStatus {
+	var dirs []DirStatus
+	index := make(map[string]int)
+
+	for _, f := range s.Files {
+		i, ok := index[f.Dir]
+		if !ok {
+			i = len(dirs)
+			index[f.Dir] = i
+			dirs = append(dirs, DirStatus{Dir: f.Dir, Applied: []FileStatus{}, Pending: []FileStatus{}})
+		}
+
+		d := &dirs[i]
+		switch f.State {
+		case StateApplied, StateMissing:
+			d.Applied = append(d.Applied, f)
+			d.Version = max(d.Version, f.Version)
+		case StatePending:
+			d.Pending = append(d.Pending, f)
+		}
+	}
+
+	return dirs
+}
+
+// Reason tells why a planned migration runs.
+type Reason string
+
+const (
+	// ReasonNew is a file with a version above the latest applied one of its directory.
+	ReasonNew Reason = "new"
+	// ReasonOutOfOrder is a file older than the latest applied version of its directory, applied by OutOfOrderApply.
+	ReasonOutOfOrder Reason = "out_of_order"
+)
+
+// PlannedMigration is a file that would be applied by the next migration.
+type PlannedMigration struct {
+	Dir      string `json:"dir"`
+	File     string `json:"file"`
+	Version  int64  `json:"version"`
+	Reason   Reason `json:"reason"`
+	Checksum string `json:"checksum"`
+
+	Metadata
+}
+
+// Status compares the migration files with the applied migrations reported by the driver.
+// The driver must implement StatusReporter.
+func (m Migrate) Status(ctx context.Context, driver Driver) (*Status, error) {
+	reporter, ok := driver.(StatusReporter)
+	if !ok {
+		return nil, fmt.Errorf("status: %w", ErrNotSupported)
+	}
+
+	records, err := reporter.History(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		dir     string
+		version int64
+	}
+
+	applied := newAppliedRecords(m.Match, records)
+	latest := make(map[string]int64)
+	for _, r := range records {
+		latest[m.stream(r.Directory)] = max(latest[m.stream(r.Directory)], r.Version)
+	}
+
+	status := &Status{Files: []FileStatus{}}
+	seen := make(map[key]bool, len(records))
+
+	for info, err := range m.Iter() {
+		if err != nil {
+			return nil, err
+		}
+
+		// Seeds are not recorded as migrations.
+		if m.seed(info.Dir) {
+			continue
+		}
+
+		for _, file := range info.Files {
+			sum, err := info.checksum(file.Path)
+			if err != nil {
+				return nil, err
+			}
+
+			st := FileStatus{
+				Dir:      info.Dir,
+				File:     file.Path,
+				Version:  file.Version,
+				Checksum: sum,
+				Metadata: file.Metadata,
+			}
+
+			r, ok, err := applied.lookup(info, file)
+			if err != nil {
+				return nil, err
+			}
+
+			switch {
+			case ok:
+				seen[key{r.Directory, r.Version}] = true
+				st.State = StateApplied
+				st.AppliedAt = &r.ProcessedAt
+				status.Applied++
+			case file.Version <= latest[m.stream(info.Dir)] && m.OutOfOrder != OutOfOrderApply:
+				st.State = StateSkipped
+			default:
+				st.State = StatePending
+				status.Pending++
+			}
+
+			status.Files = append(status.Files, st)
+		}
+	}
+
+	// Records of files filtered out by tags exist, they are not missing.
+	var filtered map[key]bool
+
+	for _, r := range records {
+		if seen[key{r.Directory, r.Version}] || !m.versionRange(r.Directory).contains(r.Version) {
+			continue
+		}
+
+		if filtered == nil {
+			filtered = make(map[key]bool)
+			for info, err := range m.all() {
+				if err != nil {
+					return nil, err
+				}
+
+				for _, file := range info.Files {
+					fr, ok, err := applied.lookup(info, file)
+					if err != nil {
+						return nil, err
+					}
+
+					if ok {
+						filtered[key{fr.Directory, fr.Version}] = true
+					}
+				}
+			}
+		}
+
+		if filtered[key{r.Directory, r.Version}] {
+			continue
+		}
+
+		status.Files = append(status.Files, FileStatus{
+			Dir:       r.Directory,
+			File:      r.FileName,
+			Version:   r.Version,
+			State:     StateMissing,
+			AppliedAt: &r.ProcessedAt,
+		})
+		status.Applied++
+	}
+
+	return status, nil
+}
+
+// Plan returns the files the next migration would apply, in order, without executing anything.
+// The driver must implement StatusReporter.
+func (m Migrate) Plan(ctx context.Context, driver Driver) ([]PlannedMigration, error) {
+	status, err := m.Status(ctx, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[string]int64)
+	for _, f := range status.Files {
+		if f.State == StateApplied || f.State == StateMissing {
+			latest[m.stream(f.Dir)] = max(latest[m.stream(f.Dir)], f.Version)
+		}
+	}
+
+	plan := []PlannedMigration{}
+	for _, f := range status.Files {
+		if f.State != StatePending {
+			continue
+		}
+
+		reason := ReasonNew
+		if f.Version <= latest[m.stream(f.Dir)] {
+			reason = ReasonOutOfOrder
+		}
+
+		plan = append(plan, PlannedMigration{
+			Dir:      f.Dir,
+			File:     f.File,
+			Version:  f.Version,
+			Reason:   reason,
+			Checksum: f.Checksum,
+			Metadata: f.Metadata,
+		})
+	}
+
+	return plan, nil
+}
+
+// WritePlan writes the files the next migration would apply followed by their content to w,
+// without executing anything. Every file starts with a "-- dir/file" comment line.
+// The driver must implement StatusReporter.
+func (m Migrate) WritePlan(ctx context.Context, driver Driver, w io.Writer) error {
+	plan, err := m.Plan(ctx, driver)
+	if err != nil {
+		return err
+	}
+
+	type key struct {
+		dir  string
+		file string
+	}
+
+	pending := make(map[key]bool, len(plan))
+	for _, p := range plan {
+		pending[key{p.Dir, p.File}] = true
+	}
+
+	for info, err := range m.Iter() {
+		if err != nil {
+			return err
+		}
+
+		for _, file := range info.Files {
+			if !pending[key{info.Dir, file.Path}] {
+				continue
+			}
+
+			content, err := info.ReadFile(file.Path)
+			if err != nil {
+				return err
+			}
+
+			if _, err := fmt.Fprintf(w, "-- %s/%s (version %d)\n%s\n", info.Dir, file.Path, file.Version, bytes.TrimRight(content, "\n")); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checksum returns the hex encoded SHA-256 of the content.
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,132 @@
+package muz
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+type historyDriver struct {
+	nopDriver
+
+	records []Record
+}
+
+func (d historyDriver) History(context.Context) ([]Record, error) {
+	return d.records, nil
+}
+
+func TestMigrateStatus(t *testing.T) {
+	appliedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql":  "CREATE TABLE users();",
+			"migrations/schema/002_orders.sql": "CREATE TABLE orders();",
+			"migrations/schema/003_items.sql":  "CREATE TABLE items();",
+			"migrations/data/001_seed.sql":     "INSERT INTO users DEFAULT VALUES;",
+		}),
+	}
+
+	driver := historyDriver{records: []Record{
+		{Version: 1, Directory: "schema", FileName: "001_users.sql", ProcessedAt: appliedAt},
+		{Version: 3, Directory: "schema", FileName: "003_items.sql", ProcessedAt: appliedAt},
+		{Version: 5, Directory: "schema", FileName: "005_removed.sql", ProcessedAt: appliedAt},
+	}}
+
+	status, err := m.Status(context.Background(), driver)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+
+	type row struct {
+		Dir     string
+		File    string
+		Version int64
+		State   State
+	}
+
+	var got []row
+	for _, f := range status.Files {
+		got = append(got, row{f.Dir, f.File, f.Version, f.State})
+	}
+
+	want := []row{
+		{"data", "001_seed.sql", 1, StatePending},
+		{"schema", "001_users.sql", 1, StateApplied},
+		{"schema", "002_orders.sql", 2, StateSkipped},
+		{"schema", "003_items.sql", 3, StateApplied},
+		{"schema", "005_removed.sql", 5, StateMissing},
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("Status() files = %v, want %v", got, want)
+	}
+
+	if status.Applied != 3 || status.Pending != 1 {
+		t.Errorf("Status() applied = %d, pending = %d, want 3 and 1", status.Applied, status.Pending)
+	}
+
+	if status.Files[0].Checksum != checksum([]byte("INSERT INTO users DEFAULT VALUES;")) {
+		t.Errorf("Status() checksum = %q", status.Files[0].Checksum)
+	}
+
+	if status.Files[1].AppliedAt == nil || !status.Files[1].AppliedAt.Equal(appliedAt) {
+		t.Errorf("Status() applied at = %v, want %v", status.Files[1].AppliedAt, appliedAt)
+	}
+
+	dirs := status.ByDir()
+	if len(dirs) != 2 || dirs[0].Dir != "data" || dirs[1].Dir != "schema" {
+		t.Fatalf("ByDir() = %v, want data and schema", dirs)
+	}
+
+	if len(dirs[0].Pending) != 1 || len(dirs[0].Applied) != 0 || dirs[0].Version != 0 {
+		t.Errorf("ByDir() data = %+v", dirs[0])
+	}
+
+	if len(dirs[1].Pending) != 0 || len(dirs[1].Applied) != 3 || dirs[1].Version != 5 {
+		t.Errorf("ByDir() schema = %+v", dirs[1])
+	}
+
+	plan, err := m.Plan(context.Background(), driver)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+
+	if len(plan) != 1 || plan[0].Dir != "data" || plan[0].File != "001_seed.sql" || plan[0].Reason != ReasonNew {
+		t.Errorf("Plan() = %v, want data/001_seed.sql", plan)
+	}
+}
+
+func TestMigrateWritePlan(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/schema/001_users.sql":  "CREATE TABLE users();\n",
+			"migrations/schema/002_orders.sql": "CREATE TABLE orders();\n",
+		}),
+	}
+
+	driver := historyDriver{records: []Record{
+		{Version: 1, Directory: "schema", FileName: "001_users.sql"},
+	}}
+
+	var buf bytes.Buffer
+	if err := m.WritePlan(context.Background(), driver, &buf); err != nil {
+		t.Fatalf("WritePlan() error = %v", err)
+	}
+
+	want := "-- schema/002_orders.sql (version 2)\nCREATE TABLE orders();\n"
+	if buf.String() != want {
+		t.Errorf("WritePlan() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestMigrateStatusNotSupported(t *testing.T) {
+	_, err := Migrate{FS: MapFS(nil)}.Status(context.Background(), nopDriver{})
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Status() error = %v, want ErrNotSupported", err)
+	}
+}
@@ -0,0 +1,66 @@
+package muz
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// strictKinds are the warnings of Validate turned into errors by Strict.
+var strictKinds = []IssueKind{IssueVersionGap, IssueMissingPrefix, IssueUnusedSkip}
+
+// strictPolicies returns m with ChecksumError and OutOfOrderError when these policies are not set,
+// for drivers implementing StatusReporter.
+func (m Migrate) strictPolicies(driver Driver) Migrate {
+	if _, ok := driver.(StatusReporter); !ok {
+		return m
+	}
+
+	if m.Checksum == ChecksumIgnore {
+		m.Checksum = ChecksumError
+	}
+
+	if m.OutOfOrder == OutOfOrderIgnore {
+		m.OutOfOrder = OutOfOrderError
+	}
+
+	return m
+}
+
+// checkStrict validates the tree before a strict run, failing on any error of the report.
+func (m Migrate) checkStrict() error {
+	report, err := m.Validate()
+	if err != nil {
+		return err
+	}
+
+	if !report.HasErrors() {
+		return nil
+	}
+
+	var issues []string
+	for _, i := range report.Issues {
+		if i.Severity != SeverityError {
+			continue
+		}
+
+		if i.File != "" {
+			issues = append(issues, path.Join(i.Dir, i.File)+": "+i.Message)
+		} else {
+			issues = append(issues, i.Message)
+		}
+	}
+
+	return fmt.Errorf("strict: %d validation errors: %s", len(issues), strings.Join(issues, "; "))
+}
+
+// strict raises the severity of the strictKinds issues to error.
+func (r *ValidationReport) strict() {
+	for i := range r.Issues {
+		for _, kind := range strictKinds {
+			if r.Issues[i].Kind == kind {
+				r.Issues[i].Severity = SeverityError
+			}
+		}
+	}
+}
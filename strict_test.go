@@ -0,0 +1,62 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMigrateStrict(t *testing.T) {
+	t.Run("gap", func(t *testing.T) {
+		m := Migrate{
+			FS: MapFS(map[string]string{
+				"migrations/app/001_users.sql":  "CREATE TABLE users();",
+				"migrations/app/003_orders.sql": "CREATE TABLE orders();",
+			}),
+			Strict: true,
+		}
+
+		driver := &recordDriver{}
+		_, err := m.Migrate(context.Background(), driver)
+		if err == nil || !strings.Contains(err.Error(), "app/003_orders.sql: versions 2 to 2 are missing") {
+			t.Fatalf("Migrate() error = %v, want the version gap", err)
+		}
+
+		if len(driver.records) != 0 {
+			t.Errorf("records = %+v, want none before the tree is valid", driver.records)
+		}
+	})
+
+	t.Run("out of order", func(t *testing.T) {
+		m := Migrate{
+			FS: MapFS(map[string]string{
+				"migrations/app/001_users.sql":  "CREATE TABLE users();",
+				"migrations/app/002_orders.sql": "CREATE TABLE orders();",
+			}),
+			Strict: true,
+		}
+
+		driver := &recordDriver{records: []Record{{Version: 2, Directory: "app", FileName: "002_orders.sql"}}}
+		if _, err := m.Migrate(context.Background(), driver); !errors.Is(err, ErrOutOfOrder) {
+			t.Errorf("Migrate() error = %v, want ErrOutOfOrder", err)
+		}
+	})
+
+	t.Run("explicit policy", func(t *testing.T) {
+		m := Migrate{
+			FS: MapFS(map[string]string{
+				"migrations/app/001_users.sql":  "CREATE TABLE users();",
+				"migrations/app/002_orders.sql": "CREATE TABLE orders();",
+			}),
+			Strict:     true,
+			OutOfOrder: OutOfOrderWarn,
+		}
+
+		driver := &recordDriver{records: []Record{{Version: 2, Directory: "app", FileName: "002_orders.sql"}}}
+		result, err := m.Migrate(context.Background(), driver)
+		if err != nil || result.Files[0].Outcome != OutcomeOutOfOrder {
+			t.Errorf("Migrate() = %+v, %v, want the policy given kept", result, err)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package muz
+
+// TableSchema overrides the statements of the tracking table used by PostgresDriver and PgxDriver,
+// for mandatory column conventions like created_by or tenant_id, or DDL restrictions.
+// All of them are optional, nil uses the default statement. Every function gets the quoted table name.
+type TableSchema struct {
+	// CreateTable returns the DDL creating the tracking table if it does not exist, run by Start.
+	// An empty statement skips it, for tables created by a DBA. The audit columns description,
+	// checksum, execution_ms, applied_by, hostname, app_version and git_sha are added to tables missing them.
+	CreateTable func(table string) string
+	// Insert returns the statement recording an applied migration.
+	// Arguments are passed in version, directory, file_name, description, execution_ms order.
+	Insert func(table string) string
+	// Upsert returns the statement recording a migration without running it, used by Force, Baseline and MarkApplied.
+	// Arguments are passed in version, directory, file_name order.
+	Upsert func(table string) string
+	// History returns the query reading the records, selecting version, directory, file_name, processed_at,
+	// description, checksum, execution_ms and applied_by in this order, optionally followed by
+	// hostname, app_version and git_sha.
+	History func(table string) string
+}
+
+func (s TableSchema) createTable(table string) string {
+	if s.CreateTable != nil {
+		return s.CreateTable(table)
+	}
+
+	return PostgresDialect{}.CreateTable(table)
+}
+
+func (s TableSchema) insert(table string) string {
+	if s.Insert != nil {
+		return s.Insert(table)
+	}
+
+	return postgresInsert(table)
+}
+
+func (s TableSchema) upsert(table string) string {
+	if s.Upsert != nil {
+		return s.Upsert(table)
+	}
+
+	return PostgresDialect{}.Upsert(table)
+}
+
+func (s TableSchema) history(table string) string {
+	if s.History != nil {
+		return s.History(table)
+	}
+
+	return postgresHistory(table)
+}
+
+// historyDest returns the scan destinations of a History query with n columns into r,
+// the execution time being read into ms.
+func historyDest(r *Record, ms *int64, n int) []any {
+	dest := []any{&r.Version, &r.Directory, &r.FileName, &r.ProcessedAt, &r.Description, &r.Checksum, ms, &r.AppliedBy}
+	if n > len(dest) {
+		dest = append(dest, &r.Hostname, &r.AppVersion, &r.GitSHA)
+	}
+
+	return dest
+}
@@ -0,0 +1,96 @@
+package muz
+
+import (
+	"errors"
+	"io/fs"
+	"iter"
+	"path"
+	"slices"
+	"strings"
+)
+
+// tagsFile in a directory tags all files of the directory, comma or newline separated.
+const tagsFile = "muz.tags"
+
+// splitTags returns the tags of a comma or newline separated list.
+func splitTags(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' ' || r == '\t'
+	})
+}
+
+// dirTags returns the tags of the muz.tags file of the directory.
+func (d *Muzo) dirTags() ([]string, error) {
+	if d.fs == nil {
+		return nil, nil
+	}
+
+	content, err := fs.ReadFile(d.fs, path.Join(d.Dir, tagsFile))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return splitTags(string(content)), nil
+}
+
+// selected reports whether a file with tags runs: tagged files run only when one of their
+// tags is in Tags, and files with a tag of ExcludeTags never run.
+func (m Migrate) selected(tags []string) bool {
+	if slices.ContainsFunc(tags, func(t string) bool { return slices.Contains(m.ExcludeTags, t) }) {
+		return false
+	}
+
+	return len(tags) == 0 || slices.ContainsFunc(tags, func(t string) bool { return slices.Contains(m.Tags, t) })
+}
+
+// withHeaders reads the header comments of the files into their Metadata
+// and drops the files not selected by Tags and ExcludeTags.
+// Files skipped by a run of Migrate are kept without being read.
+func (m Migrate) withHeaders(list iter.Seq2[*Muzo, error]) iter.Seq2[*Muzo, error] {
+	return func(yield func(*Muzo, error) bool) {
+		for info, err := range list {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+
+			dirTags, err := info.dirTags()
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			files := make([]FileInfo, 0, len(info.Files))
+			for _, file := range info.Files {
+				if m.settled.skipped(info.Dir, file) {
+					files = append(files, file)
+					continue
+				}
+
+				directives, err := info.directives(file.Path)
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+
+				if !m.selected(append(splitTags(directives[DirectiveTags]), dirTags...)) {
+					m.log().Debug("skipping migration by tags", "directory", info.Dir, "file", file.Path, "version", file.Version)
+					continue
+				}
+
+				file.Metadata = metadata(directives)
+				files = append(files, file)
+			}
+
+			if !yield(info.withFiles(files), nil) {
+				return
+			}
+		}
+	}
+}
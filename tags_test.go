@@ -0,0 +1,81 @@
+package muz
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestMigrateTags(t *testing.T) {
+	files := map[string]string{
+		"migrations/schema/001_users.sql":     "CREATE TABLE users();",
+		"migrations/schema/002_backfill.sql":  "-- muz:tags slow\nUPDATE users SET name = '';",
+		"migrations/schema/003_orders.sql":    "CREATE TABLE orders();",
+		"migrations/seed/muz.tags":            "seed\n",
+		"migrations/seed/001_users.sql":       "INSERT INTO users DEFAULT VALUES;",
+		"migrations/seed/002_admins.sql":      "-- muz:tags slow\nINSERT INTO users DEFAULT VALUES;",
+		"migrations/schema/004_partition.sql": "-- comment\n-- muz:tags big, slow\nCREATE TABLE parts();",
+	}
+
+	tests := []struct {
+		name string
+		m    Migrate
+		want []string
+	}{
+		{
+			name: "untagged only",
+			want: []string{"schema/001_users.sql", "schema/003_orders.sql"},
+		},
+		{
+			name: "file tag",
+			m:    Migrate{Tags: []string{"slow"}},
+			want: []string{"schema/001_users.sql", "schema/002_backfill.sql", "schema/003_orders.sql", "schema/004_partition.sql", "seed/002_admins.sql"},
+		},
+		{
+			name: "directory tag",
+			m:    Migrate{Tags: []string{"seed"}},
+			want: []string{"schema/001_users.sql", "schema/003_orders.sql", "seed/001_users.sql", "seed/002_admins.sql"},
+		},
+		{
+			name: "exclude wins",
+			m:    Migrate{Tags: []string{"seed", "slow"}, ExcludeTags: []string{"big"}},
+			want: []string{"schema/001_users.sql", "schema/002_backfill.sql", "schema/003_orders.sql", "seed/001_users.sql", "seed/002_admins.sql"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.m.FS = MapFS(files)
+			tt.m.Order = []string{"schema"}
+
+			driver := &execDriver{}
+			if _, err := tt.m.Migrate(context.Background(), driver); err != nil {
+				t.Fatalf("Migrate() error = %v", err)
+			}
+
+			if !slices.Equal(driver.calls, tt.want) {
+				t.Errorf("applied = %v, want %v", driver.calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrateStatusTags(t *testing.T) {
+	m := Migrate{FS: MapFS(map[string]string{
+		"migrations/001_users.sql": "CREATE TABLE users();",
+		"migrations/002_seed.sql":  "-- muz:tags seed\nINSERT INTO users DEFAULT VALUES;",
+	})}
+
+	driver := &recordDriver{records: []Record{{Version: 2, Directory: ".", FileName: "002_seed.sql"}}}
+
+	status, err := m.Status(context.Background(), driver)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+
+	for _, f := range status.Files {
+		if f.State == StateMissing {
+			t.Errorf("Status() reports %s as missing, the file is only filtered by tags", f.File)
+		}
+	}
+}
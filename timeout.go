@@ -0,0 +1,28 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrFileTimeout is returned when a migration file runs longer than Migrate.FileTimeout.
+var ErrFileTimeout = errors.New("migration file timed out")
+
+// withFileTimeout calls fn with ctx limited by FileTimeout.
+// The drivers pass the context to their queries, database/sql and pgx cancel them on the server when it expires.
+func (m Migrate) withFileTimeout(ctx context.Context, fn func(ctx context.Context) error) error {
+	if m.FileTimeout <= 0 {
+		return fn(ctx)
+	}
+
+	fileCtx, cancel := context.WithTimeout(ctx, m.FileTimeout)
+	defer cancel()
+
+	err := fn(fileCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(fileCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s: %w", ErrFileTimeout, m.FileTimeout, err)
+	}
+
+	return err
+}
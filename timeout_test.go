@@ -0,0 +1,67 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// slowDriver blocks on the files in slow until their context is done, like a runaway query.
+type slowDriver struct {
+	recordDriver
+
+	slow map[string]bool
+}
+
+func (d *slowDriver) Process(ctx context.Context, data *Muzo) error {
+	for _, file := range data.Files {
+		if d.slow[file.Path] {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+	}
+
+	return d.recordDriver.Process(ctx, data)
+}
+
+func TestMigrateFileTimeout(t *testing.T) {
+	m := Migrate{
+		FS: MapFS(map[string]string{
+			"migrations/app/001_users.sql":    "CREATE TABLE users();",
+			"migrations/app/002_backfill.sql": "UPDATE users SET name = lower(name);",
+		}),
+		FileTimeout: 20 * time.Millisecond,
+	}
+
+	driver := &slowDriver{slow: map[string]bool{"002_backfill.sql": true}}
+
+	result, err := m.Migrate(context.Background(), driver)
+	if !errors.Is(err, ErrFileTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Migrate() error = %v, want ErrFileTimeout", err)
+	}
+
+	if len(result.Files) != 2 || result.Files[0].Outcome != OutcomeApplied || result.Files[1].Outcome != OutcomeFailed {
+		t.Errorf("result files = %+v, want 001 applied and 002 failed", result.Files)
+	}
+
+	if IsTransient(err) {
+		t.Errorf("IsTransient(%v) = true, a timed out file is not retried", err)
+	}
+}
+
+func TestMigrateFileTimeoutCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	m := Migrate{
+		FS:          MapFS(map[string]string{"migrations/app/001_users.sql": "CREATE TABLE users();"}),
+		FileTimeout: time.Hour,
+	}
+
+	// The run itself is canceled, not the file.
+	_, err := m.Migrate(ctx, &slowDriver{slow: map[string]bool{"001_users.sql": true}})
+	if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrFileTimeout) {
+		t.Errorf("Migrate() error = %v, want the deadline of the run", err)
+	}
+}
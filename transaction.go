@@ -0,0 +1,133 @@
+package muz
+
+import (
+	"errors"
+	"iter"
+	"slices"
+	"strings"
+)
+
+// ErrNeedsNoTransaction is returned for a file with a statement that cannot run inside a transaction,
+// like CREATE INDEX CONCURRENTLY, VACUUM or CREATE DATABASE, without the no-transaction directive.
+var ErrNeedsNoTransaction = errors.New("statement cannot run inside a transaction")
+
+// nonTransactional returns the leading keywords of the first statement of content that cannot run
+// inside a transaction block, like "CREATE INDEX CONCURRENTLY", or "" when there is none.
+func nonTransactional(content string) string {
+	for _, statement := range SplitStatements(content) {
+		if kind := nonTransactionalStatement(statement); kind != "" {
+			return kind
+		}
+	}
+
+	return ""
+}
+
+// nonTransactionalStatement returns the leading keywords of statement when it cannot run
+// inside a transaction block, or "".
+func nonTransactionalStatement(statement string) string {
+	words := strings.Fields(strings.ToUpper(stripComments(statement)))
+	if len(words) == 0 {
+		return ""
+	}
+
+	return nonTransactionalKind(words)
+}
+
+// nonTransactionalKind matches the upper cased words of a statement against the statements
+// PostgreSQL refuses inside a transaction block.
+func nonTransactionalKind(words []string) string {
+	next := func(i int) string {
+		if i < len(words) {
+			return strings.TrimRight(words[i], "(")
+		}
+
+		return ""
+	}
+
+	switch words[0] {
+	case "VACUUM":
+		return "VACUUM"
+	case "ALTER":
+		switch {
+		case next(1) == "SYSTEM":
+			return "ALTER SYSTEM"
+		case next(1) == "TABLE" && slices.Contains(words, "DETACH") && slices.Contains(words, "CONCURRENTLY"):
+			return "ALTER TABLE DETACH PARTITION CONCURRENTLY"
+		}
+	case "CREATE", "DROP":
+		i := 1
+		if words[0] == "CREATE" && next(i) == "UNIQUE" {
+			i++
+		}
+
+		switch next(i) {
+		case "DATABASE", "TABLESPACE":
+			return words[0] + " " + next(i)
+		case "INDEX":
+			if next(i+1) == "CONCURRENTLY" {
+				return words[0] + " INDEX CONCURRENTLY"
+			}
+		}
+	case "REINDEX":
+		// REINDEX [ ( options ) ] { INDEX | TABLE | SCHEMA | DATABASE | SYSTEM } CONCURRENTLY name
+		if slices.Contains(words[:min(len(words), 6)], "CONCURRENTLY") {
+			return "REINDEX CONCURRENTLY"
+		}
+	}
+
+	return ""
+}
+
+// stripComments returns statement without its "--" and "/* */" comments.
+func stripComments(statement string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(statement); {
+		switch {
+		case strings.HasPrefix(statement[i:], "--"):
+			i = skipLine(statement, i)
+		case strings.HasPrefix(statement[i:], "/*"):
+			i = skipBlockComment(statement, i)
+			b.WriteByte(' ')
+		case statement[i] == '\'' || statement[i] == '"':
+			end := skipQuoted(statement, i, false)
+			b.WriteString(statement[i:end])
+			i = end
+		default:
+			b.WriteByte(statement[i])
+			i++
+		}
+	}
+
+	return b.String()
+}
+
+// withAutoNoTransaction marks the directories to run files needing it outside of the transaction,
+// with AutoNoTransaction or the TxMode of their DirConfig.
+func (m Migrate) withAutoNoTransaction(list iter.Seq2[*Muzo, error]) iter.Seq2[*Muzo, error] {
+	if !m.AutoNoTransaction && len(m.DirConfig) == 0 {
+		return list
+	}
+
+	return func(yield func(*Muzo, error) bool) {
+		for info, err := range list {
+			if info != nil {
+				switch m.dirOptions(info.Dir).TxMode {
+				case TxModeNone:
+					info.noTx = true
+				case TxModeAuto:
+					info.autoNoTx = true
+				case TxModeTransaction:
+					// Only the directive takes a file out of the transaction.
+				default:
+					info.autoNoTx = m.AutoNoTransaction
+				}
+			}
+
+			if !yield(info, err) {
+				return
+			}
+		}
+	}
+}
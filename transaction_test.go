@@ -0,0 +1,57 @@
+package muz
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNonTransactional(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{content: "CREATE INDEX CONCURRENTLY idx ON users (name);", want: "CREATE INDEX CONCURRENTLY"},
+		{content: "CREATE TABLE t();\n-- the index\ncreate unique index concurrently idx on t (id);", want: "CREATE INDEX CONCURRENTLY"},
+		{content: "DROP INDEX CONCURRENTLY IF EXISTS idx;", want: "DROP INDEX CONCURRENTLY"},
+		{content: "REINDEX (VERBOSE) TABLE CONCURRENTLY users;", want: "REINDEX CONCURRENTLY"},
+		{content: "ALTER TABLE events DETACH PARTITION events_2023 CONCURRENTLY;", want: "ALTER TABLE DETACH PARTITION CONCURRENTLY"},
+		{content: "/* cleanup */ VACUUM ANALYZE users;", want: "VACUUM"},
+		{content: "CREATE DATABASE reports;", want: "CREATE DATABASE"},
+		{content: "ALTER SYSTEM SET work_mem = '64MB';", want: "ALTER SYSTEM"},
+		{content: "CREATE INDEX idx ON users (name);", want: ""},
+		{content: "REFRESH MATERIALIZED VIEW CONCURRENTLY stats;", want: ""},
+		{content: "-- VACUUM;\nINSERT INTO notes VALUES ('VACUUM; CREATE DATABASE x');", want: ""},
+		{content: "CREATE FUNCTION f() RETURNS void AS $$ BEGIN VACUUM; END $$ LANGUAGE plpgsql;", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := nonTransactional(tt.content); got != tt.want {
+			t.Errorf("nonTransactional(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestAutoNoTransaction(t *testing.T) {
+	files := map[string]string{
+		"migrations/app/001_users.sql": "CREATE TABLE users();",
+		"migrations/app/002_index.sql": "CREATE INDEX CONCURRENTLY users_idx ON users (id);",
+	}
+
+	var out bytes.Buffer
+	_, err := Migrate{FS: MapFS(files)}.Migrate(context.Background(), &PostgresDriver{DryRun: &out})
+	if !errors.Is(err, ErrNeedsNoTransaction) || !strings.Contains(err.Error(), "app/002_index.sql") {
+		t.Fatalf("Migrate() error = %v, want ErrNeedsNoTransaction for app/002_index.sql", err)
+	}
+
+	out.Reset()
+	if _, err := (Migrate{FS: MapFS(files), AutoNoTransaction: true}).Migrate(context.Background(), &PostgresDriver{DryRun: &out}); err != nil {
+		t.Fatalf("Migrate() with AutoNoTransaction error = %v", err)
+	}
+
+	if want := "COMMIT;\n\n-- app/002_index.sql (version 2)\nCREATE INDEX CONCURRENTLY"; !strings.Contains(out.String(), want) {
+		t.Errorf("dry run output missing %q, got:\n%s", want, out.String())
+	}
+}
@@ -0,0 +1,275 @@
+package muz
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+)
+
+// IssueKind is the kind of a validation issue.
+type IssueKind string
+
+const (
+	// IssueDuplicateVersion is reported when a directory has more than one file with the same version.
+	IssueDuplicateVersion IssueKind = "duplicate_version"
+	// IssueVersionGap is reported when versions of a directory are not consecutive.
+	IssueVersionGap IssueKind = "version_gap"
+	// IssueEmptyFile is reported for migration files without content.
+	IssueEmptyFile IssueKind = "empty_file"
+	// IssueMissingPrefix is reported for files that are ignored because they have no numeric prefix.
+	IssueMissingPrefix IssueKind = "missing_prefix"
+	// IssueVersionCollision is reported with Linear when directories use the same version.
+	IssueVersionCollision IssueKind = "version_collision"
+	// IssueUnusedSkip is reported for Skip patterns that match nothing.
+	IssueUnusedSkip IssueKind = "unused_skip"
+	// IssueNotListed is reported for files of a directory with an order.yaml that it does not list.
+	IssueNotListed IssueKind = "not_listed"
+	// IssueNeedsNoTransaction is reported for files with statements that cannot run inside a transaction,
+	// without the no-transaction directive or AutoNoTransaction.
+	IssueNeedsNoTransaction IssueKind = "needs_no_transaction"
+)
+
+// Severity of a validation issue.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// ValidationIssue is a single problem found in the migration tree.
+type ValidationIssue struct {
+	Kind     IssueKind `json:"kind"`
+	Severity Severity  `json:"severity"`
+	Dir      string    `json:"dir,omitempty"`
+	File     string    `json:"file,omitempty"`
+	Version  int64     `json:"version,omitempty"`
+	Message  string    `json:"message"`
+}
+
+// ValidationReport is the result of Validate.
+type ValidationReport struct {
+	Issues []ValidationIssue `json:"issues"`
+}
+
+// HasErrors reports whether the report contains issues with error severity.
+func (r *ValidationReport) HasErrors() bool {
+	return slices.ContainsFunc(r.Issues, func(i ValidationIssue) bool {
+		return i.Severity == SeverityError
+	})
+}
+
+func (r *ValidationReport) add(issue ValidationIssue) {
+	r.Issues = append(r.Issues, issue)
+}
+
+// Validate checks the migration tree without touching a database.
+//
+// Duplicate versions, and with Linear versions used by more than one directory, are errors.
+// So are statements like CREATE INDEX CONCURRENTLY in files running inside a transaction.
+// So are files missing from the order.yaml of their directory.
+// Version gaps, empty files, files without numeric prefix and Skip patterns matching nothing are warnings.
+// With Strict, gaps, files without numeric prefix and unused Skip patterns are errors too.
+// The checks on ignored files and Skip patterns need the default filesystem source.
+func (m Migrate) Validate() (*ValidationReport, error) {
+	report := &ValidationReport{Issues: []ValidationIssue{}}
+
+	if m.Source == nil {
+		if err := m.validateTree(report); err != nil {
+			return nil, err
+		}
+	}
+
+	// version to the first file using it, for Linear
+	versions := make(map[int64]string)
+
+	for info, err := range m.all() {
+		if err != nil {
+			return nil, err
+		}
+
+		if err := validateDir(info, report); err != nil {
+			return nil, err
+		}
+
+		if m.Linear {
+			validateLinear(info, versions, report)
+		}
+	}
+
+	if m.Strict {
+		report.strict()
+	}
+
+	return report, nil
+}
+
+// validateTree reports files without numeric prefix or missing from an order file, and unused skip patterns.
+func (m *Migrate) validateTree(report *ValidationReport) error {
+	fileSystem, closeFS, err := m.openFS(m.rootPath())
+	if err != nil {
+		return err
+	}
+	defer closeFS()
+
+	used := make([]bool, len(m.Skip))
+
+	err = fs.WalkDir(fileSystem, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		for i, skip := range m.Skip {
+			if skipMatch(skip, path) || (d.IsDir() && skipDirMatch(skip, path)) {
+				used[i] = true
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, skip := range m.Skip {
+		if !used[i] {
+			report.add(ValidationIssue{
+				Kind:     IssueUnusedSkip,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("skip pattern %q matches nothing", skip),
+			})
+		}
+	}
+
+	dirs, names, err := m.walkTree(fileSystem)
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range m.sortDirs(dirs) {
+		if hasOrder(names[dir]) {
+			_, unlisted, err := m.orderedFiles(fileSystem, dir, names[dir])
+			if err != nil {
+				return err
+			}
+
+			for _, name := range unlisted {
+				report.add(ValidationIssue{
+					Kind:     IssueNotListed,
+					Severity: SeverityError,
+					Dir:      dir,
+					File:     name,
+					Message:  fmt.Sprintf("file is not listed in %s and is ignored", orderFile),
+				})
+			}
+
+			continue
+		}
+
+		if m.IncludeUnnumbered {
+			continue
+		}
+
+		_, unnumbered := m.selectFiles(dir, names[dir])
+
+		for _, name := range unnumbered {
+			report.add(ValidationIssue{
+				Kind:     IssueMissingPrefix,
+				Severity: SeverityWarning,
+				Dir:      dir,
+				File:     name,
+				Message:  "file has no numeric prefix and is ignored",
+			})
+		}
+	}
+
+	return nil
+}
+
+// validateDir reports duplicate versions, gaps and empty files of a directory.
+// Timestamp versions are not sequential, gaps before them are not reported.
+func validateDir(info *Muzo, report *ValidationReport) error {
+	var prev int64
+	for i, file := range info.Files {
+		if i > 0 && file.Version == info.Files[i-1].Version {
+			report.add(ValidationIssue{
+				Kind:     IssueDuplicateVersion,
+				Severity: SeverityError,
+				Dir:      info.Dir,
+				File:     file.Path,
+				Version:  file.Version,
+				Message:  fmt.Sprintf("version %d is also used by %s", file.Version, info.Files[i-1].Path),
+			})
+		} else if i > 0 && file.Version > prev+1 && file.Version < timestampVersion {
+			report.add(ValidationIssue{
+				Kind:     IssueVersionGap,
+				Severity: SeverityWarning,
+				Dir:      info.Dir,
+				File:     file.Path,
+				Version:  file.Version,
+				Message:  fmt.Sprintf("versions %d to %d are missing", prev+1, file.Version-1),
+			})
+		}
+
+		prev = file.Version
+
+		if info.GoMigration(file.Path) != nil {
+			continue
+		}
+
+		content, err := info.ReadFile(file.Path)
+		if err != nil {
+			return err
+		}
+
+		if len(bytes.TrimSpace(content)) == 0 {
+			report.add(ValidationIssue{
+				Kind:     IssueEmptyFile,
+				Severity: SeverityWarning,
+				Dir:      info.Dir,
+				File:     file.Path,
+				Version:  file.Version,
+				Message:  "file is empty",
+			})
+		}
+
+		if kind := nonTransactional(string(content)); kind != "" && !info.autoNoTx && !info.noTx && !hasDirective(content, DirectiveNoTransaction) {
+			report.add(ValidationIssue{
+				Kind:     IssueNeedsNoTransaction,
+				Severity: SeverityError,
+				Dir:      info.Dir,
+				File:     file.Path,
+				Version:  file.Version,
+				Message:  fmt.Sprintf("%s cannot run inside a transaction, add the %s directive", kind, DirectiveNoTransaction),
+			})
+		}
+	}
+
+	return nil
+}
+
+// validateLinear reports versions of info already used by another directory.
+func validateLinear(info *Muzo, versions map[int64]string, report *ValidationReport) {
+	for _, file := range info.Files {
+		other, ok := versions[file.Version]
+		if !ok {
+			versions[file.Version] = path.Join(info.Dir, file.Path)
+			continue
+		}
+
+		if path.Dir(other) == info.Dir {
+			continue // reported as duplicate version
+		}
+
+		report.add(ValidationIssue{
+			Kind:     IssueVersionCollision,
+			Severity: SeverityError,
+			Dir:      info.Dir,
+			File:     file.Path,
+			Version:  file.Version,
+			Message:  fmt.Sprintf("version %d is also used by %s", file.Version, other),
+		})
+	}
+}
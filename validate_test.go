@@ -0,0 +1,142 @@
+package muz
+
+import (
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	m := Migrate{
+		Path: ".",
+		FS: MapFS(map[string]string{
+			"1_init.sql":           "CREATE TABLE a();",
+			"2_users.sql":          "CREATE TABLE b();",
+			"2_users_again.sql":    "CREATE TABLE c();",
+			"5_gap.sql":            "CREATE TABLE d();",
+			"6_empty.sql":          "  \n",
+			"7_index.sql":          "CREATE INDEX CONCURRENTLY a_idx ON a (id);",
+			"readme.md":            "docs",
+			"seed/1_data.sql":      "INSERT INTO a DEFAULT VALUES;",
+			"seed/1_data.down.sql": "DELETE FROM a;",
+		}),
+		Skip: []string{"/seed/*.bak", "**/readme.txt", "/unknown/**"},
+	}
+
+	report, err := m.Validate()
+	if err != nil {
+		t.Fatalf("Validate error: %v", err)
+	}
+
+	type issue struct {
+		kind IssueKind
+		dir  string
+		file string
+	}
+
+	var got []issue
+	for _, i := range report.Issues {
+		got = append(got, issue{kind: i.Kind, dir: i.Dir, file: i.File})
+	}
+
+	want := []issue{
+		{kind: IssueUnusedSkip},
+		{kind: IssueUnusedSkip},
+		{kind: IssueUnusedSkip},
+		{kind: IssueMissingPrefix, dir: ".", file: "readme.md"},
+		{kind: IssueDuplicateVersion, dir: ".", file: "2_users_again.sql"},
+		{kind: IssueVersionGap, dir: ".", file: "5_gap.sql"},
+		{kind: IssueEmptyFile, dir: ".", file: "6_empty.sql"},
+		{kind: IssueNeedsNoTransaction, dir: ".", file: "7_index.sql"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got issues %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("issue[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if !report.HasErrors() {
+		t.Errorf("expected HasErrors for duplicate version")
+	}
+}
+
+func TestValidateClean(t *testing.T) {
+	m := Migrate{
+		Path: ".",
+		FS: MapFS(map[string]string{
+			"1_init.sql":                  "CREATE TABLE a();",
+			"2_users.sql":                 "CREATE TABLE b();",
+			"20240101120000_orders.sql":   "CREATE TABLE c();",
+			"20240315093000_invoices.sql": "CREATE TABLE d();",
+			"test/1_test.sql":             "SELECT 1;",
+		}),
+		Skip: []string{"/test/**"},
+	}
+
+	report, err := m.Validate()
+	if err != nil {
+		t.Fatalf("Validate error: %v", err)
+	}
+
+	if len(report.Issues) != 0 {
+		t.Errorf("expected no issues, got %+v", report.Issues)
+	}
+}
+
+func TestValidateIncludeUnnumbered(t *testing.T) {
+	m := Migrate{
+		Path: ".",
+		FS: MapFS(map[string]string{
+			"1_init.sql":    "CREATE TABLE a();",
+			"grants.sql":    "GRANT SELECT ON a TO reader;",
+			"seed/1.sql":    "INSERT INTO a DEFAULT VALUES;",
+			"seed/bulk.sql": "INSERT INTO a DEFAULT VALUES;",
+		}),
+		IncludeUnnumbered: true,
+	}
+
+	report, err := m.Validate()
+	if err != nil {
+		t.Fatalf("Validate error: %v", err)
+	}
+
+	if len(report.Issues) != 0 {
+		t.Errorf("expected no issues, got %+v", report.Issues)
+	}
+}
+
+func TestValidateStrict(t *testing.T) {
+	m := Migrate{
+		Path: ".",
+		FS: MapFS(map[string]string{
+			"1_init.sql":  "CREATE TABLE a();",
+			"3_gap.sql":   "CREATE TABLE b();",
+			"readme.md":   "docs",
+			"6_empty.sql": "",
+		}),
+		Skip:   []string{"/unknown/**"},
+		Strict: true,
+	}
+
+	report, err := m.Validate()
+	if err != nil {
+		t.Fatalf("Validate error: %v", err)
+	}
+
+	for _, i := range report.Issues {
+		want := SeverityError
+		if i.Kind == IssueEmptyFile {
+			want = SeverityWarning
+		}
+
+		if i.Severity != want {
+			t.Errorf("issue %s severity = %s, want %s", i.Kind, i.Severity, want)
+		}
+	}
+
+	if len(report.Issues) != 5 {
+		t.Errorf("got issues %+v, want unused skip, missing prefix, two gaps and empty file", report.Issues)
+	}
+}
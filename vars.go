@@ -0,0 +1,207 @@
+package muz
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"iter"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// SecretResolver returns secret values for placeholders like ${vault:db/app#password},
+// keeping credentials of users and grants out of the migration files.
+// The scheme before the colon selects the resolver in Migrate.Secrets, name is the text after it.
+type SecretResolver interface {
+	Resolve(name string) (string, error)
+}
+
+// SecretResolverFunc adapts a function, like a call to a Vault or cloud secret manager client, to a SecretResolver.
+type SecretResolverFunc func(name string) (string, error)
+
+func (f SecretResolverFunc) Resolve(name string) (string, error) {
+	return f(name)
+}
+
+// EnvSecrets resolves secrets from environment variables, ${env:DB_PASSWORD} reads DB_PASSWORD.
+// An unset variable is an error, an empty one is not.
+type EnvSecrets struct{}
+
+func (EnvSecrets) Resolve(name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+
+	return v, nil
+}
+
+// FileSecrets resolves secrets from the files of a directory, like Docker or Kubernetes secrets
+// mounted in /run/secrets. ${file:db_password} reads Dir/db_password, without its trailing line break.
+type FileSecrets struct {
+	// Dir is the directory holding one file per secret.
+	Dir string
+}
+
+func (f FileSecrets) Resolve(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", fmt.Errorf("secret %s is outside of %s", name, f.Dir)
+	}
+
+	content, err := os.ReadFile(filepath.Join(f.Dir, filepath.FromSlash(name)))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// //////////////////////////////
+
+// substitutes reports whether placeholders of the files are replaced.
+func (m Migrate) substitutes() bool {
+	return m.Vars != nil || m.Secrets != nil
+}
+
+// withVars sets the placeholder lookup of the directories when Vars or Secrets is set.
+func (m Migrate) withVars(list iter.Seq2[*Muzo, error]) iter.Seq2[*Muzo, error] {
+	if !m.substitutes() {
+		return list
+	}
+
+	return func(yield func(*Muzo, error) bool) {
+		lookup := m.placeholders()
+
+		for info, err := range list {
+			if info != nil {
+				info.vars = lookup
+			}
+
+			if !yield(info, err) {
+				return
+			}
+		}
+	}
+}
+
+// placeholders returns the lookup of placeholder values. ${scheme:name} is resolved by Secrets once and cached,
+// other names come from Vars, then from the environment. It reports false for schemes missing from Secrets,
+// keeping text like ${VAR:-default} unchanged.
+func (m Migrate) placeholders() func(name string) (string, bool, error) {
+	var (
+		mu      sync.Mutex
+		secrets = make(map[string]string)
+	)
+
+	return func(name string) (string, bool, error) {
+		if scheme, ref, ok := strings.Cut(name, ":"); ok {
+			resolver := m.Secrets[scheme]
+			if resolver == nil {
+				return "", false, nil
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if v, ok := secrets[name]; ok {
+				return v, true, nil
+			}
+
+			v, err := resolver.Resolve(ref)
+			if err != nil {
+				return "", false, fmt.Errorf("resolving secret ${%s}: %w", name, err)
+			}
+
+			secrets[name] = v
+
+			return v, true, nil
+		}
+
+		if v, ok := m.Vars[name]; ok {
+			return v, true, nil
+		}
+
+		if v, ok := os.LookupEnv(name); ok {
+			return v, true, nil
+		}
+
+		return "", false, fmt.Errorf("variable ${%s} is not set", name)
+	}
+}
+
+// substitute replaces the ${NAME} and ${scheme:name} placeholders of content, read from file, with their values.
+// "$${NAME}" is written as "${NAME}", placeholders that are not a name like ${a + b} are kept.
+func substitute(content []byte, file string, lookup func(name string) (string, bool, error)) ([]byte, error) {
+	if !bytes.Contains(content, []byte("${")) {
+		return content, nil
+	}
+
+	var buf bytes.Buffer
+
+	for i := 0; i < len(content); {
+		rest := content[i:]
+
+		if bytes.HasPrefix(rest, []byte("$${")) {
+			buf.WriteString("${")
+			i += len("$${")
+
+			continue
+		}
+
+		if !bytes.HasPrefix(rest, []byte("${")) {
+			buf.WriteByte(content[i])
+			i++
+
+			continue
+		}
+
+		end := bytes.IndexByte(rest, '}')
+		if end < 0 || !isPlaceholder(rest[2:end]) {
+			buf.WriteString("${")
+			i += len("${")
+
+			continue
+		}
+
+		value, ok, err := lookup(string(rest[2:end]))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		if !ok {
+			buf.Write(rest[:end+1])
+		} else {
+			buf.WriteString(value)
+		}
+		i += end + 1
+	}
+
+	return buf.Bytes(), nil
+}
+
+// isPlaceholder reports whether text is a variable name like DB_OWNER or app.schema,
+// or a secret reference like vault:db/app#password.
+func isPlaceholder(text []byte) bool {
+	if scheme, ref, ok := bytes.Cut(text, []byte(":")); ok {
+		return isVarName(scheme) && len(ref) > 0 && !bytes.ContainsAny(ref, " \t\r\n")
+	}
+
+	return isVarName(text)
+}
+
+// isVarName reports whether name is usable as a variable name.
+func isVarName(name []byte) bool {
+	if len(name) == 0 {
+		return false
+	}
+
+	for _, c := range name {
+		if c != '.' && c != '-' && !isIdentByte(c) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,134 @@
+package muz
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSubstitute(t *testing.T) {
+	dir := t.TempDir()
+	mustWriteFile(t, filepath.Join(dir, "db_password"), []byte("s3cret\n"))
+
+	calls := 0
+	m := Migrate{
+		Vars: map[string]string{"OWNER": "app", "app.schema": "tenant_1"},
+		Secrets: map[string]SecretResolver{
+			"file": FileSecrets{Dir: dir},
+			"vault": SecretResolverFunc(func(name string) (string, error) {
+				calls++
+				return "from-" + name, nil
+			}),
+		},
+	}
+	lookup := m.placeholders()
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{name: "placeholders", content: "CREATE SCHEMA ${app.schema} AUTHORIZATION ${OWNER};", want: "CREATE SCHEMA tenant_1 AUTHORIZATION app;"},
+		{name: "escaped", content: "SELECT '$${OWNER}', '${OWNER}';", want: "SELECT '${OWNER}', 'app';"},
+		{name: "not a name", content: "SELECT $$ `${a + b}` $$, '${', '$5';", want: "SELECT $$ `${a + b}` $$, '${', '$5';"},
+		{name: "unresolved", content: "GRANT ALL TO ${READER};", wantErr: "variable ${READER} is not set"},
+		{
+			name:    "secrets",
+			content: "CREATE USER app PASSWORD '${file:db_password}';\nALTER USER ops PASSWORD '${vault:kv/ops#password}' VALID UNTIL '${vault:kv/ops#password}';",
+			want:    "CREATE USER app PASSWORD 's3cret';\nALTER USER ops PASSWORD 'from-kv/ops#password' VALID UNTIL 'from-kv/ops#password';",
+		},
+		{name: "unknown scheme", content: "SELECT '${VAR:-default}';", want: "SELECT '${VAR:-default}';"},
+		{name: "missing secret", content: "SELECT '${file:missing}';", wantErr: "resolving secret ${file:missing}"},
+		{name: "secret outside of the directory", content: "SELECT '${file:../passwd}';", wantErr: "outside of"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := substitute([]byte(tt.content), "schema/1_init.sql", lookup)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("substitute() error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("substitute() error = %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("substitute() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if calls != 1 {
+		t.Errorf("vault resolver called %d times, want once", calls)
+	}
+}
+
+func TestMigrateVars(t *testing.T) {
+	t.Setenv("MUZ_TEST_READER", "reader")
+
+	files := MapFS(map[string]string{
+		"migrations/before_migrate.sql": "SET ROLE ${OWNER};",
+		"migrations/app/001_users.sql":  "CREATE TABLE users();\nGRANT SELECT ON users TO ${MUZ_TEST_READER};",
+	})
+
+	m := Migrate{FS: files, Vars: map[string]string{"OWNER": "app"}}
+
+	var contents []string
+	for info, err := range m.Iter() {
+		if err != nil {
+			t.Fatalf("Iter() error = %v", err)
+		}
+
+		for _, file := range info.Files {
+			content, err := info.ReadFile(file.Path)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+
+			contents = append(contents, string(content))
+		}
+	}
+
+	if want := "CREATE TABLE users();\nGRANT SELECT ON users TO reader;"; len(contents) != 1 || contents[0] != want {
+		t.Errorf("contents = %q, want %q", contents, want)
+	}
+
+	driver := &execDriver{}
+	if _, err := m.Migrate(context.Background(), driver); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	if len(driver.calls) == 0 || driver.calls[0] != "SET ROLE app;" {
+		t.Errorf("calls = %q, want the callback with its placeholder replaced first", driver.calls)
+	}
+
+	// Changing a value does not change the checksum of an applied file.
+	other := m
+	other.Vars = map[string]string{"OWNER": "admin"}
+
+	before, err := m.Status(context.Background(), &recordDriver{})
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+
+	after, err := other.Status(context.Background(), &recordDriver{})
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+
+	if before.Files[0].Checksum != after.Files[0].Checksum {
+		t.Errorf("checksum changed with the placeholder values")
+	}
+
+	m.Vars = map[string]string{}
+	if _, err := m.Migrate(context.Background(), &execDriver{}); err == nil || !strings.Contains(err.Error(), "${OWNER} is not set") {
+		t.Errorf("Migrate() error = %v, want unresolved ${OWNER}", err)
+	}
+}
@@ -0,0 +1,116 @@
+package muz
+
+import (
+	"context"
+	"fmt"
+)
+
+// DriftKind is a difference between the migration files and the tracking table.
+type DriftKind string
+
+const (
+	// DriftModified is an applied file whose checksum differs from the recorded one.
+	DriftModified DriftKind = "modified"
+	// DriftMissing is a recorded migration whose file was deleted.
+	DriftMissing DriftKind = "missing"
+	// DriftPending is a file not applied yet.
+	DriftPending DriftKind = "pending"
+	// DriftSkipped is a file older than the latest applied version of its directory, it never runs.
+	DriftSkipped DriftKind = "skipped"
+)
+
+// Drift is a single difference found by Verify.
+type Drift struct {
+	Kind    DriftKind `json:"kind"`
+	Dir     string    `json:"dir"`
+	File    string    `json:"file"`
+	Version int64     `json:"version"`
+	// Checksum is the checksum of the file, Recorded the one of the tracking table.
+	Checksum string `json:"checksum,omitempty"`
+	Recorded string `json:"recorded,omitempty"`
+}
+
+// DriftReport is the result of Verify.
+type DriftReport struct {
+	Drifts []Drift `json:"drifts"`
+}
+
+// HasDrift reports whether the files and the tracking table differ.
+func (r *DriftReport) HasDrift() bool {
+	return len(r.Drifts) > 0
+}
+
+// Count returns the number of drifts of a kind.
+func (r *DriftReport) Count(kind DriftKind) int {
+	n := 0
+	for _, d := range r.Drifts {
+		if d.Kind == kind {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Verify compares the tracking table with the migration files, reporting edited, deleted, pending
+// and skipped files, in the order of Status. Records without a checksum are not reported as edited.
+// The driver must implement StatusReporter.
+func (m Migrate) Verify(ctx context.Context, driver Driver) (*DriftReport, error) {
+	reporter, ok := driver.(StatusReporter)
+	if !ok {
+		return nil, fmt.Errorf("verify: %w", ErrNotSupported)
+	}
+
+	records, err := reporter.History(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		dir     string
+		version int64
+	}
+
+	recorded := make(map[key]string, len(records))
+	names := make(map[fileKey]string, len(records))
+	for _, r := range records {
+		recorded[key{r.Directory, r.Version}] = r.Checksum
+		names[fileKey{r.Directory, r.FileName}] = r.Checksum
+	}
+
+	status, err := m.Status(ctx, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DriftReport{Drifts: []Drift{}}
+	for _, f := range status.Files {
+		d := Drift{Dir: f.Dir, File: f.File, Version: f.Version, Checksum: f.Checksum}
+
+		switch f.State {
+		case StateApplied:
+			d.Recorded = recorded[key{f.Dir, f.Version}]
+			if m.Match != MatchVersion {
+				// A file matched by its checksum under another name is not edited.
+				d.Recorded = names[fileKey{f.Dir, f.File}]
+			}
+
+			if d.Recorded == "" || d.Recorded == f.Checksum {
+				continue
+			}
+
+			d.Kind = DriftModified
+		case StateMissing:
+			d.Kind = DriftMissing
+			d.Recorded = recorded[key{f.Dir, f.Version}]
+		case StatePending:
+			d.Kind = DriftPending
+		case StateSkipped:
+			d.Kind = DriftSkipped
+		}
+
+		report.Drifts = append(report.Drifts, d)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,90 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	files := MapFS(map[string]string{
+		"migrations/app/001_users.sql": "CREATE TABLE users (id int, name text);",
+		"migrations/app/002_posts.sql": "CREATE TABLE posts();",
+		"migrations/app/004_tags.sql":  "CREATE TABLE tags();",
+	})
+
+	users := checksum([]byte("CREATE TABLE users (id int, name text);"))
+	edited := checksum([]byte("CREATE TABLE users (id int);"))
+
+	tests := []struct {
+		name    string
+		records []Record
+		want    []DriftKind
+	}{
+		{
+			name: "no drift",
+			records: []Record{
+				{Version: 1, Directory: "app", FileName: "001_users.sql", Checksum: users},
+				{Version: 2, Directory: "app", FileName: "002_posts.sql"},
+				{Version: 4, Directory: "app", FileName: "004_tags.sql"},
+			},
+			want: []DriftKind{},
+		},
+		{
+			name: "modified",
+			records: []Record{
+				{Version: 1, Directory: "app", FileName: "001_users.sql", Checksum: edited},
+				{Version: 2, Directory: "app", FileName: "002_posts.sql"},
+				{Version: 4, Directory: "app", FileName: "004_tags.sql"},
+			},
+			want: []DriftKind{DriftModified},
+		},
+		{
+			name: "pending",
+			records: []Record{
+				{Version: 1, Directory: "app", FileName: "001_users.sql", Checksum: users},
+			},
+			want: []DriftKind{DriftPending, DriftPending},
+		},
+		{
+			name: "missing and skipped",
+			records: []Record{
+				{Version: 1, Directory: "app", FileName: "001_users.sql", Checksum: users},
+				{Version: 3, Directory: "app", FileName: "003_likes.sql"},
+				{Version: 4, Directory: "app", FileName: "004_tags.sql"},
+			},
+			want: []DriftKind{DriftSkipped, DriftMissing},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Migrate{FS: files}.Verify(context.Background(), &recordDriver{records: tt.records})
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+
+			got := []DriftKind{}
+			for _, d := range report.Drifts {
+				got = append(got, d.Kind)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("drifts = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("drifts = %v, want %v", got, tt.want)
+				}
+			}
+
+			if report.HasDrift() != (len(tt.want) > 0) {
+				t.Errorf("HasDrift() = %v, want %v", report.HasDrift(), len(tt.want) > 0)
+			}
+		})
+	}
+
+	if _, err := (Migrate{FS: files}).Verify(context.Background(), nopDriver{}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Verify() without StatusReporter error = %v, want ErrNotSupported", err)
+	}
+}
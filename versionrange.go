@@ -0,0 +1,72 @@
+package muz
+
+import (
+	"iter"
+	"slices"
+)
+
+// VersionRange limits the files considered to versions from Min to Max, both included.
+// Zero means no limit on that side.
+type VersionRange struct {
+	Min int64 `cfg:"min" json:"min"`
+	Max int64 `cfg:"max" json:"max"`
+}
+
+// contains reports whether version is inside the range.
+func (r VersionRange) contains(version int64) bool {
+	return (r.Min == 0 || version >= r.Min) && (r.Max == 0 || version <= r.Max)
+}
+
+// versionRange returns the range of dir, DirVersions before MinVersion and MaxVersion.
+func (m Migrate) versionRange(dir string) VersionRange {
+	if r, ok := m.DirVersions[dir]; ok {
+		return r
+	}
+
+	// Keys written like "/schema" or with backslashes on Windows.
+	for d, r := range m.DirVersions {
+		if cleanDir(d) == dir {
+			return r
+		}
+	}
+
+	return VersionRange{Min: m.MinVersion, Max: m.MaxVersion}
+}
+
+// limited reports whether any version limit is set.
+func (m Migrate) limited() bool {
+	return m.MinVersion != 0 || m.MaxVersion != 0 || len(m.DirVersions) > 0
+}
+
+// withVersions drops the files outside of the version range of their directory.
+func (m Migrate) withVersions(list iter.Seq2[*Muzo, error]) iter.Seq2[*Muzo, error] {
+	if !m.limited() {
+		return list
+	}
+
+	return func(yield func(*Muzo, error) bool) {
+		for info, err := range list {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+
+			r := m.versionRange(info.Dir)
+			files := slices.DeleteFunc(slices.Clone(info.Files), func(f FileInfo) bool {
+				if r.contains(f.Version) {
+					return false
+				}
+
+				m.log().Debug("skipping migration outside of the version range", "directory", info.Dir, "file", f.Path, "version", f.Version)
+
+				return true
+			})
+
+			if !yield(info.withFiles(files), nil) {
+				return
+			}
+		}
+	}
+}
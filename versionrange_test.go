@@ -0,0 +1,72 @@
+package muz
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestMigrateVersionRange(t *testing.T) {
+	files := map[string]string{
+		"migrations/schema/001_users.sql":  "CREATE TABLE users();",
+		"migrations/schema/002_orders.sql": "CREATE TABLE orders();",
+		"migrations/schema/003_items.sql":  "CREATE TABLE items();",
+		"migrations/data/001_seed.sql":     "INSERT INTO users DEFAULT VALUES;",
+		"migrations/data/002_more.sql":     "INSERT INTO users DEFAULT VALUES;",
+	}
+
+	tests := []struct {
+		name string
+		m    Migrate
+		want []string
+	}{
+		{
+			name: "max",
+			m:    Migrate{MaxVersion: 2},
+			want: []string{"data/001_seed.sql", "data/002_more.sql", "schema/001_users.sql", "schema/002_orders.sql"},
+		},
+		{
+			name: "min",
+			m:    Migrate{MinVersion: 2},
+			want: []string{"data/002_more.sql", "schema/002_orders.sql", "schema/003_items.sql"},
+		},
+		{
+			name: "per directory",
+			m:    Migrate{MaxVersion: 1, DirVersions: map[string]VersionRange{"schema": {Min: 2, Max: 2}}},
+			want: []string{"data/001_seed.sql", "schema/002_orders.sql"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.m.FS = MapFS(files)
+
+			driver := &execDriver{}
+			if _, err := tt.m.Migrate(context.Background(), driver); err != nil {
+				t.Fatalf("Migrate() error = %v", err)
+			}
+
+			if !slices.Equal(driver.calls, tt.want) {
+				t.Errorf("applied = %v, want %v", driver.calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrateStatusVersionRange(t *testing.T) {
+	m := Migrate{
+		FS:         MapFS(map[string]string{"migrations/001_users.sql": "CREATE TABLE users();", "migrations/002_orders.sql": "CREATE TABLE orders();"}),
+		MaxVersion: 1,
+	}
+
+	driver := &recordDriver{records: []Record{{Version: 1, Directory: ".", FileName: "001_users.sql"}, {Version: 2, Directory: ".", FileName: "002_orders.sql"}}}
+
+	status, err := m.Status(context.Background(), driver)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+
+	if len(status.Files) != 1 || status.Files[0].State != StateApplied {
+		t.Errorf("Status() files = %+v, want only version 1 applied", status.Files)
+	}
+}
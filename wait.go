@@ -0,0 +1,44 @@
+package muz
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Waiter is implemented by drivers that can wait for their database to be reachable, for runs racing
+// the startup of the database like init containers. The drivers with a WaitTimeout field call it
+// on Lock and Start.
+type Waiter interface {
+	// WaitForDB pings the database with backoff until it answers, failing after timeout.
+	WaitForDB(ctx context.Context, timeout time.Duration) error
+}
+
+// waitBackoff is the wait between two pings of waitForDB.
+var waitBackoff = ExponentialBackoff(100*time.Millisecond, 5*time.Second)
+
+// waitForDB calls ping until it succeeds, for up to timeout.
+func waitForDB(ctx context.Context, timeout time.Duration, logger Logger, ping func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for attempt := 2; ; attempt++ {
+		err := ping(ctx)
+		if err == nil {
+			return nil
+		}
+
+		if logger != nil {
+			logger.Warn("waiting for the database", "error", err)
+		}
+
+		timer := time.NewTimer(waitBackoff(attempt))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("database not reachable after %s: %w", timeout, err)
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package muz
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitForDB(t *testing.T) {
+	refused := errors.New("connection refused")
+
+	t.Run("reachable later", func(t *testing.T) {
+		calls := 0
+		err := waitForDB(context.Background(), time.Minute, nil, func(context.Context) error {
+			calls++
+			if calls < 3 {
+				return refused
+			}
+
+			return nil
+		})
+		if err != nil || calls != 3 {
+			t.Errorf("waitForDB() error = %v after %d pings, want nil after 3", err, calls)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		err := waitForDB(context.Background(), 50*time.Millisecond, nil, func(context.Context) error { return refused })
+		if !errors.Is(err, refused) {
+			t.Errorf("waitForDB() error = %v, want the last ping error", err)
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package muz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// WebhookFormat is the payload sent by Webhook.
+type WebhookFormat string
+
+const (
+	// WebhookJSON posts a WebhookPayload, the default.
+	WebhookJSON WebhookFormat = "json"
+	// WebhookSlack posts a Slack incoming webhook message.
+	WebhookSlack WebhookFormat = "slack"
+	// WebhookTeams posts a Microsoft Teams incoming webhook message card.
+	WebhookTeams WebhookFormat = "teams"
+)
+
+// Webhook notifies an HTTP endpoint when a run finishes, like a Slack channel of the on-call engineers.
+//
+//	webhook := muz.Webhook{URL: os.Getenv("SLACK_WEBHOOK"), Format: muz.WebhookSlack, Name: "production"}
+//	m.Hooks.OnEvent = webhook.OnEvent
+type Webhook struct {
+	// URL the payload is posted to.
+	URL string
+	// Format of the payload.
+	//  - Default: WebhookJSON
+	Format WebhookFormat
+	// Name of the migrated database in the message, like the environment.
+	//  - Default: "the database"
+	Name string
+	// OnlyFailures skips the notification of successful runs.
+	OnlyFailures bool
+	// Header is added to the request, like an Authorization header.
+	Header http.Header
+	// Client used for the request.
+	//  - Default: http.DefaultClient
+	Client *http.Client
+	// Timeout of the request, the notification of a canceled run is still sent.
+	//  - Default: 10 seconds
+	Timeout time.Duration
+	// Logger if set, used to log failed notifications of OnEvent.
+	Logger Logger
+}
+
+// WebhookPayload is the body of WebhookJSON.
+type WebhookPayload struct {
+	Name    string  `json:"name"`
+	Status  string  `json:"status"`
+	Text    string  `json:"text"`
+	Applied int     `json:"applied"`
+	Error   string  `json:"error,omitempty"`
+	Result  *Result `json:"result"`
+}
+
+// OnEvent notifies the RunFinished events, it is meant for Hooks.OnEvent.
+func (w Webhook) OnEvent(ctx context.Context, event Event) {
+	finished, ok := event.(RunFinished)
+	if !ok {
+		return
+	}
+
+	if err := w.Notify(ctx, finished.Result, finished.Err); err != nil && w.Logger != nil {
+		w.Logger.Error("webhook notification failed", "error", err)
+	}
+}
+
+// Notify posts the summary of a run with result and the error of the run, nil when it succeeded.
+func (w Webhook) Notify(ctx context.Context, result *Result, runErr error) error {
+	if runErr == nil && w.OnlyFailures {
+		return nil
+	}
+
+	body, err := w.payload(result, runErr)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+
+	timeout := w.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	// A failed run is often a canceled one, the notification matters the most then.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+
+	for name, values := range w.Header {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook: unexpected status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	return nil
+}
+
+// payload returns the body of the request in the format of w.
+func (w Webhook) payload(result *Result, runErr error) ([]byte, error) {
+	if result == nil {
+		result = &Result{}
+	}
+
+	name := w.Name
+	if name == "" {
+		name = "the database"
+	}
+
+	status, color := "succeeded", "2EB67D"
+	text := fmt.Sprintf("muz: %d migrations applied on %s in %s", result.Applied(), name, result.Duration.Round(time.Millisecond))
+	if runErr != nil {
+		status, color = "failed", "E01E5A"
+		text = fmt.Sprintf("muz: migration on %s failed after %s: %v", name, result.Duration.Round(time.Millisecond), runErr)
+	}
+
+	switch w.Format {
+	case "", WebhookJSON:
+		p := WebhookPayload{Name: name, Status: status, Text: text, Applied: result.Applied(), Result: result}
+		if runErr != nil {
+			p.Error = runErr.Error()
+		}
+
+		return json.Marshal(p)
+	case WebhookSlack:
+		return json.Marshal(map[string]any{
+			"text": text,
+			"attachments": []map[string]any{{
+				"color": "#" + color,
+				"text":  failedFile(result),
+			}},
+		})
+	case WebhookTeams:
+		return json.Marshal(map[string]any{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    "muz migration " + status,
+			"themeColor": color,
+			"title":      "muz migration " + status + " on " + name,
+			"text":       text + "\n\n" + failedFile(result),
+		})
+	default:
+		return nil, fmt.Errorf("unknown format %q", w.Format)
+	}
+}
+
+// failedFile describes the failed file of result, or the applied count when none failed.
+func failedFile(result *Result) string {
+	for _, f := range result.Files {
+		if f.Outcome == OutcomeFailed {
+			return fmt.Sprintf("%s/%s (version %d): %s", f.Dir, f.File, f.Version, f.Error)
+		}
+	}
+
+	return fmt.Sprintf("%d applied, %d directories", result.Applied(), len(result.Dirs))
+}
@@ -0,0 +1,83 @@
+package muz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhook(t *testing.T) {
+	var bodies []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Authorization = %q, want the configured header", got)
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	result := &Result{Files: []FileResult{
+		{Dir: "app", File: "001_users.sql", Version: 1, Outcome: OutcomeApplied},
+		{Dir: "app", File: "002_posts.sql", Version: 2, Outcome: OutcomeFailed, Error: "syntax error"},
+	}}
+
+	tests := []struct {
+		name   string
+		format WebhookFormat
+		want   []string
+	}{
+		{name: "json", want: []string{`"status":"failed"`, `"name":"production"`, `"applied":1`, `"error":"boom"`}},
+		{name: "slack", format: WebhookSlack, want: []string{`"text":"muz: migration on production failed after 0s: boom"`, `app/002_posts.sql (version 2): syntax error`}},
+		{name: "teams", format: WebhookTeams, want: []string{`"@type":"MessageCard"`, `"title":"muz migration failed on production"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bodies = nil
+
+			w := Webhook{URL: server.URL, Format: tt.format, Name: "production", Header: http.Header{"Authorization": {"Bearer token"}}}
+			w.OnEvent(context.Background(), RunFinished{Result: result, Err: errors.New("boom")})
+
+			if len(bodies) != 1 {
+				t.Fatalf("got %d requests, want 1", len(bodies))
+			}
+
+			if !json.Valid([]byte(bodies[0])) {
+				t.Fatalf("body is not JSON: %s", bodies[0])
+			}
+
+			for _, w := range tt.want {
+				if !strings.Contains(bodies[0], w) {
+					t.Errorf("body missing %s, got %s", w, bodies[0])
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookOnlyFailures(t *testing.T) {
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	w := Webhook{URL: server.URL, OnlyFailures: true}
+	if err := w.Notify(context.Background(), &Result{}, nil); err != nil || requests != 0 {
+		t.Fatalf("Notify() of a successful run = %v with %d requests, want nothing sent", err, requests)
+	}
+
+	if err := w.Notify(context.Background(), &Result{}, errors.New("boom")); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("Notify() error = %v, want the unexpected status", err)
+	}
+}